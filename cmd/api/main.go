@@ -15,16 +15,25 @@ import (
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/redis/go-redis/v9"
 
+	"github.com/flicky/go-ecommerce-api/internal/allocation"
+	"github.com/flicky/go-ecommerce-api/internal/cache"
+	"github.com/flicky/go-ecommerce-api/internal/carttoken"
 	"github.com/flicky/go-ecommerce-api/internal/config"
+	"github.com/flicky/go-ecommerce-api/internal/currency"
 	"github.com/flicky/go-ecommerce-api/internal/handler"
+	"github.com/flicky/go-ecommerce-api/internal/invoice"
 	"github.com/flicky/go-ecommerce-api/internal/middleware"
 	"github.com/flicky/go-ecommerce-api/internal/repository"
 	"github.com/flicky/go-ecommerce-api/internal/service"
+	"github.com/flicky/go-ecommerce-api/internal/shipping"
+	"github.com/flicky/go-ecommerce-api/internal/storage"
+	"github.com/flicky/go-ecommerce-api/internal/tax"
 	"github.com/flicky/go-ecommerce-api/internal/worker"
 )
 
 func main() {
 	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	slog.SetDefault(log)
 
 	cfg, err := config.Load()
 	if err != nil {
@@ -77,31 +86,123 @@ func main() {
 		log.Error("setup queues", "error", err)
 		os.Exit(1)
 	}
+	if err := worker.SetupImportQueue(amqpCh); err != nil {
+		log.Error("setup import queue", "error", err)
+		os.Exit(1)
+	}
+	if err := worker.SetupStockAlertQueue(amqpCh); err != nil {
+		log.Error("setup stock alert queue", "error", err)
+		os.Exit(1)
+	}
+
+	// Storage
+	var store storage.Storage
+	switch cfg.Storage.Backend {
+	case "s3":
+		store = storage.NewS3Storage(storage.S3Config{
+			Endpoint: cfg.Storage.S3Endpoint, Region: cfg.Storage.S3Region, Bucket: cfg.Storage.S3Bucket,
+			AccessKey: cfg.Storage.S3AccessKey, SecretKey: cfg.Storage.S3SecretKey, PublicURL: cfg.Storage.PublicURL,
+		}, nil)
+	default:
+		store, err = storage.NewLocalStorage(cfg.Storage.LocalDir, cfg.Storage.PublicURL)
+		if err != nil {
+			log.Error("init local storage", "error", err)
+			os.Exit(1)
+		}
+	}
 
 	// Repos
 	userRepo := repository.NewUserRepository(db)
 	productRepo := repository.NewProductRepository(db)
 	cartRepo := repository.NewCartRepository(db)
-	orderRepo := repository.NewOrderRepository(db)
+	orderRepo := repository.NewOrderRepository(db, allocation.SingleSourceFirst{})
+	mediaRepo := repository.NewProductMediaRepository(db)
+	importJobRepo := repository.NewImportJobRepository(db)
+	inventoryRepo := repository.NewInventoryRepository(db)
+	warehouseRepo := repository.NewWarehouseRepository(db)
+	stockAlertRepo := repository.NewStockAlertRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+	promotionRepo := repository.NewPromotionRepository(db)
+	wishlistRepo := repository.NewWishlistRepository(db)
+	addressRepo := repository.NewAddressRepository(db)
+	shippingRepo := repository.NewShippingRepository(db)
+	taxRepo := repository.NewTaxRepository(db)
+	currencyRepo := repository.NewCurrencyRepository(db)
+	checkoutRepo := repository.NewCheckoutRepository(db)
+	orderAdminRepo := repository.NewOrderAdminRepository(db)
+	invoiceRepo := repository.NewInvoiceRepository(db)
+
+	productCache := cache.New(rdb, cfg.Cache.ProductTTL, cfg.Cache.ListTTL)
 
 	// Services
+	prices := currency.NewConverter(cfg.Currency.Base, currencyRepo)
 	authSvc := service.NewAuthService(userRepo, cfg.JWT.Secret, cfg.JWT.Expiration)
-	productSvc := service.NewProductService(productRepo, rdb)
-	cartSvc := service.NewCartService(cartRepo, productRepo)
-	orderSvc := service.NewOrderService(orderRepo, cartRepo, productRepo, amqpCh)
+	productSvc := service.NewProductService(productRepo, mediaRepo, productCache, prices)
+	mediaSvc := service.NewMediaService(mediaRepo, productRepo, store, productCache, cfg.Storage.MaxUploadSize)
+	stockAlertSvc := service.NewStockAlertService(stockAlertRepo, notificationRepo, productRepo, amqpCh)
+	notificationSvc := service.NewNotificationService(notificationRepo)
+	importSvc := service.NewImportService(importJobRepo, productRepo, inventoryRepo, productCache, stockAlertSvc, amqpCh)
+	inventorySvc := service.NewInventoryService(inventoryRepo, productRepo, orderRepo, productCache, stockAlertSvc)
+	warehouseSvc := service.NewWarehouseService(warehouseRepo, productRepo)
+	promotionSvc := service.NewPromotionService(promotionRepo)
+	// External carriers can be added to the quoter next to the rate table.
+	shippingQuoter := shipping.NewQuoter(shipping.NewTable(shippingRepo))
+	taxCalc := tax.NewTable(taxRepo, cfg.Tax.PricesIncludeTax)
+	cartSvc := service.NewCartService(cartRepo, productRepo, promotionRepo, shippingQuoter, taxCalc, prices, cfg.Cart.GuestTTL, cfg.Cart.EstimateCountry)
+	orderSvc := service.NewOrderService(orderRepo, cartRepo, productRepo, promotionRepo, addressRepo, shippingQuoter, taxCalc, prices, amqpCh)
+	checkoutSvc := service.NewCheckoutService(checkoutRepo, orderSvc, cfg.Checkout.TTL)
+	adminOrderSvc := service.NewAdminOrderService(orderAdminRepo, orderSvc, productCache, stockAlertSvc)
+	invoiceSvc := service.NewInvoiceService(invoiceRepo, orderAdminRepo, orderSvc, invoice.Party{
+		Name: cfg.Invoice.SellerName, Lines: cfg.Invoice.SellerAddress, TaxID: cfg.Invoice.SellerTaxID,
+	})
+	wishlistSvc := service.NewWishlistService(wishlistRepo, cartRepo, productRepo)
+	addressSvc := service.NewAddressService(addressRepo)
+	shippingSvc := service.NewShippingService(shippingRepo)
+	taxSvc := service.NewTaxService(taxRepo)
+	currencySvc := service.NewCurrencyService(currencyRepo, prices.Base())
 
 	// Worker
-	orderWorker := worker.NewOrderWorker(amqpCh, orderRepo, rdb, log)
+	orderWorker := worker.NewOrderWorker(amqpCh, orderRepo, rdb, productCache, stockAlertSvc, log)
 	if err := orderWorker.Start(ctx); err != nil {
 		log.Error("start order worker", "error", err)
 		os.Exit(1)
 	}
+	importWorker := worker.NewImportWorker(amqpCh, importSvc, log)
+	if err := importWorker.Start(ctx); err != nil {
+		log.Error("start import worker", "error", err)
+		os.Exit(1)
+	}
+	stockAlertWorker := worker.NewStockAlertWorker(amqpCh, stockAlertSvc, log)
+	if err := stockAlertWorker.Start(ctx); err != nil {
+		log.Error("start stock alert worker", "error", err)
+		os.Exit(1)
+	}
+	cartSweepWorker := worker.NewCartSweepWorker(cartSvc, cfg.Cart.SweepInterval, log)
+	cartSweepWorker.Start(ctx)
+	orderPublishWorker := worker.NewOrderPublishWorker(orderSvc, cfg.Order.PublishInterval, log)
+	orderPublishWorker.Start(ctx)
 
 	// Handlers
-	authH := handler.NewAuthHandler(authSvc)
+	cartTokens := handler.NewCartTokens(carttoken.New(cfg.Cart.TokenSecret), cfg.Cart.GuestTTL)
+	authH := handler.NewAuthHandler(authSvc, cartSvc, cartTokens)
 	productH := handler.NewProductHandler(productSvc)
-	cartH := handler.NewCartHandler(cartSvc)
+	mediaH := handler.NewMediaHandler(mediaSvc)
+	importH := handler.NewImportHandler(importSvc)
+	inventoryH := handler.NewInventoryHandler(inventorySvc)
+	warehouseH := handler.NewWarehouseHandler(warehouseSvc)
+	stockAlertH := handler.NewStockAlertHandler(stockAlertSvc)
+	notificationH := handler.NewNotificationHandler(notificationSvc)
+	promotionH := handler.NewPromotionHandler(promotionSvc)
+	wishlistH := handler.NewWishlistHandler(wishlistSvc)
+	addressH := handler.NewAddressHandler(addressSvc)
+	shippingH := handler.NewShippingHandler(shippingSvc)
+	taxH := handler.NewTaxHandler(taxSvc)
+	currencyH := handler.NewCurrencyHandler(currencySvc)
+	cartH := handler.NewCartHandler(cartSvc, cartTokens)
 	orderH := handler.NewOrderHandler(orderSvc)
+	checkoutH := handler.NewCheckoutHandler(checkoutSvc)
+	adminOrderH := handler.NewAdminOrderHandler(adminOrderSvc)
+	invoiceH := handler.NewInvoiceHandler(invoiceSvc)
 
 	// Router
 	r := gin.Default()
@@ -120,26 +221,117 @@ func main() {
 		c.JSON(200, gin.H{"status": "ready"})
 	})
 
+	if cfg.Storage.Backend != "s3" {
+		r.Static("/media", cfg.Storage.LocalDir)
+	}
+
 	v1 := r.Group("/api/v1")
 	v1.POST("/auth/register", authH.Register)
 	v1.POST("/auth/login", authH.Login)
 
-	v1.GET("/products", productH.List)
-	v1.GET("/products/:id", productH.GetByID)
+	v1.GET("/products", middleware.CacheControl("public, max-age=30, stale-while-revalidate=30"), productH.List)
+	v1.GET("/products/:id", middleware.CacheControl("public, max-age=60, stale-while-revalidate=60"), productH.GetByID)
+	// Shared wishlists can be unshared at any time, so they are not cached.
+	v1.GET("/shared/wishlists/:token", middleware.CacheControl("no-store"), wishlistH.GetShared)
 
-	admin := v1.Group("", middleware.AuthMiddleware(cfg.JWT.Secret), middleware.AdminOnly())
+	admin := v1.Group("", middleware.AuthMiddleware(cfg.JWT.Secret), middleware.AdminOnly(), middleware.CacheControl("no-store"))
 	admin.POST("/products", productH.Create)
 	admin.PUT("/products/:id", productH.Update)
+	admin.PATCH("/products/:id", productH.Patch)
 	admin.DELETE("/products/:id", productH.Delete)
+	admin.POST("/products/:id/media", mediaH.Upload)
+	admin.PATCH("/products/:id/media/:mediaId", mediaH.Update)
+	admin.DELETE("/products/:id/media/:mediaId", mediaH.Delete)
+	admin.GET("/admin/products", productH.AdminList)
+	admin.GET("/admin/products/:id", productH.AdminGetByID)
+	admin.POST("/admin/products/:id/restore", productH.Restore)
+	admin.GET("/admin/products/:id/history", productH.History)
+	admin.GET("/admin/products/:id/movements", inventoryH.Movements)
+	admin.GET("/admin/products/:id/stock", warehouseH.ProductStock)
+	admin.PUT("/admin/products/:id/reorder-threshold", stockAlertH.SetThreshold)
+	admin.PUT("/admin/products/:id/order-limit", productH.SetOrderLimit)
+	admin.GET("/admin/products/:id/prices", currencyH.ListPrices)
+	admin.PUT("/admin/products/:id/prices/:code", currencyH.SetPrice)
+	admin.DELETE("/admin/products/:id/prices/:code", currencyH.DeletePrice)
+	admin.POST("/admin/products/import", importH.Import)
+	admin.GET("/admin/products/import/:id", importH.GetJob)
+	admin.GET("/admin/products/export", importH.Export)
+	admin.POST("/admin/inventory/adjustments", inventoryH.Adjust)
+	admin.POST("/admin/inventory/transfers", inventoryH.Transfer)
+	admin.GET("/admin/inventory/reconciliation", inventoryH.Discrepancies)
+	admin.POST("/admin/inventory/reconciliation", inventoryH.Reconcile)
+	admin.GET("/admin/warehouses", warehouseH.List)
+	admin.POST("/admin/warehouses", warehouseH.Create)
+	admin.GET("/admin/promotions", promotionH.List)
+	admin.POST("/admin/promotions", promotionH.Create)
+	admin.PUT("/admin/promotions/:id/active", promotionH.SetActive)
+	admin.GET("/admin/shipping/zones", shippingH.ListZones)
+	admin.POST("/admin/shipping/zones", shippingH.CreateZone)
+	admin.DELETE("/admin/shipping/zones/:id", shippingH.DeleteZone)
+	admin.GET("/admin/shipping/rates", shippingH.ListRates)
+	admin.POST("/admin/shipping/rates", shippingH.CreateRate)
+	admin.DELETE("/admin/shipping/rates/:id", shippingH.DeleteRate)
+	admin.GET("/admin/tax/rates", taxH.ListRates)
+	admin.POST("/admin/tax/rates", taxH.CreateRate)
+	admin.DELETE("/admin/tax/rates/:id", taxH.DeleteRate)
+	admin.GET("/admin/currencies", currencyH.ListRates)
+	admin.PUT("/admin/currencies/:code", currencyH.SetRate)
+	admin.DELETE("/admin/currencies/:code", currencyH.DeleteRate)
+	admin.GET("/admin/orders", adminOrderH.List)
+	admin.GET("/admin/orders/:id", adminOrderH.Get)
+	admin.POST("/admin/orders/:id/status", adminOrderH.ChangeStatus)
+	admin.POST("/admin/orders/:id/cancel", adminOrderH.Cancel)
+	admin.POST("/admin/orders/:id/refunds", adminOrderH.Refund)
+	admin.POST("/admin/orders/:id/resend-confirmation", adminOrderH.ResendConfirmation)
+	admin.GET("/admin/invoices", invoiceH.List)
+	admin.GET("/admin/invoices/:id/pdf", invoiceH.PDF)
+	admin.POST("/admin/invoices/:id/regenerate", invoiceH.Regenerate)
+
+	// Carts work for guests too; a bearer token, when sent, selects the user's cart.
+	cart := v1.Group("/cart", middleware.OptionalAuth(cfg.JWT.Secret), middleware.CacheControl("private, no-store"))
+	cart.GET("", cartH.GetCart)
+	cart.POST("/items", cartH.AddItem)
+	cart.PUT("/items/:id", cartH.UpdateItem)
+	cart.DELETE("/items/:id", cartH.DeleteItem)
+	cart.POST("/items/:id/save-for-later", cartH.SaveForLater)
+	cart.POST("/items/:id/move-to-cart", cartH.MoveToCart)
+	cart.POST("/coupon", cartH.ApplyCoupon)
+	cart.DELETE("/coupon", cartH.RemoveCoupon)
+	cart.GET("/shipping-quotes", cartH.ShippingQuotes)
 
-	auth := v1.Group("", middleware.AuthMiddleware(cfg.JWT.Secret))
-	auth.GET("/cart", cartH.GetCart)
-	auth.POST("/cart/items", cartH.AddItem)
-	auth.PUT("/cart/items/:id", cartH.UpdateItem)
-	auth.DELETE("/cart/items/:id", cartH.DeleteItem)
+	auth := v1.Group("", middleware.AuthMiddleware(cfg.JWT.Secret), middleware.CacheControl("private, no-store"))
 	auth.POST("/orders", orderH.CreateOrder)
 	auth.GET("/orders", orderH.ListOrders)
 	auth.GET("/orders/:id", orderH.GetOrder)
+	auth.GET("/orders/:id/invoice", invoiceH.OrderInvoice)
+	auth.POST("/checkout", checkoutH.Start)
+	auth.GET("/checkout/:id", checkoutH.Get)
+	auth.DELETE("/checkout/:id", checkoutH.Cancel)
+	auth.PUT("/checkout/:id/address", checkoutH.SetAddresses)
+	auth.PUT("/checkout/:id/shipping", checkoutH.SetShippingMethod)
+	auth.POST("/checkout/:id/coupon", checkoutH.ApplyCoupon)
+	auth.DELETE("/checkout/:id/coupon", checkoutH.RemoveCoupon)
+	auth.PUT("/checkout/:id/payment", checkoutH.SetPaymentIntent)
+	auth.POST("/checkout/:id/complete", checkoutH.Complete)
+	auth.POST("/products/:id/stock-subscription", stockAlertH.Subscribe)
+	auth.DELETE("/products/:id/stock-subscription", stockAlertH.Unsubscribe)
+	auth.GET("/addresses", addressH.List)
+	auth.POST("/addresses", addressH.Create)
+	auth.GET("/addresses/:id", addressH.Get)
+	auth.PUT("/addresses/:id", addressH.Update)
+	auth.DELETE("/addresses/:id", addressH.Delete)
+	auth.POST("/cart/items/:id/move-to-wishlist", wishlistH.MoveFromCart)
+	auth.GET("/wishlists", wishlistH.List)
+	auth.POST("/wishlists", wishlistH.Create)
+	auth.GET("/wishlists/:id", wishlistH.Get)
+	auth.DELETE("/wishlists/:id", wishlistH.Delete)
+	auth.POST("/wishlists/:id/items", wishlistH.AddItem)
+	auth.DELETE("/wishlists/:id/items/:itemId", wishlistH.RemoveItem)
+	auth.POST("/wishlists/:id/items/:itemId/move-to-cart", wishlistH.MoveToCart)
+	auth.PUT("/wishlists/:id/share", wishlistH.Share)
+	auth.DELETE("/wishlists/:id/share", wishlistH.Unshare)
+	auth.GET("/notifications", notificationH.List)
+	auth.POST("/notifications/:id/read", notificationH.MarkRead)
 
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
@@ -162,6 +354,10 @@ func main() {
 
 	log.Info("shutting down...")
 	orderWorker.Stop()
+	importWorker.Stop()
+	stockAlertWorker.Stop()
+	cartSweepWorker.Stop()
+	orderPublishWorker.Stop()
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer shutdownCancel()
@@ -0,0 +1,101 @@
+// Package allocation decides which warehouses an order is shipped from.
+package allocation
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// Line is one order line to be sourced.
+type Line struct {
+	ProductID uuid.UUID
+	Quantity  int
+}
+
+// Source is a warehouse and the stock it has available per product.
+// Sources are passed in priority order, most preferred first.
+type Source struct {
+	WarehouseID uuid.UUID
+	Stock       map[uuid.UUID]int
+}
+
+// Allocation assigns Quantity units of lines[Line] to a warehouse. A line
+// may be split over several allocations.
+type Allocation struct {
+	Line        int
+	WarehouseID uuid.UUID
+	Quantity    int
+}
+
+type Strategy interface {
+	Allocate(lines []Line, sources []Source) ([]Allocation, error)
+}
+
+// SingleSourceFirst ships the whole order from one warehouse when any can
+// cover it, preferring higher-priority warehouses. Otherwise it splits the
+// order greedily: each round takes the warehouse that covers the most of
+// what is still unallocated, which keeps the number of shipments low.
+type SingleSourceFirst struct{}
+
+func (SingleSourceFirst) Allocate(lines []Line, sources []Source) ([]Allocation, error) {
+	remaining := make([]int, len(lines))
+	left := 0
+	for i, l := range lines {
+		remaining[i] = l.Quantity
+		left += l.Quantity
+	}
+	available := make([]map[uuid.UUID]int, len(sources))
+	for i, s := range sources {
+		available[i] = make(map[uuid.UUID]int, len(s.Stock))
+		for id, qty := range s.Stock {
+			available[i][id] = qty
+		}
+	}
+
+	used := make([]bool, len(sources))
+	var out []Allocation
+	for left > 0 {
+		best, bestUnits := -1, 0
+		for i := range sources {
+			if used[i] {
+				continue
+			}
+			if units := coverable(lines, remaining, available[i]); units > bestUnits {
+				best, bestUnits = i, units
+			}
+		}
+		if best < 0 {
+			return nil, ErrInsufficientStock
+		}
+		used[best] = true
+		for i, l := range lines {
+			take := min(remaining[i], available[best][l.ProductID])
+			if take == 0 {
+				continue
+			}
+			out = append(out, Allocation{Line: i, WarehouseID: sources[best].WarehouseID, Quantity: take})
+			remaining[i] -= take
+			available[best][l.ProductID] -= take
+			left -= take
+		}
+	}
+	return out, nil
+}
+
+// coverable counts how many of the remaining units stock could supply.
+// Lines for the same product draw from the same pool.
+func coverable(lines []Line, remaining []int, stock map[uuid.UUID]int) int {
+	drawn := make(map[uuid.UUID]int)
+	units := 0
+	for i, l := range lines {
+		take := min(remaining[i], stock[l.ProductID]-drawn[l.ProductID])
+		if take > 0 {
+			drawn[l.ProductID] += take
+			units += take
+		}
+	}
+	return units
+}
@@ -0,0 +1,81 @@
+package allocation
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSingleSourceFirst(t *testing.T) {
+	mug, lamp := uuid.New(), uuid.New()
+	north, south, east := uuid.New(), uuid.New(), uuid.New()
+
+	tests := []struct {
+		name    string
+		lines   []Line
+		sources []Source
+		want    []Allocation
+		wantErr error
+	}{
+		{
+			name:  "highest priority warehouse that covers everything",
+			lines: []Line{{mug, 2}, {lamp, 1}},
+			sources: []Source{
+				{north, map[uuid.UUID]int{mug: 5}},
+				{south, map[uuid.UUID]int{mug: 2, lamp: 1}},
+				{east, map[uuid.UUID]int{mug: 9, lamp: 9}},
+			},
+			want: []Allocation{{0, south, 2}, {1, south, 1}},
+		},
+		{
+			name:  "split across warehouses when none covers the order",
+			lines: []Line{{mug, 2}, {lamp, 3}},
+			sources: []Source{
+				{north, map[uuid.UUID]int{mug: 2}},
+				{south, map[uuid.UUID]int{lamp: 3}},
+			},
+			want: []Allocation{{1, south, 3}, {0, north, 2}},
+		},
+		{
+			name:  "single line split over two warehouses",
+			lines: []Line{{mug, 5}},
+			sources: []Source{
+				{north, map[uuid.UUID]int{mug: 3}},
+				{south, map[uuid.UUID]int{mug: 4}},
+			},
+			want: []Allocation{{0, south, 4}, {0, north, 1}},
+		},
+		{
+			name:    "not enough stock anywhere",
+			lines:   []Line{{mug, 5}},
+			sources: []Source{{north, map[uuid.UUID]int{mug: 2}}, {south, map[uuid.UUID]int{mug: 2}}},
+			wantErr: ErrInsufficientStock,
+		},
+		{
+			name:    "no warehouses",
+			lines:   []Line{{mug, 1}},
+			wantErr: ErrInsufficientStock,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SingleSourceFirst{}.Allocate(tt.lines, tt.sources)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSingleSourceFirst_DoesNotMutateSources(t *testing.T) {
+	mug, north := uuid.New(), uuid.New()
+	sources := []Source{{north, map[uuid.UUID]int{mug: 3}}}
+	_, err := SingleSourceFirst{}.Allocate([]Line{{mug, 2}}, sources)
+	require.NoError(t, err)
+	assert.Equal(t, 3, sources[0].Stock[mug])
+}
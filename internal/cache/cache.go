@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+const productListVersionKey = "products:list:version"
+
+// Cache is a read-through JSON cache on top of Redis. Concurrent misses for
+// the same key are collapsed into a single load, so an expiring hot key does
+// not stampede the database. A nil *Cache is valid and caches nothing.
+type Cache struct {
+	rdb        *redis.Client
+	group      singleflight.Group
+	productTTL time.Duration
+	listTTL    time.Duration
+}
+
+func New(rdb *redis.Client, productTTL, listTTL time.Duration) *Cache {
+	return &Cache{rdb: rdb, productTTL: productTTL, listTTL: listTTL}
+}
+
+func (c *Cache) ProductTTL() time.Duration {
+	if c == nil {
+		return 0
+	}
+	return c.productTTL
+}
+
+func (c *Cache) ListTTL() time.Duration {
+	if c == nil {
+		return 0
+	}
+	return c.listTTL
+}
+
+// GetOrLoad returns the cached value for key or calls load, caching its
+// result for ttl. Redis failures fall back to load.
+func GetOrLoad[T any](ctx context.Context, c *Cache, key string, ttl time.Duration, load func(context.Context) (T, error)) (T, error) {
+	if c == nil {
+		return load(ctx)
+	}
+	if data, err := c.rdb.Get(ctx, key).Bytes(); err == nil {
+		var v T
+		if json.Unmarshal(data, &v) == nil {
+			return v, nil
+		}
+	}
+
+	v, err, _ := c.group.Do(key, func() (any, error) {
+		v, err := load(ctx)
+		if err != nil {
+			return v, err
+		}
+		if data, err := json.Marshal(v); err == nil {
+			_ = c.rdb.Set(ctx, key, data, ttl).Err()
+		}
+		return v, nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+func ProductKey(id uuid.UUID) string { return "product:" + id.String() }
+
+// ProductListKey namespaces a list/search page under the current list
+// version; bumping the version invalidates every cached page at once.
+func (c *Cache) ProductListKey(ctx context.Context, parts ...string) string {
+	version := "0"
+	if c != nil {
+		if v, err := c.rdb.Get(ctx, productListVersionKey).Result(); err == nil {
+			version = v
+		}
+	}
+	key := "products:list:v" + version
+	for _, p := range parts {
+		key += ":" + p
+	}
+	return key
+}
+
+// InvalidateProducts drops the cached products and every cached list page.
+// It must be called after any write that changes what a product looks like,
+// including stock changes made by the order worker.
+func (c *Cache) InvalidateProducts(ctx context.Context, ids ...uuid.UUID) {
+	if c == nil {
+		return
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = ProductKey(id)
+	}
+	pipe := c.rdb.TxPipeline()
+	if len(keys) > 0 {
+		pipe.Del(ctx, keys...)
+	}
+	pipe.Incr(ctx, productListVersionKey)
+	_, _ = pipe.Exec(ctx)
+}
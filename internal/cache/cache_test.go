@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	Name string `json:"name"`
+}
+
+func newTestCache(t *testing.T) (*Cache, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	return New(rdb, time.Minute, 30*time.Second), mr
+}
+
+func TestGetOrLoad_CachesResult(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+	var loads int
+	load := func(context.Context) (*item, error) {
+		loads++
+		return &item{Name: "mug"}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		v, err := GetOrLoad(ctx, c, "k", c.ProductTTL(), load)
+		require.NoError(t, err)
+		assert.Equal(t, "mug", v.Name)
+	}
+	assert.Equal(t, 1, loads)
+	assert.Equal(t, time.Minute, mr.TTL("k"))
+}
+
+func TestGetOrLoad_ErrorsAreNotCached(t *testing.T) {
+	c, mr := newTestCache(t)
+	errBoom := errors.New("boom")
+	_, err := GetOrLoad(context.Background(), c, "k", time.Minute, func(context.Context) (*item, error) {
+		return nil, errBoom
+	})
+	assert.ErrorIs(t, err, errBoom)
+	assert.False(t, mr.Exists("k"))
+}
+
+func TestGetOrLoad_CollapsesConcurrentMisses(t *testing.T) {
+	c, _ := newTestCache(t)
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(context.Context) (*item, error) {
+		loads.Add(1)
+		<-release
+		return &item{Name: "mug"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = GetOrLoad(context.Background(), c, "hot", time.Minute, load)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), loads.Load())
+}
+
+func TestInvalidateProducts(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+	id := uuid.New()
+	require.NoError(t, mr.Set(ProductKey(id), "{}"))
+
+	before := c.ProductListKey(ctx, "active", "20")
+	c.InvalidateProducts(ctx, id)
+	after := c.ProductListKey(ctx, "active", "20")
+
+	assert.False(t, mr.Exists(ProductKey(id)))
+	assert.NotEqual(t, before, after)
+}
+
+func TestNilCache(t *testing.T) {
+	var c *Cache
+	v, err := GetOrLoad(context.Background(), c, "k", c.ProductTTL(), func(context.Context) (int, error) { return 7, nil })
+	require.NoError(t, err)
+	assert.Equal(t, 7, v)
+	c.InvalidateProducts(context.Background(), uuid.New())
+}
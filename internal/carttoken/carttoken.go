@@ -0,0 +1,46 @@
+// Package carttoken signs and verifies the tokens that identify guest carts.
+package carttoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidToken = errors.New("invalid cart token")
+
+// Signer issues tokens of the form "<cart id>.<HMAC-SHA256 of the id>", so a
+// guest can only present cart IDs the server handed out.
+type Signer struct {
+	secret []byte
+}
+
+func New(secret string) *Signer {
+	return &Signer{secret: []byte(secret)}
+}
+
+func (s *Signer) Sign(cartID uuid.UUID) string {
+	return cartID.String() + "." + s.mac(cartID)
+}
+
+func (s *Signer) Verify(token string) (uuid.UUID, error) {
+	id, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.Nil, ErrInvalidToken
+	}
+	cartID, err := uuid.Parse(id)
+	if err != nil || !hmac.Equal([]byte(sig), []byte(s.mac(cartID))) {
+		return uuid.Nil, ErrInvalidToken
+	}
+	return cartID, nil
+}
+
+func (s *Signer) mac(cartID uuid.UUID) string {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write(cartID[:])
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,40 @@
+package carttoken
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner_RoundTrip(t *testing.T) {
+	s := New("secret")
+	id := uuid.New()
+
+	got, err := s.Verify(s.Sign(id))
+	require.NoError(t, err)
+	assert.Equal(t, id, got)
+}
+
+func TestSigner_Rejects(t *testing.T) {
+	s := New("secret")
+	id := uuid.New()
+	token := s.Sign(id)
+
+	tests := map[string]string{
+		"empty":          "",
+		"no signature":   id.String(),
+		"bad id":         "not-a-uuid." + token[len(id.String())+1:],
+		"other cart":     uuid.New().String() + token[len(id.String()):],
+		"other secret":   New("other").Sign(id),
+		"truncated":      token[:len(token)-1],
+		"trailing parts": token + ".x",
+	}
+	for name, tok := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := s.Verify(tok)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
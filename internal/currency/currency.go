@@ -0,0 +1,158 @@
+// Package currency prices amounts in the currencies the shop sells in: the
+// base currency products are priced in, and others reached through exchange
+// rates or per-product price lists.
+package currency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+// ErrUnsupported means the shop does not sell in a currency.
+var ErrUnsupported = errors.New("unsupported currency")
+
+// minorUnits holds the ISO 4217 currencies whose minor unit is not a
+// hundredth of the major one.
+var minorUnits = map[string]int32{
+	"BHD": 3, "CLP": 0, "IQD": 3, "ISK": 0, "JOD": 3, "JPY": 0, "KRW": 0, "KWD": 3,
+	"LYD": 3, "OMR": 3, "PYG": 0, "TND": 3, "UGX": 0, "VND": 0, "XAF": 0, "XOF": 0,
+}
+
+// Decimals is the number of decimal places amounts in code are kept to.
+func Decimals(code string) int32 {
+	if d, ok := minorUnits[code]; ok {
+		return d
+	}
+	return 2
+}
+
+// Round rounds amount to code's minor unit.
+func Round(amount decimal.Decimal, code string) decimal.Decimal {
+	return amount.Round(Decimals(code))
+}
+
+// Normalize upper-cases code and reports whether it is shaped like an
+// ISO 4217 code.
+func Normalize(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return code, false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return code, false
+		}
+	}
+	return code, true
+}
+
+// Source holds the exchange rates and product price lists.
+type Source interface {
+	// GetRate returns the rate for code, or nil if there is none.
+	GetRate(ctx context.Context, code string) (*model.ExchangeRate, error)
+	// PricesFor returns the listed prices in code of those of productIDs
+	// that have one.
+	PricesFor(ctx context.Context, code string, productIDs []uuid.UUID) (map[uuid.UUID]decimal.Decimal, error)
+}
+
+// Pricing prices amounts in one currency.
+type Pricing struct {
+	Currency string
+	// Rate is how many units of Currency one unit of the base currency buys.
+	Rate decimal.Decimal
+	base bool
+}
+
+// IsBase reports whether p prices in the base currency.
+func (p Pricing) IsBase() bool { return p.base }
+
+// Convert converts an amount in the base currency, rounded to p's currency.
+func (p Pricing) Convert(amount decimal.Decimal) decimal.Decimal {
+	return Round(amount.Mul(p.Rate), p.Currency)
+}
+
+// ToBase converts an amount in p's currency back to the base currency. It is
+// not rounded, as it is only compared against thresholds.
+func (p Pricing) ToBase(amount decimal.Decimal) decimal.Decimal {
+	if p.base {
+		return amount
+	}
+	return amount.Div(p.Rate)
+}
+
+// Round rounds amount to p's currency.
+func (p Pricing) Round(amount decimal.Decimal) decimal.Decimal {
+	return Round(amount, p.Currency)
+}
+
+// Converter resolves currencies against the base currency and a Source.
+type Converter struct {
+	base string
+	src  Source
+}
+
+func NewConverter(base string, src Source) *Converter {
+	return &Converter{base: strings.ToUpper(base), src: src}
+}
+
+// Base is the currency product prices are kept in.
+func (c *Converter) Base() string { return c.base }
+
+// Pricing returns how to price in code. An empty code is the base currency;
+// any other currency needs an exchange rate, else ErrUnsupported.
+func (c *Converter) Pricing(ctx context.Context, code string) (Pricing, error) {
+	if code == "" {
+		return Pricing{Currency: c.base, Rate: decimal.NewFromInt(1), base: true}, nil
+	}
+	code, ok := Normalize(code)
+	if !ok {
+		return Pricing{}, fmt.Errorf("%w: %q", ErrUnsupported, code)
+	}
+	if code == c.base {
+		return Pricing{Currency: c.base, Rate: decimal.NewFromInt(1), base: true}, nil
+	}
+	r, err := c.src.GetRate(ctx, code)
+	if err != nil {
+		return Pricing{}, fmt.Errorf("get exchange rate: %w", err)
+	}
+	if r == nil {
+		return Pricing{}, fmt.Errorf("%w: %s", ErrUnsupported, code)
+	}
+	return Pricing{Currency: code, Rate: r.Rate}, nil
+}
+
+// Prices prices products in p's currency: at the price listed for them in
+// that currency if there is one, else at their base price converted. base
+// maps each product to its base price.
+func (c *Converter) Prices(ctx context.Context, p Pricing, base map[uuid.UUID]decimal.Decimal) (map[uuid.UUID]decimal.Decimal, error) {
+	out := make(map[uuid.UUID]decimal.Decimal, len(base))
+	if p.base {
+		for id, price := range base {
+			out[id] = p.Round(price)
+		}
+		return out, nil
+	}
+	ids := make([]uuid.UUID, 0, len(base))
+	for id := range base {
+		ids = append(ids, id)
+	}
+	listed, err := c.src.PricesFor(ctx, p.Currency, ids)
+	if err != nil {
+		return nil, fmt.Errorf("get price list: %w", err)
+	}
+	for id, price := range base {
+		if l, ok := listed[id]; ok {
+			out[id] = p.Round(l)
+		} else {
+			out[id] = p.Convert(price)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,96 @@
+package currency
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+var d = decimal.RequireFromString
+
+type staticSource struct {
+	rates  map[string]string
+	listed map[string]map[uuid.UUID]decimal.Decimal
+}
+
+func (s staticSource) GetRate(_ context.Context, code string) (*model.ExchangeRate, error) {
+	r, ok := s.rates[code]
+	if !ok {
+		return nil, nil
+	}
+	return &model.ExchangeRate{Currency: code, Rate: d(r)}, nil
+}
+
+func (s staticSource) PricesFor(_ context.Context, code string, ids []uuid.UUID) (map[uuid.UUID]decimal.Decimal, error) {
+	out := make(map[uuid.UUID]decimal.Decimal)
+	for _, id := range ids {
+		if p, ok := s.listed[code][id]; ok {
+			out[id] = p
+		}
+	}
+	return out, nil
+}
+
+func TestRound(t *testing.T) {
+	assert.Equal(t, "10.57", Round(d("10.5678"), "EUR").String())
+	assert.Equal(t, "1235", Round(d("1234.5"), "JPY").String())
+	assert.Equal(t, "1.235", Round(d("1.2345"), "KWD").String())
+}
+
+func TestNormalize(t *testing.T) {
+	code, ok := Normalize(" eur ")
+	assert.True(t, ok)
+	assert.Equal(t, "EUR", code)
+	for _, bad := range []string{"EU", "EURO", "E1R"} {
+		_, ok := Normalize(bad)
+		assert.False(t, ok, bad)
+	}
+}
+
+func TestConverter(t *testing.T) {
+	listed, converted := uuid.New(), uuid.New()
+	c := NewConverter("usd", staticSource{
+		rates:  map[string]string{"EUR": "0.9", "JPY": "151.237"},
+		listed: map[string]map[uuid.UUID]decimal.Decimal{"EUR": {listed: d("8.99")}},
+	})
+	ctx := context.Background()
+	base := map[uuid.UUID]decimal.Decimal{listed: d("10"), converted: d("19.99")}
+
+	t.Run("base currency", func(t *testing.T) {
+		for _, code := range []string{"", "USD", "usd"} {
+			p, err := c.Pricing(ctx, code)
+			require.NoError(t, err)
+			assert.True(t, p.IsBase())
+			assert.Equal(t, "USD", p.Currency)
+		}
+	})
+
+	t.Run("listed prices win over conversion", func(t *testing.T) {
+		p, err := c.Pricing(ctx, "eur")
+		require.NoError(t, err)
+		prices, err := c.Prices(ctx, p, base)
+		require.NoError(t, err)
+		assert.Equal(t, "8.99", prices[listed].StringFixed(2))
+		assert.Equal(t, "17.99", prices[converted].StringFixed(2))
+		assert.Equal(t, "19.99", p.ToBase(d("17.991")).StringFixed(2))
+	})
+
+	t.Run("conversion rounds to the currency's minor unit", func(t *testing.T) {
+		p, err := c.Pricing(ctx, "JPY")
+		require.NoError(t, err)
+		assert.Equal(t, "3023", p.Convert(d("19.99")).String())
+	})
+
+	t.Run("currencies without a rate are unsupported", func(t *testing.T) {
+		for _, code := range []string{"GBP", "pounds"} {
+			_, err := c.Pricing(ctx, code)
+			assert.ErrorIs(t, err, ErrUnsupported)
+		}
+	})
+}
@@ -34,34 +34,264 @@ type UserResponse struct {
 	Role      string    `json:"role"`
 }
 
+// Pagination
+
+type PageInfo struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	Total      *int   `json:"total,omitempty"`
+}
+
 // Product
 
 type CreateProductRequest struct {
+	SKU         string          `json:"sku" binding:"max=64"`
 	Name        string          `json:"name" binding:"required"`
 	Description string          `json:"description"`
+	Category    string          `json:"category" binding:"max=64"`
 	Price       decimal.Decimal `json:"price" binding:"required"`
 	Stock       int             `json:"stock" binding:"required,min=0"`
+	Status      string          `json:"status" binding:"omitempty,oneof=draft active"`
+	WeightGrams int             `json:"weight_grams" binding:"min=0"`
+	TaxClass    string          `json:"tax_class" binding:"max=32"`
 }
 
+// UpdateProductRequest replaces the catalogue fields of a product. Stock is
+// changed separately through the inventory ledger. Version, when sent, must
+// equal the product's current version or the update is rejected.
 type UpdateProductRequest struct {
+	SKU         string          `json:"sku" binding:"max=64"`
 	Name        string          `json:"name" binding:"required"`
 	Description string          `json:"description"`
+	Category    string          `json:"category" binding:"max=64"`
 	Price       decimal.Decimal `json:"price" binding:"required"`
-	Stock       int             `json:"stock" binding:"required,min=0"`
+	Status      string          `json:"status" binding:"omitempty,oneof=draft active"`
+	WeightGrams int             `json:"weight_grams" binding:"min=0"`
+	TaxClass    string          `json:"tax_class" binding:"max=32"`
+	Version     *int            `json:"version" binding:"omitempty,min=1"`
+}
+
+// PatchProductRequest is a JSON Merge Patch (RFC 7396) of the catalogue
+// fields: absent members stay unchanged and only present ones are validated.
+// A null sku, description or category clears it; the other members cannot
+// be null.
+type PatchProductRequest struct {
+	SKU         *string          `json:"sku" binding:"omitempty,max=64"`
+	Name        *string          `json:"name" binding:"omitempty,min=1"`
+	Description *string          `json:"description"`
+	Category    *string          `json:"category" binding:"omitempty,max=64"`
+	Price       *decimal.Decimal `json:"price"`
+	Status      *string          `json:"status" binding:"omitempty,oneof=draft active"`
+	WeightGrams *int             `json:"weight_grams" binding:"omitempty,min=0"`
+	TaxClass    *string          `json:"tax_class" binding:"omitempty,min=1,max=32"`
+	Version     *int             `json:"version" binding:"omitempty,min=1"`
 }
 
 type ProductResponse struct {
-	ID          uuid.UUID       `json:"id"`
-	Name        string          `json:"name"`
-	Description string          `json:"description"`
-	Price       decimal.Decimal `json:"price"`
-	Stock       int             `json:"stock"`
-	CreatedAt   time.Time       `json:"created_at"`
+	ID          uuid.UUID              `json:"id"`
+	SKU         string                 `json:"sku,omitempty"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Category    string                 `json:"category,omitempty"`
+	Price       decimal.Decimal        `json:"price"`
+	Stock       int                    `json:"stock"`
+	Status      string                 `json:"status"`
+	MaxPerOrder *int                   `json:"max_per_order,omitempty"`
+	WeightGrams int                    `json:"weight_grams"`
+	TaxClass    string                 `json:"tax_class"`
+	Currency    string                 `json:"currency"`
+	Version     int                    `json:"version"`
+	Media       []ProductMediaResponse `json:"media"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+	ArchivedAt  *time.Time             `json:"archived_at,omitempty"`
+}
+
+// OrderLimitRequest caps how many units of a product one cart or order may
+// hold; null removes the cap.
+type OrderLimitRequest struct {
+	MaxPerOrder *int `json:"max_per_order" binding:"omitempty,min=1"`
+}
+
+type ProductMediaResponse struct {
+	ID           uuid.UUID `json:"id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	AltText      string    `json:"alt_text"`
+	Position     int       `json:"position"`
+	IsPrimary    bool      `json:"is_primary"`
+}
+
+type UpdateProductMediaRequest struct {
+	AltText   *string `json:"alt_text" binding:"omitempty,max=255"`
+	Position  *int    `json:"position" binding:"omitempty,min=0"`
+	IsPrimary *bool   `json:"is_primary"`
 }
 
 type ProductListResponse struct {
 	Products []ProductResponse `json:"products"`
-	Total    int               `json:"total"`
+	PageInfo
+}
+
+type ProductAuditResponse struct {
+	ID        uuid.UUID           `json:"id"`
+	ActorID   *uuid.UUID          `json:"actor_id"`
+	Action    string              `json:"action"`
+	Changes   []FieldChangeResult `json:"changes"`
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+type FieldChangeResult struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+type ProductAuditListResponse struct {
+	Entries []ProductAuditResponse `json:"entries"`
+	PageInfo
+}
+
+type ImportJobResponse struct {
+	ID         uuid.UUID              `json:"id"`
+	Format     string                 `json:"format"`
+	Status     string                 `json:"status"`
+	TotalRows  int                    `json:"total_rows"`
+	Created    int                    `json:"created"`
+	Updated    int                    `json:"updated"`
+	Failed     int                    `json:"failed"`
+	Errors     []ImportRowErrorResult `json:"errors"`
+	CreatedAt  time.Time              `json:"created_at"`
+	FinishedAt *time.Time             `json:"finished_at,omitempty"`
+}
+
+type ImportRowErrorResult struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// Inventory
+
+// InventoryAdjustmentRequest books a manual stock movement. Quantity is a
+// signed delta; sales are only ever recorded by order processing. Without a
+// warehouse the default one is used.
+type InventoryAdjustmentRequest struct {
+	ProductID   uuid.UUID  `json:"product_id" binding:"required"`
+	Kind        string     `json:"kind" binding:"required,oneof=return restock adjustment correction"`
+	Quantity    int        `json:"quantity" binding:"required"`
+	Reason      string     `json:"reason" binding:"max=500"`
+	WarehouseID *uuid.UUID `json:"warehouse_id"`
+	OrderID     *uuid.UUID `json:"order_id"`
+}
+
+type StockTransferRequest struct {
+	ProductID       uuid.UUID `json:"product_id" binding:"required"`
+	FromWarehouseID uuid.UUID `json:"from_warehouse_id" binding:"required"`
+	ToWarehouseID   uuid.UUID `json:"to_warehouse_id" binding:"required"`
+	Quantity        int       `json:"quantity" binding:"required,min=1"`
+	Reason          string    `json:"reason" binding:"max=500"`
+}
+
+type StockTransferResponse struct {
+	Movements []InventoryMovementResponse `json:"movements"`
+}
+
+type InventoryMovementResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	ProductID   uuid.UUID  `json:"product_id"`
+	Kind        string     `json:"kind"`
+	Quantity    int        `json:"quantity"`
+	StockAfter  int        `json:"stock_after"`
+	Reason      string     `json:"reason,omitempty"`
+	WarehouseID *uuid.UUID `json:"warehouse_id,omitempty"`
+	ActorID     *uuid.UUID `json:"actor_id,omitempty"`
+	OrderID     *uuid.UUID `json:"order_id,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type InventoryMovementListResponse struct {
+	Movements []InventoryMovementResponse `json:"movements"`
+	PageInfo
+}
+
+type StockDiscrepancyResponse struct {
+	ProductID   uuid.UUID `json:"product_id"`
+	Stock       int       `json:"stock"`
+	LedgerStock int       `json:"ledger_stock"`
+}
+
+type ReconciliationResponse struct {
+	Discrepancies []StockDiscrepancyResponse `json:"discrepancies"`
+}
+
+// Stock alerts
+
+// ReorderThresholdRequest sets the stock level at or below which admins get
+// a low-stock alert; null turns alerts off.
+type ReorderThresholdRequest struct {
+	Threshold *int `json:"threshold" binding:"omitempty,min=0"`
+}
+
+type ReorderThresholdResponse struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Threshold *int      `json:"threshold"`
+}
+
+type StockSubscriptionResponse struct {
+	ID        uuid.UUID `json:"id"`
+	ProductID uuid.UUID `json:"product_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type NotificationResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	Kind      string     `json:"kind"`
+	ProductID *uuid.UUID `json:"product_id,omitempty"`
+	OrderID   *uuid.UUID `json:"order_id,omitempty"`
+	Message   string     `json:"message"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+}
+
+type NotificationListResponse struct {
+	Notifications []NotificationResponse `json:"notifications"`
+	PageInfo
+}
+
+// Warehouses
+
+type CreateWarehouseRequest struct {
+	Code     string `json:"code" binding:"required,max=32"`
+	Name     string `json:"name" binding:"required,max=255"`
+	Priority int    `json:"priority" binding:"min=0"`
+}
+
+type WarehouseResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	Priority  int       `json:"priority"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WarehouseListResponse struct {
+	Warehouses []WarehouseResponse `json:"warehouses"`
+}
+
+type WarehouseStockResponse struct {
+	WarehouseID   uuid.UUID `json:"warehouse_id"`
+	WarehouseCode string    `json:"warehouse_code"`
+	Quantity      int       `json:"quantity"`
+}
+
+type ProductStockResponse struct {
+	ProductID  uuid.UUID                `json:"product_id"`
+	Stock      int                      `json:"stock"`
+	Warehouses []WarehouseStockResponse `json:"warehouses"`
 }
 
 // Cart
@@ -75,29 +305,602 @@ type UpdateCartItemRequest struct {
 	Quantity int `json:"quantity" binding:"required,min=1"`
 }
 
+type ApplyCouponRequest struct {
+	Code string `json:"code" binding:"required,max=64"`
+}
+
+// CartResponse prices the cart at current product prices. Lines whose product
+// is no longer available, and items saved for later, are listed but left out
+// of the totals.
 type CartResponse struct {
-	ID    uuid.UUID          `json:"id"`
-	Items []CartItemResponse `json:"items"`
+	ID            uuid.UUID           `json:"id"`
+	Currency      string              `json:"currency"`
+	Items         []CartItemResponse  `json:"items"`
+	SavedForLater []CartItemResponse  `json:"saved_for_later"`
+	Coupon        *CartCouponResponse `json:"coupon,omitempty"`
+	Subtotal      decimal.Decimal     `json:"subtotal"`
+	Discount      decimal.Decimal     `json:"discount"`
+	Tax           decimal.Decimal     `json:"tax"`
+	Shipping      decimal.Decimal     `json:"shipping"`
+	Total         decimal.Decimal     `json:"total"`
+	// PricesIncludeTax says whether Tax is part of the prices or added to
+	// the total.
+	PricesIncludeTax bool `json:"prices_include_tax"`
+	// Estimate says where Tax and Shipping assume the cart ships, and how.
+	// Without it neither is estimated.
+	Estimate *CartEstimate `json:"estimate,omitempty"`
+	// LockedUntil is set while the cart is in an open checkout and cannot
+	// be changed.
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
+
+// CartEstimate is the destination a cart's tax and shipping are estimated
+// for. ShippingMethod is empty if the standard method does not ship there.
+type CartEstimate struct {
+	Country        string `json:"country"`
+	ShippingMethod string `json:"shipping_method,omitempty"`
 }
 
 type CartItemResponse struct {
+	ID        uuid.UUID       `json:"id"`
+	ProductID uuid.UUID       `json:"product_id"`
+	Name      string          `json:"name"`
+	Quantity  int             `json:"quantity"`
+	UnitPrice decimal.Decimal `json:"unit_price"`
+	LineTotal decimal.Decimal `json:"line_total"`
+	Warnings  []CartWarning   `json:"warnings,omitempty"`
+}
+
+// CartCouponResponse is the coupon applied to a cart. A coupon that no longer
+// applies (say, the cart fell below its minimum spend) stays on the cart
+// with Applied false and the reason, and gives no discount.
+type CartCouponResponse struct {
+	Code         string          `json:"code"`
+	Description  string          `json:"description,omitempty"`
+	Applied      bool            `json:"applied"`
+	Discount     decimal.Decimal `json:"discount"`
+	FreeShipping bool            `json:"free_shipping,omitempty"`
+	Reason       string          `json:"reason,omitempty"`
+	Message      string          `json:"message,omitempty"`
+}
+
+// CartWarning flags a line the customer should look at before checkout.
+// PreviousPrice comes with price_changed, Available with insufficient_stock
+// and MaxPerOrder with max_per_order_exceeded.
+type CartWarning struct {
+	Code          string           `json:"code"`
+	Message       string           `json:"message"`
+	PreviousPrice *decimal.Decimal `json:"previous_price,omitempty"`
+	Available     *int             `json:"available,omitempty"`
+	MaxPerOrder   *int             `json:"max_per_order,omitempty"`
+}
+
+// Wishlists
+
+type CreateWishlistRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type AddWishlistItemRequest struct {
+	ProductID uuid.UUID `json:"product_id" binding:"required"`
+}
+
+// MoveToCartRequest moves a wishlist item into the cart; Quantity defaults
+// to 1.
+type MoveToCartRequest struct {
+	Quantity int `json:"quantity" binding:"omitempty,min=1"`
+}
+
+type MoveToWishlistRequest struct {
+	WishlistID uuid.UUID `json:"wishlist_id" binding:"required"`
+}
+
+// WishlistResponse is a wishlist as its owner sees it. ShareToken is set
+// while the wishlist is shared, and is the last segment of its public link.
+type WishlistResponse struct {
+	ID         uuid.UUID              `json:"id"`
+	Name       string                 `json:"name"`
+	ShareToken string                 `json:"share_token,omitempty"`
+	Items      []WishlistItemResponse `json:"items"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+type WishlistItemResponse struct {
+	ID        uuid.UUID       `json:"id"`
+	ProductID uuid.UUID       `json:"product_id"`
+	Name      string          `json:"name"`
+	Price     decimal.Decimal `json:"price"`
+	Available bool            `json:"available"`
+	AddedAt   time.Time       `json:"added_at"`
+}
+
+type WishlistListResponse struct {
+	Wishlists []WishlistResponse `json:"wishlists"`
+}
+
+// SharedWishlistResponse is a wishlist opened by its public link; it does
+// not say whose it is.
+type SharedWishlistResponse struct {
+	Name  string                 `json:"name"`
+	Items []WishlistItemResponse `json:"items"`
+}
+
+// Promotions
+
+// CreatePromotionRequest defines a coupon. Value is the percentage off for
+// percentage promotions and the amount off for fixed ones; buy_x_get_y uses
+// BuyQuantity and GetQuantity instead. Empty ProductIDs and Categories make
+// the promotion apply to the whole cart.
+type CreatePromotionRequest struct {
+	Code               string          `json:"code" binding:"required,max=64"`
+	Description        string          `json:"description"`
+	Kind               string          `json:"kind" binding:"required,oneof=percentage fixed free_shipping buy_x_get_y"`
+	Value              decimal.Decimal `json:"value"`
+	BuyQuantity        int             `json:"buy_quantity" binding:"min=0"`
+	GetQuantity        int             `json:"get_quantity" binding:"min=0"`
+	MinSubtotal        decimal.Decimal `json:"min_subtotal"`
+	ProductIDs         []uuid.UUID     `json:"product_ids"`
+	Categories         []string        `json:"categories" binding:"dive,required,max=64"`
+	MaxUses            *int            `json:"max_uses" binding:"omitempty,min=1"`
+	MaxUsesPerCustomer *int            `json:"max_uses_per_customer" binding:"omitempty,min=1"`
+	StartsAt           *time.Time      `json:"starts_at"`
+	EndsAt             *time.Time      `json:"ends_at"`
+}
+
+type PromotionActiveRequest struct {
+	Active *bool `json:"active" binding:"required"`
+}
+
+type PromotionResponse struct {
+	ID                 uuid.UUID       `json:"id"`
+	Code               string          `json:"code"`
+	Description        string          `json:"description"`
+	Kind               string          `json:"kind"`
+	Value              decimal.Decimal `json:"value"`
+	BuyQuantity        int             `json:"buy_quantity,omitempty"`
+	GetQuantity        int             `json:"get_quantity,omitempty"`
+	MinSubtotal        decimal.Decimal `json:"min_subtotal"`
+	ProductIDs         []uuid.UUID     `json:"product_ids"`
+	Categories         []string        `json:"categories"`
+	MaxUses            *int            `json:"max_uses"`
+	MaxUsesPerCustomer *int            `json:"max_uses_per_customer"`
+	Uses               int             `json:"uses"`
+	StartsAt           *time.Time      `json:"starts_at"`
+	EndsAt             *time.Time      `json:"ends_at"`
+	Active             bool            `json:"active"`
+	CreatedAt          time.Time       `json:"created_at"`
+}
+
+type PromotionListResponse struct {
+	Promotions []PromotionResponse `json:"promotions"`
+	PageInfo
+}
+
+// Shipping
+
+// CreateShippingZoneRequest groups destination countries that share rates.
+// A country may belong to one zone only.
+type CreateShippingZoneRequest struct {
+	Name      string   `json:"name" binding:"required,max=64"`
+	Countries []string `json:"countries" binding:"required,min=1,dive,len=2,alpha"`
+}
+
+type ShippingZoneResponse struct {
 	ID        uuid.UUID `json:"id"`
-	ProductID uuid.UUID `json:"product_id"`
-	Quantity  int       `json:"quantity"`
+	Name      string    `json:"name"`
+	Countries []string  `json:"countries"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ShippingZoneListResponse struct {
+	Zones []ShippingZoneResponse `json:"zones"`
+}
+
+// CreateShippingRateRequest adds a row to the rate table. The rate applies
+// to shipments from the minimums up to, but not including, the maximums;
+// null maximums are open-ended and a null zone means every destination.
+// The cost is price plus price_per_kg for every started kilogram.
+type CreateShippingRateRequest struct {
+	Method         string           `json:"method" binding:"required,oneof=standard express pickup"`
+	ZoneID         *uuid.UUID       `json:"zone_id"`
+	MinWeightGrams int              `json:"min_weight_grams" binding:"min=0"`
+	MaxWeightGrams *int             `json:"max_weight_grams" binding:"omitempty,min=1"`
+	MinSubtotal    decimal.Decimal  `json:"min_subtotal"`
+	MaxSubtotal    *decimal.Decimal `json:"max_subtotal"`
+	Price          decimal.Decimal  `json:"price"`
+	PricePerKg     decimal.Decimal  `json:"price_per_kg"`
+}
+
+type ShippingRateResponse struct {
+	ID             uuid.UUID        `json:"id"`
+	Method         string           `json:"method"`
+	ZoneID         *uuid.UUID       `json:"zone_id"`
+	MinWeightGrams int              `json:"min_weight_grams"`
+	MaxWeightGrams *int             `json:"max_weight_grams"`
+	MinSubtotal    decimal.Decimal  `json:"min_subtotal"`
+	MaxSubtotal    *decimal.Decimal `json:"max_subtotal"`
+	Price          decimal.Decimal  `json:"price"`
+	PricePerKg     decimal.Decimal  `json:"price_per_kg"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+type ShippingRateListResponse struct {
+	Rates []ShippingRateResponse `json:"rates"`
+}
+
+// ShippingQuoteQuery is the destination a cart is quoted for.
+type ShippingQuoteQuery struct {
+	Country string `form:"country" binding:"required,len=2,alpha"`
+}
+
+// ShippingQuoteResponse lists the cheapest quote for each method that can
+// ship the cart. A free-shipping coupon on the cart makes standard shipping
+// free.
+type ShippingQuoteResponse struct {
+	Country     string          `json:"country"`
+	Currency    string          `json:"currency"`
+	WeightGrams int             `json:"weight_grams"`
+	Quotes      []ShippingQuote `json:"quotes"`
+}
+
+type ShippingQuote struct {
+	Method   string          `json:"method"`
+	Cost     decimal.Decimal `json:"cost"`
+	Provider string          `json:"provider"`
+}
+
+// Tax
+
+// CreateTaxRateRequest sets the percentage charged on a tax class in a
+// country, or in one of its regions. An empty region covers the whole
+// country.
+type CreateTaxRateRequest struct {
+	Country  string          `json:"country" binding:"required,len=2,alpha"`
+	Region   string          `json:"region" binding:"max=100"`
+	TaxClass string          `json:"tax_class" binding:"required,max=32"`
+	Name     string          `json:"name" binding:"required,max=64"`
+	Rate     decimal.Decimal `json:"rate"`
+}
+
+type TaxRateResponse struct {
+	ID        uuid.UUID       `json:"id"`
+	Country   string          `json:"country"`
+	Region    string          `json:"region,omitempty"`
+	TaxClass  string          `json:"tax_class"`
+	Name      string          `json:"name"`
+	Rate      decimal.Decimal `json:"rate"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type TaxRateListResponse struct {
+	Rates []TaxRateResponse `json:"rates"`
+}
+
+// TaxLine is the tax charged at one rate. Rate is a percentage.
+type TaxLine struct {
+	Name   string          `json:"name"`
+	Rate   decimal.Decimal `json:"rate"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// Currencies
+
+// ExchangeRateRequest sets how many units of a currency one unit of the base
+// currency buys.
+type ExchangeRateRequest struct {
+	Rate decimal.Decimal `json:"rate"`
+}
+
+type ExchangeRateResponse struct {
+	Currency  string          `json:"currency"`
+	Rate      decimal.Decimal `json:"rate"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type ExchangeRateListResponse struct {
+	Base  string                 `json:"base"`
+	Rates []ExchangeRateResponse `json:"rates"`
+}
+
+// ProductPriceRequest sets a product's price in a currency by hand.
+type ProductPriceRequest struct {
+	Price decimal.Decimal `json:"price"`
+}
+
+type ProductPriceResponse struct {
+	Currency  string          `json:"currency"`
+	Price     decimal.Decimal `json:"price"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type ProductPriceListResponse struct {
+	ProductID uuid.UUID              `json:"product_id"`
+	Prices    []ProductPriceResponse `json:"prices"`
+}
+
+// Addresses
+
+// PostalAddress is an address given in a request or shown in a response.
+// Country is an ISO 3166-1 alpha-2 code.
+type PostalAddress struct {
+	FullName   string `json:"full_name" binding:"required,max=200"`
+	Line1      string `json:"line1" binding:"required,max=200"`
+	Line2      string `json:"line2,omitempty" binding:"max=200"`
+	City       string `json:"city" binding:"required,max=100"`
+	Region     string `json:"region,omitempty" binding:"max=100"`
+	PostalCode string `json:"postal_code" binding:"required,max=20"`
+	Country    string `json:"country" binding:"required,len=2,alpha"`
+	Phone      string `json:"phone,omitempty" binding:"max=32"`
+}
+
+// AddressRequest creates or replaces an address book entry.
+type AddressRequest struct {
+	Label string `json:"label" binding:"max=64"`
+	PostalAddress
+	DefaultShipping bool `json:"default_shipping"`
+	DefaultBilling  bool `json:"default_billing"`
+}
+
+type AddressResponse struct {
+	ID    uuid.UUID `json:"id"`
+	Label string    `json:"label,omitempty"`
+	PostalAddress
+	DefaultShipping bool      `json:"default_shipping"`
+	DefaultBilling  bool      `json:"default_billing"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type AddressListResponse struct {
+	Addresses []AddressResponse `json:"addresses"`
 }
 
 // Order
 
+// CreateOrderRequest says where and how the order goes. Each address is
+// either the ID of an address book entry or given inline, not both. Without
+// a shipping address the default one is used; without a billing address,
+// the default billing address and then the shipping address. ShippingMethod
+// defaults to standard.
+type CreateOrderRequest struct {
+	ShippingAddressID *uuid.UUID     `json:"shipping_address_id"`
+	ShippingAddress   *PostalAddress `json:"shipping_address"`
+	BillingAddressID  *uuid.UUID     `json:"billing_address_id"`
+	BillingAddress    *PostalAddress `json:"billing_address"`
+	ShippingMethod    string         `json:"shipping_method" binding:"omitempty,oneof=standard express pickup"`
+	// Currency defaults to the one selected by ?currency= or
+	// Accept-Currency, else the base currency.
+	Currency string `json:"currency" binding:"omitempty,len=3,alpha"`
+}
+
 type OrderResponse struct {
-	ID         uuid.UUID           `json:"id"`
-	Status     string              `json:"status"`
-	TotalPrice decimal.Decimal     `json:"total_price"`
-	Items      []OrderItemResponse `json:"items"`
-	CreatedAt  time.Time           `json:"created_at"`
+	ID           uuid.UUID       `json:"id"`
+	Status       string          `json:"status"`
+	Subtotal     decimal.Decimal `json:"subtotal"`
+	Discount     decimal.Decimal `json:"discount"`
+	ShippingCost decimal.Decimal `json:"shipping_cost"`
+	Tax          decimal.Decimal `json:"tax"`
+	// PricesIncludeTax says whether Tax is part of the prices or was added
+	// on top of them.
+	PricesIncludeTax bool            `json:"prices_include_tax"`
+	TaxBreakdown     []TaxLine       `json:"tax_breakdown"`
+	TotalPrice       decimal.Decimal `json:"total_price"`
+	Currency         string          `json:"currency"`
+	// ExchangeRate is what one unit of the base currency bought in Currency
+	// when the order was placed.
+	ExchangeRate    decimal.Decimal `json:"exchange_rate"`
+	CouponCode      string          `json:"coupon_code,omitempty"`
+	ShippingMethod  string          `json:"shipping_method,omitempty"`
+	ShippingAddress *PostalAddress  `json:"shipping_address,omitempty"`
+	BillingAddress  *PostalAddress  `json:"billing_address,omitempty"`
+	PaymentIntent   string          `json:"payment_intent,omitempty"`
+	// Refunded is how much of TotalPrice has been refunded.
+	Refunded  decimal.Decimal        `json:"refunded"`
+	Items     []OrderItemResponse    `json:"items"`
+	Customer  *OrderCustomerResponse `json:"customer,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
 }
 
+// Order list sorts and includes.
+const (
+	OrderSortNewest   = "newest"
+	OrderSortOldest   = "oldest"
+	OrderIncludeItems = "items"
+)
+
+// OrderListQuery filters and sorts an order list. Status takes a
+// comma-separated list of statuses; From and To bound the order date and take
+// RFC 3339 times or dates, a date in To including that whole day.
+type OrderListQuery struct {
+	Status  string `form:"status"`
+	From    string `form:"from"`
+	To      string `form:"to"`
+	Sort    string `form:"sort" binding:"omitempty,oneof=newest oldest"`
+	Include string `form:"include" binding:"omitempty,oneof=items"`
+}
+
+type OrderListResponse struct {
+	Orders []OrderResponse `json:"orders"`
+	PageInfo
+}
+
+// OrderItemResponse is an order line. ProductID is null once the product
+// has been deleted; Product is the product as it was when it was ordered.
 type OrderItemResponse struct {
+	ProductID   *uuid.UUID      `json:"product_id"`
+	Quantity    int             `json:"quantity"`
+	Price       decimal.Decimal `json:"price"`
+	TaxRate     decimal.Decimal `json:"tax_rate"`
+	TaxAmount   decimal.Decimal `json:"tax_amount"`
+	WarehouseID *uuid.UUID      `json:"warehouse_id,omitempty"`
+	Product     OrderProduct    `json:"product"`
+}
+
+// OrderProduct describes the product on an order line.
+type OrderProduct struct {
+	Name       string                 `json:"name"`
+	SKU        string                 `json:"sku,omitempty"`
+	Attributes OrderProductAttributes `json:"attributes"`
+	ImageURL   string                 `json:"image_url,omitempty"`
+	TaxClass   string                 `json:"tax_class,omitempty"`
+}
+
+type OrderProductAttributes struct {
+	Category    string `json:"category,omitempty"`
+	WeightGrams int    `json:"weight_grams,omitempty"`
+}
+
+// Invoices
+
+// InvoiceListQuery narrows the invoice list to an order's documents or to a
+// kind, invoice or credit_note.
+type InvoiceListQuery struct {
+	OrderID string `form:"order_id"`
+	Kind    string `form:"kind"`
+}
+
+// InvoiceResponse is an invoice or credit note. Number is the printed
+// document number; a credit note has the invoice it corrects and its
+// refund.
+type InvoiceResponse struct {
+	ID         uuid.UUID       `json:"id"`
+	Kind       string          `json:"kind"`
+	Number     string          `json:"number"`
+	OrderID    uuid.UUID       `json:"order_id"`
+	InvoiceID  *uuid.UUID      `json:"invoice_id,omitempty"`
+	RefundID   *uuid.UUID      `json:"refund_id,omitempty"`
+	Currency   string          `json:"currency"`
+	Total      decimal.Decimal `json:"total"`
+	RenderedAt *time.Time      `json:"rendered_at,omitempty"`
+	IssuedAt   time.Time       `json:"issued_at"`
+}
+
+type InvoiceListResponse struct {
+	Invoices []InvoiceResponse `json:"invoices"`
+	PageInfo
+}
+
+// Admin orders
+
+// AdminOrderListQuery searches every order: ID is an order ID, Email part of
+// the customer's email, and MinTotal and MaxTotal bound the total, both
+// included.
+type AdminOrderListQuery struct {
+	OrderListQuery
+	ID       string `form:"id"`
+	Email    string `form:"email"`
+	MinTotal string `form:"min_total"`
+	MaxTotal string `form:"max_total"`
+}
+
+// OrderStatusRequest moves an order to Status, leaving Note on record.
+type OrderStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Note   string `json:"note" binding:"max=1000"`
+}
+
+// OrderNoteRequest is the note left with a cancellation or a resent
+// confirmation.
+type OrderNoteRequest struct {
+	Note string `json:"note" binding:"max=1000"`
+}
+
+// OrderRefundRequest refunds Amount of an order, or all that is left to
+// refund without one. Reference is the payment provider's refund ID.
+type OrderRefundRequest struct {
+	Amount    *decimal.Decimal `json:"amount"`
+	Reference string           `json:"reference" binding:"max=255"`
+	Note      string           `json:"note" binding:"max=1000"`
+}
+
+type OrderCustomerResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Email     string    `json:"email"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+}
+
+type OrderEventResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Kind       string     `json:"kind"`
+	FromStatus string     `json:"from_status,omitempty"`
+	ToStatus   string     `json:"to_status,omitempty"`
+	Note       string     `json:"note,omitempty"`
+	ActorID    *uuid.UUID `json:"actor_id,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type OrderRefundResponse struct {
+	ID        uuid.UUID       `json:"id"`
+	Amount    decimal.Decimal `json:"amount"`
+	Currency  string          `json:"currency"`
+	Reference string          `json:"reference,omitempty"`
+	Note      string          `json:"note,omitempty"`
+	ActorID   *uuid.UUID      `json:"actor_id,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// AdminOrderResponse is an order with its customer and what staff have
+// done to it, oldest first.
+type AdminOrderResponse struct {
+	OrderResponse
+	Events  []OrderEventResponse  `json:"events"`
+	Refunds []OrderRefundResponse `json:"refunds"`
+}
+
+// Checkout
+
+// StartCheckoutRequest starts a checkout session for the cart. Currency
+// defaults as in CreateOrderRequest.
+type StartCheckoutRequest struct {
+	Currency string `json:"currency" binding:"omitempty,len=3,alpha"`
+}
+
+// CheckoutAddressRequest picks a checkout session's addresses the way
+// CreateOrderRequest picks an order's.
+type CheckoutAddressRequest struct {
+	ShippingAddressID *uuid.UUID     `json:"shipping_address_id"`
+	ShippingAddress   *PostalAddress `json:"shipping_address"`
+	BillingAddressID  *uuid.UUID     `json:"billing_address_id"`
+	BillingAddress    *PostalAddress `json:"billing_address"`
+}
+
+type CheckoutShippingRequest struct {
+	Method string `json:"method" binding:"required,oneof=standard express pickup"`
+}
+
+// CheckoutPaymentRequest attaches the payment the customer authorised with
+// the payment provider, by the provider's reference for it.
+type CheckoutPaymentRequest struct {
+	PaymentIntent string `json:"payment_intent" binding:"required,max=255"`
+}
+
+// CheckoutResponse is a checkout session with the totals of its last
+// change. Missing lists the steps left before it can be completed, out of
+// shipping_address, shipping_method and payment_intent.
+type CheckoutResponse struct {
+	ID               uuid.UUID              `json:"id"`
+	Status           string                 `json:"status"`
+	Currency         string                 `json:"currency"`
+	Items            []CheckoutItemResponse `json:"items"`
+	ShippingAddress  *PostalAddress         `json:"shipping_address,omitempty"`
+	BillingAddress   *PostalAddress         `json:"billing_address,omitempty"`
+	ShippingMethod   string                 `json:"shipping_method,omitempty"`
+	CouponCode       string                 `json:"coupon_code,omitempty"`
+	PaymentIntent    string                 `json:"payment_intent,omitempty"`
+	Subtotal         decimal.Decimal        `json:"subtotal"`
+	Discount         decimal.Decimal        `json:"discount"`
+	ShippingCost     decimal.Decimal        `json:"shipping_cost"`
+	Tax              decimal.Decimal        `json:"tax"`
+	PricesIncludeTax bool                   `json:"prices_include_tax"`
+	Total            decimal.Decimal        `json:"total"`
+	Missing          []string               `json:"missing"`
+	OrderID          *uuid.UUID             `json:"order_id,omitempty"`
+	ExpiresAt        time.Time              `json:"expires_at"`
+}
+
+type CheckoutItemResponse struct {
 	ProductID uuid.UUID       `json:"product_id"`
 	Quantity  int             `json:"quantity"`
-	Price     decimal.Decimal `json:"price"`
+	UnitPrice decimal.Decimal `json:"unit_price"`
+	LineTotal decimal.Decimal `json:"line_total"`
 }
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/middleware"
+	"github.com/flicky/go-ecommerce-api/internal/service"
+)
+
+// AddressHandler serves the signed-in user's address book.
+type AddressHandler struct {
+	svc *service.AddressService
+}
+
+func NewAddressHandler(svc *service.AddressService) *AddressHandler {
+	return &AddressHandler{svc: svc}
+}
+
+func (h *AddressHandler) Create(c *gin.Context) {
+	var req dto.AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.Create(c.Request.Context(), middleware.GetUserID(c), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *AddressHandler) List(c *gin.Context) {
+	resp, err := h.svc.List(c.Request.Context(), middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *AddressHandler) Get(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	resp, err := h.svc.Get(c.Request.Context(), middleware.GetUserID(c), id)
+	if err != nil {
+		writeAddressError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *AddressHandler) Update(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req dto.AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.Update(c.Request.Context(), middleware.GetUserID(c), id, req)
+	if err != nil {
+		writeAddressError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *AddressHandler) Delete(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := h.svc.Delete(c.Request.Context(), middleware.GetUserID(c), id); err != nil {
+		writeAddressError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func writeAddressError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrAddressNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "address not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+}
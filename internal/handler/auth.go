@@ -5,17 +5,34 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 
 	"github.com/flicky/go-ecommerce-api/internal/dto"
 	"github.com/flicky/go-ecommerce-api/internal/service"
 )
 
 type AuthHandler struct {
-	svc *service.AuthService
+	svc    *service.AuthService
+	carts  *service.CartService
+	tokens *CartTokens
 }
 
-func NewAuthHandler(svc *service.AuthService) *AuthHandler {
-	return &AuthHandler{svc: svc}
+func NewAuthHandler(svc *service.AuthService, carts *service.CartService, tokens *CartTokens) *AuthHandler {
+	return &AuthHandler{svc: svc, carts: carts, tokens: tokens}
+}
+
+// mergeGuestCart moves the caller's guest cart, if any, into the account
+// they just signed in to. Signing in does not fail over it.
+func (h *AuthHandler) mergeGuestCart(c *gin.Context, userID uuid.UUID) {
+	guestCartID := h.tokens.guestCartID(c)
+	if guestCartID == uuid.Nil {
+		return
+	}
+	if err := h.carts.MergeGuestCart(c.Request.Context(), guestCartID, userID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	h.tokens.clear(c)
 }
 
 func (h *AuthHandler) Register(c *gin.Context) {
@@ -33,6 +50,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
+	h.mergeGuestCart(c, resp.User.ID)
 	c.JSON(http.StatusCreated, resp)
 }
 
@@ -51,5 +69,6 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
+	h.mergeGuestCart(c, resp.User.ID)
 	c.JSON(http.StatusOK, resp)
 }
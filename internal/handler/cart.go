@@ -2,37 +2,106 @@ package handler
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
 	"github.com/flicky/go-ecommerce-api/internal/dto"
-	"github.com/flicky/go-ecommerce-api/internal/middleware"
 	"github.com/flicky/go-ecommerce-api/internal/service"
 )
 
+// CartHandler serves both signed-in users and guests; a guest's cart is
+// identified by its cart token.
 type CartHandler struct {
-	svc *service.CartService
+	svc    *service.CartService
+	tokens *CartTokens
 }
 
-func NewCartHandler(svc *service.CartService) *CartHandler {
-	return &CartHandler{svc: svc}
+func NewCartHandler(svc *service.CartService, tokens *CartTokens) *CartHandler {
+	return &CartHandler{svc: svc, tokens: tokens}
 }
 
-func (h *CartHandler) GetCart(c *gin.Context) {
-	cart, err := h.svc.GetCart(c.Request.Context(), middleware.GetUserID(c))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+// Cart error codes, sent alongside the message so clients can tell failures
+// apart without parsing it.
+const (
+	cartErrProductNotFound   = "product_not_found"
+	cartErrItemNotFound      = "cart_item_not_found"
+	cartErrInsufficientStock = "insufficient_stock"
+	cartErrMaxPerOrder       = "max_per_order_exceeded"
+	cartErrCurrency          = "unsupported_currency"
+	cartErrLocked            = "cart_locked"
+)
+
+func writeCartError(c *gin.Context, err error) {
+	if writeQuantityError(c, err) || writeCouponError(c, err) {
 		return
 	}
-	items := make([]dto.CartItemResponse, 0, len(cart.Items))
-	for _, item := range cart.Items {
-		items = append(items, dto.CartItemResponse{
-			ID: item.ID, ProductID: item.ProductID, Quantity: item.Quantity,
+	switch {
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "product not found", "code": cartErrProductNotFound})
+	case errors.Is(err, service.ErrCartItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "cart item not found", "code": cartErrItemNotFound})
+	case errors.Is(err, service.ErrUnsupportedCurrency):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": cartErrCurrency})
+	case errors.Is(err, service.ErrCartLocked):
+		c.JSON(http.StatusConflict, gin.H{"error": "cart is locked by checkout", "code": cartErrLocked})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+// writeQuantityError answers a quantity over stock or the per-order limit
+// with the product and the most its line may hold, and reports whether err
+// was one.
+func writeQuantityError(c *gin.Context, err error) bool {
+	var qe *service.QuantityError
+	if !errors.As(err, &qe) {
+		return false
+	}
+	if errors.Is(qe, service.ErrMaxPerOrderExceeded) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("at most %d per order", qe.Allowed), "code": cartErrMaxPerOrder,
+			"product_id": qe.ProductID, "max_per_order": qe.Allowed,
 		})
+		return true
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"error": fmt.Sprintf("only %d in stock", qe.Allowed), "code": cartErrInsufficientStock,
+		"product_id": qe.ProductID, "available": qe.Allowed,
+	})
+	return true
+}
+
+// writeCouponError answers a rejected coupon with its reason code, and
+// reports whether err was one.
+func writeCouponError(c *gin.Context, err error) bool {
+	var ce *service.CouponError
+	if !errors.As(err, &ce) {
+		return false
+	}
+	status := http.StatusConflict
+	if ce.Code == service.CouponNotFound {
+		status = http.StatusNotFound
+	}
+	body := gin.H{"error": ce.Message, "code": ce.Code}
+	if ce.MinSubtotal != nil {
+		body["min_subtotal"] = ce.MinSubtotal
+	}
+	c.JSON(status, body)
+	return true
+}
+
+func (h *CartHandler) GetCart(c *gin.Context) {
+	ref := h.tokens.ref(c)
+	cart, err := h.svc.GetCart(c.Request.Context(), ref)
+	if err != nil {
+		writeCartError(c, err)
+		return
 	}
-	c.JSON(http.StatusOK, dto.CartResponse{ID: cart.ID, Items: items})
+	h.tokens.issue(c, ref, cart.ID)
+	c.JSON(http.StatusOK, cart)
 }
 
 func (h *CartHandler) AddItem(c *gin.Context) {
@@ -41,14 +110,13 @@ func (h *CartHandler) AddItem(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := h.svc.AddItem(c.Request.Context(), middleware.GetUserID(c), req.ProductID, req.Quantity); err != nil {
-		if errors.Is(err, service.ErrProductNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	ref := h.tokens.ref(c)
+	cartID, err := h.svc.AddItem(c.Request.Context(), ref, req.ProductID, req.Quantity)
+	if err != nil {
+		writeCartError(c, err)
 		return
 	}
+	h.tokens.issue(c, ref, cartID)
 	c.JSON(http.StatusCreated, gin.H{"message": "item added"})
 }
 
@@ -63,8 +131,8 @@ func (h *CartHandler) UpdateItem(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := h.svc.UpdateItem(c.Request.Context(), middleware.GetUserID(c), itemID, req.Quantity); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	if err := h.svc.UpdateItem(c.Request.Context(), h.tokens.ref(c), itemID, req.Quantity); err != nil {
+		writeCartError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "item updated"})
@@ -76,9 +144,89 @@ func (h *CartHandler) DeleteItem(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	if err := h.svc.DeleteItem(c.Request.Context(), middleware.GetUserID(c), itemID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	if err := h.svc.DeleteItem(c.Request.Context(), h.tokens.ref(c), itemID); err != nil {
+		writeCartError(c, err)
 		return
 	}
 	c.Status(http.StatusNoContent)
 }
+
+// SaveForLater moves a line out of the active cart into its saved-for-later
+// section and returns the cart.
+func (h *CartHandler) SaveForLater(c *gin.Context) {
+	itemID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	cart, err := h.svc.SaveForLater(c.Request.Context(), h.tokens.ref(c), itemID)
+	if err != nil {
+		writeCartError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, cart)
+}
+
+// MoveToCart moves a saved-for-later line back into the active cart and
+// returns the cart.
+func (h *CartHandler) MoveToCart(c *gin.Context) {
+	itemID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	cart, err := h.svc.MoveToCart(c.Request.Context(), h.tokens.ref(c), itemID)
+	if err != nil {
+		writeCartError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, cart)
+}
+
+// ApplyCoupon puts a coupon code on the cart and returns the repriced cart.
+func (h *CartHandler) ApplyCoupon(c *gin.Context) {
+	var req dto.ApplyCouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ref := h.tokens.ref(c)
+	cart, err := h.svc.ApplyCoupon(c.Request.Context(), ref, req.Code)
+	if err != nil {
+		writeCartError(c, err)
+		return
+	}
+	h.tokens.issue(c, ref, cart.ID)
+	c.JSON(http.StatusOK, cart)
+}
+
+func (h *CartHandler) RemoveCoupon(c *gin.Context) {
+	ref := h.tokens.ref(c)
+	cart, err := h.svc.RemoveCoupon(c.Request.Context(), ref)
+	if err != nil {
+		writeCartError(c, err)
+		return
+	}
+	h.tokens.issue(c, ref, cart.ID)
+	c.JSON(http.StatusOK, cart)
+}
+
+// ShippingQuotes quotes shipping the cart to the country in the query.
+func (h *CartHandler) ShippingQuotes(c *gin.Context) {
+	var query dto.ShippingQuoteQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ref := h.tokens.ref(c)
+	resp, err := h.svc.ShippingQuotes(c.Request.Context(), ref, query.Country)
+	if err != nil {
+		if errors.Is(err, service.ErrEmptyCart) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cart is empty"})
+			return
+		}
+		writeCartError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
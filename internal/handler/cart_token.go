@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/carttoken"
+	"github.com/flicky/go-ecommerce-api/internal/middleware"
+	"github.com/flicky/go-ecommerce-api/internal/service"
+)
+
+const (
+	cartTokenHeader = "X-Cart-Token"
+	cartTokenCookie = "cart_token"
+)
+
+// CartTokens reads and issues the signed token that identifies a guest's
+// cart. Clients send it back as the X-Cart-Token header or the cart_token
+// cookie; responses carry it in both.
+type CartTokens struct {
+	signer *carttoken.Signer
+	ttl    time.Duration
+}
+
+func NewCartTokens(signer *carttoken.Signer, ttl time.Duration) *CartTokens {
+	return &CartTokens{signer: signer, ttl: ttl}
+}
+
+// guestCartID returns the cart ID from a valid token, or uuid.Nil. Invalid
+// tokens are treated as absent so a guest simply starts a new cart.
+func (t *CartTokens) guestCartID(c *gin.Context) uuid.UUID {
+	token := c.GetHeader(cartTokenHeader)
+	if token == "" {
+		token, _ = c.Cookie(cartTokenCookie)
+	}
+	if token == "" {
+		return uuid.Nil
+	}
+	id, err := t.signer.Verify(token)
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
+
+// ref identifies the request's cart, the signed-in user's else the guest's,
+// the currency asked for and the ?country= to estimate tax and shipping to.
+func (t *CartTokens) ref(c *gin.Context) service.CartRef {
+	ref := service.CartRef{Currency: requestCurrency(c), Country: c.Query("country")}
+	if userID := middleware.GetUserID(c); userID != uuid.Nil {
+		ref.UserID = userID
+	} else {
+		ref.GuestCartID = t.guestCartID(c)
+	}
+	return ref
+}
+
+// issue sends a guest the token for cartID, renewing the cookie's lifetime
+// along with the cart's.
+func (t *CartTokens) issue(c *gin.Context, ref service.CartRef, cartID uuid.UUID) {
+	if !ref.IsGuest() {
+		return
+	}
+	token := t.signer.Sign(cartID)
+	c.Header(cartTokenHeader, token)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(cartTokenCookie, token, int(t.ttl.Seconds()), "/", "", c.Request.TLS != nil, true)
+}
+
+func (t *CartTokens) clear(c *gin.Context) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(cartTokenCookie, "", -1, "/", "", c.Request.TLS != nil, true)
+}
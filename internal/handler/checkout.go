@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/middleware"
+	"github.com/flicky/go-ecommerce-api/internal/service"
+)
+
+// CheckoutHandler serves the signed-in user's checkout sessions.
+type CheckoutHandler struct {
+	svc *service.CheckoutService
+}
+
+func NewCheckoutHandler(svc *service.CheckoutService) *CheckoutHandler {
+	return &CheckoutHandler{svc: svc}
+}
+
+// Start opens a checkout session for the cart. The body, which may pick
+// the currency, may be left out.
+func (h *CheckoutHandler) Start(c *gin.Context) {
+	var req dto.StartCheckoutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Currency == "" {
+		req.Currency = requestCurrency(c)
+	}
+	resp, err := h.svc.Start(c.Request.Context(), middleware.GetUserID(c), req.Currency)
+	if err != nil {
+		writeCheckoutError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *CheckoutHandler) Get(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	resp, err := h.svc.Get(c.Request.Context(), middleware.GetUserID(c), id)
+	if err != nil {
+		writeCheckoutError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *CheckoutHandler) SetAddresses(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req dto.CheckoutAddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.SetAddresses(c.Request.Context(), middleware.GetUserID(c), id, req)
+	if err != nil {
+		writeCheckoutError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *CheckoutHandler) SetShippingMethod(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req dto.CheckoutShippingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.SetShippingMethod(c.Request.Context(), middleware.GetUserID(c), id, req.Method)
+	if err != nil {
+		writeCheckoutError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *CheckoutHandler) ApplyCoupon(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req dto.ApplyCouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.ApplyCoupon(c.Request.Context(), middleware.GetUserID(c), id, req.Code)
+	if err != nil {
+		writeCheckoutError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *CheckoutHandler) RemoveCoupon(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	resp, err := h.svc.RemoveCoupon(c.Request.Context(), middleware.GetUserID(c), id)
+	if err != nil {
+		writeCheckoutError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *CheckoutHandler) SetPaymentIntent(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req dto.CheckoutPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.SetPaymentIntent(c.Request.Context(), middleware.GetUserID(c), id, req.PaymentIntent)
+	if err != nil {
+		writeCheckoutError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Complete places the session's order.
+func (h *CheckoutHandler) Complete(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	order, err := h.svc.Complete(c.Request.Context(), middleware.GetUserID(c), id)
+	if err != nil {
+		writeCheckoutError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, toOrderResponse(order))
+}
+
+func (h *CheckoutHandler) Cancel(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := h.svc.Cancel(c.Request.Context(), middleware.GetUserID(c), id); err != nil {
+		writeCheckoutError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func writeCheckoutError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrCheckoutNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "checkout not found"})
+	case errors.Is(err, service.ErrCheckoutExpired):
+		c.JSON(http.StatusGone, gin.H{"error": "checkout expired"})
+	case errors.Is(err, service.ErrCheckoutClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": "checkout is no longer open"})
+	case errors.Is(err, service.ErrCheckoutIncomplete), errors.Is(err, service.ErrCheckoutChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		writeOrderError(c, err)
+	}
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/service"
+)
+
+// requestCurrency is the currency the client asked for with ?currency= or
+// the Accept-Currency header; empty means the base currency.
+func requestCurrency(c *gin.Context) string {
+	if code := c.Query("currency"); code != "" {
+		return code
+	}
+	return c.GetHeader("Accept-Currency")
+}
+
+// CurrencyHandler lets admins manage exchange rates and product price lists.
+type CurrencyHandler struct {
+	svc *service.CurrencyService
+}
+
+func NewCurrencyHandler(svc *service.CurrencyService) *CurrencyHandler {
+	return &CurrencyHandler{svc: svc}
+}
+
+func (h *CurrencyHandler) ListRates(c *gin.Context) {
+	resp, err := h.svc.ListRates(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *CurrencyHandler) SetRate(c *gin.Context) {
+	var req dto.ExchangeRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.SetRate(c.Request.Context(), c.Param("code"), req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCurrency) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *CurrencyHandler) DeleteRate(c *gin.Context) {
+	if err := h.svc.DeleteRate(c.Request.Context(), c.Param("code")); err != nil {
+		if errors.Is(err, service.ErrExchangeRateNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "exchange rate not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *CurrencyHandler) ListPrices(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	resp, err := h.svc.ListPrices(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *CurrencyHandler) SetPrice(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req dto.ProductPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.SetPrice(c.Request.Context(), id, c.Param("code"), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCurrency):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrProductNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *CurrencyHandler) DeletePrice(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := h.svc.DeletePrice(c.Request.Context(), id, c.Param("code")); err != nil {
+		if errors.Is(err, service.ErrProductPriceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "product price not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/flicky/go-ecommerce-api/internal/service"
+)
+
+// notModified sets the ETag header and, when the request's If-None-Match
+// matches it, answers 304 Not Modified. Callers return early on true.
+func notModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	if inm := c.GetHeader("If-None-Match"); inm != "" && service.MatchETag(inm, etag, true) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/middleware"
+	"github.com/flicky/go-ecommerce-api/internal/service"
+)
+
+type ImportHandler struct {
+	svc *service.ImportService
+}
+
+func NewImportHandler(svc *service.ImportService) *ImportHandler {
+	return &ImportHandler{svc: svc}
+}
+
+// Import accepts multipart/form-data with a "file" part. The format comes
+// from the "format" field or, failing that, the file extension.
+func (h *ImportHandler) Import(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, service.MaxImportSize+1<<20)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	format := c.PostForm("format")
+	if format == "" {
+		format = formatFromFilename(fh.Filename)
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file"})
+		return
+	}
+	defer f.Close() //nolint:errcheck // read-only
+	data, err := io.ReadAll(io.LimitReader(f, service.MaxImportSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file"})
+		return
+	}
+
+	resp, err := h.svc.CreateJob(c.Request.Context(), middleware.GetUserID(c), format, data)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnsupportedFormat):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or ndjson"})
+		case errors.Is(err, service.ErrImportTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+	c.JSON(http.StatusAccepted, resp)
+}
+
+func (h *ImportHandler) GetJob(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	resp, err := h.svc.GetJob(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, service.ErrImportJobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "import job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Export streams the catalogue; once the body has started, errors can only
+// be logged by gin, not turned into a JSON response.
+func (h *ImportHandler) Export(c *gin.Context) {
+	format := c.DefaultQuery("format", service.FormatCSV)
+	contentType := map[string]string{
+		service.FormatCSV:    "text/csv; charset=utf-8",
+		service.FormatNDJSON: "application/x-ndjson",
+	}[format]
+	if contentType == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or ndjson"})
+		return
+	}
+
+	// The export can outlive the server's write timeout on large catalogues,
+	// which would cut the file short without any error reaching the client.
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		_ = c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="products.`+format+`"`)
+	c.Status(http.StatusOK)
+	if err := h.svc.Export(c.Request.Context(), format, c.Writer); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+func formatFromFilename(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return service.FormatCSV
+	case ".ndjson", ".jsonl":
+		return service.FormatNDJSON
+	}
+	return ""
+}
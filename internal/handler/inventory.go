@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/middleware"
+	"github.com/flicky/go-ecommerce-api/internal/service"
+)
+
+type InventoryHandler struct {
+	svc *service.InventoryService
+}
+
+func NewInventoryHandler(svc *service.InventoryService) *InventoryHandler {
+	return &InventoryHandler{svc: svc}
+}
+
+func (h *InventoryHandler) Adjust(c *gin.Context) {
+	var req dto.InventoryAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.Adjust(c.Request.Context(), middleware.GetUserID(c), req)
+	if err != nil {
+		writeInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// Transfer moves stock of a product from one warehouse to another.
+func (h *InventoryHandler) Transfer(c *gin.Context) {
+	var req dto.StockTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.Transfer(c.Request.Context(), middleware.GetUserID(c), req)
+	if err != nil {
+		writeInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+func writeInventoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidAdjustment):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+	case errors.Is(err, service.ErrWarehouseNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "warehouse not found"})
+	case errors.Is(err, service.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+	case errors.Is(err, service.ErrInsufficientStock):
+		c.JSON(http.StatusConflict, gin.H{"error": "insufficient stock"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+// Movements lists a product's inventory ledger, newest first.
+func (h *InventoryHandler) Movements(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	params, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+		return
+	}
+	resp, err := h.svc.Movements(c.Request.Context(), id, params)
+	if err != nil {
+		if errors.Is(err, service.ErrProductNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Discrepancies reports products whose stock disagrees with the ledger.
+func (h *InventoryHandler) Discrepancies(c *gin.Context) {
+	resp, err := h.svc.Discrepancies(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Reconcile books corrections so the ledger matches on-hand stock.
+func (h *InventoryHandler) Reconcile(c *gin.Context) {
+	resp, err := h.svc.Reconcile(c.Request.Context(), middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/middleware"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/service"
+)
+
+// InvoiceHandler serves invoice PDFs to customers and the invoice list to
+// staff.
+type InvoiceHandler struct {
+	svc *service.InvoiceService
+}
+
+func NewInvoiceHandler(svc *service.InvoiceService) *InvoiceHandler {
+	return &InvoiceHandler{svc: svc}
+}
+
+// OrderInvoice sends the PDF invoice of one of the user's orders.
+func (h *InvoiceHandler) OrderInvoice(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	inv, pdf, err := h.svc.OrderInvoice(c.Request.Context(), middleware.GetUserID(c), orderID)
+	if err != nil {
+		writeInvoiceError(c, err)
+		return
+	}
+	writePDF(c, inv, pdf)
+}
+
+// List pages through the invoices and credit notes as in
+// dto.InvoiceListQuery.
+func (h *InvoiceHandler) List(c *gin.Context) {
+	var query dto.InvoiceListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	params, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+		return
+	}
+	invoices, page, err := h.svc.List(c.Request.Context(), query, params)
+	if err != nil {
+		writeInvoiceError(c, err)
+		return
+	}
+	resp := make([]dto.InvoiceResponse, len(invoices))
+	for i := range invoices {
+		resp[i] = toInvoiceResponse(&invoices[i])
+	}
+	c.JSON(http.StatusOK, dto.InvoiceListResponse{Invoices: resp, PageInfo: page})
+}
+
+// PDF sends any invoice or credit note as a PDF.
+func (h *InvoiceHandler) PDF(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	inv, pdf, err := h.svc.PDF(c.Request.Context(), id)
+	if err != nil {
+		writeInvoiceError(c, err)
+		return
+	}
+	writePDF(c, inv, pdf)
+}
+
+// Regenerate renders a document's PDF again and returns the document.
+func (h *InvoiceHandler) Regenerate(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	inv, err := h.svc.Regenerate(c.Request.Context(), id)
+	if err != nil {
+		writeInvoiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toInvoiceResponse(inv))
+}
+
+func writePDF(c *gin.Context, inv *model.Invoice, pdf []byte) {
+	c.Header("Content-Disposition", `attachment; filename="`+inv.Code()+`.pdf"`)
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+func writeInvoiceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvoiceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "invoice not found"})
+	case errors.Is(err, service.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+	case errors.Is(err, service.ErrOrderAccessDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	case errors.Is(err, service.ErrInvalidInvoiceQuery):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+func toInvoiceResponse(inv *model.Invoice) dto.InvoiceResponse {
+	return dto.InvoiceResponse{
+		ID: inv.ID, Kind: inv.Kind, Number: inv.Code(), OrderID: inv.OrderID, InvoiceID: inv.InvoiceID,
+		RefundID: inv.RefundID, Currency: inv.Currency, Total: inv.Total, RenderedAt: inv.RenderedAt,
+		IssuedAt: inv.CreatedAt,
+	}
+}
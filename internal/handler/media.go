@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/service"
+)
+
+type MediaHandler struct {
+	svc *service.MediaService
+}
+
+func NewMediaHandler(svc *service.MediaService) *MediaHandler {
+	return &MediaHandler{svc: svc}
+}
+
+// Upload accepts multipart/form-data with a "file" part and optional
+// "alt_text" and "is_primary" fields.
+func (h *MediaHandler) Upload(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	// Leave room for the other form fields on top of the file itself.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.svc.MaxSize()+1<<20)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if fh.Size > h.svc.MaxSize() {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file"})
+		return
+	}
+	defer f.Close() //nolint:errcheck // read-only
+	data, err := io.ReadAll(io.LimitReader(f, h.svc.MaxSize()+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file"})
+		return
+	}
+	primary, _ := strconv.ParseBool(c.PostForm("is_primary"))
+
+	resp, err := h.svc.Upload(c.Request.Context(), productID, data, c.PostForm("alt_text"), primary)
+	if err != nil {
+		writeMediaError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *MediaHandler) Update(c *gin.Context) {
+	productID, mediaID, ok := parseMediaIDs(c)
+	if !ok {
+		return
+	}
+	var req dto.UpdateProductMediaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.Update(c.Request.Context(), productID, mediaID, req)
+	if err != nil {
+		writeMediaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *MediaHandler) Delete(c *gin.Context) {
+	productID, mediaID, ok := parseMediaIDs(c)
+	if !ok {
+		return
+	}
+	if err := h.svc.Delete(c.Request.Context(), productID, mediaID); err != nil {
+		writeMediaError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func parseMediaIDs(c *gin.Context) (productID, mediaID uuid.UUID, ok bool) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	mediaID, err = uuid.Parse(c.Param("mediaId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid media id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return productID, mediaID, true
+}
+
+func writeMediaError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+	case errors.Is(err, service.ErrMediaNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "media not found"})
+	case errors.Is(err, service.ErrMediaTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+	case errors.Is(err, service.ErrImageTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "image dimensions too large"})
+	case errors.Is(err, service.ErrUnsupportedMediaType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "unsupported media type"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
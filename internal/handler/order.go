@@ -11,6 +11,7 @@ import (
 	"github.com/flicky/go-ecommerce-api/internal/middleware"
 	"github.com/flicky/go-ecommerce-api/internal/model"
 	"github.com/flicky/go-ecommerce-api/internal/service"
+	"github.com/flicky/go-ecommerce-api/internal/tax"
 )
 
 type OrderHandler struct {
@@ -21,22 +22,74 @@ func NewOrderHandler(svc *service.OrderService) *OrderHandler {
 	return &OrderHandler{svc: svc}
 }
 
+// CreateOrder places an order for the cart. The body, which picks the
+// addresses and shipping method, may be left out when the user has a
+// default shipping address.
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
-	order, err := h.svc.CreateOrder(c.Request.Context(), middleware.GetUserID(c))
-	if err != nil {
-		if errors.Is(err, service.ErrEmptyCart) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "cart is empty"})
+	var req dto.CreateOrderRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+	if req.Currency == "" {
+		req.Currency = requestCurrency(c)
+	}
+	order, err := h.svc.CreateOrder(c.Request.Context(), middleware.GetUserID(c), req)
+	if err != nil {
+		writeOrderError(c, err)
 		return
 	}
 	c.JSON(http.StatusCreated, toOrderResponse(order))
 }
 
+// writeOrderError answers a failure to place an order.
+func writeOrderError(c *gin.Context, err error) {
+	if writeQuantityError(c, err) || writeCouponError(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrEmptyCart):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cart is empty"})
+	case errors.Is(err, service.ErrShippingAddressRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "shipping address required"})
+	case errors.Is(err, service.ErrInvalidAddress), errors.Is(err, service.ErrUnsupportedCurrency):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrShippingMethodUnavailable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAddressNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "address not found"})
+	case errors.Is(err, service.ErrProductUnavailable):
+		c.JSON(http.StatusConflict, gin.H{"error": "cart contains unavailable products"})
+	case errors.Is(err, service.ErrInsufficientStock):
+		c.JSON(http.StatusConflict, gin.H{"error": "insufficient stock", "code": cartErrInsufficientStock})
+	case errors.Is(err, service.ErrCartLocked):
+		c.JSON(http.StatusConflict, gin.H{"error": "cart is locked by checkout", "code": cartErrLocked})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+// ListOrders pages through the user's orders, filtered and sorted as in
+// dto.OrderListQuery.
 func (h *OrderHandler) ListOrders(c *gin.Context) {
-	orders, err := h.svc.ListByUserID(c.Request.Context(), middleware.GetUserID(c))
+	var query dto.OrderListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	params, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+		return
+	}
+	orders, page, err := h.svc.ListByUserID(c.Request.Context(), middleware.GetUserID(c), query, params)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidOrderQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
@@ -44,7 +97,7 @@ func (h *OrderHandler) ListOrders(c *gin.Context) {
 	for i := range orders {
 		resp[i] = toOrderResponse(&orders[i])
 	}
-	c.JSON(http.StatusOK, resp)
+	c.JSON(http.StatusOK, dto.OrderListResponse{Orders: resp, PageInfo: page})
 }
 
 func (h *OrderHandler) GetOrder(c *gin.Context) {
@@ -71,13 +124,43 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 
 func toOrderResponse(o *model.Order) dto.OrderResponse {
 	items := make([]dto.OrderItemResponse, len(o.Items))
+	lineTaxes := make([]tax.LineTax, len(o.Items))
 	for i, item := range o.Items {
+		p := item.Product
 		items[i] = dto.OrderItemResponse{
-			ProductID: item.ProductID, Quantity: item.Quantity, Price: item.Price,
+			Quantity: item.Quantity, Price: item.Price, WarehouseID: item.WarehouseID,
+			TaxRate: item.TaxRate, TaxAmount: item.TaxAmount,
+			Product: dto.OrderProduct{
+				Name: p.Name, SKU: p.SKU, ImageURL: p.ImageURL, TaxClass: p.TaxClass,
+				Attributes: dto.OrderProductAttributes{Category: p.Attributes.Category, WeightGrams: p.Attributes.WeightGrams},
+			},
 		}
+		if item.ProductID != uuid.Nil {
+			items[i].ProductID = &item.ProductID
+		}
+		lineTaxes[i] = tax.LineTax{Name: item.TaxName, Rate: item.TaxRate, Amount: item.TaxAmount}
+	}
+	breakdown := []dto.TaxLine{}
+	for _, l := range tax.Breakdown(lineTaxes) {
+		breakdown = append(breakdown, dto.TaxLine{Name: l.Name, Rate: l.Rate, Amount: l.Amount})
 	}
-	return dto.OrderResponse{
-		ID: o.ID, Status: o.Status, TotalPrice: o.TotalPrice,
+	resp := dto.OrderResponse{
+		ID: o.ID, Status: o.Status, Subtotal: o.Subtotal, Discount: o.Discount, ShippingCost: o.ShippingCost,
+		Tax: o.Tax, PricesIncludeTax: o.PricesIncludeTax, TaxBreakdown: breakdown,
+		TotalPrice: o.TotalPrice, Currency: o.Currency, ExchangeRate: o.ExchangeRate, CouponCode: o.PromotionCode, ShippingMethod: o.ShippingMethod, ShippingAddress: toPostalAddressResponse(o.ShippingAddress),
+		BillingAddress: toPostalAddressResponse(o.BillingAddress), PaymentIntent: o.PaymentIntent, Refunded: o.Refunded,
 		Items: items, CreatedAt: o.CreatedAt,
 	}
+	if c := o.Customer; c != nil {
+		resp.Customer = &dto.OrderCustomerResponse{ID: c.ID, Email: c.Email, FirstName: c.FirstName, LastName: c.LastName}
+	}
+	return resp
+}
+
+func toPostalAddressResponse(a *model.PostalAddress) *dto.PostalAddress {
+	if a == nil {
+		return nil
+	}
+	resp := dto.PostalAddress(*a)
+	return &resp
 }
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/middleware"
+	"github.com/flicky/go-ecommerce-api/internal/service"
+)
+
+// AdminOrderHandler serves the staff order console.
+type AdminOrderHandler struct {
+	svc *service.AdminOrderService
+}
+
+func NewAdminOrderHandler(svc *service.AdminOrderService) *AdminOrderHandler {
+	return &AdminOrderHandler{svc: svc}
+}
+
+// List searches every order as in dto.AdminOrderListQuery.
+func (h *AdminOrderHandler) List(c *gin.Context) {
+	var query dto.AdminOrderListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	params, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+		return
+	}
+	orders, page, err := h.svc.List(c.Request.Context(), query, params)
+	if err != nil {
+		writeAdminOrderError(c, err)
+		return
+	}
+	resp := make([]dto.OrderResponse, len(orders))
+	for i := range orders {
+		resp[i] = toOrderResponse(&orders[i])
+	}
+	c.JSON(http.StatusOK, dto.OrderListResponse{Orders: resp, PageInfo: page})
+}
+
+func (h *AdminOrderHandler) Get(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	d, err := h.svc.Get(c.Request.Context(), id)
+	if err != nil {
+		writeAdminOrderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toAdminOrderResponse(d))
+}
+
+// ChangeStatus moves an order to the status in the body.
+func (h *AdminOrderHandler) ChangeStatus(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req dto.OrderStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	d, err := h.svc.ChangeStatus(c.Request.Context(), middleware.GetUserID(c), id, req.Status, req.Note)
+	if err != nil {
+		writeAdminOrderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toAdminOrderResponse(d))
+}
+
+func (h *AdminOrderHandler) Cancel(c *gin.Context) {
+	id, req, ok := orderNoteParams(c)
+	if !ok {
+		return
+	}
+	d, err := h.svc.Cancel(c.Request.Context(), middleware.GetUserID(c), id, req.Note)
+	if err != nil {
+		writeAdminOrderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toAdminOrderResponse(d))
+}
+
+func (h *AdminOrderHandler) Refund(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req dto.OrderRefundRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	d, err := h.svc.Refund(c.Request.Context(), middleware.GetUserID(c), id, req)
+	if err != nil {
+		writeAdminOrderError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, toAdminOrderResponse(d))
+}
+
+func (h *AdminOrderHandler) ResendConfirmation(c *gin.Context) {
+	id, req, ok := orderNoteParams(c)
+	if !ok {
+		return
+	}
+	d, err := h.svc.ResendConfirmation(c.Request.Context(), middleware.GetUserID(c), id, req.Note)
+	if err != nil {
+		writeAdminOrderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toAdminOrderResponse(d))
+}
+
+// orderNoteParams reads the order ID and the optional note body, writing
+// the error response when they are invalid.
+func orderNoteParams(c *gin.Context) (uuid.UUID, dto.OrderNoteRequest, bool) {
+	var req dto.OrderNoteRequest
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return id, req, false
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return id, req, false
+		}
+	}
+	return id, req, true
+}
+
+func writeAdminOrderError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+	case errors.Is(err, service.ErrInvalidOrderQuery):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrOrderTransition), errors.Is(err, service.ErrInvalidRefund):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrOrderStatusChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": "order status changed, reload and try again"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+func toAdminOrderResponse(d *service.OrderDetail) dto.AdminOrderResponse {
+	resp := dto.AdminOrderResponse{
+		OrderResponse: toOrderResponse(d.Order),
+		Events:        make([]dto.OrderEventResponse, len(d.Events)),
+		Refunds:       make([]dto.OrderRefundResponse, len(d.Refunds)),
+	}
+	for i, ev := range d.Events {
+		resp.Events[i] = dto.OrderEventResponse{
+			ID: ev.ID, Kind: ev.Kind, FromStatus: ev.FromStatus, ToStatus: ev.ToStatus, Note: ev.Note,
+			ActorID: ev.ActorID, CreatedAt: ev.CreatedAt,
+		}
+	}
+	for i, rf := range d.Refunds {
+		resp.Refunds[i] = dto.OrderRefundResponse{
+			ID: rf.ID, Amount: rf.Amount, Currency: rf.Currency, Reference: rf.Reference, Note: rf.Note,
+			ActorID: rf.ActorID, CreatedAt: rf.CreatedAt,
+		}
+	}
+	return resp
+}
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+)
+
+// parsePagination reads ?limit, ?cursor and ?include_total from the query.
+func parsePagination(c *gin.Context) (pagination.Params, error) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(pagination.DefaultLimit)))
+	if limit < 1 || limit > pagination.MaxLimit {
+		limit = pagination.DefaultLimit
+	}
+	params := pagination.Params{Limit: limit}
+	params.WithTotal, _ = strconv.ParseBool(c.Query("include_total"))
+
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := pagination.Decode(raw)
+		if err != nil {
+			return params, err
+		}
+		params.Cursor = cursor
+	}
+	return params, nil
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const mimeMergePatch = "application/merge-patch+json"
+
+var errPatchContentType = errors.New("content type must be " + mimeMergePatch)
+
+// bindMergePatch decodes a JSON Merge Patch (RFC 7396) body into req, whose
+// fields must be pointers so that absent members stay nil. Unknown members
+// are rejected. A null member is accepted only for the string fields named in
+// clearable and is bound as ""; validation then runs on the present fields.
+func bindMergePatch(c *gin.Context, req any, clearable ...string) error {
+	if ct := c.ContentType(); ct != mimeMergePatch && ct != binding.MIMEJSON {
+		return errPatchContentType
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil {
+		return errors.New("patch must be a JSON object")
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(req); err != nil {
+		return err
+	}
+
+	cleared := make(map[string]string)
+	for name, raw := range members {
+		if !bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			continue
+		}
+		if !slices.Contains(clearable, name) {
+			return fmt.Errorf("%s cannot be null", name)
+		}
+		cleared[name] = ""
+	}
+	if len(cleared) > 0 {
+		data, err := json.Marshal(cleared)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, req); err != nil {
+			return err
+		}
+	}
+	return binding.Validator.ValidateStruct(req)
+}
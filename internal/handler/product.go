@@ -3,12 +3,13 @@ package handler
 import (
 	"errors"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
 	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/middleware"
+	"github.com/flicky/go-ecommerce-api/internal/model"
 	"github.com/flicky/go-ecommerce-api/internal/service"
 )
 
@@ -26,8 +27,12 @@ func (h *ProductHandler) Create(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	resp, err := h.svc.Create(c.Request.Context(), req)
+	resp, err := h.svc.Create(c.Request.Context(), middleware.GetUserID(c), req)
 	if err != nil {
+		if errors.Is(err, service.ErrSKUAlreadyExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "sku already exists"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
@@ -40,32 +45,45 @@ func (h *ProductHandler) GetByID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	resp, err := h.svc.GetByID(c.Request.Context(), id)
+	resp, err := h.svc.GetByID(c.Request.Context(), id, false, requestCurrency(c))
 	if err != nil {
 		if errors.Is(err, service.ErrProductNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
 			return
 		}
+		if errors.Is(err, service.ErrUnsupportedCurrency) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
+	c.Header("Vary", "Accept-Currency")
+	if notModified(c, service.ProductETag(resp)) {
+		return
+	}
 	c.JSON(http.StatusOK, resp)
 }
 
 func (h *ProductHandler) List(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 || limit > 100 {
-		limit = 20
+	params, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+		return
 	}
-	resp, err := h.svc.List(c.Request.Context(), page, limit)
+	resp, err := h.svc.List(c.Request.Context(), model.ProductStatusActive, requestCurrency(c), params)
 	if err != nil {
+		if errors.Is(err, service.ErrUnsupportedCurrency) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
+	c.Header("Vary", "Accept-Currency")
+	if notModified(c, service.ProductListETag(resp)) {
+		return
+	}
 	c.JSON(http.StatusOK, resp)
 }
 
@@ -80,7 +98,71 @@ func (h *ProductHandler) Update(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	resp, err := h.svc.Update(c.Request.Context(), id, req)
+	resp, err := h.svc.Update(c.Request.Context(), id, middleware.GetUserID(c), req, c.GetHeader("If-Match"))
+	if err != nil {
+		writeProductUpdateError(c, err)
+		return
+	}
+	c.Header("ETag", service.ProductETag(resp))
+	c.JSON(http.StatusOK, resp)
+}
+
+// Patch applies a JSON Merge Patch to the catalogue fields, so clients only
+// send what they change.
+func (h *ProductHandler) Patch(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req dto.PatchProductRequest
+	if err := bindMergePatch(c, &req, "sku", "description", "category"); err != nil {
+		if errors.Is(err, errPatchContentType) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.Patch(c.Request.Context(), id, middleware.GetUserID(c), req, c.GetHeader("If-Match"))
+	if err != nil {
+		writeProductUpdateError(c, err)
+		return
+	}
+	c.Header("ETag", service.ProductETag(resp))
+	c.JSON(http.StatusOK, resp)
+}
+
+func writeProductUpdateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+	case errors.Is(err, service.ErrPreconditionFailed):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "product was modified, reload and retry"})
+	case errors.Is(err, service.ErrVersionConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "product was modified, reload and retry"})
+	case errors.Is(err, service.ErrSKUAlreadyExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "sku already exists"})
+	case errors.Is(err, service.ErrArchiveByPatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+// History lists the product's audit trail, newest first.
+func (h *ProductHandler) History(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	params, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+		return
+	}
+	resp, err := h.svc.History(c.Request.Context(), id, params)
 	if err != nil {
 		if errors.Is(err, service.ErrProductNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
@@ -99,8 +181,97 @@ func (h *ProductHandler) Delete(c *gin.Context) {
 		return
 	}
 	if err := h.svc.Delete(c.Request.Context(), id); err != nil {
+		if errors.Is(err, service.ErrProductNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
 	c.Status(http.StatusNoContent)
 }
+
+// AdminList lists products of any status, optionally filtered by ?status.
+func (h *ProductHandler) AdminList(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", model.ProductStatusDraft, model.ProductStatusActive, model.ProductStatusArchived:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+		return
+	}
+	params, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+		return
+	}
+	resp, err := h.svc.List(c.Request.Context(), status, "", params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *ProductHandler) AdminGetByID(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	resp, err := h.svc.GetByID(c.Request.Context(), id, true, "")
+	if err != nil {
+		if errors.Is(err, service.ErrProductNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	// Admins read the ETag here to send it back as If-Match on PUT.
+	c.Header("ETag", service.ProductETag(resp))
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *ProductHandler) Restore(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	resp, err := h.svc.Restore(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, service.ErrProductNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "archived product not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// SetOrderLimit sets or clears how many units of a product one order may hold.
+func (h *ProductHandler) SetOrderLimit(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req dto.OrderLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.SetOrderLimit(c.Request.Context(), id, req)
+	if err != nil {
+		if errors.Is(err, service.ErrProductNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.Header("ETag", service.ProductETag(resp))
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/service"
+)
+
+type PromotionHandler struct {
+	svc *service.PromotionService
+}
+
+func NewPromotionHandler(svc *service.PromotionService) *PromotionHandler {
+	return &PromotionHandler{svc: svc}
+}
+
+func (h *PromotionHandler) Create(c *gin.Context) {
+	var req dto.CreatePromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.Create(c.Request.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidPromotion):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrDuplicatePromotionCode):
+			c.JSON(http.StatusConflict, gin.H{"error": "promotion code already exists"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// List shows promotions newest first, with how often each has been used.
+func (h *PromotionHandler) List(c *gin.Context) {
+	params, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+		return
+	}
+	resp, err := h.svc.List(c.Request.Context(), params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// SetActive switches a promotion on or off.
+func (h *PromotionHandler) SetActive(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req dto.PromotionActiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.SetActive(c.Request.Context(), id, *req.Active)
+	if err != nil {
+		if errors.Is(err, service.ErrPromotionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "promotion not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/service"
+)
+
+// ShippingHandler lets admins manage the shipping rate table.
+type ShippingHandler struct {
+	svc *service.ShippingService
+}
+
+func NewShippingHandler(svc *service.ShippingService) *ShippingHandler {
+	return &ShippingHandler{svc: svc}
+}
+
+func (h *ShippingHandler) CreateZone(c *gin.Context) {
+	var req dto.CreateShippingZoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.CreateZone(c.Request.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrZoneCountryTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrDuplicateZoneName):
+			c.JSON(http.StatusConflict, gin.H{"error": "shipping zone name already exists"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *ShippingHandler) ListZones(c *gin.Context) {
+	resp, err := h.svc.ListZones(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteZone removes a zone together with its rates.
+func (h *ShippingHandler) DeleteZone(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := h.svc.DeleteZone(c.Request.Context(), id); err != nil {
+		if errors.Is(err, service.ErrShippingZoneNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "shipping zone not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ShippingHandler) CreateRate(c *gin.Context) {
+	var req dto.CreateShippingRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.CreateRate(c.Request.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidShippingRate):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrShippingZoneNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "shipping zone not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *ShippingHandler) ListRates(c *gin.Context) {
+	resp, err := h.svc.ListRates(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *ShippingHandler) DeleteRate(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := h.svc.DeleteRate(c.Request.Context(), id); err != nil {
+		if errors.Is(err, service.ErrShippingRateNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "shipping rate not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
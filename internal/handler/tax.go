@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/service"
+)
+
+// TaxHandler lets admins manage tax rates.
+type TaxHandler struct {
+	svc *service.TaxService
+}
+
+func NewTaxHandler(svc *service.TaxService) *TaxHandler {
+	return &TaxHandler{svc: svc}
+}
+
+func (h *TaxHandler) CreateRate(c *gin.Context) {
+	var req dto.CreateTaxRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.CreateRate(c.Request.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidTaxRate):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrDuplicateTaxRate):
+			c.JSON(http.StatusConflict, gin.H{"error": "tax rate already exists for this country, region and tax class"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *TaxHandler) ListRates(c *gin.Context) {
+	resp, err := h.svc.ListRates(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *TaxHandler) DeleteRate(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := h.svc.DeleteRate(c.Request.Context(), id); err != nil {
+		if errors.Is(err, service.ErrTaxRateNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "tax rate not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/service"
+)
+
+type WarehouseHandler struct {
+	svc *service.WarehouseService
+}
+
+func NewWarehouseHandler(svc *service.WarehouseService) *WarehouseHandler {
+	return &WarehouseHandler{svc: svc}
+}
+
+func (h *WarehouseHandler) Create(c *gin.Context) {
+	var req dto.CreateWarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.Create(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, service.ErrDuplicateWarehouseCode) {
+			c.JSON(http.StatusConflict, gin.H{"error": "warehouse code already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *WarehouseHandler) List(c *gin.Context) {
+	resp, err := h.svc.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ProductStock shows a product's stock per warehouse.
+func (h *WarehouseHandler) ProductStock(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	resp, err := h.svc.ProductStock(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, service.ErrProductNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/middleware"
+	"github.com/flicky/go-ecommerce-api/internal/service"
+)
+
+type WishlistHandler struct {
+	svc *service.WishlistService
+}
+
+func NewWishlistHandler(svc *service.WishlistService) *WishlistHandler {
+	return &WishlistHandler{svc: svc}
+}
+
+func writeWishlistError(c *gin.Context, err error) {
+	if writeQuantityError(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrWishlistNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "wishlist not found"})
+	case errors.Is(err, service.ErrWishlistItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "wishlist item not found"})
+	case errors.Is(err, service.ErrCartItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "cart item not found", "code": cartErrItemNotFound})
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "product not found", "code": cartErrProductNotFound})
+	case errors.Is(err, service.ErrCartLocked):
+		c.JSON(http.StatusConflict, gin.H{"error": "cart is locked by checkout", "code": cartErrLocked})
+	case errors.Is(err, service.ErrDuplicateWishlistName):
+		c.JSON(http.StatusConflict, gin.H{"error": "wishlist name already exists"})
+	case errors.Is(err, service.ErrInvalidWishlistName):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+func (h *WishlistHandler) Create(c *gin.Context) {
+	var req dto.CreateWishlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.Create(c.Request.Context(), middleware.GetUserID(c), req.Name)
+	if err != nil {
+		writeWishlistError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *WishlistHandler) List(c *gin.Context) {
+	resp, err := h.svc.List(c.Request.Context(), middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *WishlistHandler) Get(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	resp, err := h.svc.Get(c.Request.Context(), middleware.GetUserID(c), id)
+	if err != nil {
+		writeWishlistError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *WishlistHandler) Delete(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := h.svc.Delete(c.Request.Context(), middleware.GetUserID(c), id); err != nil {
+		writeWishlistError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *WishlistHandler) AddItem(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req dto.AddWishlistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.svc.AddItem(c.Request.Context(), middleware.GetUserID(c), id, req.ProductID)
+	if err != nil {
+		writeWishlistError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *WishlistHandler) RemoveItem(c *gin.Context) {
+	id, itemID, ok := wishlistItemParams(c)
+	if !ok {
+		return
+	}
+	if err := h.svc.RemoveItem(c.Request.Context(), middleware.GetUserID(c), id, itemID); err != nil {
+		writeWishlistError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// MoveToCart moves a wishlist item into the user's cart.
+func (h *WishlistHandler) MoveToCart(c *gin.Context) {
+	id, itemID, ok := wishlistItemParams(c)
+	if !ok {
+		return
+	}
+	var req dto.MoveToCartRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := h.svc.MoveToCart(c.Request.Context(), middleware.GetUserID(c), id, itemID, req.Quantity); err != nil {
+		writeWishlistError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "item moved to cart"})
+}
+
+// MoveFromCart moves a line of the user's cart onto one of their wishlists.
+func (h *WishlistHandler) MoveFromCart(c *gin.Context) {
+	itemID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req dto.MoveToWishlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.svc.MoveFromCart(c.Request.Context(), middleware.GetUserID(c), itemID, req.WishlistID); err != nil {
+		writeWishlistError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "item moved to wishlist"})
+}
+
+// Share turns on the wishlist's public link and returns its token.
+func (h *WishlistHandler) Share(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	resp, err := h.svc.Share(c.Request.Context(), middleware.GetUserID(c), id)
+	if err != nil {
+		writeWishlistError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *WishlistHandler) Unshare(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := h.svc.Unshare(c.Request.Context(), middleware.GetUserID(c), id); err != nil {
+		writeWishlistError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetShared serves a wishlist by its public link; no sign-in is needed.
+func (h *WishlistHandler) GetShared(c *gin.Context) {
+	resp, err := h.svc.GetShared(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		writeWishlistError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func wishlistItemParams(c *gin.Context) (id, itemID uuid.UUID, ok bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	itemID, err = uuid.Parse(c.Param("itemId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid item id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return id, itemID, true
+}
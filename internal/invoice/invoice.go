@@ -0,0 +1,305 @@
+// Package invoice lays out invoices and credit notes as A4 PDF documents.
+package invoice
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"github.com/flicky/go-ecommerce-api/internal/currency"
+	"github.com/flicky/go-ecommerce-api/internal/pdf"
+)
+
+// Party is the seller or a customer as a document addresses them.
+type Party struct {
+	Name  string
+	Lines []string
+	TaxID string
+}
+
+// Line is a line of a document. Amount is UnitPrice times Quantity and
+// TaxRate a percentage.
+type Line struct {
+	Description string
+	SKU         string
+	Quantity    int
+	UnitPrice   decimal.Decimal
+	TaxRate     decimal.Decimal
+	Amount      decimal.Decimal
+}
+
+// TaxLine is the tax at one rate. Rate is a percentage.
+type TaxLine struct {
+	Name   string
+	Rate   decimal.Decimal
+	Amount decimal.Decimal
+}
+
+// Document is an invoice or a credit note, with amounts in Currency. Its
+// totals are given rather than worked out from the lines, so it shows what
+// was charged or credited. When TaxIncluded the line amounts already
+// include Tax, which is then shown but not added.
+type Document struct {
+	Title       string
+	Number      string
+	IssuedAt    time.Time
+	References  []string
+	Seller      Party
+	BillTo      *Party
+	ShipTo      *Party
+	Currency    string
+	Lines       []Line
+	Subtotal    decimal.Decimal
+	Discount    decimal.Decimal
+	Shipping    decimal.Decimal
+	Taxes       []TaxLine
+	Tax         decimal.Decimal
+	TaxIncluded bool
+	Total       decimal.Decimal
+	Note        string
+}
+
+type fontSet struct{ regular, bold *pdf.Font }
+
+// fonts are the Go fonts, which cover Latin, Greek and Cyrillic text.
+var fonts = sync.OnceValues(func() (fontSet, error) {
+	regular, err := pdf.ParseFont("GoRegular", goregular.TTF)
+	if err != nil {
+		return fontSet{}, err
+	}
+	bold, err := pdf.ParseFont("GoBold", gobold.TTF)
+	if err != nil {
+		return fontSet{}, err
+	}
+	return fontSet{regular: regular, bold: bold}, nil
+})
+
+// Page layout, in points.
+const (
+	margin     = 50.0
+	right      = pdf.A4Width - margin
+	rowHeight  = 16.0
+	bodySize   = 9.0
+	smallSize  = 8.0
+	bottom     = 90.0 // lowest line of a table row
+	totalsRoom = 60.0 // kept below the last row on a page for the totals' first lines
+)
+
+// Table columns: where descriptions start and where the other columns end.
+const (
+	colDescription = margin
+	colQuantity    = 350.0
+	colUnitPrice   = 425.0
+	colTaxRate     = 475.0
+	colAmount      = right
+)
+
+// Render lays out the document.
+func Render(d *Document) ([]byte, error) {
+	fs, err := fonts()
+	if err != nil {
+		return nil, err
+	}
+	r := &renderer{doc: pdf.New(), fonts: fs, d: d}
+	r.header()
+	r.table()
+	r.totals()
+	r.footer()
+	return r.doc.Bytes()
+}
+
+type renderer struct {
+	doc   *pdf.Document
+	fonts fontSet
+	d     *Document
+	pages []*pdf.Page
+	page  *pdf.Page
+	y     float64
+}
+
+func (r *renderer) newPage() {
+	r.page = r.doc.AddPage()
+	r.pages = append(r.pages, r.page)
+	r.y = pdf.A4Height - margin
+}
+
+// text writes s at x on the current line; alignRight makes x its end.
+func (r *renderer) text(f *pdf.Font, size, x float64, s string, alignRight bool) {
+	if alignRight {
+		x -= f.Width(s, size)
+	}
+	r.page.Text(f, size, x, r.y, s)
+}
+
+func (r *renderer) header() {
+	d := r.d
+	r.newPage()
+	r.y -= 20
+	r.text(r.fonts.bold, 20, margin, d.Title, false)
+	r.text(r.fonts.bold, 11, right, d.Number, true)
+	r.y -= 14
+	r.text(r.fonts.regular, bodySize, right, "Date: "+d.IssuedAt.Format("2006-01-02"), true)
+	for _, ref := range d.References {
+		r.y -= 12
+		r.text(r.fonts.regular, bodySize, right, ref, true)
+	}
+
+	r.y -= 24
+	top := r.y
+	r.party("From", &d.Seller, margin)
+	low := r.y
+	if d.BillTo != nil {
+		r.y = top
+		r.party("Bill to", d.BillTo, 230)
+		low = min(low, r.y)
+	}
+	if d.ShipTo != nil {
+		r.y = top
+		r.party("Ship to", d.ShipTo, 410)
+		low = min(low, r.y)
+	}
+	r.y = low - 24
+}
+
+// party writes p under heading at x, leaving r.y on its last line.
+func (r *renderer) party(heading string, p *Party, x float64) {
+	const width = 170
+	r.text(r.fonts.bold, smallSize, x, heading, false)
+	r.y -= 13
+	r.text(r.fonts.bold, bodySize, x, fit(r.fonts.bold, bodySize, p.Name, width), false)
+	lines := p.Lines
+	if p.TaxID != "" {
+		lines = append(lines[:len(lines):len(lines)], "Tax ID: "+p.TaxID)
+	}
+	for _, l := range lines {
+		r.y -= 12
+		r.text(r.fonts.regular, bodySize, x, fit(r.fonts.regular, bodySize, l, width), false)
+	}
+}
+
+func (r *renderer) tableHeader() {
+	r.page.Fill(margin, r.y-5, right-margin, rowHeight, 0.9)
+	f := r.fonts.bold
+	r.text(f, smallSize, colDescription+4, "Description", false)
+	r.text(f, smallSize, colQuantity, "Qty", true)
+	r.text(f, smallSize, colUnitPrice, "Unit price", true)
+	r.text(f, smallSize, colTaxRate, "Tax", true)
+	r.text(f, smallSize, colAmount-4, "Amount", true)
+	r.y -= rowHeight + 2
+}
+
+func (r *renderer) table() {
+	d := r.d
+	r.tableHeader()
+	for i, l := range d.Lines {
+		// The last row keeps room for the totals under it.
+		room := bottom
+		if i == len(d.Lines)-1 {
+			room += totalsRoom
+		}
+		if r.y < room {
+			r.newPage()
+			r.tableHeader()
+		}
+		desc := l.Description
+		if l.SKU != "" {
+			desc += " (" + l.SKU + ")"
+		}
+		f := r.fonts.regular
+		r.text(f, bodySize, colDescription+4, fit(f, bodySize, desc, colQuantity-colDescription-40), false)
+		r.text(f, bodySize, colQuantity, fmt.Sprint(l.Quantity), true)
+		r.text(f, bodySize, colUnitPrice, r.amount(l.UnitPrice), true)
+		r.text(f, bodySize, colTaxRate, percent(l.TaxRate), true)
+		r.text(f, bodySize, colAmount-4, r.amount(l.Amount), true)
+		r.y -= rowHeight
+	}
+	r.page.Line(margin, r.y+rowHeight-4, right, r.y+rowHeight-4, 0.5)
+	r.y -= 6
+}
+
+func (r *renderer) totals() {
+	d := r.d
+	r.total("Subtotal", d.Subtotal, false)
+	if !d.Discount.IsZero() {
+		r.total("Discount", d.Discount.Neg(), false)
+	}
+	if !d.Shipping.IsZero() {
+		r.total("Shipping", d.Shipping, false)
+	}
+	taxes := d.Taxes
+	if len(taxes) == 0 && !d.Tax.IsZero() {
+		taxes = []TaxLine{{Name: "Tax", Amount: d.Tax}}
+	}
+	for _, t := range taxes {
+		label := t.Name
+		if !t.Rate.IsZero() {
+			label += " " + percent(t.Rate)
+		}
+		if d.TaxIncluded {
+			label = "Includes " + label
+		}
+		r.total(label, t.Amount, false)
+	}
+	r.y -= 4
+	r.total("Total", d.Total, true)
+
+	if d.Note != "" {
+		r.y -= 12
+		r.room()
+		r.text(r.fonts.regular, bodySize, margin, fit(r.fonts.regular, bodySize, d.Note, right-margin), false)
+	}
+}
+
+// total writes a line of the totals, starting a page if there is no room.
+func (r *renderer) total(label string, amount decimal.Decimal, bold bool) {
+	f := r.fonts.regular
+	if bold {
+		f = r.fonts.bold
+	}
+	r.room()
+	r.text(f, bodySize, colTaxRate-60, label, false)
+	r.text(f, bodySize, colAmount-4, r.amount(amount)+" "+r.d.Currency, true)
+	r.y -= 14
+}
+
+// room starts a page unless there is room on this one for a line of text.
+func (r *renderer) room() {
+	if r.y < bottom-30 {
+		r.newPage()
+	}
+}
+
+func (r *renderer) footer() {
+	for i, p := range r.pages {
+		label := fmt.Sprintf("%s %s, page %d of %d", r.d.Title, r.d.Number, i+1, len(r.pages))
+		f := r.fonts.regular
+		p.Text(f, smallSize, right-f.Width(label, smallSize), margin-20, label)
+	}
+}
+
+func (r *renderer) amount(v decimal.Decimal) string {
+	return v.StringFixed(currency.Decimals(r.d.Currency))
+}
+
+func percent(rate decimal.Decimal) string {
+	return rate.String() + "%"
+}
+
+// fit shortens s with an ellipsis until it is no wider than width.
+func fit(f *pdf.Font, size float64, s string, width float64) string {
+	if f.Width(s, size) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if t := string(runes) + "…"; f.Width(t, size) <= width {
+			return t
+		}
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDocument(lines int) *Document {
+	d := &Document{
+		Title: "Invoice", Number: "INV-000042", IssuedAt: time.Date(2026, 3, 9, 12, 0, 0, 0, time.UTC),
+		References: []string{"Order 4f1c"},
+		Seller:     Party{Name: "Shop Ltd", Lines: []string{"1 Market St", "Berlin 10115", "DE"}, TaxID: "DE123456789"},
+		BillTo:     &Party{Name: "Анна Петрова", Lines: []string{"ул. Ленина 1", "Москва"}},
+		Currency:   "EUR",
+		Taxes:      []TaxLine{{Name: "VAT", Rate: decimal.NewFromInt(19), Amount: decimal.RequireFromString("1.90")}},
+		Tax:        decimal.RequireFromString("1.90"),
+	}
+	for i := range lines {
+		d.Lines = append(d.Lines, Line{
+			Description: fmt.Sprintf("Product %d", i), SKU: "SKU", Quantity: 1,
+			UnitPrice: decimal.NewFromInt(10), TaxRate: decimal.NewFromInt(19), Amount: decimal.NewFromInt(10),
+		})
+		d.Subtotal = d.Subtotal.Add(decimal.NewFromInt(10))
+	}
+	d.Total = d.Subtotal.Add(d.Tax)
+	return d
+}
+
+func TestRender(t *testing.T) {
+	out, err := Render(testDocument(2))
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(out, []byte("%PDF-")))
+	assert.Contains(t, string(out), "/Count 1")
+	assert.Contains(t, string(out), "/BaseFont /GoBold")
+}
+
+func TestRender_BreaksPages(t *testing.T) {
+	out, err := Render(testDocument(80))
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "/Count 1 ")
+	assert.Equal(t, 3, strings.Count(string(out), "/Type /Page /Parent"))
+}
+
+func TestFit(t *testing.T) {
+	fs, err := fonts()
+	require.NoError(t, err)
+	assert.Equal(t, "Mug", fit(fs.regular, 9, "Mug", 100))
+
+	long := strings.Repeat("Very long product name ", 10)
+	got := fit(fs.regular, 9, long, 100)
+	assert.True(t, strings.HasSuffix(got, "…"))
+	assert.LessOrEqual(t, fs.regular.Width(got, 9), 100.0)
+}
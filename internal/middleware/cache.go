@@ -0,0 +1,11 @@
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// CacheControl sets the Cache-Control header for every response of a route.
+func CacheControl(policy string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", policy)
+		c.Next()
+	}
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,47 +19,618 @@ type User struct {
 	UpdatedAt time.Time
 }
 
+const (
+	ProductStatusDraft    = "draft"
+	ProductStatusActive   = "active"
+	ProductStatusArchived = "archived"
+)
+
 type Product struct {
 	ID          uuid.UUID
+	SKU         string
 	Name        string
 	Description string
+	Category    string
 	Price       decimal.Decimal
 	Stock       int
+	// Reserved is the part of Stock held by orders not yet processed.
+	Reserved int
+	Status   string
+	// MaxPerOrder caps the quantity of the product in one cart or order;
+	// nil means only stock limits it.
+	MaxPerOrder *int
+	// WeightGrams is the shipping weight of one unit.
+	WeightGrams int
+	TaxClass    string
+	Version     int
+	Media       []ProductMedia
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+	DeletedAt   *time.Time
+}
+
+// Available is how much of the product can still be sold.
+func (p *Product) Available() int { return p.Stock - p.Reserved }
+
+type ProductMedia struct {
+	ID           uuid.UUID
+	ProductID    uuid.UUID
+	StorageKey   string
+	ThumbnailKey string
+	URL          string
+	ThumbnailURL string
+	ContentType  string
+	SizeBytes    int64
+	Width        int
+	Height       int
+	AltText      string
+	Position     int
+	IsPrimary    bool
+	CreatedAt    time.Time
 }
 
+// Cart belongs to a user, or to a guest when UserID is uuid.Nil. Guest carts
+// expire at ExpiresAt unless used again. PromotionID is the coupon applied
+// to the cart, if any. A cart in an open checkout is locked until
+// LockedUntil.
 type Cart struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	ExpiresAt   *time.Time
+	PromotionID *uuid.UUID
+	LockedUntil *time.Time
+	Items       []CartItem
+}
+
+// Locked reports whether the cart is locked at now.
+func (c *Cart) Locked(now time.Time) bool {
+	return c.LockedUntil != nil && now.Before(*c.LockedUntil)
+}
+
+// CartItem is a product in a cart. PriceAtAdd is the unit price when it was
+// last added. Items saved for later stay in the cart but are not priced into
+// it or ordered.
+type CartItem struct {
+	ID            uuid.UUID
+	CartID        uuid.UUID
+	ProductID     uuid.UUID
+	Quantity      int
+	PriceAtAdd    decimal.Decimal
+	SavedForLater bool
+}
+
+// CartLine is a cart item together with its product's current state.
+// Stock is what the product has available, net of reservations.
+type CartLine struct {
+	CartItem
+	ProductName   string
+	Category      string
+	UnitPrice     decimal.Decimal
+	Stock         int
+	MaxPerOrder   *int
+	WeightGrams   int
+	ProductStatus string
+	TaxClass      string
+}
+
+// Wishlist is a named list of products a user wants to keep. ShareToken is
+// set while the wishlist is shared by public link.
+type Wishlist struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	ShareToken *string
+	Items      []WishlistItem
+	CreatedAt  time.Time
+}
+
+// WishlistItem is a product on a wishlist, together with the product's
+// current state.
+type WishlistItem struct {
+	ID            uuid.UUID
+	WishlistID    uuid.UUID
+	ProductID     uuid.UUID
+	ProductName   string
+	Price         decimal.Decimal
+	Stock         int
+	ProductStatus string
+	CreatedAt     time.Time
+}
+
+// PostalAddress is where an order ships or is billed to. Orders store it
+// as JSON. Country is an ISO 3166-1 alpha-2 code.
+type PostalAddress struct {
+	FullName   string `json:"full_name"`
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city"`
+	Region     string `json:"region,omitempty"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+	Phone      string `json:"phone,omitempty"`
+}
+
+// Address is an entry in a user's address book. A user has at most one
+// default shipping and one default billing address.
+type Address struct {
 	ID     uuid.UUID
 	UserID uuid.UUID
-	Items  []CartItem
+	Label  string
+	PostalAddress
+	DefaultShipping bool
+	DefaultBilling  bool
+	CreatedAt       time.Time
 }
 
-type CartItem struct {
+// Order statuses. An order is placed pending and completed once its stock
+// is taken; staff move it on from there. Refunded means refunded in full.
+const (
+	OrderPending   = "pending"
+	OrderCompleted = "completed"
+	OrderFailed    = "failed"
+	OrderShipped   = "shipped"
+	OrderDelivered = "delivered"
+	OrderCancelled = "cancelled"
+	OrderRefunded  = "refunded"
+)
+
+// Order totals are snapshotted when it is placed: TotalPrice is Subtotal
+// less Discount plus ShippingCost, plus Tax unless PricesIncludeTax.
+// PromotionCode keeps the coupon as the customer entered it
+// even if the promotion is edited later, and the addresses are copies that
+// address book edits do not touch.
+type Order struct {
+	ID               uuid.UUID
+	UserID           uuid.UUID
+	Status           string
+	Subtotal         decimal.Decimal
+	Discount         decimal.Decimal
+	TotalPrice       decimal.Decimal
+	PromotionID      *uuid.UUID
+	PromotionCode    string
+	ShippingMethod   string
+	ShippingCost     decimal.Decimal
+	Tax              decimal.Decimal
+	PricesIncludeTax bool
+	// Currency is what every amount on the order is in; ExchangeRate is
+	// what one unit of the base currency bought in it at checkout.
+	Currency        string
+	ExchangeRate    decimal.Decimal
+	ShippingAddress *PostalAddress
+	BillingAddress  *PostalAddress
+	// PaymentIntent is the payment provider's reference for the payment,
+	// for orders placed through a checkout session.
+	PaymentIntent string
+	// Refunded is how much of TotalPrice has been refunded.
+	Refunded  decimal.Decimal
+	Items     []OrderItem
+	CreatedAt time.Time
+	// Customer is who placed the order; only staff views load it.
+	Customer *OrderCustomer
+}
+
+// OrderCustomer is the account an order was placed from.
+type OrderCustomer struct {
 	ID        uuid.UUID
-	CartID    uuid.UUID
-	ProductID uuid.UUID
-	Quantity  int
+	Email     string
+	FirstName string
+	LastName  string
 }
 
-type Order struct {
+// Order event kinds.
+const (
+	OrderEventStatus = "status"
+	OrderEventRefund = "refund"
+	OrderEventResend = "resend"
+)
+
+// OrderEvent records something staff did to an order. Status events and
+// refunds that completed the refund carry the status change.
+type OrderEvent struct {
 	ID         uuid.UUID
-	UserID     uuid.UUID
-	Status     string
-	TotalPrice decimal.Decimal
-	Items      []OrderItem
+	OrderID    uuid.UUID
+	Kind       string
+	FromStatus string
+	ToStatus   string
+	Note       string
+	ActorID    *uuid.UUID
 	CreatedAt  time.Time
 }
 
-type OrderItem struct {
+// OrderRefund is money given back on an order, in the order's currency.
+// Reference is the payment provider's ID for the refund, if any.
+type OrderRefund struct {
 	ID        uuid.UUID
 	OrderID   uuid.UUID
-	ProductID uuid.UUID
-	Quantity  int
-	Price     decimal.Decimal
+	Amount    decimal.Decimal
+	Currency  string
+	Reference string
+	Note      string
+	ActorID   *uuid.UUID
+	CreatedAt time.Time
+}
+
+// Invoice kinds.
+const (
+	InvoiceKindInvoice    = "invoice"
+	InvoiceKindCreditNote = "credit_note"
+)
+
+// Invoice is an order's invoice, issued when the order completes, or a
+// credit note for a refund of an invoiced order; InvoiceID and RefundID are
+// set on credit notes. Each kind is numbered from its own gap-free
+// sequence. Total is the order's total or the refund amount, in Currency.
+// RenderedAt is when its PDF was last rendered, nil if it has not been.
+type Invoice struct {
+	ID         uuid.UUID
+	Kind       string
+	Number     int64
+	OrderID    uuid.UUID
+	InvoiceID  *uuid.UUID
+	RefundID   *uuid.UUID
+	Currency   string
+	Total      decimal.Decimal
+	RenderedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// Code is the document number as printed, such as INV-000042 or CN-000007.
+func (i *Invoice) Code() string {
+	prefix := "INV"
+	if i.Kind == InvoiceKindCreditNote {
+		prefix = "CN"
+	}
+	return fmt.Sprintf("%s-%06d", prefix, i.Number)
+}
+
+// OrderItem is one order line. WarehouseID is the warehouse it ships from,
+// set once the order is processed; a line split over several warehouses
+// becomes one item per warehouse, sharing out the line's tax. TaxRate is a
+// percentage. Product is the product as it was when the order was placed,
+// so the line keeps its meaning when the product is renamed, archived or
+// deleted; ProductID is uuid.Nil once it is deleted.
+type OrderItem struct {
+	ID          uuid.UUID
+	OrderID     uuid.UUID
+	ProductID   uuid.UUID
+	Quantity    int
+	Price       decimal.Decimal
+	TaxName     string
+	TaxRate     decimal.Decimal
+	TaxAmount   decimal.Decimal
+	WarehouseID *uuid.UUID
+	Product     ProductSnapshot
+}
+
+// ProductSnapshot is what an order line keeps of its product. ImageURL is
+// the product's primary image, or its first one when none is primary.
+type ProductSnapshot struct {
+	Name       string
+	SKU        string
+	Attributes ProductAttributes
+	ImageURL   string
+	TaxClass   string
+}
+
+// ProductAttributes are the details of a product that describe what was
+// sold rather than how it was priced.
+type ProductAttributes struct {
+	Category    string `json:"category,omitempty"`
+	WeightGrams int    `json:"weight_grams,omitempty"`
+}
+
+// Snapshot is the product as an order line keeps it, without its image.
+func (p *Product) Snapshot() ProductSnapshot {
+	return ProductSnapshot{
+		Name: p.Name, SKU: p.SKU, TaxClass: p.TaxClass,
+		Attributes: ProductAttributes{Category: p.Category, WeightGrams: p.WeightGrams},
+	}
 }
 
 type OrderMessage struct {
 	OrderID uuid.UUID `json:"order_id"`
 	UserID  uuid.UUID `json:"user_id"`
 }
+
+// Checkout session statuses. Expired is reported for open sessions past
+// their expiry and never stored.
+const (
+	CheckoutOpen      = "open"
+	CheckoutCompleted = "completed"
+	CheckoutCancelled = "cancelled"
+	CheckoutExpired   = "expired"
+)
+
+// CheckoutSession takes a user's cart to an order one step at a time. It
+// keeps the cart's lines as they were when it started, while the cart stays
+// locked, along with the choices made since and the totals they came to
+// (see Order). An open session past ExpiresAt has expired; a completed one
+// has OrderID set.
+type CheckoutSession struct {
+	ID               uuid.UUID
+	UserID           uuid.UUID
+	CartID           uuid.UUID
+	Status           string
+	Currency         string
+	Items            []CheckoutItem
+	ShippingAddress  *PostalAddress
+	BillingAddress   *PostalAddress
+	ShippingMethod   string
+	PromotionID      *uuid.UUID
+	PromotionCode    string
+	PaymentIntent    string
+	Subtotal         decimal.Decimal
+	Discount         decimal.Decimal
+	ShippingCost     decimal.Decimal
+	Tax              decimal.Decimal
+	PricesIncludeTax bool
+	Total            decimal.Decimal
+	OrderID          *uuid.UUID
+	ExpiresAt        time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// Expired reports whether the session is open but past its expiry at now.
+func (s *CheckoutSession) Expired(now time.Time) bool {
+	return s.Status == CheckoutOpen && !now.Before(s.ExpiresAt)
+}
+
+// CheckoutItem is a cart line locked into a checkout session, with its unit
+// price when the session was last priced. Sessions store them as JSON.
+type CheckoutItem struct {
+	CartItemID uuid.UUID       `json:"cart_item_id"`
+	ProductID  uuid.UUID       `json:"product_id"`
+	Quantity   int             `json:"quantity"`
+	Price      decimal.Decimal `json:"price"`
+}
+
+// Inventory movement kinds. Quantity is signed: sales are negative, returns
+// and restocks positive, adjustments and corrections either way.
+const (
+	MovementSale       = "sale"
+	MovementReturn     = "return"
+	MovementRestock    = "restock"
+	MovementAdjustment = "adjustment"
+	MovementCorrection = "correction"
+	// MovementTransfer comes in pairs (out of one warehouse, into another)
+	// that leave the product's total stock unchanged.
+	MovementTransfer = "transfer"
+)
+
+// InventoryMovement is one entry of the append-only stock ledger. The sum of
+// a product's movements equals its on-hand stock.
+type InventoryMovement struct {
+	ID          uuid.UUID
+	ProductID   uuid.UUID
+	Kind        string
+	Quantity    int
+	StockAfter  int
+	Reason      string
+	WarehouseID *uuid.UUID
+	ActorID     *uuid.UUID
+	OrderID     *uuid.UUID
+	CreatedAt   time.Time
+}
+
+type Warehouse struct {
+	ID        uuid.UUID
+	Code      string
+	Name      string
+	Priority  int
+	Active    bool
+	CreatedAt time.Time
+}
+
+// WarehouseStock is a product's stock level in one warehouse.
+type WarehouseStock struct {
+	WarehouseID   uuid.UUID
+	WarehouseCode string
+	ProductID     uuid.UUID
+	Quantity      int
+}
+
+// StockTransfer moves stock of one product between two warehouses.
+type StockTransfer struct {
+	ProductID       uuid.UUID
+	FromWarehouseID uuid.UUID
+	ToWarehouseID   uuid.UUID
+	Quantity        int
+	Reason          string
+	ActorID         *uuid.UUID
+}
+
+// Stock events are published on the product_events exchange with the type
+// as routing key.
+const (
+	EventLowStock    = "product.low_stock"
+	EventBackInStock = "product.back_in_stock"
+)
+
+// StockEvent reports that a product's stock crossed its reorder threshold
+// (low stock) or rose above zero (back in stock).
+type StockEvent struct {
+	Type      string    `json:"type"`
+	ProductID uuid.UUID `json:"product_id"`
+	Stock     int       `json:"stock"`
+	Threshold int       `json:"threshold,omitempty"`
+}
+
+// StockSubscription asks for a notification when a sold-out product is back
+// in stock. NotifiedAt is set once the notification has been sent.
+type StockSubscription struct {
+	ID         uuid.UUID
+	ProductID  uuid.UUID
+	UserID     uuid.UUID
+	CreatedAt  time.Time
+	NotifiedAt *time.Time
+}
+
+const (
+	NotificationLowStock    = "low_stock"
+	NotificationBackInStock = "back_in_stock"
+	// NotificationOrderConfirmation sums up an order for its customer.
+	// Staff resend it from the order console.
+	NotificationOrderConfirmation = "order_confirmation"
+)
+
+type Notification struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Kind      string
+	ProductID *uuid.UUID
+	OrderID   *uuid.UUID
+	Message   string
+	CreatedAt time.Time
+	ReadAt    *time.Time
+}
+
+// StockDiscrepancy is a product whose stock disagrees with its ledger.
+type StockDiscrepancy struct {
+	ProductID   uuid.UUID
+	Stock       int
+	LedgerStock int
+}
+
+const (
+	ProductAuditUpdate = "update"
+	ProductAuditImport = "import"
+)
+
+// ProductAuditEntry records one catalogue edit and the fields it changed.
+type ProductAuditEntry struct {
+	ID        uuid.UUID
+	ProductID uuid.UUID
+	ActorID   *uuid.UUID
+	Action    string
+	Changes   []FieldChange
+	CreatedAt time.Time
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+type ImportJob struct {
+	ID         uuid.UUID
+	Format     string
+	Status     string
+	Payload    []byte
+	TotalRows  int
+	Created    int
+	Updated    int
+	Failed     int
+	Errors     []ImportRowError
+	CreatedBy  uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	FinishedAt *time.Time
+}
+
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type ImportMessage struct {
+	JobID uuid.UUID `json:"job_id"`
+}
+
+// Promotion kinds. Value is a percentage for PromotionPercentage and an
+// amount off for PromotionFixed; buy-X-get-Y gives GetQuantity of every
+// BuyQuantity+GetQuantity eligible units free, cheapest first.
+const (
+	PromotionPercentage   = "percentage"
+	PromotionFixed        = "fixed"
+	PromotionFreeShipping = "free_shipping"
+	PromotionBuyXGetY     = "buy_x_get_y"
+)
+
+// Promotion is a coupon code and the discount it gives. A promotion scoped
+// to ProductIDs or Categories only discounts, and only counts towards
+// MinSubtotal, the cart lines that match one of them. Nil limits and window
+// bounds mean unlimited and open-ended.
+type Promotion struct {
+	ID                 uuid.UUID
+	Code               string
+	Description        string
+	Kind               string
+	Value              decimal.Decimal
+	BuyQuantity        int
+	GetQuantity        int
+	MinSubtotal        decimal.Decimal
+	ProductIDs         []uuid.UUID
+	Categories         []string
+	MaxUses            *int
+	MaxUsesPerCustomer *int
+	StartsAt           *time.Time
+	EndsAt             *time.Time
+	Active             bool
+	Uses               int
+	CreatedAt          time.Time
+}
+
+// Shipping methods.
+const (
+	ShippingStandard = "standard"
+	ShippingExpress  = "express"
+	ShippingPickup   = "pickup"
+)
+
+// ShippingZone groups destination countries that share shipping rates.
+type ShippingZone struct {
+	ID        uuid.UUID
+	Name      string
+	Countries []string
+	CreatedAt time.Time
+}
+
+// ShippingRate prices a shipping method for shipments within its weight and
+// subtotal bands: Price plus PricePerKg for every started kilogram. A nil
+// ZoneID applies to every destination; nil upper bounds are open-ended.
+type ShippingRate struct {
+	ID             uuid.UUID
+	Method         string
+	ZoneID         *uuid.UUID
+	MinWeightGrams int
+	MaxWeightGrams *int
+	MinSubtotal    decimal.Decimal
+	MaxSubtotal    *decimal.Decimal
+	Price          decimal.Decimal
+	PricePerKg     decimal.Decimal
+	CreatedAt      time.Time
+}
+
+// TaxClassStandard is the tax class products get by default.
+const TaxClassStandard = "standard"
+
+// TaxRate is the percentage charged on a tax class in a country, or in one
+// of its regions when Region is set.
+type TaxRate struct {
+	ID        uuid.UUID
+	Country   string
+	Region    string
+	TaxClass  string
+	Name      string
+	Rate      decimal.Decimal
+	CreatedAt time.Time
+}
+
+// ExchangeRate is how many units of Currency one unit of the base currency
+// buys.
+type ExchangeRate struct {
+	Currency  string
+	Rate      decimal.Decimal
+	UpdatedAt time.Time
+}
+
+// ProductPrice is a product's price in a currency other than the base one,
+// set by hand instead of converted at the exchange rate.
+type ProductPrice struct {
+	ProductID uuid.UUID
+	Currency  string
+	Price     decimal.Decimal
+	UpdatedAt time.Time
+}
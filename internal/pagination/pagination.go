@@ -0,0 +1,92 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor identifies a row in a list ordered by (created_at, id), newest
+// first unless the list is sorted the other way.
+// Backward marks a cursor that pages towards the start of the list
+// (prev_cursor).
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+	Backward  bool      `json:"b,omitempty"`
+}
+
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func Decode(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == uuid.Nil || c.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+type Params struct {
+	Limit     int
+	Cursor    *Cursor
+	WithTotal bool
+}
+
+// Backward reports whether the page is fetched towards newer rows.
+func (p Params) Backward() bool { return p.Cursor != nil && p.Cursor.Backward }
+
+type Page struct {
+	NextCursor string
+	PrevCursor string
+	Total      *int
+}
+
+// Build turns rows fetched with LIMIT Limit+1 in query order into the visible
+// page (always in the list's sort order, newest first unless the list sorts
+// oldest first) and the cursors around it.
+func Build[T any](rows []T, p Params, key func(T) (time.Time, uuid.UUID)) ([]T, Page) {
+	hasMore := len(rows) > p.Limit
+	if hasMore {
+		rows = rows[:p.Limit]
+	}
+	if p.Backward() {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	var page Page
+	if len(rows) == 0 {
+		return rows, page
+	}
+	hasNext, hasPrev := hasMore, p.Cursor != nil
+	if p.Backward() {
+		hasNext, hasPrev = true, hasMore
+	}
+	if hasNext {
+		t, id := key(rows[len(rows)-1])
+		page.NextCursor = Cursor{CreatedAt: t, ID: id}.Encode()
+	}
+	if hasPrev {
+		t, id := key(rows[0])
+		page.PrevCursor = Cursor{CreatedAt: t, ID: id, Backward: true}.Encode()
+	}
+	return rows, page
+}
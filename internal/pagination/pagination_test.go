@@ -0,0 +1,60 @@
+package pagination
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type row struct {
+	t  time.Time
+	id uuid.UUID
+}
+
+func rowKey(r row) (time.Time, uuid.UUID) { return r.t, r.id }
+
+func TestCursor_RoundTrip(t *testing.T) {
+	c := Cursor{CreatedAt: time.Now().UTC().Truncate(time.Microsecond), ID: uuid.New(), Backward: true}
+	decoded, err := Decode(c.Encode())
+	require.NoError(t, err)
+	assert.Equal(t, c, *decoded)
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	for _, s := range []string{"", "not-base64!", "e30"} {
+		_, err := Decode(s)
+		assert.ErrorIs(t, err, ErrInvalidCursor, s)
+	}
+}
+
+func TestBuild_Forward(t *testing.T) {
+	now := time.Now()
+	rows := []row{{now, uuid.New()}, {now.Add(-time.Second), uuid.New()}, {now.Add(-2 * time.Second), uuid.New()}}
+
+	items, page := Build(rows, Params{Limit: 2}, rowKey)
+	require.Len(t, items, 2)
+	assert.NotEmpty(t, page.NextCursor)
+	assert.Empty(t, page.PrevCursor)
+
+	next, err := Decode(page.NextCursor)
+	require.NoError(t, err)
+	assert.Equal(t, rows[1].id, next.ID)
+	assert.False(t, next.Backward)
+}
+
+func TestBuild_Backward(t *testing.T) {
+	now := time.Now()
+	// Backward pages are fetched oldest first.
+	rows := []row{{now.Add(-2 * time.Second), uuid.New()}, {now.Add(-time.Second), uuid.New()}}
+	newest := rows[1].id
+	cursor := &Cursor{CreatedAt: now.Add(-3 * time.Second), ID: uuid.New(), Backward: true}
+
+	items, page := Build(rows, Params{Limit: 2, Cursor: cursor}, rowKey)
+	require.Len(t, items, 2)
+	assert.Equal(t, newest, items[0].id)
+	assert.NotEmpty(t, page.NextCursor)
+	assert.Empty(t, page.PrevCursor)
+}
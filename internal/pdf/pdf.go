@@ -0,0 +1,305 @@
+// Package pdf writes simple PDF documents: pages of text in embedded
+// TrueType fonts, lines and shaded boxes. It is what invoices need rather
+// than a general PDF library; positions are in points from the bottom left
+// corner of the page.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// A4 page size.
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// Font is a TrueType font. Documents embed it whole and write text as its
+// glyph IDs, so text can be in any script the font covers.
+type Font struct {
+	name string
+	data []byte
+	sfnt *sfnt.Font
+	// ppem asks sfnt for sizes in font units, scaled to 1000ths of an em
+	// by scale.
+	ppem  fixed.Int26_6
+	scale float64
+	// Metrics in 1000ths of an em, as the font descriptor wants them.
+	ascent, descent, capHeight float64
+	bbox                       [4]float64
+}
+
+// ParseFont reads the TrueType font data. name is the font's PostScript
+// name in documents and must not contain spaces.
+func ParseFont(name string, data []byte) (*Font, error) {
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse font %s: %w", name, err)
+	}
+	upem := int(f.UnitsPerEm())
+	ft := &Font{name: name, data: data, sfnt: f, ppem: fixed.I(upem), scale: 1000 / float64(upem)}
+
+	var buf sfnt.Buffer
+	m, err := f.Metrics(&buf, ft.ppem, font.HintingNone)
+	if err != nil {
+		return nil, fmt.Errorf("read font %s metrics: %w", name, err)
+	}
+	b, err := f.Bounds(&buf, ft.ppem, font.HintingNone)
+	if err != nil {
+		return nil, fmt.Errorf("read font %s bounds: %w", name, err)
+	}
+	// sfnt measures down from the baseline; PDF measures up.
+	ft.ascent, ft.descent, ft.capHeight = ft.units(m.Ascent), -ft.units(m.Descent), ft.units(m.CapHeight)
+	ft.bbox = [4]float64{ft.units(b.Min.X), -ft.units(b.Max.Y), ft.units(b.Max.X), -ft.units(b.Min.Y)}
+	return ft, nil
+}
+
+func (f *Font) units(v fixed.Int26_6) float64 { return float64(v) / 64 * f.scale }
+
+// Width is how wide s is at size, in points.
+func (f *Font) Width(s string, size float64) float64 {
+	var w float64
+	for _, g := range f.glyphs(s) {
+		w += g.width
+	}
+	return w * size / 1000
+}
+
+// glyph is a glyph of a font with the rune it stands for and its advance in
+// 1000ths of an em.
+type glyph struct {
+	id    sfnt.GlyphIndex
+	r     rune
+	width float64
+}
+
+// glyphs maps s to the font's glyphs. Runes the font lacks become its
+// .notdef glyph.
+func (f *Font) glyphs(s string) []glyph {
+	var buf sfnt.Buffer
+	gs := make([]glyph, 0, len(s))
+	for _, r := range s {
+		id, err := f.sfnt.GlyphIndex(&buf, r)
+		if err != nil {
+			id = 0
+		}
+		adv, err := f.sfnt.GlyphAdvance(&buf, id, f.ppem, font.HintingNone)
+		if err != nil {
+			adv = 0
+		}
+		gs = append(gs, glyph{id: id, r: r, width: f.units(adv)})
+	}
+	return gs
+}
+
+// Document is a PDF being put together page by page.
+type Document struct {
+	pages []*Page
+	fonts []*Font
+	// used holds the glyphs written in each font, for its widths and
+	// ToUnicode map.
+	used map[*Font]map[sfnt.GlyphIndex]glyph
+}
+
+func New() *Document {
+	return &Document{used: make(map[*Font]map[sfnt.GlyphIndex]glyph)}
+}
+
+// Page is an A4 page of a document.
+type Page struct {
+	doc     *Document
+	content bytes.Buffer
+}
+
+// AddPage adds an empty page to the end of the document.
+func (d *Document) AddPage() *Page {
+	p := &Page{doc: d}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// fontRef registers f with the document and returns its resource name.
+func (d *Document) fontRef(f *Font) string {
+	i := slices.Index(d.fonts, f)
+	if i < 0 {
+		i = len(d.fonts)
+		d.fonts = append(d.fonts, f)
+		d.used[f] = make(map[sfnt.GlyphIndex]glyph)
+	}
+	return fmt.Sprintf("F%d", i+1)
+}
+
+// Text writes s in f at size with its baseline starting at x, y.
+func (p *Page) Text(f *Font, size, x, y float64, s string) {
+	if s == "" {
+		return
+	}
+	name := p.doc.fontRef(f)
+	used := p.doc.used[f]
+	var hex strings.Builder
+	for _, g := range f.glyphs(s) {
+		used[g.id] = g
+		fmt.Fprintf(&hex, "%04X", uint16(g.id))
+	}
+	fmt.Fprintf(&p.content, "BT /%s %s Tf 1 0 0 1 %s %s Tm <%s> Tj ET\n", name, num(size), num(x), num(y), hex.String())
+}
+
+// Line draws a black line width points thick.
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s m %s %s l S\n", num(width), num(x1), num(y1), num(x2), num(y2))
+}
+
+// Fill paints a box with its bottom left corner at x, y in gray, from 0
+// for black to 1 for white.
+func (p *Page) Fill(x, y, w, h, gray float64) {
+	fmt.Fprintf(&p.content, "%s g %s %s %s %s re f 0 g\n", num(gray), num(x), num(y), num(w), num(h))
+}
+
+func num(v float64) string {
+	s := strings.TrimRight(fmt.Sprintf("%.2f", v), "0")
+	s = strings.TrimSuffix(s, ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// Objects per embedded font: the Type0 font, its CID font, font
+// descriptor, font file and ToUnicode map.
+const objectsPerFont = 5
+
+// Bytes writes out the document. Objects are numbered catalog, page tree,
+// fonts, then each page followed by its content.
+func (d *Document) Bytes() ([]byte, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	w := &writer{}
+	w.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	firstPage := 3 + objectsPerFont*len(d.fonts)
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	w.object("<< /Type /Catalog /Pages 2 0 R >>")
+	w.object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	var resources strings.Builder
+	for i, f := range d.fonts {
+		ref := 3 + objectsPerFont*i
+		fmt.Fprintf(&resources, "/F%d %d 0 R ", i+1, ref)
+		if err := d.writeFont(w, f, ref); err != nil {
+			return nil, err
+		}
+	}
+	for i, p := range d.pages {
+		ref := firstPage + 2*i
+		w.object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << %s>> >> /Contents %d 0 R >>",
+			num(A4Width), num(A4Height), resources.String(), ref+1))
+		if err := w.stream("", p.content.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	return w.finish(), nil
+}
+
+func (d *Document) writeFont(w *writer, f *Font, ref int) error {
+	used := d.used[f]
+	ids := make([]sfnt.GlyphIndex, 0, len(used))
+	for id := range used {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	var widths strings.Builder
+	for _, id := range ids {
+		fmt.Fprintf(&widths, "%d [%s] ", id, num(used[id].width))
+	}
+	w.object(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		f.name, ref+1, ref+4))
+	w.object(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /CIDToGIDMap /Identity /W [%s] >>",
+		f.name, ref+2, widths.String()))
+	w.object(fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%s %s %s %s] /ItalicAngle 0 /Ascent %s /Descent %s /CapHeight %s /StemV 80 /FontFile2 %d 0 R >>",
+		f.name, num(f.bbox[0]), num(f.bbox[1]), num(f.bbox[2]), num(f.bbox[3]), num(f.ascent), num(f.descent), num(f.capHeight), ref+3))
+	if err := w.stream(fmt.Sprintf("/Length1 %d", len(f.data)), f.data); err != nil {
+		return err
+	}
+	return w.stream("", toUnicode(ids, used))
+}
+
+// toUnicode is the CMap that maps the glyphs back to text, for copying
+// and searching.
+func toUnicode(ids []sfnt.GlyphIndex, used map[sfnt.GlyphIndex]glyph) []byte {
+	var b bytes.Buffer
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	// A bfchar block holds at most 100 entries.
+	for chunk := range slices.Chunk(ids, 100) {
+		fmt.Fprintf(&b, "%d beginbfchar\n", len(chunk))
+		for _, id := range chunk {
+			fmt.Fprintf(&b, "<%04X> <", uint16(id))
+			for _, u := range utf16.Encode([]rune{used[id].r}) {
+				fmt.Fprintf(&b, "%04X", u)
+			}
+			b.WriteString(">\n")
+		}
+		b.WriteString("endbfchar\n")
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.Bytes()
+}
+
+// writer numbers objects in the order they are written and keeps their
+// offsets for the cross-reference table.
+type writer struct {
+	bytes.Buffer
+	offsets []int
+}
+
+func (w *writer) object(body string) {
+	w.offsets = append(w.offsets, w.Len())
+	fmt.Fprintf(w, "%d 0 obj\n%s\nendobj\n", len(w.offsets), body)
+}
+
+// stream writes data compressed as a stream object, with extra entries
+// for its dictionary.
+func (w *writer) stream(extra string, data []byte) error {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	if _, err := zw.Write(data); err != nil {
+		return fmt.Errorf("compress stream: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("compress stream: %w", err)
+	}
+	if extra != "" {
+		extra += " "
+	}
+	w.offsets = append(w.offsets, w.Len())
+	fmt.Fprintf(w, "%d 0 obj\n<< %s/Length %d /Filter /FlateDecode >>\nstream\n", len(w.offsets), extra, z.Len())
+	w.Write(z.Bytes())
+	w.WriteString("\nendstream\nendobj\n")
+	return nil
+}
+
+func (w *writer) finish() []byte {
+	xref := w.Len()
+	fmt.Fprintf(w, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, off := range w.offsets {
+		fmt.Fprintf(w, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(w, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets)+1, xref)
+	return w.Bytes()
+}
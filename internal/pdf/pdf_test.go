@@ -0,0 +1,89 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func goRegular(t *testing.T) *Font {
+	t.Helper()
+	f, err := ParseFont("GoRegular", goregular.TTF)
+	require.NoError(t, err)
+	return f
+}
+
+func TestFont_Width(t *testing.T) {
+	f := goRegular(t)
+	assert.Zero(t, f.Width("", 10))
+	assert.Positive(t, f.Width("a", 10))
+	assert.InDelta(t, f.Width("a", 10)+f.Width("b", 10), f.Width("ab", 10), 1e-9)
+	assert.InDelta(t, 2*f.Width("Invoice", 10), f.Width("Invoice", 20), 1e-9)
+}
+
+func TestDocument_Bytes(t *testing.T) {
+	f := goRegular(t)
+	doc := New()
+	p := doc.AddPage()
+	p.Text(f, 12, 50, 800, "Invoice")
+	p.Line(50, 790, 545, 790, 0.5)
+	doc.AddPage().Text(f, 10, 50, 800, "Счёт")
+
+	out, err := doc.Bytes()
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(out, []byte("%PDF-1.7\n")))
+	assert.True(t, bytes.HasSuffix(out, []byte("%%EOF\n")))
+	assert.Contains(t, string(out), "/Count 2")
+
+	// Every cross-reference entry points at its object.
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(out)
+	require.NotNil(t, m)
+	xref, err := strconv.Atoi(string(m[1]))
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(out[xref:], []byte("xref\n")))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(out[xref:], -1)
+	require.NotEmpty(t, entries)
+	for i, e := range entries {
+		off, err := strconv.Atoi(string(e[1]))
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(out[off:], fmt.Appendf(nil, "%d 0 obj\n", i+1)), "object %d", i+1)
+	}
+
+	// The text can be read back through the ToUnicode map.
+	var cmaps string
+	for _, s := range streams(t, out) {
+		cmaps += s
+	}
+	assert.Contains(t, cmaps, "<0421>") // С
+	assert.Contains(t, cmaps, "<0049>") // I
+}
+
+func TestDocument_BytesEmpty(t *testing.T) {
+	out, err := New().Bytes()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "/Count 1")
+}
+
+// streams inflates the document's streams.
+func streams(t *testing.T, out []byte) []string {
+	t.Helper()
+	var res []string
+	for _, m := range regexp.MustCompile(`(?s)/Length (\d+) /Filter /FlateDecode >>\nstream\n`).FindAllSubmatchIndex(out, -1) {
+		n, err := strconv.Atoi(string(out[m[2]:m[3]]))
+		require.NoError(t, err)
+		r, err := zlib.NewReader(bytes.NewReader(out[m[1] : m[1]+n]))
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		res = append(res, string(data))
+	}
+	return res
+}
@@ -0,0 +1,120 @@
+// Package promotion works out what a coupon takes off a cart.
+package promotion
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+var (
+	ErrInactive      = errors.New("promotion is not active")
+	ErrNotStarted    = errors.New("promotion has not started")
+	ErrExpired       = errors.New("promotion has expired")
+	ErrNotApplicable = errors.New("promotion does not apply to the cart")
+	ErrMinSubtotal   = errors.New("cart is below the promotion's minimum spend")
+)
+
+// Line is one cart line a promotion is evaluated against.
+type Line struct {
+	ProductID uuid.UUID
+	Category  string
+	UnitPrice decimal.Decimal
+	Quantity  int
+}
+
+// Result is what a promotion gives the cart.
+type Result struct {
+	Discount     decimal.Decimal
+	FreeShipping bool
+}
+
+// Apply evaluates p against the cart lines at time now. The discount is
+// rounded to cents and never exceeds the total of the lines it covers.
+func Apply(p *model.Promotion, lines []Line, now time.Time) (Result, error) {
+	if !p.Active {
+		return Result{}, ErrInactive
+	}
+	if p.StartsAt != nil && now.Before(*p.StartsAt) {
+		return Result{}, ErrNotStarted
+	}
+	if p.EndsAt != nil && !now.Before(*p.EndsAt) {
+		return Result{}, ErrExpired
+	}
+
+	eligible := eligibleLines(p, lines)
+	if len(eligible) == 0 {
+		return Result{}, ErrNotApplicable
+	}
+	var subtotal decimal.Decimal
+	for _, l := range eligible {
+		subtotal = subtotal.Add(l.UnitPrice.Mul(decimal.NewFromInt(int64(l.Quantity))))
+	}
+	if subtotal.LessThan(p.MinSubtotal) {
+		return Result{}, ErrMinSubtotal
+	}
+
+	var discount decimal.Decimal
+	switch p.Kind {
+	case model.PromotionPercentage:
+		discount = subtotal.Mul(p.Value).Div(decimal.NewFromInt(100)).Round(2)
+	case model.PromotionFixed:
+		discount = p.Value
+	case model.PromotionFreeShipping:
+		return Result{FreeShipping: true}, nil
+	case model.PromotionBuyXGetY:
+		discount = freeUnits(eligible, p.BuyQuantity, p.GetQuantity)
+		if discount.IsZero() {
+			return Result{}, ErrNotApplicable
+		}
+	default:
+		return Result{}, fmt.Errorf("unknown promotion kind %q", p.Kind)
+	}
+	return Result{Discount: decimal.Min(discount, subtotal)}, nil
+}
+
+// eligibleLines returns the lines p covers: all of them for an unscoped
+// promotion, otherwise those matching one of its products or categories.
+func eligibleLines(p *model.Promotion, lines []Line) []Line {
+	if len(p.ProductIDs) == 0 && len(p.Categories) == 0 {
+		return lines
+	}
+	var out []Line
+	for _, l := range lines {
+		if slices.Contains(p.ProductIDs, l.ProductID) || (l.Category != "" && slices.Contains(p.Categories, l.Category)) {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// freeUnits prices the units buy-X-get-Y gives away: get of every buy+get
+// units, taking the cheapest units first.
+func freeUnits(lines []Line, buy, get int) decimal.Decimal {
+	if buy < 1 || get < 1 {
+		return decimal.Zero
+	}
+	units := 0
+	for _, l := range lines {
+		units += l.Quantity
+	}
+	free := units / (buy + get) * get
+
+	byPrice := slices.Clone(lines)
+	slices.SortStableFunc(byPrice, func(a, b Line) int { return a.UnitPrice.Cmp(b.UnitPrice) })
+	var total decimal.Decimal
+	for _, l := range byPrice {
+		n := min(free, l.Quantity)
+		total = total.Add(l.UnitPrice.Mul(decimal.NewFromInt(int64(n))))
+		if free -= n; free == 0 {
+			break
+		}
+	}
+	return total
+}
@@ -0,0 +1,112 @@
+package promotion
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+func TestApply(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	yesterday, tomorrow := now.AddDate(0, 0, -1), now.AddDate(0, 0, 1)
+	mug, lamp, sock := uuid.New(), uuid.New(), uuid.New()
+	d := decimal.RequireFromString
+
+	// 2 mugs at 10.00, 1 lamp at 45.50, 3 socks at 3.00: 74.50 in total.
+	lines := []Line{
+		{mug, "kitchen", d("10.00"), 2},
+		{lamp, "home", d("45.50"), 1},
+		{sock, "apparel", d("3.00"), 3},
+	}
+
+	tests := []struct {
+		name    string
+		promo   model.Promotion
+		want    Result
+		wantErr error
+	}{
+		{
+			name:  "percentage of the whole cart, rounded to cents",
+			promo: model.Promotion{Active: true, Kind: model.PromotionPercentage, Value: d("15")},
+			want:  Result{Discount: d("11.18")},
+		},
+		{
+			name:  "percentage scoped to a category and a product",
+			promo: model.Promotion{Active: true, Kind: model.PromotionPercentage, Value: d("50"), Categories: []string{"apparel"}, ProductIDs: []uuid.UUID{mug}},
+			want:  Result{Discount: d("14.50")},
+		},
+		{
+			name:  "fixed amount",
+			promo: model.Promotion{Active: true, Kind: model.PromotionFixed, Value: d("5")},
+			want:  Result{Discount: d("5")},
+		},
+		{
+			name:  "fixed amount capped at the lines it covers",
+			promo: model.Promotion{Active: true, Kind: model.PromotionFixed, Value: d("20"), ProductIDs: []uuid.UUID{sock}},
+			want:  Result{Discount: d("9.00")},
+		},
+		{
+			name:  "free shipping",
+			promo: model.Promotion{Active: true, Kind: model.PromotionFreeShipping},
+			want:  Result{FreeShipping: true},
+		},
+		{
+			name:  "buy two get one gives the cheapest units away",
+			promo: model.Promotion{Active: true, Kind: model.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1},
+			want:  Result{Discount: d("6.00")},
+		},
+		{
+			name:    "buy-x-get-y without enough units",
+			promo:   model.Promotion{Active: true, Kind: model.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1, ProductIDs: []uuid.UUID{mug}},
+			wantErr: ErrNotApplicable,
+		},
+		{
+			name:  "minimum spend met",
+			promo: model.Promotion{Active: true, Kind: model.PromotionFixed, Value: d("5"), MinSubtotal: d("74.50")},
+			want:  Result{Discount: d("5")},
+		},
+		{
+			name:    "minimum spend counts only covered lines",
+			promo:   model.Promotion{Active: true, Kind: model.PromotionFixed, Value: d("5"), MinSubtotal: d("30"), Categories: []string{"kitchen"}},
+			wantErr: ErrMinSubtotal,
+		},
+		{
+			name:    "no line in scope",
+			promo:   model.Promotion{Active: true, Kind: model.PromotionPercentage, Value: d("10"), Categories: []string{"garden"}},
+			wantErr: ErrNotApplicable,
+		},
+		{
+			name:    "inactive",
+			promo:   model.Promotion{Kind: model.PromotionFixed, Value: d("5")},
+			wantErr: ErrInactive,
+		},
+		{
+			name:    "not started",
+			promo:   model.Promotion{Active: true, Kind: model.PromotionFixed, Value: d("5"), StartsAt: &tomorrow},
+			wantErr: ErrNotStarted,
+		},
+		{
+			name:    "expired",
+			promo:   model.Promotion{Active: true, Kind: model.PromotionFixed, Value: d("5"), StartsAt: &yesterday, EndsAt: &now},
+			wantErr: ErrExpired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(&tt.promo, lines, now)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tt.want.Discount.Equal(got.Discount), "discount %s, want %s", got.Discount, tt.want.Discount)
+			assert.Equal(t, tt.want.FreeShipping, got.FreeShipping)
+		})
+	}
+}
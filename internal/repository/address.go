@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+type AddressRepository interface {
+	Create(ctx context.Context, a *model.Address) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Address, error)
+	ListByUserID(ctx context.Context, userID uuid.UUID) ([]model.Address, error)
+	Update(ctx context.Context, a *model.Address) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type pgAddressRepo struct{ pool *pgxpool.Pool }
+
+func NewAddressRepository(pool *pgxpool.Pool) AddressRepository {
+	return &pgAddressRepo{pool: pool}
+}
+
+const addressColumns = `id, user_id, label, full_name, line1, line2, city, region, postal_code, country, phone,
+	is_default_shipping, is_default_billing, created_at`
+
+func scanAddress(row pgx.Row, a *model.Address) error {
+	return row.Scan(&a.ID, &a.UserID, &a.Label, &a.FullName, &a.Line1, &a.Line2, &a.City, &a.Region,
+		&a.PostalCode, &a.Country, &a.Phone, &a.DefaultShipping, &a.DefaultBilling, &a.CreatedAt)
+}
+
+// Create adds an address; the user's first becomes their default.
+func (r *pgAddressRepo) Create(ctx context.Context, a *model.Address) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	var hasShipping, hasBilling bool
+	err = tx.QueryRow(ctx,
+		`SELECT COALESCE(bool_or(is_default_shipping), false), COALESCE(bool_or(is_default_billing), false)
+		 FROM addresses WHERE user_id = $1`, a.UserID,
+	).Scan(&hasShipping, &hasBilling)
+	if err != nil {
+		return fmt.Errorf("get default addresses: %w", err)
+	}
+	a.DefaultShipping = a.DefaultShipping || !hasShipping
+	a.DefaultBilling = a.DefaultBilling || !hasBilling
+	if err := clearDefaultAddresses(ctx, tx, a); err != nil {
+		return err
+	}
+
+	a.ID = uuid.New()
+	err = tx.QueryRow(ctx,
+		`INSERT INTO addresses (id, user_id, label, full_name, line1, line2, city, region, postal_code, country, phone,
+		   is_default_shipping, is_default_billing, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NOW(), NOW()) RETURNING created_at`,
+		a.ID, a.UserID, a.Label, a.FullName, a.Line1, a.Line2, a.City, a.Region, a.PostalCode, a.Country, a.Phone,
+		a.DefaultShipping, a.DefaultBilling,
+	).Scan(&a.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert address: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// clearDefaultAddresses unsets the user's other defaults of the kinds a is
+// about to become.
+func clearDefaultAddresses(ctx context.Context, tx pgx.Tx, a *model.Address) error {
+	_, err := tx.Exec(ctx,
+		`UPDATE addresses SET is_default_shipping = is_default_shipping AND NOT $3,
+		   is_default_billing = is_default_billing AND NOT $4, updated_at = NOW()
+		 WHERE user_id = $1 AND id <> $2 AND ((is_default_shipping AND $3) OR (is_default_billing AND $4))`,
+		a.UserID, a.ID, a.DefaultShipping, a.DefaultBilling,
+	)
+	if err != nil {
+		return fmt.Errorf("clear default addresses: %w", err)
+	}
+	return nil
+}
+
+func (r *pgAddressRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.Address, error) {
+	a := &model.Address{}
+	err := scanAddress(r.pool.QueryRow(ctx, `SELECT `+addressColumns+` FROM addresses WHERE id = $1`, id), a)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get address: %w", err)
+	}
+	return a, nil
+}
+
+// ListByUserID returns the user's address book, oldest first.
+func (r *pgAddressRepo) ListByUserID(ctx context.Context, userID uuid.UUID) ([]model.Address, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+addressColumns+` FROM addresses WHERE user_id = $1 ORDER BY created_at, id`, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list addresses: %w", err)
+	}
+	defer rows.Close()
+
+	var addresses []model.Address
+	for rows.Next() {
+		var a model.Address
+		if err := scanAddress(rows, &a); err != nil {
+			return nil, fmt.Errorf("scan address: %w", err)
+		}
+		addresses = append(addresses, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate addresses: %w", err)
+	}
+	return addresses, nil
+}
+
+// Update replaces the address. Making it a default replaces the old one.
+func (r *pgAddressRepo) Update(ctx context.Context, a *model.Address) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	if err := clearDefaultAddresses(ctx, tx, a); err != nil {
+		return err
+	}
+	ct, err := tx.Exec(ctx,
+		`UPDATE addresses SET label = $2, full_name = $3, line1 = $4, line2 = $5, city = $6, region = $7,
+		   postal_code = $8, country = $9, phone = $10, is_default_shipping = $11, is_default_billing = $12,
+		   updated_at = NOW()
+		 WHERE id = $1`,
+		a.ID, a.Label, a.FullName, a.Line1, a.Line2, a.City, a.Region, a.PostalCode, a.Country, a.Phone,
+		a.DefaultShipping, a.DefaultBilling,
+	)
+	if err != nil {
+		return fmt.Errorf("update address: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *pgAddressRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	ct, err := r.pool.Exec(ctx, `DELETE FROM addresses WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete address: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -15,10 +16,17 @@ import (
 type CartRepository interface {
 	GetOrCreateCart(ctx context.Context, userID uuid.UUID) (*model.Cart, error)
 	GetCartWithItems(ctx context.Context, cartID uuid.UUID) (*model.Cart, error)
+	GetCartLines(ctx context.Context, cartID uuid.UUID) ([]model.CartLine, error)
 	AddItem(ctx context.Context, item *model.CartItem) error
 	UpdateItem(ctx context.Context, item *model.CartItem) error
 	DeleteItem(ctx context.Context, itemID uuid.UUID) error
+	SetSavedForLater(ctx context.Context, itemID uuid.UUID, saved bool) error
 	ClearCart(ctx context.Context, cartID uuid.UUID) error
+	SetPromotion(ctx context.Context, cartID uuid.UUID, promotionID *uuid.UUID) error
+	CreateGuestCart(ctx context.Context, ttl time.Duration) (*model.Cart, error)
+	GetGuestCart(ctx context.Context, cartID uuid.UUID, ttl time.Duration) (*model.Cart, error)
+	MergeCarts(ctx context.Context, guestCartID, userCartID uuid.UUID) error
+	DeleteExpiredGuestCarts(ctx context.Context) (int, error)
 }
 
 type pgCartRepo struct{ pool *pgxpool.Pool }
@@ -29,7 +37,9 @@ func NewCartRepository(pool *pgxpool.Pool) CartRepository {
 
 func (r *pgCartRepo) GetOrCreateCart(ctx context.Context, userID uuid.UUID) (*model.Cart, error) {
 	cart := &model.Cart{UserID: userID}
-	err := r.pool.QueryRow(ctx, `SELECT id FROM carts WHERE user_id = $1`, userID).Scan(&cart.ID)
+	err := r.pool.QueryRow(ctx,
+		`SELECT id, promotion_id, locked_until FROM carts WHERE user_id = $1`, userID,
+	).Scan(&cart.ID, &cart.PromotionID, &cart.LockedUntil)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			cart.ID = uuid.New()
@@ -49,16 +59,22 @@ func (r *pgCartRepo) GetOrCreateCart(ctx context.Context, userID uuid.UUID) (*mo
 
 func (r *pgCartRepo) GetCartWithItems(ctx context.Context, cartID uuid.UUID) (*model.Cart, error) {
 	cart := &model.Cart{ID: cartID}
-	err := r.pool.QueryRow(ctx, `SELECT user_id FROM carts WHERE id = $1`, cartID).Scan(&cart.UserID)
+	var userID *uuid.UUID
+	err := r.pool.QueryRow(ctx,
+		`SELECT user_id, expires_at, promotion_id, locked_until FROM carts WHERE id = $1`, cartID,
+	).Scan(&userID, &cart.ExpiresAt, &cart.PromotionID, &cart.LockedUntil)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("get cart: %w", err)
 	}
+	if userID != nil {
+		cart.UserID = *userID
+	}
 
 	rows, err := r.pool.Query(ctx,
-		`SELECT id, product_id, quantity FROM cart_items WHERE cart_id = $1`, cartID,
+		`SELECT id, product_id, quantity, price_at_add, saved_for_later FROM cart_items WHERE cart_id = $1`, cartID,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("get cart items: %w", err)
@@ -67,7 +83,7 @@ func (r *pgCartRepo) GetCartWithItems(ctx context.Context, cartID uuid.UUID) (*m
 
 	for rows.Next() {
 		var item model.CartItem
-		if err := rows.Scan(&item.ID, &item.ProductID, &item.Quantity); err != nil {
+		if err := rows.Scan(&item.ID, &item.ProductID, &item.Quantity, &item.PriceAtAdd, &item.SavedForLater); err != nil {
 			return nil, fmt.Errorf("scan cart item: %w", err)
 		}
 		item.CartID = cartID
@@ -79,14 +95,47 @@ func (r *pgCartRepo) GetCartWithItems(ctx context.Context, cartID uuid.UUID) (*m
 	return cart, nil
 }
 
+// GetCartLines returns the cart's items joined with their products, in the
+// order they were added.
+func (r *pgCartRepo) GetCartLines(ctx context.Context, cartID uuid.UUID) ([]model.CartLine, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT ci.id, ci.product_id, ci.quantity, ci.price_at_add, ci.saved_for_later, p.name, COALESCE(p.category, ''),
+		   p.price, p.stock - p.reserved, p.max_per_order, p.weight_grams, p.status, p.tax_class
+		 FROM cart_items ci JOIN products p ON p.id = ci.product_id
+		 WHERE ci.cart_id = $1
+		 ORDER BY ci.created_at, ci.id`, cartID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get cart lines: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []model.CartLine
+	for rows.Next() {
+		l := model.CartLine{CartItem: model.CartItem{CartID: cartID}}
+		if err := rows.Scan(&l.ID, &l.ProductID, &l.Quantity, &l.PriceAtAdd, &l.SavedForLater,
+			&l.ProductName, &l.Category, &l.UnitPrice, &l.Stock, &l.MaxPerOrder, &l.WeightGrams, &l.ProductStatus,
+			&l.TaxClass); err != nil {
+			return nil, fmt.Errorf("scan cart line: %w", err)
+		}
+		lines = append(lines, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate cart lines: %w", err)
+	}
+	return lines, nil
+}
+
+// addCartItemSQL adds $4 of a product to a cart, refreshing its price snapshot.
+const addCartItemSQL = `INSERT INTO cart_items (id, cart_id, product_id, quantity, price_at_add, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+	ON CONFLICT (cart_id, product_id)
+	DO UPDATE SET quantity = cart_items.quantity + $4, price_at_add = EXCLUDED.price_at_add,
+	  saved_for_later = false, updated_at = NOW()`
+
 func (r *pgCartRepo) AddItem(ctx context.Context, item *model.CartItem) error {
 	item.ID = uuid.New()
-	_, err := r.pool.Exec(ctx,
-		`INSERT INTO cart_items (id, cart_id, product_id, quantity, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, NOW(), NOW())
-		 ON CONFLICT (cart_id, product_id) DO UPDATE SET quantity = cart_items.quantity + $4, updated_at = NOW()`,
-		item.ID, item.CartID, item.ProductID, item.Quantity,
-	)
+	_, err := r.pool.Exec(ctx, addCartItemSQL, item.ID, item.CartID, item.ProductID, item.Quantity, item.PriceAtAdd)
 	if err != nil {
 		return fmt.Errorf("add cart item: %w", err)
 	}
@@ -112,10 +161,129 @@ func (r *pgCartRepo) DeleteItem(ctx context.Context, itemID uuid.UUID) error {
 	return nil
 }
 
+// SetSavedForLater moves a cart item out of the active cart, or back into it.
+func (r *pgCartRepo) SetSavedForLater(ctx context.Context, itemID uuid.UUID, saved bool) error {
+	ct, err := r.pool.Exec(ctx,
+		`UPDATE cart_items SET saved_for_later = $2, updated_at = NOW() WHERE id = $1`, itemID, saved,
+	)
+	if err != nil {
+		return fmt.Errorf("set cart item saved for later: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ClearCart empties the active cart; items saved for later are kept.
 func (r *pgCartRepo) ClearCart(ctx context.Context, cartID uuid.UUID) error {
-	_, err := r.pool.Exec(ctx, `DELETE FROM cart_items WHERE cart_id = $1`, cartID)
+	_, err := r.pool.Exec(ctx, `DELETE FROM cart_items WHERE cart_id = $1 AND NOT saved_for_later`, cartID)
 	if err != nil {
 		return fmt.Errorf("clear cart: %w", err)
 	}
 	return nil
 }
+
+// SetPromotion applies a coupon to the cart; nil removes it.
+func (r *pgCartRepo) SetPromotion(ctx context.Context, cartID uuid.UUID, promotionID *uuid.UUID) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE carts SET promotion_id = $2, updated_at = NOW() WHERE id = $1`, cartID, promotionID,
+	)
+	if err != nil {
+		return fmt.Errorf("set cart promotion: %w", err)
+	}
+	return nil
+}
+
+func (r *pgCartRepo) CreateGuestCart(ctx context.Context, ttl time.Duration) (*model.Cart, error) {
+	cart := &model.Cart{ID: uuid.New()}
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO carts (id, expires_at, created_at, updated_at) VALUES ($1, NOW() + $2::interval, NOW(), NOW())
+		 RETURNING expires_at`,
+		cart.ID, ttl,
+	).Scan(&cart.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("create guest cart: %w", err)
+	}
+	return cart, nil
+}
+
+// GetGuestCart returns an unexpired guest cart and extends its expiry by
+// ttl, or nil when there is no such cart.
+func (r *pgCartRepo) GetGuestCart(ctx context.Context, cartID uuid.UUID, ttl time.Duration) (*model.Cart, error) {
+	cart := &model.Cart{ID: cartID}
+	err := r.pool.QueryRow(ctx,
+		`UPDATE carts SET expires_at = NOW() + $2::interval, updated_at = NOW()
+		 WHERE id = $1 AND user_id IS NULL AND expires_at > NOW()
+		 RETURNING expires_at, promotion_id`,
+		cartID, ttl,
+	).Scan(&cart.ExpiresAt, &cart.PromotionID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get guest cart: %w", err)
+	}
+	return cart, nil
+}
+
+// MergeCarts moves a guest cart's available items into a user's cart, within
+// stock and limits, and deletes the guest cart.
+func (r *pgCartRepo) MergeCarts(ctx context.Context, guestCartID, userCartID uuid.UUID) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	var promotionID *uuid.UUID
+	err = tx.QueryRow(ctx,
+		`SELECT promotion_id FROM carts WHERE id = $1 AND user_id IS NULL AND expires_at > NOW() FOR UPDATE`, guestCartID,
+	).Scan(&promotionID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("lock guest cart: %w", err)
+	}
+	// LEAST ignores NULLs, so a product without a per-order limit is capped
+	// by its stock alone.
+	_, err = tx.Exec(ctx,
+		`INSERT INTO cart_items (id, cart_id, product_id, quantity, price_at_add, saved_for_later, created_at, updated_at)
+		 SELECT gen_random_uuid(), $2, ci.product_id,
+		   LEAST(ci.quantity, p.stock - p.reserved, p.max_per_order),
+		   ci.price_at_add, ci.saved_for_later, NOW(), NOW()
+		 FROM cart_items ci JOIN products p ON p.id = ci.product_id
+		 WHERE ci.cart_id = $1 AND p.status = 'active' AND p.deleted_at IS NULL AND p.stock > p.reserved
+		 ON CONFLICT (cart_id, product_id)
+		 DO UPDATE SET quantity = (
+		     SELECT LEAST(GREATEST(cart_items.quantity, EXCLUDED.quantity), p.stock - p.reserved, p.max_per_order)
+		     FROM products p WHERE p.id = EXCLUDED.product_id),
+		   saved_for_later = cart_items.saved_for_later AND EXCLUDED.saved_for_later, updated_at = NOW()`,
+		guestCartID, userCartID,
+	)
+	if err != nil {
+		return fmt.Errorf("merge cart items: %w", err)
+	}
+	if promotionID != nil {
+		_, err = tx.Exec(ctx,
+			`UPDATE carts SET promotion_id = COALESCE(promotion_id, $2), updated_at = NOW() WHERE id = $1`,
+			userCartID, promotionID,
+		)
+		if err != nil {
+			return fmt.Errorf("merge cart promotion: %w", err)
+		}
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM carts WHERE id = $1`, guestCartID); err != nil {
+		return fmt.Errorf("delete guest cart: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *pgCartRepo) DeleteExpiredGuestCarts(ctx context.Context) (int, error) {
+	ct, err := r.pool.Exec(ctx, `DELETE FROM carts WHERE user_id IS NULL AND expires_at <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired guest carts: %w", err)
+	}
+	return int(ct.RowsAffected()), nil
+}
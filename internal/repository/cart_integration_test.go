@@ -0,0 +1,61 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+func TestCartRepository_MergeCarts_Integration(t *testing.T) {
+	pool := setupTestDB(t)
+	ctx := context.Background()
+	products := NewProductRepository(pool)
+	repo := NewCartRepository(pool)
+
+	user := &model.User{Email: uuid.NewString() + "@example.com", Password: "x", Role: "customer"}
+	require.NoError(t, NewUserRepository(pool).Create(ctx, user))
+
+	newProduct := func(stock int) *model.Product {
+		p := &model.Product{Name: "Merge test", Price: decimal.NewFromInt(10), Stock: stock, Status: model.ProductStatusActive}
+		require.NoError(t, products.Create(ctx, p, nil))
+		return p
+	}
+	unlimited, limited, scarce := newProduct(50), newProduct(50), newProduct(3)
+	require.NoError(t, products.SetMaxPerOrder(ctx, limited.ID, ptr(2)))
+
+	userCart, err := repo.GetOrCreateCart(ctx, user.ID)
+	require.NoError(t, err)
+	guestCart, err := repo.CreateGuestCart(ctx, time.Hour)
+	require.NoError(t, err)
+	add := func(cartID uuid.UUID, p *model.Product, quantity int) {
+		item := &model.CartItem{CartID: cartID, ProductID: p.ID, Quantity: quantity, PriceAtAdd: p.Price}
+		require.NoError(t, repo.AddItem(ctx, item))
+	}
+	add(userCart.ID, unlimited, 1)
+	add(guestCart.ID, unlimited, 5)
+	add(userCart.ID, limited, 1)
+	add(guestCart.ID, limited, 5)
+	add(guestCart.ID, scarce, 5)
+
+	require.NoError(t, repo.MergeCarts(ctx, guestCart.ID, userCart.ID))
+
+	merged, err := repo.GetCartWithItems(ctx, userCart.ID)
+	require.NoError(t, err)
+	quantities := make(map[uuid.UUID]int)
+	for _, item := range merged.Items {
+		quantities[item.ProductID] = item.Quantity
+	}
+	// The larger guest quantity wins, within the per-order limit and stock.
+	assert.Equal(t, map[uuid.UUID]int{unlimited.ID: 5, limited.ID: 2, scarce.ID: 3}, quantities)
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+// ErrCheckoutClosed means the checkout session is no longer open: it has
+// been completed or cancelled, or has expired.
+var ErrCheckoutClosed = errors.New("checkout session closed")
+
+type CheckoutRepository interface {
+	Create(ctx context.Context, s *model.CheckoutSession, ttl time.Duration) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.CheckoutSession, error)
+	Update(ctx context.Context, s *model.CheckoutSession) error
+	Cancel(ctx context.Context, id uuid.UUID) error
+	Complete(ctx context.Context, s *model.CheckoutSession, order *model.Order) error
+}
+
+type pgCheckoutRepo struct{ pool *pgxpool.Pool }
+
+func NewCheckoutRepository(pool *pgxpool.Pool) CheckoutRepository {
+	return &pgCheckoutRepo{pool: pool}
+}
+
+const checkoutColumns = `id, user_id, cart_id, status, currency, items, shipping_address, billing_address,
+	COALESCE(shipping_method, ''), promotion_id, COALESCE(promotion_code, ''), COALESCE(payment_intent, ''),
+	subtotal, discount, shipping_cost, tax, prices_include_tax, total, order_id, expires_at, created_at, updated_at`
+
+func scanCheckout(row pgx.Row, s *model.CheckoutSession) error {
+	return row.Scan(&s.ID, &s.UserID, &s.CartID, &s.Status, &s.Currency, &s.Items, &s.ShippingAddress,
+		&s.BillingAddress, &s.ShippingMethod, &s.PromotionID, &s.PromotionCode, &s.PaymentIntent, &s.Subtotal,
+		&s.Discount, &s.ShippingCost, &s.Tax, &s.PricesIncludeTax, &s.Total, &s.OrderID, &s.ExpiresAt, &s.CreatedAt,
+		&s.UpdatedAt)
+}
+
+// Create opens a session that expires after ttl and locks its cart until
+// then. Any other open session for the cart is cancelled.
+func (r *pgCheckoutRepo) Create(ctx context.Context, s *model.CheckoutSession, ttl time.Duration) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	_, err = tx.Exec(ctx,
+		`UPDATE checkout_sessions SET status = 'cancelled', updated_at = NOW() WHERE cart_id = $1 AND status = 'open'`,
+		s.CartID,
+	)
+	if err != nil {
+		return fmt.Errorf("cancel open checkout: %w", err)
+	}
+	s.ID = uuid.New()
+	s.Status = model.CheckoutOpen
+	err = tx.QueryRow(ctx,
+		`INSERT INTO checkout_sessions (id, user_id, cart_id, status, currency, items, shipping_address, billing_address,
+		   shipping_method, promotion_id, promotion_code, payment_intent, subtotal, discount, shipping_cost, tax,
+		   prices_include_tax, total, expires_at, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, NULLIF($11, ''), NULLIF($12, ''), $13, $14, $15,
+		   $16, $17, $18, NOW() + $19::interval, NOW(), NOW())
+		 RETURNING expires_at, created_at, updated_at`,
+		s.ID, s.UserID, s.CartID, s.Status, s.Currency, s.Items, s.ShippingAddress, s.BillingAddress,
+		s.ShippingMethod, s.PromotionID, s.PromotionCode, s.PaymentIntent, s.Subtotal, s.Discount, s.ShippingCost,
+		s.Tax, s.PricesIncludeTax, s.Total, ttl,
+	).Scan(&s.ExpiresAt, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("insert checkout: %w", err)
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE carts SET locked_until = $2, updated_at = NOW() WHERE id = $1`, s.CartID, s.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("lock cart: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *pgCheckoutRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.CheckoutSession, error) {
+	s := &model.CheckoutSession{}
+	err := scanCheckout(r.pool.QueryRow(ctx, `SELECT `+checkoutColumns+` FROM checkout_sessions WHERE id = $1`, id), s)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get checkout: %w", err)
+	}
+	return s, nil
+}
+
+// Update saves the session's choices and totals. It fails with
+// ErrCheckoutClosed unless the session is open and unexpired.
+func (r *pgCheckoutRepo) Update(ctx context.Context, s *model.CheckoutSession) error {
+	err := r.pool.QueryRow(ctx,
+		`UPDATE checkout_sessions SET items = $2, shipping_address = $3, billing_address = $4,
+		   shipping_method = NULLIF($5, ''), promotion_id = $6, promotion_code = NULLIF($7, ''),
+		   payment_intent = NULLIF($8, ''), subtotal = $9, discount = $10, shipping_cost = $11, tax = $12,
+		   prices_include_tax = $13, total = $14, updated_at = NOW()
+		 WHERE id = $1 AND status = 'open' AND expires_at > NOW()
+		 RETURNING updated_at`,
+		s.ID, s.Items, s.ShippingAddress, s.BillingAddress, s.ShippingMethod, s.PromotionID, s.PromotionCode,
+		s.PaymentIntent, s.Subtotal, s.Discount, s.ShippingCost, s.Tax, s.PricesIncludeTax, s.Total,
+	).Scan(&s.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrCheckoutClosed
+		}
+		return fmt.Errorf("update checkout: %w", err)
+	}
+	return nil
+}
+
+// Cancel ends an open session, expired or not, and unlocks its cart.
+func (r *pgCheckoutRepo) Cancel(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	var cartID uuid.UUID
+	err = tx.QueryRow(ctx,
+		`UPDATE checkout_sessions SET status = 'cancelled', updated_at = NOW() WHERE id = $1 AND status = 'open'
+		 RETURNING cart_id`, id,
+	).Scan(&cartID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrCheckoutClosed
+		}
+		return fmt.Errorf("cancel checkout: %w", err)
+	}
+	if err := unlockCart(ctx, tx, cartID, false); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Complete places order for the session and closes it in one transaction.
+func (r *pgCheckoutRepo) Complete(ctx context.Context, s *model.CheckoutSession, order *model.Order) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	var open bool
+	err = tx.QueryRow(ctx,
+		`SELECT status = 'open' AND expires_at > NOW() FROM checkout_sessions WHERE id = $1 FOR UPDATE`, s.ID,
+	).Scan(&open)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrCheckoutClosed
+		}
+		return fmt.Errorf("lock checkout: %w", err)
+	}
+	if !open {
+		return ErrCheckoutClosed
+	}
+	if err := insertOrder(ctx, tx, order); err != nil {
+		return err
+	}
+	err = tx.QueryRow(ctx,
+		`UPDATE checkout_sessions SET status = 'completed', order_id = $2, updated_at = NOW() WHERE id = $1
+		 RETURNING updated_at`, s.ID, order.ID,
+	).Scan(&s.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("complete checkout: %w", err)
+	}
+	itemIDs := make([]uuid.UUID, len(s.Items))
+	for i, item := range s.Items {
+		itemIDs[i] = item.CartItemID
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM cart_items WHERE cart_id = $1 AND id = ANY($2)`, s.CartID, itemIDs); err != nil {
+		return fmt.Errorf("clear cart: %w", err)
+	}
+	if err := unlockCart(ctx, tx, s.CartID, order.PromotionID != nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit checkout: %w", err)
+	}
+	s.Status, s.OrderID = model.CheckoutCompleted, &order.ID
+	return nil
+}
+
+// unlockCart unlocks a cart after checkout, taking its coupon off too with
+// clearPromotion.
+func unlockCart(ctx context.Context, tx pgx.Tx, cartID uuid.UUID, clearPromotion bool) error {
+	_, err := tx.Exec(ctx,
+		`UPDATE carts SET locked_until = NULL, promotion_id = CASE WHEN $2 THEN NULL ELSE promotion_id END,
+		   updated_at = NOW()
+		 WHERE id = $1`, cartID, clearPromotion,
+	)
+	if err != nil {
+		return fmt.Errorf("unlock cart: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+// CurrencyRepository stores exchange rates and product price lists. It
+// satisfies currency.Source.
+type CurrencyRepository interface {
+	// SetRate creates or replaces the rate for r.Currency.
+	SetRate(ctx context.Context, r *model.ExchangeRate) error
+	GetRate(ctx context.Context, code string) (*model.ExchangeRate, error)
+	ListRates(ctx context.Context) ([]model.ExchangeRate, error)
+	DeleteRate(ctx context.Context, code string) error
+	// SetPrice creates or replaces a product's price in p.Currency. It
+	// returns ErrNotFound if the product does not exist.
+	SetPrice(ctx context.Context, p *model.ProductPrice) error
+	ListPrices(ctx context.Context, productID uuid.UUID) ([]model.ProductPrice, error)
+	DeletePrice(ctx context.Context, productID uuid.UUID, code string) error
+	PricesFor(ctx context.Context, code string, productIDs []uuid.UUID) (map[uuid.UUID]decimal.Decimal, error)
+}
+
+type pgCurrencyRepo struct{ pool *pgxpool.Pool }
+
+func NewCurrencyRepository(pool *pgxpool.Pool) CurrencyRepository {
+	return &pgCurrencyRepo{pool: pool}
+}
+
+func (r *pgCurrencyRepo) SetRate(ctx context.Context, er *model.ExchangeRate) error {
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO exchange_rates (currency, rate, updated_at) VALUES ($1, $2, NOW())
+		 ON CONFLICT (currency) DO UPDATE SET rate = EXCLUDED.rate, updated_at = NOW()
+		 RETURNING updated_at`,
+		er.Currency, er.Rate,
+	).Scan(&er.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("upsert exchange rate: %w", err)
+	}
+	return nil
+}
+
+func (r *pgCurrencyRepo) GetRate(ctx context.Context, code string) (*model.ExchangeRate, error) {
+	er := &model.ExchangeRate{}
+	err := r.pool.QueryRow(ctx,
+		`SELECT currency, rate, updated_at FROM exchange_rates WHERE currency = $1`, code,
+	).Scan(&er.Currency, &er.Rate, &er.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get exchange rate: %w", err)
+	}
+	return er, nil
+}
+
+func (r *pgCurrencyRepo) ListRates(ctx context.Context) ([]model.ExchangeRate, error) {
+	rows, err := r.pool.Query(ctx, `SELECT currency, rate, updated_at FROM exchange_rates ORDER BY currency`)
+	if err != nil {
+		return nil, fmt.Errorf("list exchange rates: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []model.ExchangeRate
+	for rows.Next() {
+		var er model.ExchangeRate
+		if err := rows.Scan(&er.Currency, &er.Rate, &er.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan exchange rate: %w", err)
+		}
+		rates = append(rates, er)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate exchange rates: %w", err)
+	}
+	return rates, nil
+}
+
+func (r *pgCurrencyRepo) DeleteRate(ctx context.Context, code string) error {
+	ct, err := r.pool.Exec(ctx, `DELETE FROM exchange_rates WHERE currency = $1`, code)
+	if err != nil {
+		return fmt.Errorf("delete exchange rate: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgCurrencyRepo) SetPrice(ctx context.Context, p *model.ProductPrice) error {
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO product_prices (product_id, currency, price, updated_at) VALUES ($1, $2, $3, NOW())
+		 ON CONFLICT (product_id, currency) DO UPDATE SET price = EXCLUDED.price, updated_at = NOW()
+		 RETURNING updated_at`,
+		p.ProductID, p.Currency, p.Price,
+	).Scan(&p.UpdatedAt)
+	if err != nil {
+		if isForeignKeyViolation(err, "product_prices_product_id_fkey") {
+			return ErrNotFound
+		}
+		return fmt.Errorf("upsert product price: %w", err)
+	}
+	return nil
+}
+
+func (r *pgCurrencyRepo) ListPrices(ctx context.Context, productID uuid.UUID) ([]model.ProductPrice, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT product_id, currency, price, updated_at FROM product_prices WHERE product_id = $1 ORDER BY currency`,
+		productID)
+	if err != nil {
+		return nil, fmt.Errorf("list product prices: %w", err)
+	}
+	defer rows.Close()
+
+	var prices []model.ProductPrice
+	for rows.Next() {
+		var p model.ProductPrice
+		if err := rows.Scan(&p.ProductID, &p.Currency, &p.Price, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan product price: %w", err)
+		}
+		prices = append(prices, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate product prices: %w", err)
+	}
+	return prices, nil
+}
+
+func (r *pgCurrencyRepo) DeletePrice(ctx context.Context, productID uuid.UUID, code string) error {
+	ct, err := r.pool.Exec(ctx, `DELETE FROM product_prices WHERE product_id = $1 AND currency = $2`, productID, code)
+	if err != nil {
+		return fmt.Errorf("delete product price: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgCurrencyRepo) PricesFor(ctx context.Context, code string, productIDs []uuid.UUID) (map[uuid.UUID]decimal.Decimal, error) {
+	prices := make(map[uuid.UUID]decimal.Decimal)
+	if len(productIDs) == 0 {
+		return prices, nil
+	}
+	rows, err := r.pool.Query(ctx,
+		`SELECT product_id, price FROM product_prices WHERE currency = $1 AND product_id = ANY($2)`, code, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("get product prices: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id uuid.UUID
+		var price decimal.Decimal
+		if err := rows.Scan(&id, &price); err != nil {
+			return nil, fmt.Errorf("scan product price: %w", err)
+		}
+		prices[id] = price
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate product prices: %w", err)
+	}
+	return prices, nil
+}
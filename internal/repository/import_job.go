@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+type ImportJobRepository interface {
+	Create(ctx context.Context, job *model.ImportJob) error
+	GetByID(ctx context.Context, id uuid.UUID, withPayload bool) (*model.ImportJob, error)
+	UpdateProgress(ctx context.Context, job *model.ImportJob) error
+}
+
+type pgImportJobRepo struct{ pool *pgxpool.Pool }
+
+func NewImportJobRepository(pool *pgxpool.Pool) ImportJobRepository {
+	return &pgImportJobRepo{pool: pool}
+}
+
+func (r *pgImportJobRepo) Create(ctx context.Context, job *model.ImportJob) error {
+	job.ID = uuid.New()
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO import_jobs (id, format, status, payload, created_by, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, NOW(), NOW()) RETURNING created_at, updated_at`,
+		job.ID, job.Format, job.Status, job.Payload, job.CreatedBy,
+	).Scan(&job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("create import job: %w", err)
+	}
+	return nil
+}
+
+func (r *pgImportJobRepo) GetByID(ctx context.Context, id uuid.UUID, withPayload bool) (*model.ImportJob, error) {
+	payload := "NULL"
+	if withPayload {
+		payload = "payload"
+	}
+	job := &model.ImportJob{}
+	var errs []byte
+	err := r.pool.QueryRow(ctx,
+		`SELECT id, format, status, `+payload+`, total_rows, created_rows, updated_rows, failed_rows, errors,
+		 created_by, created_at, updated_at, finished_at FROM import_jobs WHERE id = $1`, id,
+	).Scan(&job.ID, &job.Format, &job.Status, &job.Payload, &job.TotalRows, &job.Created, &job.Updated,
+		&job.Failed, &errs, &job.CreatedBy, &job.CreatedAt, &job.UpdatedAt, &job.FinishedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get import job: %w", err)
+	}
+	if err := json.Unmarshal(errs, &job.Errors); err != nil {
+		return nil, fmt.Errorf("decode import errors: %w", err)
+	}
+	return job, nil
+}
+
+// UpdateProgress stores counters and row errors. The payload is dropped once
+// the job reaches a terminal state.
+func (r *pgImportJobRepo) UpdateProgress(ctx context.Context, job *model.ImportJob) error {
+	rowErrors := job.Errors
+	if rowErrors == nil {
+		rowErrors = []model.ImportRowError{}
+	}
+	errs, err := json.Marshal(rowErrors)
+	if err != nil {
+		return fmt.Errorf("encode import errors: %w", err)
+	}
+	err = r.pool.QueryRow(ctx,
+		`UPDATE import_jobs SET status = $2, total_rows = $3, created_rows = $4, updated_rows = $5,
+		 failed_rows = $6, errors = $7, finished_at = $8, updated_at = NOW(),
+		 payload = CASE WHEN $8::timestamptz IS NULL THEN payload END
+		 WHERE id = $1 RETURNING updated_at`,
+		job.ID, job.Status, job.TotalRows, job.Created, job.Updated, job.Failed, errs, job.FinishedAt,
+	).Scan(&job.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("update import job: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+)
+
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrWarehouseNotFound = errors.New("warehouse not found")
+)
+
+type InventoryRepository interface {
+	Adjust(ctx context.Context, m *model.InventoryMovement) error
+	Transfer(ctx context.Context, t *model.StockTransfer) ([]model.InventoryMovement, error)
+	ListByProductID(ctx context.Context, productID uuid.UUID, p pagination.Params) ([]model.InventoryMovement, pagination.Page, error)
+	Discrepancies(ctx context.Context) ([]model.StockDiscrepancy, error)
+	Reconcile(ctx context.Context, actorID *uuid.UUID) ([]model.StockDiscrepancy, error)
+}
+
+type pgInventoryRepo struct{ pool *pgxpool.Pool }
+
+func NewInventoryRepository(pool *pgxpool.Pool) InventoryRepository {
+	return &pgInventoryRepo{pool: pool}
+}
+
+// insertMovement appends m to the ledger. It must run in the transaction
+// that changed products.stock so the two never drift apart.
+func insertMovement(ctx context.Context, tx pgx.Tx, m *model.InventoryMovement) error {
+	m.ID = uuid.New()
+	err := tx.QueryRow(ctx,
+		`INSERT INTO inventory_movements (id, product_id, kind, quantity, stock_after, reason, warehouse_id, actor_id, order_id, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW()) RETURNING created_at`,
+		m.ID, m.ProductID, m.Kind, m.Quantity, m.StockAfter, m.Reason, m.WarehouseID, m.ActorID, m.OrderID,
+	).Scan(&m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert inventory movement: %w", err)
+	}
+	return nil
+}
+
+// defaultWarehouse is where stock without an explicit warehouse is booked:
+// the active warehouse with the lowest priority.
+func defaultWarehouse(ctx context.Context, tx pgx.Tx) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := tx.QueryRow(ctx,
+		`SELECT id FROM warehouses WHERE active ORDER BY priority, code LIMIT 1`,
+	).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, ErrWarehouseNotFound
+		}
+		return uuid.Nil, fmt.Errorf("get default warehouse: %w", err)
+	}
+	return id, nil
+}
+
+// resolveWarehouse returns *id after checking it is an active warehouse, or
+// the default warehouse when id is nil.
+func resolveWarehouse(ctx context.Context, tx pgx.Tx, id *uuid.UUID) (uuid.UUID, error) {
+	if id == nil {
+		return defaultWarehouse(ctx, tx)
+	}
+	var active bool
+	err := tx.QueryRow(ctx, `SELECT active FROM warehouses WHERE id = $1`, *id).Scan(&active)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !active) {
+		return uuid.Nil, ErrWarehouseNotFound
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("get warehouse: %w", err)
+	}
+	return *id, nil
+}
+
+// changeWarehouseStock adds delta to a product's stock in one warehouse.
+func changeWarehouseStock(ctx context.Context, tx pgx.Tx, warehouseID, productID uuid.UUID, delta int) error {
+	if delta >= 0 {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO warehouse_stock (warehouse_id, product_id, quantity, updated_at) VALUES ($1, $2, $3, NOW())
+			 ON CONFLICT (warehouse_id, product_id)
+			 DO UPDATE SET quantity = warehouse_stock.quantity + EXCLUDED.quantity, updated_at = NOW()`,
+			warehouseID, productID, delta,
+		)
+		if err != nil {
+			return fmt.Errorf("increase warehouse stock: %w", err)
+		}
+		return nil
+	}
+	ct, err := tx.Exec(ctx,
+		`UPDATE warehouse_stock SET quantity = quantity + $3, updated_at = NOW()
+		 WHERE warehouse_id = $1 AND product_id = $2 AND quantity + $3 >= 0`,
+		warehouseID, productID, delta,
+	)
+	if err != nil {
+		return fmt.Errorf("decrease warehouse stock: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrInsufficientStock
+	}
+	return nil
+}
+
+// Adjust applies m.Quantity to the stock in m.WarehouseID and records it.
+func (r *pgInventoryRepo) Adjust(ctx context.Context, m *model.InventoryMovement) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	err = tx.QueryRow(ctx,
+		`UPDATE products SET stock = stock + $2, updated_at = NOW()
+		 WHERE id = $1 AND stock + $2 >= 0 RETURNING stock`, m.ProductID, m.Quantity,
+	).Scan(&m.StockAfter)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("adjust stock: %w", err)
+		}
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM products WHERE id = $1)`, m.ProductID).Scan(&exists); err != nil {
+			return fmt.Errorf("adjust stock: %w", err)
+		}
+		if !exists {
+			return ErrNotFound
+		}
+		return ErrInsufficientStock
+	}
+	warehouseID, err := resolveWarehouse(ctx, tx, m.WarehouseID)
+	if err != nil {
+		return err
+	}
+	m.WarehouseID = &warehouseID
+	if err := changeWarehouseStock(ctx, tx, warehouseID, m.ProductID, m.Quantity); err != nil {
+		return err
+	}
+	if err := insertMovement(ctx, tx, m); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Transfer moves stock between two warehouses. The product's total stock is
+// unchanged; the ledger gets a matching pair of transfer movements.
+func (r *pgInventoryRepo) Transfer(ctx context.Context, t *model.StockTransfer) ([]model.InventoryMovement, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	var stock int
+	err = tx.QueryRow(ctx, `SELECT stock FROM products WHERE id = $1 FOR UPDATE`, t.ProductID).Scan(&stock)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get product stock: %w", err)
+	}
+	for _, id := range []uuid.UUID{t.FromWarehouseID, t.ToWarehouseID} {
+		if _, err := resolveWarehouse(ctx, tx, &id); err != nil {
+			return nil, err
+		}
+	}
+	if err := changeWarehouseStock(ctx, tx, t.FromWarehouseID, t.ProductID, -t.Quantity); err != nil {
+		return nil, err
+	}
+	if err := changeWarehouseStock(ctx, tx, t.ToWarehouseID, t.ProductID, t.Quantity); err != nil {
+		return nil, err
+	}
+
+	movements := []model.InventoryMovement{
+		{WarehouseID: &t.FromWarehouseID, Quantity: -t.Quantity},
+		{WarehouseID: &t.ToWarehouseID, Quantity: t.Quantity},
+	}
+	for i := range movements {
+		m := &movements[i]
+		m.ProductID, m.Kind, m.StockAfter, m.Reason, m.ActorID = t.ProductID, model.MovementTransfer, stock, t.Reason, t.ActorID
+		if err := insertMovement(ctx, tx, m); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit transfer: %w", err)
+	}
+	return movements, nil
+}
+
+func (r *pgInventoryRepo) ListByProductID(ctx context.Context, productID uuid.UUID, p pagination.Params) ([]model.InventoryMovement, pagination.Page, error) {
+	cond, tail, args := keyset(p, "", []any{productID})
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, product_id, kind, quantity, stock_after, reason, warehouse_id, actor_id, order_id, created_at
+		 FROM inventory_movements `+whereClause([]string{"product_id = $1", cond})+` `+tail, args...,
+	)
+	if err != nil {
+		return nil, pagination.Page{}, fmt.Errorf("list inventory movements: %w", err)
+	}
+	defer rows.Close()
+
+	var movements []model.InventoryMovement
+	for rows.Next() {
+		var m model.InventoryMovement
+		if err := rows.Scan(&m.ID, &m.ProductID, &m.Kind, &m.Quantity, &m.StockAfter, &m.Reason,
+			&m.WarehouseID, &m.ActorID, &m.OrderID, &m.CreatedAt); err != nil {
+			return nil, pagination.Page{}, fmt.Errorf("scan inventory movement: %w", err)
+		}
+		movements = append(movements, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, pagination.Page{}, fmt.Errorf("iterate inventory movements: %w", err)
+	}
+	movements, page := pagination.Build(movements, p, movementKey)
+	return movements, page, nil
+}
+
+func movementKey(m model.InventoryMovement) (time.Time, uuid.UUID) { return m.CreatedAt, m.ID }
+
+const discrepancyQuery = `SELECT p.id, p.stock, l.total
+	FROM products p,
+	     LATERAL (SELECT COALESCE(SUM(quantity), 0)::int AS total
+	              FROM inventory_movements WHERE product_id = p.id) l
+	WHERE p.stock <> l.total
+	ORDER BY p.id`
+
+// Discrepancies lists products whose stock differs from the sum of their
+// ledger movements.
+func (r *pgInventoryRepo) Discrepancies(ctx context.Context) ([]model.StockDiscrepancy, error) {
+	return queryDiscrepancies(ctx, r.pool, discrepancyQuery)
+}
+
+// Reconcile records a correction for every ledger discrepancy.
+func (r *pgInventoryRepo) Reconcile(ctx context.Context, actorID *uuid.UUID) ([]model.StockDiscrepancy, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	// Locking the products keeps sales from slipping in between the check
+	// and the corrections.
+	found, err := queryDiscrepancies(ctx, tx, discrepancyQuery+` FOR UPDATE OF p`)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range found {
+		err := insertMovement(ctx, tx, &model.InventoryMovement{
+			ProductID: d.ProductID, Kind: model.MovementCorrection, Quantity: d.Stock - d.LedgerStock,
+			StockAfter: d.Stock, Reason: "reconciliation", ActorID: actorID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit reconciliation: %w", err)
+	}
+	return found, nil
+}
+
+func queryDiscrepancies(ctx context.Context, q querier, sql string) ([]model.StockDiscrepancy, error) {
+	rows, err := q.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("query stock discrepancies: %w", err)
+	}
+	defer rows.Close()
+
+	var found []model.StockDiscrepancy
+	for rows.Next() {
+		var d model.StockDiscrepancy
+		if err := rows.Scan(&d.ProductID, &d.Stock, &d.LedgerStock); err != nil {
+			return nil, fmt.Errorf("scan stock discrepancy: %w", err)
+		}
+		found = append(found, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate stock discrepancies: %w", err)
+	}
+	return found, nil
+}
@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+)
+
+// InvoiceFilter narrows the invoice list to an order's documents or to one
+// kind.
+type InvoiceFilter struct {
+	OrderID *uuid.UUID
+	Kind    string
+}
+
+// InvoiceRepository keeps invoices and credit notes.
+type InvoiceRepository interface {
+	Issue(ctx context.Context, orderID uuid.UUID) (*model.Invoice, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Invoice, error)
+	// GetByOrderID returns the order's invoice, not its credit notes.
+	GetByOrderID(ctx context.Context, orderID uuid.UUID) (*model.Invoice, error)
+	List(ctx context.Context, f InvoiceFilter, p pagination.Params) ([]model.Invoice, pagination.Page, error)
+	GetPDF(ctx context.Context, id uuid.UUID) ([]byte, error)
+	SavePDF(ctx context.Context, id uuid.UUID, pdf []byte) (time.Time, error)
+}
+
+type pgInvoiceRepo struct{ pool *pgxpool.Pool }
+
+func NewInvoiceRepository(pool *pgxpool.Pool) InvoiceRepository {
+	return &pgInvoiceRepo{pool: pool}
+}
+
+const invoiceColumns = `id, kind, number, order_id, invoice_id, refund_id, COALESCE(currency, ''), total, rendered_at, created_at`
+
+func scanInvoice(row pgx.Row, inv *model.Invoice) error {
+	return row.Scan(&inv.ID, &inv.Kind, &inv.Number, &inv.OrderID, &inv.InvoiceID, &inv.RefundID, &inv.Currency,
+		&inv.Total, &inv.RenderedAt, &inv.CreatedAt)
+}
+
+// Issue issues the order's invoice unless it has one, and returns it.
+func (r *pgInvoiceRepo) Issue(ctx context.Context, orderID uuid.UUID) (*model.Invoice, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	if _, _, err := lockOrder(ctx, tx, orderID); err != nil {
+		return nil, err
+	}
+	inv, err := issueInvoice(ctx, tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit invoice: %w", err)
+	}
+	return inv, nil
+}
+
+func (r *pgInvoiceRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.Invoice, error) {
+	return getInvoice(ctx, r.pool, `id = $1`, id)
+}
+
+func (r *pgInvoiceRepo) GetByOrderID(ctx context.Context, orderID uuid.UUID) (*model.Invoice, error) {
+	return getInvoice(ctx, r.pool, `order_id = $1 AND kind = 'invoice'`, orderID)
+}
+
+func getInvoice(ctx context.Context, q querier, cond string, arg any) (*model.Invoice, error) {
+	inv := &model.Invoice{}
+	err := scanInvoice(q.QueryRow(ctx, `SELECT `+invoiceColumns+` FROM invoices WHERE `+cond, arg), inv)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get invoice: %w", err)
+	}
+	return inv, nil
+}
+
+// List pages through the documents newest first.
+func (r *pgInvoiceRepo) List(ctx context.Context, f InvoiceFilter, p pagination.Params) ([]model.Invoice, pagination.Page, error) {
+	var conds []string
+	var args []any
+	if f.OrderID != nil {
+		args = append(args, *f.OrderID)
+		conds = append(conds, fmt.Sprintf("order_id = $%d", len(args)))
+	}
+	if f.Kind != "" {
+		args = append(args, f.Kind)
+		conds = append(conds, fmt.Sprintf("kind = $%d", len(args)))
+	}
+	cond, tail, args := keyset(p, "", args)
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+invoiceColumns+` FROM invoices `+whereClause(append(conds, cond))+` `+tail, args...,
+	)
+	if err != nil {
+		return nil, pagination.Page{}, fmt.Errorf("list invoices: %w", err)
+	}
+	defer rows.Close()
+
+	var invoices []model.Invoice
+	for rows.Next() {
+		var inv model.Invoice
+		if err := scanInvoice(rows, &inv); err != nil {
+			return nil, pagination.Page{}, fmt.Errorf("scan invoice: %w", err)
+		}
+		invoices = append(invoices, inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, pagination.Page{}, fmt.Errorf("iterate invoices: %w", err)
+	}
+	invoices, page := pagination.Build(invoices, p, invoiceKey)
+	return invoices, page, nil
+}
+
+// GetPDF returns the document's rendered PDF, nil if it has not been
+// rendered.
+func (r *pgInvoiceRepo) GetPDF(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	var pdf []byte
+	err := r.pool.QueryRow(ctx, `SELECT pdf FROM invoices WHERE id = $1`, id).Scan(&pdf)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get invoice pdf: %w", err)
+	}
+	return pdf, nil
+}
+
+// SavePDF stores the document's rendered PDF and returns when it was
+// rendered.
+func (r *pgInvoiceRepo) SavePDF(ctx context.Context, id uuid.UUID, pdf []byte) (time.Time, error) {
+	var renderedAt time.Time
+	err := r.pool.QueryRow(ctx,
+		`UPDATE invoices SET pdf = $2, rendered_at = NOW() WHERE id = $1 RETURNING rendered_at`, id, pdf,
+	).Scan(&renderedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, ErrNotFound
+		}
+		return time.Time{}, fmt.Errorf("save invoice pdf: %w", err)
+	}
+	return renderedAt, nil
+}
+
+// issueInvoice issues the invoice of the order, which the caller has
+// locked, unless it has one, and returns it.
+func issueInvoice(ctx context.Context, tx pgx.Tx, orderID uuid.UUID) (*model.Invoice, error) {
+	inv, err := getInvoice(ctx, tx, `order_id = $1 AND kind = 'invoice'`, orderID)
+	if err != nil || inv != nil {
+		return inv, err
+	}
+	number, err := nextInvoiceNumber(ctx, tx, model.InvoiceKindInvoice)
+	if err != nil {
+		return nil, err
+	}
+	inv = &model.Invoice{}
+	err = scanInvoice(tx.QueryRow(ctx,
+		`INSERT INTO invoices (id, kind, number, order_id, currency, total, created_at)
+		 SELECT $1, 'invoice', $2, id, currency, total_price, NOW() FROM orders WHERE id = $3
+		 RETURNING `+invoiceColumns, uuid.New(), number, orderID,
+	), inv)
+	if err != nil {
+		return nil, fmt.Errorf("insert invoice: %w", err)
+	}
+	return inv, nil
+}
+
+// issueCreditNote issues the credit note for the refund if its order has
+// an invoice.
+func issueCreditNote(ctx context.Context, tx pgx.Tx, refund *model.OrderRefund) error {
+	inv, err := getInvoice(ctx, tx, `order_id = $1 AND kind = 'invoice'`, refund.OrderID)
+	if err != nil || inv == nil {
+		return err
+	}
+	number, err := nextInvoiceNumber(ctx, tx, model.InvoiceKindCreditNote)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO invoices (id, kind, number, order_id, invoice_id, refund_id, currency, total, created_at)
+		 VALUES ($1, 'credit_note', $2, $3, $4, $5, NULLIF($6, ''), $7, NOW())`,
+		uuid.New(), number, refund.OrderID, inv.ID, refund.ID, inv.Currency, refund.Amount,
+	)
+	if err != nil {
+		return fmt.Errorf("insert credit note: %w", err)
+	}
+	return nil
+}
+
+// nextInvoiceNumber takes the next number of kind. The counter stays locked
+// until the transaction ends, and a rollback gives the number back.
+func nextInvoiceNumber(ctx context.Context, tx pgx.Tx, kind string) (int64, error) {
+	var number int64
+	err := tx.QueryRow(ctx,
+		`UPDATE invoice_counters SET last_number = last_number + 1 WHERE kind = $1 RETURNING last_number`, kind,
+	).Scan(&number)
+	if err != nil {
+		return 0, fmt.Errorf("next %s number: %w", kind, err)
+	}
+	return number, nil
+}
+
+func invoiceKey(inv model.Invoice) (time.Time, uuid.UUID) { return inv.CreatedAt, inv.ID }
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+type ProductMediaRepository interface {
+	Create(ctx context.Context, media *model.ProductMedia) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.ProductMedia, error)
+	ListByProductIDs(ctx context.Context, productIDs []uuid.UUID) (map[uuid.UUID][]model.ProductMedia, error)
+	Update(ctx context.Context, media *model.ProductMedia) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type pgProductMediaRepo struct{ pool *pgxpool.Pool }
+
+func NewProductMediaRepository(pool *pgxpool.Pool) ProductMediaRepository {
+	return &pgProductMediaRepo{pool: pool}
+}
+
+const mediaColumns = `id, product_id, storage_key, thumbnail_key, url, thumbnail_url, content_type,
+	size_bytes, width, height, alt_text, position, is_primary, created_at`
+
+func scanMedia(row pgx.Row, m *model.ProductMedia) error {
+	return row.Scan(&m.ID, &m.ProductID, &m.StorageKey, &m.ThumbnailKey, &m.URL, &m.ThumbnailURL,
+		&m.ContentType, &m.SizeBytes, &m.Width, &m.Height, &m.AltText, &m.Position, &m.IsPrimary, &m.CreatedAt)
+}
+
+// Create appends the media to the product's gallery and bumps the product's
+// updated_at, as every media change does.
+func (r *pgProductMediaRepo) Create(ctx context.Context, m *model.ProductMedia) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	var count int
+	err = tx.QueryRow(ctx,
+		`SELECT COUNT(*), COALESCE(MAX(position) + 1, 0) FROM product_media WHERE product_id = $1`, m.ProductID,
+	).Scan(&count, &m.Position)
+	if err != nil {
+		return fmt.Errorf("next media position: %w", err)
+	}
+	if count == 0 {
+		m.IsPrimary = true
+	}
+	if m.IsPrimary {
+		if err := clearPrimary(ctx, tx, m.ProductID); err != nil {
+			return err
+		}
+	}
+
+	m.ID = uuid.New()
+	err = tx.QueryRow(ctx,
+		`INSERT INTO product_media (id, product_id, storage_key, thumbnail_key, url, thumbnail_url, content_type,
+		 size_bytes, width, height, alt_text, position, is_primary, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NOW()) RETURNING created_at`,
+		m.ID, m.ProductID, m.StorageKey, m.ThumbnailKey, m.URL, m.ThumbnailURL, m.ContentType,
+		m.SizeBytes, m.Width, m.Height, m.AltText, m.Position, m.IsPrimary,
+	).Scan(&m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert product media: %w", err)
+	}
+	if err := touchProduct(ctx, tx, m.ProductID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *pgProductMediaRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.ProductMedia, error) {
+	m := &model.ProductMedia{}
+	err := scanMedia(r.pool.QueryRow(ctx, `SELECT `+mediaColumns+` FROM product_media WHERE id = $1`, id), m)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get product media: %w", err)
+	}
+	return m, nil
+}
+
+func (r *pgProductMediaRepo) ListByProductIDs(ctx context.Context, productIDs []uuid.UUID) (map[uuid.UUID][]model.ProductMedia, error) {
+	result := make(map[uuid.UUID][]model.ProductMedia, len(productIDs))
+	if len(productIDs) == 0 {
+		return result, nil
+	}
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+mediaColumns+` FROM product_media WHERE product_id = ANY($1) ORDER BY product_id, position`,
+		productIDs,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list product media: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m model.ProductMedia
+		if err := scanMedia(rows, &m); err != nil {
+			return nil, fmt.Errorf("scan product media: %w", err)
+		}
+		result[m.ProductID] = append(result[m.ProductID], m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate product media: %w", err)
+	}
+	return result, nil
+}
+
+func (r *pgProductMediaRepo) Update(ctx context.Context, m *model.ProductMedia) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	if m.IsPrimary {
+		if err := clearPrimary(ctx, tx, m.ProductID); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE product_media SET alt_text = $2, position = $3, is_primary = $4 WHERE id = $1`,
+		m.ID, m.AltText, m.Position, m.IsPrimary,
+	)
+	if err != nil {
+		return fmt.Errorf("update product media: %w", err)
+	}
+	if err := touchProduct(ctx, tx, m.ProductID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Delete removes the media. If it was the primary image, the product's
+// remaining media with the lowest position becomes primary.
+func (r *pgProductMediaRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	var productID uuid.UUID
+	var primary bool
+	err = tx.QueryRow(ctx,
+		`DELETE FROM product_media WHERE id = $1 RETURNING product_id, is_primary`, id,
+	).Scan(&productID, &primary)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("delete product media: %w", err)
+	}
+	if primary {
+		_, err = tx.Exec(ctx,
+			`UPDATE product_media SET is_primary = TRUE
+			 WHERE id = (SELECT id FROM product_media WHERE product_id = $1 ORDER BY position, created_at LIMIT 1)`,
+			productID,
+		)
+		if err != nil {
+			return fmt.Errorf("promote primary media: %w", err)
+		}
+	}
+	if err := touchProduct(ctx, tx, productID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func touchProduct(ctx context.Context, tx pgx.Tx, productID uuid.UUID) error {
+	if _, err := tx.Exec(ctx, `UPDATE products SET updated_at = NOW() WHERE id = $1`, productID); err != nil {
+		return fmt.Errorf("touch product: %w", err)
+	}
+	return nil
+}
+
+func clearPrimary(ctx context.Context, tx pgx.Tx, productID uuid.UUID) error {
+	_, err := tx.Exec(ctx, `UPDATE product_media SET is_primary = FALSE WHERE product_id = $1 AND is_primary`, productID)
+	if err != nil {
+		return fmt.Errorf("clear primary media: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+)
+
+type NotificationRepository interface {
+	NotifyRole(ctx context.Context, role string, n *model.Notification) (int, error)
+	NotifySubscribers(ctx context.Context, n *model.Notification) (int, error)
+	ListByUserID(ctx context.Context, userID uuid.UUID, p pagination.Params) ([]model.Notification, pagination.Page, error)
+	MarkRead(ctx context.Context, id, userID uuid.UUID) error
+}
+
+type pgNotificationRepo struct{ pool *pgxpool.Pool }
+
+func NewNotificationRepository(pool *pgxpool.Pool) NotificationRepository {
+	return &pgNotificationRepo{pool: pool}
+}
+
+// NotifyRole sends a copy of n to every user with the role and returns how
+// many were sent. n.UserID is ignored.
+func (r *pgNotificationRepo) NotifyRole(ctx context.Context, role string, n *model.Notification) (int, error) {
+	ct, err := r.pool.Exec(ctx,
+		`INSERT INTO notifications (id, user_id, kind, product_id, message, created_at)
+		 SELECT gen_random_uuid(), id, $2, $3, $4, NOW() FROM users WHERE role = $1`,
+		role, n.Kind, n.ProductID, n.Message,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("notify %s users: %w", role, err)
+	}
+	return int(ct.RowsAffected()), nil
+}
+
+// NotifySubscribers notifies n.ProductID's pending subscribers once each.
+func (r *pgNotificationRepo) NotifySubscribers(ctx context.Context, n *model.Notification) (int, error) {
+	ct, err := r.pool.Exec(ctx,
+		`WITH claimed AS (
+		     UPDATE stock_subscriptions SET notified_at = NOW()
+		     WHERE product_id = $1 AND notified_at IS NULL
+		     RETURNING user_id
+		 )
+		 INSERT INTO notifications (id, user_id, kind, product_id, message, created_at)
+		 SELECT gen_random_uuid(), user_id, $2, $1, $3, NOW() FROM claimed`,
+		n.ProductID, n.Kind, n.Message,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("notify subscribers: %w", err)
+	}
+	return int(ct.RowsAffected()), nil
+}
+
+func (r *pgNotificationRepo) ListByUserID(ctx context.Context, userID uuid.UUID, p pagination.Params) ([]model.Notification, pagination.Page, error) {
+	cond, tail, args := keyset(p, "", []any{userID})
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, kind, product_id, order_id, message, created_at, read_at
+		 FROM notifications `+whereClause([]string{"user_id = $1", cond})+` `+tail, args...,
+	)
+	if err != nil {
+		return nil, pagination.Page{}, fmt.Errorf("list notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []model.Notification
+	for rows.Next() {
+		n := model.Notification{UserID: userID}
+		if err := rows.Scan(&n.ID, &n.Kind, &n.ProductID, &n.OrderID, &n.Message, &n.CreatedAt, &n.ReadAt); err != nil {
+			return nil, pagination.Page{}, fmt.Errorf("scan notification: %w", err)
+		}
+		notifications = append(notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, pagination.Page{}, fmt.Errorf("iterate notifications: %w", err)
+	}
+	notifications, page := pagination.Build(notifications, p, notificationKey)
+	return notifications, page, nil
+}
+
+func (r *pgNotificationRepo) MarkRead(ctx context.Context, id, userID uuid.UUID) error {
+	ct, err := r.pool.Exec(ctx,
+		`UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE id = $1 AND user_id = $2`, id, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("mark notification read: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func notificationKey(n model.Notification) (time.Time, uuid.UUID) { return n.CreatedAt, n.ID }
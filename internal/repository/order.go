@@ -1,146 +1,545 @@
 package repository
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
 
+	"github.com/flicky/go-ecommerce-api/internal/allocation"
+	"github.com/flicky/go-ecommerce-api/internal/currency"
 	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
 )
 
 type OrderRepository interface {
 	Create(ctx context.Context, order *model.Order) error
-	ProcessOrder(ctx context.Context, orderID uuid.UUID, items []model.OrderItem) error
+	ProcessOrder(ctx context.Context, orderID uuid.UUID) ([]model.InventoryMovement, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*model.Order, error)
-	ListByUserID(ctx context.Context, userID uuid.UUID) ([]model.Order, error)
+	ListByUserID(ctx context.Context, userID uuid.UUID, f OrderFilter, p pagination.Params) ([]model.Order, pagination.Page, error)
 	UpdateStatus(ctx context.Context, id uuid.UUID, status string) error
+	// MarkPublished records that the order's message reached the queue.
+	MarkPublished(ctx context.Context, id uuid.UUID) error
+	// ListUnpublished returns up to limit pending orders created before
+	// before whose message never reached the queue, oldest first.
+	ListUnpublished(ctx context.Context, before time.Time, limit int) ([]model.OrderMessage, error)
 }
 
-type pgOrderRepo struct{ pool *pgxpool.Pool }
+// OrderFilter narrows order lists; To is exclusive and Email only applies to
+// Search.
+type OrderFilter struct {
+	ID                 *uuid.UUID
+	Statuses           []string
+	From, To           *time.Time
+	MinTotal, MaxTotal *decimal.Decimal
+	Email              string
+	Oldest             bool
+	WithItems          bool
+}
 
-func NewOrderRepository(pool *pgxpool.Pool) OrderRepository {
-	return &pgOrderRepo{pool: pool}
+// conds appends f's conditions to conds, numbering placeholders after args.
+func (f OrderFilter) conds(conds []string, args []any) ([]string, []any) {
+	if len(f.Statuses) > 0 {
+		args = append(args, f.Statuses)
+		conds = append(conds, fmt.Sprintf("status = ANY($%d)", len(args)))
+	}
+	if f.From != nil {
+		args = append(args, *f.From)
+		conds = append(conds, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if f.To != nil {
+		args = append(args, *f.To)
+		conds = append(conds, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if f.ID != nil {
+		args = append(args, *f.ID)
+		conds = append(conds, fmt.Sprintf("id = $%d", len(args)))
+	}
+	if f.MinTotal != nil {
+		args = append(args, *f.MinTotal)
+		conds = append(conds, fmt.Sprintf("total_price >= $%d", len(args)))
+	}
+	if f.MaxTotal != nil {
+		args = append(args, *f.MaxTotal)
+		conds = append(conds, fmt.Sprintf("total_price <= $%d", len(args)))
+	}
+	if f.Email != "" {
+		args = append(args, "%"+likeEscaper.Replace(f.Email)+"%")
+		conds = append(conds, fmt.Sprintf("email ILIKE $%d", len(args)))
+	}
+	return conds, args
 }
 
+type pgOrderRepo struct {
+	pool      *pgxpool.Pool
+	allocator allocation.Strategy
+}
+
+// NewOrderRepository returns an order repository that sources orders from
+// warehouses with allocator when they are processed.
+func NewOrderRepository(pool *pgxpool.Pool, allocator allocation.Strategy) OrderRepository {
+	return &pgOrderRepo{pool: pool, allocator: allocator}
+}
+
+// Create inserts the order with its items. Items have no warehouse until
+// the order is processed.
 func (r *pgOrderRepo) Create(ctx context.Context, order *model.Order) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	if err := insertOrder(ctx, tx, order); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// insertOrder inserts the order, redeems its coupon and reserves its stock.
+func insertOrder(ctx context.Context, tx pgx.Tx, order *model.Order) error {
 	order.ID = uuid.New()
-	err := r.pool.QueryRow(ctx,
-		`INSERT INTO orders (id, user_id, status, total_price, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, NOW(), NOW()) RETURNING created_at`,
-		order.ID, order.UserID, order.Status, order.TotalPrice,
+	err := tx.QueryRow(ctx,
+		`INSERT INTO orders (id, user_id, status, subtotal, discount, total_price, promotion_id, promotion_code,
+		   shipping_address, billing_address, shipping_method, shipping_cost, tax, prices_include_tax,
+		   currency, exchange_rate, payment_intent, stock_reserved, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, NULLIF($11, ''), $12, $13, $14, $15, $16,
+		   NULLIF($17, ''), true, NOW(), NOW())
+		 RETURNING created_at`,
+		order.ID, order.UserID, order.Status, order.Subtotal, order.Discount, order.TotalPrice,
+		order.PromotionID, order.PromotionCode, order.ShippingAddress, order.BillingAddress,
+		order.ShippingMethod, order.ShippingCost, order.Tax, order.PricesIncludeTax, order.Currency, order.ExchangeRate,
+		order.PaymentIntent,
 	).Scan(&order.CreatedAt)
 	if err != nil {
 		return fmt.Errorf("insert order: %w", err)
 	}
+	if order.PromotionID != nil {
+		if err := redeemPromotion(ctx, tx, order); err != nil {
+			return err
+		}
+	}
+	for i := range order.Items {
+		order.Items[i].ID = uuid.New()
+		order.Items[i].OrderID = order.ID
+		if err := insertOrderItem(ctx, tx, &order.Items[i]); err != nil {
+			return err
+		}
+	}
+	return reserveStock(ctx, tx, order.Items)
+}
+
+// reserveStock reserves the items' quantities, locking products in ID order.
+func reserveStock(ctx context.Context, tx pgx.Tx, items []model.OrderItem) error {
+	quantities := make(map[uuid.UUID]int)
+	for _, item := range items {
+		quantities[item.ProductID] += item.Quantity
+	}
+	productIDs := slices.SortedFunc(maps.Keys(quantities), func(a, b uuid.UUID) int {
+		return bytes.Compare(a[:], b[:])
+	})
+	for _, id := range productIDs {
+		ct, err := tx.Exec(ctx,
+			`UPDATE products SET reserved = reserved + $2 WHERE id = $1 AND stock - reserved >= $2`,
+			id, quantities[id],
+		)
+		if err != nil {
+			return fmt.Errorf("reserve stock: %w", err)
+		}
+		if ct.RowsAffected() == 0 {
+			return fmt.Errorf("product %s: %w", id, ErrInsufficientStock)
+		}
+	}
+	return nil
+}
+
+// releaseStock gives back the stock an unprocessed order reserved.
+func releaseStock(ctx context.Context, tx pgx.Tx, orderID uuid.UUID) error {
+	_, err := tx.Exec(ctx,
+		`UPDATE products p SET reserved = GREATEST(p.reserved - i.quantity, 0)
+		 FROM (SELECT product_id, SUM(quantity) AS quantity FROM order_items WHERE order_id = $1 GROUP BY product_id) i
+		 WHERE p.id = i.product_id`, orderID,
+	)
+	if err != nil {
+		return fmt.Errorf("release stock: %w", err)
+	}
 	return nil
 }
 
-func (r *pgOrderRepo) ProcessOrder(ctx context.Context, orderID uuid.UUID, items []model.OrderItem) error {
+// insertOrderItem stores the item with its product snapshot.
+func insertOrderItem(ctx context.Context, tx pgx.Tx, item *model.OrderItem) error {
+	p := &item.Product
+	err := tx.QueryRow(ctx,
+		`INSERT INTO order_items (id, order_id, product_id, quantity, price, tax_name, tax_rate, tax_amount,
+		   warehouse_id, product_name, product_sku, product_attributes, product_image_url, tax_class, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12,
+		   COALESCE(NULLIF($13, ''), (SELECT url FROM product_media WHERE product_id = $3
+		     ORDER BY is_primary DESC, position, created_at LIMIT 1), ''),
+		   $14, NOW())
+		 RETURNING product_image_url`,
+		item.ID, item.OrderID, item.ProductID, item.Quantity, item.Price, item.TaxName, item.TaxRate, item.TaxAmount,
+		item.WarehouseID, p.Name, p.SKU, p.Attributes, p.ImageURL, p.TaxClass,
+	).Scan(&p.ImageURL)
+	if err != nil {
+		return fmt.Errorf("insert order item: %w", err)
+	}
+	return nil
+}
+
+// ProcessOrder allocates a pending order to warehouses and completes it.
+func (r *pgOrderRepo) ProcessOrder(ctx context.Context, orderID uuid.UUID) ([]model.InventoryMovement, error) {
 	tx, err := r.pool.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("begin tx: %w", err)
+		return nil, fmt.Errorf("begin tx: %w", err)
 	}
 	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
 
-	for i := range items {
-		items[i].ID = uuid.New()
-		items[i].OrderID = orderID
-		_, err = tx.Exec(ctx,
-			`INSERT INTO order_items (id, order_id, product_id, quantity, price, created_at)
-			 VALUES ($1, $2, $3, $4, $5, NOW())`,
-			items[i].ID, items[i].OrderID, items[i].ProductID, items[i].Quantity, items[i].Price,
-		)
-		if err != nil {
-			return fmt.Errorf("insert order item: %w", err)
+	status, reserved, err := lockOrder(ctx, tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if status != model.OrderPending {
+		return nil, nil
+	}
+	var code string
+	if err := tx.QueryRow(ctx, `SELECT COALESCE(currency, '') FROM orders WHERE id = $1`, orderID).Scan(&code); err != nil {
+		return nil, fmt.Errorf("get order currency: %w", err)
+	}
+
+	items, err := orderItems(ctx, tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]allocation.Line, len(items))
+	productIDs := make([]uuid.UUID, len(items))
+	for i, item := range items {
+		lines[i] = allocation.Line{ProductID: item.ProductID, Quantity: item.Quantity}
+		productIDs[i] = item.ProductID
+	}
+	sources, err := lockSources(ctx, tx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	allocs, err := r.allocator.Allocate(lines, sources)
+	if err != nil {
+		if errors.Is(err, allocation.ErrInsufficientStock) {
+			return nil, ErrInsufficientStock
 		}
+		return nil, fmt.Errorf("allocate order: %w", err)
+	}
 
-		ct, err := tx.Exec(ctx,
-			`UPDATE products SET stock = stock - $2, updated_at = NOW() WHERE id = $1 AND stock >= $2`,
-			items[i].ProductID, items[i].Quantity,
-		)
+	var movements []model.InventoryMovement
+	allocated := make([]bool, len(items))
+	// What is left of each line's quantity and tax as it is split up; the
+	// last part takes the tax that is left, so the parts add up exactly.
+	quantityLeft := make([]int, len(items))
+	taxLeft := make([]decimal.Decimal, len(items))
+	for i, item := range items {
+		quantityLeft[i], taxLeft[i] = item.Quantity, item.TaxAmount
+	}
+	for _, a := range allocs {
+		item := items[a.Line]
+		warehouseID := a.WarehouseID
+		taxAmount := taxLeft[a.Line]
+		if a.Quantity < quantityLeft[a.Line] {
+			taxAmount = item.TaxAmount.Mul(decimal.NewFromInt(int64(a.Quantity))).
+				Div(decimal.NewFromInt(int64(item.Quantity)))
+			taxAmount = currency.Round(taxAmount, code)
+		}
+		quantityLeft[a.Line] -= a.Quantity
+		taxLeft[a.Line] = taxLeft[a.Line].Sub(taxAmount)
+		if !allocated[a.Line] {
+			allocated[a.Line] = true
+			_, err = tx.Exec(ctx,
+				`UPDATE order_items SET warehouse_id = $2, quantity = $3, tax_amount = $4 WHERE id = $1`,
+				item.ID, warehouseID, a.Quantity, taxAmount,
+			)
+			if err != nil {
+				return nil, fmt.Errorf("update order item: %w", err)
+			}
+		} else {
+			split := model.OrderItem{
+				ID: uuid.New(), OrderID: orderID, ProductID: item.ProductID,
+				Quantity: a.Quantity, Price: item.Price, TaxName: item.TaxName, TaxRate: item.TaxRate,
+				TaxAmount: taxAmount, WarehouseID: &warehouseID, Product: item.Product,
+			}
+			if err := insertOrderItem(ctx, tx, &split); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := changeWarehouseStock(ctx, tx, warehouseID, item.ProductID, -a.Quantity); err != nil {
+			return nil, err
+		}
+		// An order that reserved its stock may use its reservation; one
+		// that did not must leave other orders' reservations alone.
+		released := 0
+		if reserved {
+			released = a.Quantity
+		}
+		var stock int
+		err = tx.QueryRow(ctx,
+			`UPDATE products SET stock = stock - $2, reserved = GREATEST(reserved - $3, 0), updated_at = NOW()
+			 WHERE id = $1 AND stock - GREATEST(reserved - $3, 0) >= $2 RETURNING stock`,
+			item.ProductID, a.Quantity, released,
+		).Scan(&stock)
 		if err != nil {
-			return fmt.Errorf("decrement stock: %w", err)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, fmt.Errorf("product %s: %w", item.ProductID, ErrInsufficientStock)
+			}
+			return nil, fmt.Errorf("decrement stock: %w", err)
 		}
-		if ct.RowsAffected() == 0 {
-			return fmt.Errorf("insufficient stock for product %s", items[i].ProductID)
+		m := model.InventoryMovement{
+			ProductID: item.ProductID, Kind: model.MovementSale, Quantity: -a.Quantity,
+			StockAfter: stock, WarehouseID: &warehouseID, OrderID: &orderID,
 		}
+		if err := insertMovement(ctx, tx, &m); err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
 	}
 
 	_, err = tx.Exec(ctx,
-		`UPDATE orders SET status = 'completed', updated_at = NOW() WHERE id = $1`, orderID,
+		`UPDATE orders SET status = 'completed', stock_reserved = false, updated_at = NOW() WHERE id = $1`, orderID,
 	)
 	if err != nil {
-		return fmt.Errorf("update order status: %w", err)
+		return nil, fmt.Errorf("update order status: %w", err)
 	}
-	return tx.Commit(ctx)
+	if _, err := issueInvoice(ctx, tx, orderID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit order: %w", err)
+	}
+	return movements, nil
 }
 
-func (r *pgOrderRepo) UpdateStatus(ctx context.Context, id uuid.UUID, status string) error {
-	_, err := r.pool.Exec(ctx,
-		`UPDATE orders SET status = $2, updated_at = NOW() WHERE id = $1`, id, status,
+func orderItems(ctx context.Context, q querier, orderID uuid.UUID) ([]model.OrderItem, error) {
+	items, err := itemsByOrder(ctx, q, []uuid.UUID{orderID})
+	if err != nil {
+		return nil, err
+	}
+	return items[orderID], nil
+}
+
+// itemsByOrder loads the items of the given orders, keyed by order.
+func itemsByOrder(ctx context.Context, q querier, orderIDs []uuid.UUID) (map[uuid.UUID][]model.OrderItem, error) {
+	rows, err := q.Query(ctx,
+		`SELECT id, order_id, product_id, quantity, price, tax_name, tax_rate, tax_amount, warehouse_id,
+		   product_name, product_sku, product_attributes, product_image_url, tax_class
+		 FROM order_items WHERE order_id = ANY($1) ORDER BY created_at, id`, orderIDs,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get order items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make(map[uuid.UUID][]model.OrderItem, len(orderIDs))
+	for rows.Next() {
+		var item model.OrderItem
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.Quantity, &item.Price, &item.TaxName,
+			&item.TaxRate, &item.TaxAmount, &item.WarehouseID, &item.Product.Name, &item.Product.SKU,
+			&item.Product.Attributes, &item.Product.ImageURL, &item.Product.TaxClass); err != nil {
+			return nil, fmt.Errorf("scan order item: %w", err)
+		}
+		items[item.OrderID] = append(items[item.OrderID], item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate order items: %w", err)
+	}
+	return items, nil
+}
+
+// lockSources loads and locks the active warehouses' stock of the given
+// products, in warehouse priority order.
+func lockSources(ctx context.Context, tx pgx.Tx, productIDs []uuid.UUID) ([]allocation.Source, error) {
+	rows, err := tx.Query(ctx,
+		`SELECT ws.warehouse_id, ws.product_id, ws.quantity
+		 FROM warehouse_stock ws JOIN warehouses w ON w.id = ws.warehouse_id
+		 WHERE w.active AND ws.product_id = ANY($1) AND ws.quantity > 0
+		 ORDER BY w.priority, w.code
+		 FOR UPDATE OF ws`, productIDs,
 	)
-	return err
+	if err != nil {
+		return nil, fmt.Errorf("get warehouse stock: %w", err)
+	}
+	defer rows.Close()
+
+	var sources []allocation.Source
+	for rows.Next() {
+		var warehouseID, productID uuid.UUID
+		var qty int
+		if err := rows.Scan(&warehouseID, &productID, &qty); err != nil {
+			return nil, fmt.Errorf("scan warehouse stock: %w", err)
+		}
+		if n := len(sources); n == 0 || sources[n-1].WarehouseID != warehouseID {
+			sources = append(sources, allocation.Source{WarehouseID: warehouseID, Stock: make(map[uuid.UUID]int)})
+		}
+		sources[len(sources)-1].Stock[productID] = qty
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate warehouse stock: %w", err)
+	}
+	return sources, nil
 }
 
-func (r *pgOrderRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.Order, error) {
-	order := &model.Order{}
-	err := r.pool.QueryRow(ctx,
-		`SELECT id, user_id, status, total_price, created_at FROM orders WHERE id = $1`, id,
-	).Scan(&order.ID, &order.UserID, &order.Status, &order.TotalPrice, &order.CreatedAt)
+// UpdateStatus sets the order's status. An order that fails gives back the
+// stock it reserved and its coupon redemption.
+func (r *pgOrderRepo) UpdateStatus(ctx context.Context, id uuid.UUID, status string) error {
+	tx, err := r.pool.Begin(ctx)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, nil
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	_, reserved, err := lockOrder(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	release := reserved && status == model.OrderFailed
+	if release {
+		if err := releaseStock(ctx, tx, id); err != nil {
+			return err
 		}
-		return nil, fmt.Errorf("get order: %w", err)
 	}
+	if status == model.OrderFailed {
+		if err := releasePromotion(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE orders SET status = $2, stock_reserved = stock_reserved AND NOT $3, updated_at = NOW() WHERE id = $1`,
+		id, status, release,
+	)
+	if err != nil {
+		return fmt.Errorf("update order status: %w", err)
+	}
+	return tx.Commit(ctx)
+}
 
+func (r *pgOrderRepo) MarkPublished(ctx context.Context, id uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `UPDATE orders SET published_at = NOW() WHERE id = $1 AND published_at IS NULL`, id)
+	if err != nil {
+		return fmt.Errorf("mark order published: %w", err)
+	}
+	return nil
+}
+
+func (r *pgOrderRepo) ListUnpublished(ctx context.Context, before time.Time, limit int) ([]model.OrderMessage, error) {
 	rows, err := r.pool.Query(ctx,
-		`SELECT id, product_id, quantity, price FROM order_items WHERE order_id = $1`, id,
+		`SELECT id, user_id FROM orders
+		 WHERE published_at IS NULL AND status = 'pending' AND created_at < $1
+		 ORDER BY created_at LIMIT $2`, before, limit,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("get order items: %w", err)
+		return nil, fmt.Errorf("list unpublished orders: %w", err)
 	}
 	defer rows.Close()
 
+	var msgs []model.OrderMessage
 	for rows.Next() {
-		var item model.OrderItem
-		if err := rows.Scan(&item.ID, &item.ProductID, &item.Quantity, &item.Price); err != nil {
-			return nil, fmt.Errorf("scan order item: %w", err)
+		var m model.OrderMessage
+		if err := rows.Scan(&m.OrderID, &m.UserID); err != nil {
+			return nil, fmt.Errorf("scan unpublished order: %w", err)
 		}
-		item.OrderID = order.ID
-		order.Items = append(order.Items, item)
+		msgs = append(msgs, m)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterate order items: %w", err)
+		return nil, fmt.Errorf("iterate unpublished orders: %w", err)
+	}
+	return msgs, nil
+}
+
+const orderColumns = `id, user_id, status, subtotal, discount, total_price, promotion_id,
+	COALESCE(promotion_code, ''), shipping_address, billing_address, COALESCE(shipping_method, ''), shipping_cost,
+	tax, prices_include_tax, COALESCE(currency, ''), exchange_rate, COALESCE(payment_intent, ''), refunded, created_at`
+
+func scanOrder(row pgx.Row, o *model.Order) error {
+	return row.Scan(&o.ID, &o.UserID, &o.Status, &o.Subtotal, &o.Discount, &o.TotalPrice,
+		&o.PromotionID, &o.PromotionCode, &o.ShippingAddress, &o.BillingAddress, &o.ShippingMethod,
+		&o.ShippingCost, &o.Tax, &o.PricesIncludeTax, &o.Currency, &o.ExchangeRate, &o.PaymentIntent, &o.Refunded,
+		&o.CreatedAt)
+}
+
+func (r *pgOrderRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.Order, error) {
+	order := &model.Order{}
+	err := scanOrder(r.pool.QueryRow(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = $1`, id), order)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get order: %w", err)
+	}
+
+	if order.Items, err = orderItems(ctx, r.pool, id); err != nil {
+		return nil, err
 	}
 	return order, nil
 }
 
-func (r *pgOrderRepo) ListByUserID(ctx context.Context, userID uuid.UUID) ([]model.Order, error) {
-	rows, err := r.pool.Query(ctx,
-		`SELECT id, status, total_price, created_at FROM orders WHERE user_id = $1 ORDER BY created_at DESC`,
-		userID,
+// ListByUserID pages through the user's orders that match f.
+func (r *pgOrderRepo) ListByUserID(ctx context.Context, userID uuid.UUID, f OrderFilter, p pagination.Params) ([]model.Order, pagination.Page, error) {
+	conds, args := f.conds([]string{"user_id = $1"}, []any{userID})
+	return listOrders(ctx, r.pool, "orders", orderColumns, conds, args, f, p, scanOrder)
+}
+
+// listOrders pages through the orders in from that meet conds.
+func listOrders(ctx context.Context, pool *pgxpool.Pool, from, columns string, conds []string, args []any,
+	f OrderFilter, p pagination.Params, scan func(pgx.Row, *model.Order) error,
+) ([]model.Order, pagination.Page, error) {
+	filter, filterArgs := whereClause(conds), args
+
+	cond, tail, args := keysetSorted(p, "", args, f.Oldest)
+	rows, err := pool.Query(ctx,
+		`SELECT `+columns+` FROM `+from+` `+whereClause(append(conds, cond))+` `+tail, args...,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("list orders: %w", err)
+		return nil, pagination.Page{}, fmt.Errorf("list orders: %w", err)
 	}
 	defer rows.Close()
 
 	var orders []model.Order
 	for rows.Next() {
 		var o model.Order
-		o.UserID = userID
-		if err := rows.Scan(&o.ID, &o.Status, &o.TotalPrice, &o.CreatedAt); err != nil {
-			return nil, fmt.Errorf("scan order: %w", err)
+		if err := scan(rows, &o); err != nil {
+			return nil, pagination.Page{}, fmt.Errorf("scan order: %w", err)
 		}
 		orders = append(orders, o)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterate orders: %w", err)
+		return nil, pagination.Page{}, fmt.Errorf("iterate orders: %w", err)
+	}
+
+	orders, page := pagination.Build(orders, p, orderKey)
+	if f.WithItems && len(orders) > 0 {
+		ids := make([]uuid.UUID, len(orders))
+		for i := range orders {
+			ids[i] = orders[i].ID
+		}
+		items, err := itemsByOrder(ctx, pool, ids)
+		if err != nil {
+			return nil, pagination.Page{}, err
+		}
+		for i := range orders {
+			orders[i].Items = items[orders[i].ID]
+		}
 	}
-	return orders, nil
+	if p.WithTotal {
+		var total int
+		if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM `+from+` `+filter, filterArgs...).Scan(&total); err != nil {
+			return nil, pagination.Page{}, fmt.Errorf("count orders: %w", err)
+		}
+		page.Total = &total
+	}
+	return orders, page, nil
 }
+
+func orderKey(o model.Order) (time.Time, uuid.UUID) { return o.CreatedAt, o.ID }
@@ -0,0 +1,319 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+)
+
+var (
+	// ErrOrderStatusChanged means the order's status is no longer the one a
+	// change was made from.
+	ErrOrderStatusChanged = errors.New("order status changed")
+	// ErrRefundExceedsTotal means a refund would take an order's refunds
+	// past its total.
+	ErrRefundExceedsTotal = errors.New("refund exceeds order total")
+)
+
+// OrderAdminRepository is what staff do with orders.
+type OrderAdminRepository interface {
+	Search(ctx context.Context, f OrderFilter, p pagination.Params) ([]model.Order, pagination.Page, error)
+	GetCustomer(ctx context.Context, userID uuid.UUID) (*model.OrderCustomer, error)
+	ListEvents(ctx context.Context, orderID uuid.UUID) ([]model.OrderEvent, error)
+	ListRefunds(ctx context.Context, orderID uuid.UUID) ([]model.OrderRefund, error)
+	ChangeStatus(ctx context.Context, ev *model.OrderEvent) ([]model.InventoryMovement, error)
+	Refund(ctx context.Context, refund *model.OrderRefund, ev *model.OrderEvent) error
+	Resend(ctx context.Context, n *model.Notification, ev *model.OrderEvent) error
+}
+
+type pgOrderAdminRepo struct{ pool *pgxpool.Pool }
+
+func NewOrderAdminRepository(pool *pgxpool.Pool) OrderAdminRepository {
+	return &pgOrderAdminRepo{pool: pool}
+}
+
+// customerOrders is orders with their customers' details alongside, so
+// filters can refer to both.
+const customerOrders = `(SELECT o.*, u.email, u.first_name, u.last_name
+	FROM orders o JOIN users u ON u.id = o.user_id) orders`
+
+func scanCustomerOrder(row pgx.Row, o *model.Order) error {
+	c := &model.OrderCustomer{}
+	err := row.Scan(&o.ID, &o.UserID, &o.Status, &o.Subtotal, &o.Discount, &o.TotalPrice,
+		&o.PromotionID, &o.PromotionCode, &o.ShippingAddress, &o.BillingAddress, &o.ShippingMethod,
+		&o.ShippingCost, &o.Tax, &o.PricesIncludeTax, &o.Currency, &o.ExchangeRate, &o.PaymentIntent, &o.Refunded,
+		&o.CreatedAt, &c.Email, &c.FirstName, &c.LastName)
+	if err != nil {
+		return err
+	}
+	c.ID = o.UserID
+	o.Customer = c
+	return nil
+}
+
+// Search pages through every order that matches f, with its customer.
+func (r *pgOrderAdminRepo) Search(ctx context.Context, f OrderFilter, p pagination.Params) ([]model.Order, pagination.Page, error) {
+	conds, args := f.conds(nil, nil)
+	return listOrders(ctx, r.pool, customerOrders, orderColumns+`, email, first_name, last_name`,
+		conds, args, f, p, scanCustomerOrder)
+}
+
+func (r *pgOrderAdminRepo) GetCustomer(ctx context.Context, userID uuid.UUID) (*model.OrderCustomer, error) {
+	c := &model.OrderCustomer{}
+	err := r.pool.QueryRow(ctx,
+		`SELECT id, email, first_name, last_name FROM users WHERE id = $1`, userID,
+	).Scan(&c.ID, &c.Email, &c.FirstName, &c.LastName)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get customer: %w", err)
+	}
+	return c, nil
+}
+
+// ListEvents returns the order's events, oldest first.
+func (r *pgOrderAdminRepo) ListEvents(ctx context.Context, orderID uuid.UUID) ([]model.OrderEvent, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, kind, COALESCE(from_status, ''), COALESCE(to_status, ''), note, actor_id, created_at
+		 FROM order_events WHERE order_id = $1 ORDER BY created_at, id`, orderID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list order events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []model.OrderEvent
+	for rows.Next() {
+		ev := model.OrderEvent{OrderID: orderID}
+		if err := rows.Scan(&ev.ID, &ev.Kind, &ev.FromStatus, &ev.ToStatus, &ev.Note, &ev.ActorID, &ev.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan order event: %w", err)
+		}
+		events = append(events, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate order events: %w", err)
+	}
+	return events, nil
+}
+
+// ListRefunds returns the order's refunds, oldest first.
+func (r *pgOrderAdminRepo) ListRefunds(ctx context.Context, orderID uuid.UUID) ([]model.OrderRefund, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, amount, currency, COALESCE(reference, ''), note, actor_id, created_at
+		 FROM order_refunds WHERE order_id = $1 ORDER BY created_at, id`, orderID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list order refunds: %w", err)
+	}
+	defer rows.Close()
+
+	var refunds []model.OrderRefund
+	for rows.Next() {
+		rf := model.OrderRefund{OrderID: orderID}
+		if err := rows.Scan(&rf.ID, &rf.Amount, &rf.Currency, &rf.Reference, &rf.Note, &rf.ActorID, &rf.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan order refund: %w", err)
+		}
+		refunds = append(refunds, rf)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate order refunds: %w", err)
+	}
+	return refunds, nil
+}
+
+// ChangeStatus moves the order to ev.ToStatus and records ev.
+func (r *pgOrderAdminRepo) ChangeStatus(ctx context.Context, ev *model.OrderEvent) ([]model.InventoryMovement, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	status, reserved, err := lockOrder(ctx, tx, ev.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if status != ev.FromStatus {
+		return nil, ErrOrderStatusChanged
+	}
+	ended := ev.ToStatus == model.OrderFailed || ev.ToStatus == model.OrderCancelled
+	release := reserved && ended
+	if release {
+		if err := releaseStock(ctx, tx, ev.OrderID); err != nil {
+			return nil, err
+		}
+	}
+	if ended {
+		if err := releasePromotion(ctx, tx, ev.OrderID); err != nil {
+			return nil, err
+		}
+	}
+	var movements []model.InventoryMovement
+	if status == model.OrderCompleted && ev.ToStatus == model.OrderCancelled {
+		if movements, err = returnStock(ctx, tx, ev); err != nil {
+			return nil, err
+		}
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE orders SET status = $2, stock_reserved = stock_reserved AND NOT $3, updated_at = NOW() WHERE id = $1`,
+		ev.OrderID, ev.ToStatus, release,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("update order status: %w", err)
+	}
+	if err := insertOrderEvent(ctx, tx, ev); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit order status: %w", err)
+	}
+	return movements, nil
+}
+
+// returnStock puts a cancelled order's items back into the warehouses they
+// shipped from, booking a return movement for each.
+func returnStock(ctx context.Context, tx pgx.Tx, ev *model.OrderEvent) ([]model.InventoryMovement, error) {
+	items, err := orderItems(ctx, tx, ev.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	var movements []model.InventoryMovement
+	for _, item := range items {
+		// Nothing to return to a product that has since been deleted.
+		if item.WarehouseID == nil || item.ProductID == uuid.Nil {
+			continue
+		}
+		if err := changeWarehouseStock(ctx, tx, *item.WarehouseID, item.ProductID, item.Quantity); err != nil {
+			return nil, err
+		}
+		m := model.InventoryMovement{
+			ProductID: item.ProductID, Kind: model.MovementReturn, Quantity: item.Quantity,
+			Reason: "order cancelled", WarehouseID: item.WarehouseID, ActorID: ev.ActorID, OrderID: &ev.OrderID,
+		}
+		err := tx.QueryRow(ctx,
+			`UPDATE products SET stock = stock + $2, updated_at = NOW() WHERE id = $1 RETURNING stock`,
+			item.ProductID, item.Quantity,
+		).Scan(&m.StockAfter)
+		if err != nil {
+			return nil, fmt.Errorf("return stock: %w", err)
+		}
+		if err := insertMovement(ctx, tx, &m); err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+	return movements, nil
+}
+
+// Refund records refund and ev, issuing a credit note if the order has an
+// invoice.
+func (r *pgOrderAdminRepo) Refund(ctx context.Context, refund *model.OrderRefund, ev *model.OrderEvent) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	status, _, err := lockOrder(ctx, tx, refund.OrderID)
+	if err != nil {
+		return err
+	}
+	if status != ev.FromStatus {
+		return ErrOrderStatusChanged
+	}
+	refund.ID = uuid.New()
+	_, err = tx.Exec(ctx,
+		`INSERT INTO order_refunds (id, order_id, amount, currency, reference, note, actor_id, created_at)
+		 VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, NOW())`,
+		refund.ID, refund.OrderID, refund.Amount, refund.Currency, refund.Reference, refund.Note, refund.ActorID,
+	)
+	if err != nil {
+		return fmt.Errorf("insert order refund: %w", err)
+	}
+	if err := issueCreditNote(ctx, tx, refund); err != nil {
+		return err
+	}
+	var newStatus string
+	err = tx.QueryRow(ctx,
+		`UPDATE orders SET refunded = refunded + $2,
+		   status = CASE WHEN refunded + $2 >= total_price THEN 'refunded' ELSE status END, updated_at = NOW()
+		 WHERE id = $1 AND refunded + $2 <= total_price
+		 RETURNING status`, refund.OrderID, refund.Amount,
+	).Scan(&newStatus)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRefundExceedsTotal
+		}
+		return fmt.Errorf("update order refunded: %w", err)
+	}
+	ev.ToStatus = newStatus
+	if newStatus == status {
+		ev.FromStatus, ev.ToStatus = "", ""
+	}
+	if err := insertOrderEvent(ctx, tx, ev); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit order refund: %w", err)
+	}
+	return nil
+}
+
+// Resend notifies the order's customer with n and records ev.
+func (r *pgOrderAdminRepo) Resend(ctx context.Context, n *model.Notification, ev *model.OrderEvent) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	n.ID = uuid.New()
+	err = tx.QueryRow(ctx,
+		`INSERT INTO notifications (id, user_id, kind, order_id, message, created_at)
+		 VALUES ($1, $2, $3, $4, $5, NOW()) RETURNING created_at`,
+		n.ID, n.UserID, n.Kind, n.OrderID, n.Message,
+	).Scan(&n.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert notification: %w", err)
+	}
+	if err := insertOrderEvent(ctx, tx, ev); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// lockOrder locks the order and returns its status and whether it holds a
+// stock reservation.
+func lockOrder(ctx context.Context, tx pgx.Tx, id uuid.UUID) (status string, reserved bool, err error) {
+	err = tx.QueryRow(ctx,
+		`SELECT status, stock_reserved FROM orders WHERE id = $1 FOR UPDATE`, id,
+	).Scan(&status, &reserved)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", false, ErrNotFound
+		}
+		return "", false, fmt.Errorf("lock order: %w", err)
+	}
+	return status, reserved, nil
+}
+
+func insertOrderEvent(ctx context.Context, tx pgx.Tx, ev *model.OrderEvent) error {
+	ev.ID = uuid.New()
+	err := tx.QueryRow(ctx,
+		`INSERT INTO order_events (id, order_id, kind, from_status, to_status, note, actor_id, created_at)
+		 VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7, NOW()) RETURNING created_at`,
+		ev.ID, ev.OrderID, ev.Kind, ev.FromStatus, ev.ToStatus, ev.Note, ev.ActorID,
+	).Scan(&ev.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert order event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+)
+
+// keyset builds the cursor condition and ORDER BY/LIMIT tail of a keyset page.
+func keyset(p pagination.Params, alias string, args []any) (cond, tail string, out []any) {
+	return keysetSorted(p, alias, args, false)
+}
+
+// keysetSorted is keyset for a list sorted oldest first when ascending.
+func keysetSorted(p pagination.Params, alias string, args []any, ascending bool) (cond, tail string, out []any) {
+	created, id := alias+"created_at", alias+"id"
+	// A backward page is fetched against the sort order and flipped by
+	// pagination.Build.
+	op, dir := "<", "DESC"
+	if ascending != p.Backward() {
+		op, dir = ">", "ASC"
+	}
+	order := fmt.Sprintf("%s %s, %s %s", created, dir, id, dir)
+	if p.Cursor != nil {
+		args = append(args, p.Cursor.CreatedAt, p.Cursor.ID)
+		cond = fmt.Sprintf("(%s, %s) %s ($%d, $%d)", created, id, op, len(args)-1, len(args))
+	}
+	args = append(args, p.Limit+1)
+	return cond, fmt.Sprintf("ORDER BY %s LIMIT $%d", order, len(args)), args
+}
@@ -2,22 +2,46 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+)
+
+var (
+	ErrDuplicateSKU = errors.New("duplicate sku")
+	// ErrVersionConflict means the row's version no longer matches the one
+	// the caller read, i.e. the product was edited concurrently.
+	ErrVersionConflict = errors.New("version conflict")
 )
 
 type ProductRepository interface {
-	Create(ctx context.Context, product *model.Product) error
+	Create(ctx context.Context, product *model.Product, actorID *uuid.UUID) error
 	GetByID(ctx context.Context, id uuid.UUID) (*model.Product, error)
-	List(ctx context.Context, limit, offset int) ([]model.Product, int, error)
-	Update(ctx context.Context, product *model.Product) error
-	Delete(ctx context.Context, id uuid.UUID) error
+	List(ctx context.Context, f ProductFilter, p pagination.Params) ([]model.Product, pagination.Page, error)
+	Update(ctx context.Context, product *model.Product, audit *model.ProductAuditEntry) error
+	Archive(ctx context.Context, id uuid.UUID) error
+	Restore(ctx context.Context, id uuid.UUID) error
+	SetMaxPerOrder(ctx context.Context, id uuid.UUID, limit *int) error
+	UpsertBySKU(ctx context.Context, product *model.Product, actorID *uuid.UUID, audit AuditFunc) (created bool, err error)
+	Each(ctx context.Context, fn func(*model.Product) error) error
+	ListAudit(ctx context.Context, productID uuid.UUID, p pagination.Params) ([]model.ProductAuditEntry, pagination.Page, error)
+}
+
+// AuditFunc returns the audit entry for a product that changed from old to
+// updated, or nil to record none.
+type AuditFunc func(old, updated *model.Product) *model.ProductAuditEntry
+
+// ProductFilter narrows product lists. An empty Status matches every status.
+type ProductFilter struct {
+	Status string
 }
 
 type pgProductRepo struct{ pool *pgxpool.Pool }
@@ -26,24 +50,65 @@ func NewProductRepository(pool *pgxpool.Pool) ProductRepository {
 	return &pgProductRepo{pool: pool}
 }
 
-func (r *pgProductRepo) Create(ctx context.Context, product *model.Product) error {
-	product.ID = uuid.New()
-	err := r.pool.QueryRow(ctx,
-		`INSERT INTO products (id, name, description, price, stock, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, NOW(), NOW()) RETURNING created_at, updated_at`,
-		product.ID, product.Name, product.Description, product.Price, product.Stock,
-	).Scan(&product.CreatedAt, &product.UpdatedAt)
+const productColumns = `id, COALESCE(sku, ''), name, description, COALESCE(category, ''), price, stock, reserved, status,
+	max_per_order, weight_grams, tax_class, version, created_at, updated_at, deleted_at`
+
+func scanProduct(row pgx.Row, p *model.Product) error {
+	return row.Scan(&p.ID, &p.SKU, &p.Name, &p.Description, &p.Category, &p.Price, &p.Stock, &p.Reserved, &p.Status,
+		&p.MaxPerOrder, &p.WeightGrams, &p.TaxClass, &p.Version, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt)
+}
+
+// Create inserts the product, recording its initial stock as a restock.
+func (r *pgProductRepo) Create(ctx context.Context, product *model.Product, actorID *uuid.UUID) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	if product.ID == uuid.Nil {
+		product.ID = uuid.New()
+	}
+	err = tx.QueryRow(ctx,
+		`INSERT INTO products (id, sku, name, description, category, price, stock, status, weight_grams, tax_class,
+		   created_at, updated_at)
+		 VALUES ($1, NULLIF($2, ''), $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10, NOW(), NOW()) RETURNING version, created_at, updated_at`,
+		product.ID, product.SKU, product.Name, product.Description, product.Category, product.Price, product.Stock, product.Status,
+		product.WeightGrams, product.TaxClass,
+	).Scan(&product.Version, &product.CreatedAt, &product.UpdatedAt)
 	if err != nil {
+		if isUniqueViolation(err, "products_sku_key") {
+			return ErrDuplicateSKU
+		}
 		return fmt.Errorf("create product: %w", err)
 	}
-	return nil
+	if err := recordInitialStock(ctx, tx, product, actorID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// recordInitialStock books a new product's stock into the default warehouse.
+func recordInitialStock(ctx context.Context, tx pgx.Tx, product *model.Product, actorID *uuid.UUID) error {
+	if product.Stock == 0 {
+		return nil
+	}
+	warehouseID, err := defaultWarehouse(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if err := changeWarehouseStock(ctx, tx, warehouseID, product.ID, product.Stock); err != nil {
+		return err
+	}
+	return insertMovement(ctx, tx, &model.InventoryMovement{
+		ProductID: product.ID, Kind: model.MovementRestock, Quantity: product.Stock,
+		StockAfter: product.Stock, Reason: "initial stock", WarehouseID: &warehouseID, ActorID: actorID,
+	})
 }
 
 func (r *pgProductRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.Product, error) {
 	p := &model.Product{}
-	err := r.pool.QueryRow(ctx,
-		`SELECT id, name, description, price, stock, created_at, updated_at FROM products WHERE id = $1`, id,
-	).Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Stock, &p.CreatedAt, &p.UpdatedAt)
+	err := scanProduct(r.pool.QueryRow(ctx, `SELECT `+productColumns+` FROM products WHERE id = $1`, id), p)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
@@ -53,54 +118,250 @@ func (r *pgProductRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.Produ
 	return p, nil
 }
 
-func (r *pgProductRepo) List(ctx context.Context, limit, offset int) ([]model.Product, int, error) {
-	var total int
-	if err := r.pool.QueryRow(ctx, `SELECT COUNT(*) FROM products`).Scan(&total); err != nil {
-		return nil, 0, fmt.Errorf("count products: %w", err)
+func (r *pgProductRepo) List(ctx context.Context, f ProductFilter, p pagination.Params) ([]model.Product, pagination.Page, error) {
+	var conds []string
+	var args []any
+	if f.Status != "" {
+		args = append(args, f.Status)
+		conds = append(conds, fmt.Sprintf("status = $%d", len(args)))
 	}
+	filter, filterArgs := whereClause(conds), args
 
+	cond, tail, args := keyset(p, "", args)
 	rows, err := r.pool.Query(ctx,
-		`SELECT id, name, description, price, stock, created_at, updated_at
-		 FROM products ORDER BY created_at DESC LIMIT $1 OFFSET $2`, limit, offset,
+		`SELECT `+productColumns+` FROM products `+whereClause(append(conds, cond))+` `+tail, args...,
 	)
 	if err != nil {
-		return nil, 0, fmt.Errorf("list products: %w", err)
+		return nil, pagination.Page{}, fmt.Errorf("list products: %w", err)
 	}
 	defer rows.Close()
 
 	var products []model.Product
 	for rows.Next() {
 		var p model.Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Stock, &p.CreatedAt, &p.UpdatedAt); err != nil {
-			return nil, 0, fmt.Errorf("scan product: %w", err)
+		if err := scanProduct(rows, &p); err != nil {
+			return nil, pagination.Page{}, fmt.Errorf("scan product: %w", err)
 		}
 		products = append(products, p)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, 0, fmt.Errorf("iterate products: %w", err)
+		return nil, pagination.Page{}, fmt.Errorf("iterate products: %w", err)
+	}
+
+	products, page := pagination.Build(products, p, productKey)
+	if p.WithTotal {
+		var total int
+		if err := r.pool.QueryRow(ctx, `SELECT COUNT(*) FROM products `+filter, filterArgs...).Scan(&total); err != nil {
+			return nil, pagination.Page{}, fmt.Errorf("count products: %w", err)
+		}
+		page.Total = &total
 	}
-	return products, total, nil
+	return products, page, nil
 }
 
-func (r *pgProductRepo) Update(ctx context.Context, product *model.Product) error {
-	err := r.pool.QueryRow(ctx,
-		`UPDATE products SET name=$2, description=$3, price=$4, stock=$5, updated_at=NOW()
-		 WHERE id=$1 RETURNING updated_at`,
-		product.ID, product.Name, product.Description, product.Price, product.Stock,
-	).Scan(&product.UpdatedAt)
+func productKey(p model.Product) (time.Time, uuid.UUID) { return p.CreatedAt, p.ID }
+
+// Update overwrites all catalogue fields but stock, so callers start from the
+// stored product.
+func (r *pgProductRepo) Update(ctx context.Context, product *model.Product, audit *model.ProductAuditEntry) error {
+	tx, err := r.pool.Begin(ctx)
 	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	err = tx.QueryRow(ctx,
+		`UPDATE products SET sku=NULLIF($2, ''), name=$3, description=$4, price=$5, status=$6,
+		 deleted_at=CASE WHEN $6 = 'archived' THEN deleted_at END, category=NULLIF($8, ''),
+		 weight_grams=$9, tax_class=COALESCE(NULLIF($10, ''), tax_class), version=version+1, updated_at=NOW()
+		 WHERE id=$1 AND version=$7 RETURNING stock, version, updated_at`,
+		product.ID, product.SKU, product.Name, product.Description, product.Price, product.Status, product.Version,
+		product.Category, product.WeightGrams, product.TaxClass,
+	).Scan(&product.Stock, &product.Version, &product.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrVersionConflict
+		}
+		if isUniqueViolation(err, "products_sku_key") {
+			return ErrDuplicateSKU
+		}
 		return fmt.Errorf("update product: %w", err)
 	}
+
+	if audit != nil {
+		if err := insertProductAudit(ctx, tx, product.ID, audit); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func insertProductAudit(ctx context.Context, tx pgx.Tx, productID uuid.UUID, audit *model.ProductAuditEntry) error {
+	changes, err := json.Marshal(audit.Changes)
+	if err != nil {
+		return fmt.Errorf("marshal audit changes: %w", err)
+	}
+	audit.ID, audit.ProductID = uuid.New(), productID
+	err = tx.QueryRow(ctx,
+		`INSERT INTO product_audit_log (id, product_id, actor_id, action, changes, created_at)
+		 VALUES ($1, $2, $3, $4, $5, NOW()) RETURNING created_at`,
+		audit.ID, audit.ProductID, audit.ActorID, audit.Action, changes,
+	).Scan(&audit.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert product audit: %w", err)
+	}
+	return nil
+}
+
+// Archive soft-deletes the product. The row stays in place so order items
+// keep resolving to it; archived products are simply not sold any more.
+func (r *pgProductRepo) Archive(ctx context.Context, id uuid.UUID) error {
+	ct, err := r.pool.Exec(ctx,
+		`UPDATE products SET status = 'archived', deleted_at = NOW(), version = version + 1, updated_at = NOW()
+		 WHERE id = $1`, id,
+	)
+	if err != nil {
+		return fmt.Errorf("archive product: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
 	return nil
 }
 
-func (r *pgProductRepo) Delete(ctx context.Context, id uuid.UUID) error {
-	ct, err := r.pool.Exec(ctx, `DELETE FROM products WHERE id = $1`, id)
+func (r *pgProductRepo) Restore(ctx context.Context, id uuid.UUID) error {
+	ct, err := r.pool.Exec(ctx,
+		`UPDATE products SET status = 'active', deleted_at = NULL, version = version + 1, updated_at = NOW()
+		 WHERE id = $1 AND status = 'archived'`, id,
+	)
 	if err != nil {
-		return fmt.Errorf("delete product: %w", err)
+		return fmt.Errorf("restore product: %w", err)
 	}
 	if ct.RowsAffected() == 0 {
-		return fmt.Errorf("product not found")
+		return ErrNotFound
 	}
 	return nil
 }
+
+// SetMaxPerOrder sets how many units of the product one order may hold; nil
+// removes the limit.
+func (r *pgProductRepo) SetMaxPerOrder(ctx context.Context, id uuid.UUID, limit *int) error {
+	ct, err := r.pool.Exec(ctx,
+		`UPDATE products SET max_per_order = $2, version = version + 1, updated_at = NOW() WHERE id = $1`,
+		id, limit,
+	)
+	if err != nil {
+		return fmt.Errorf("set max per order: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UpsertBySKU inserts the product or overwrites the one with its SKU.
+func (r *pgProductRepo) UpsertBySKU(ctx context.Context, product *model.Product, actorID *uuid.UUID, audit AuditFunc) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	old := &model.Product{}
+	err = scanProduct(tx.QueryRow(ctx, `SELECT `+productColumns+` FROM products WHERE sku = $1 FOR UPDATE`, product.SKU), old)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return false, fmt.Errorf("lock product: %w", err)
+		}
+		old = nil
+	}
+
+	var created bool
+	err = tx.QueryRow(ctx,
+		`INSERT INTO products (id, sku, name, description, price, stock, status, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, COALESCE(NULLIF($7, ''), 'active'), NOW(), NOW())
+		 ON CONFLICT (sku) DO UPDATE SET name = EXCLUDED.name, description = EXCLUDED.description,
+		   price = EXCLUDED.price, version = products.version + 1, updated_at = NOW(),
+		   status = CASE WHEN $7 = '' THEN products.status ELSE EXCLUDED.status END,
+		   deleted_at = CASE WHEN $7 = '' THEN products.deleted_at END
+		 RETURNING id, COALESCE(category, ''), weight_grams, tax_class, stock, status, version, created_at, updated_at,
+		   (xmax = 0)`,
+		uuid.New(), product.SKU, product.Name, product.Description, product.Price, product.Stock, product.Status,
+	).Scan(&product.ID, &product.Category, &product.WeightGrams, &product.TaxClass, &product.Stock, &product.Status,
+		&product.Version, &product.CreatedAt, &product.UpdatedAt, &created)
+	if err != nil {
+		return false, fmt.Errorf("upsert product: %w", err)
+	}
+	switch {
+	case created:
+		if err := recordInitialStock(ctx, tx, product, actorID); err != nil {
+			return false, err
+		}
+	case old != nil && audit != nil:
+		if entry := audit(old, product); entry != nil {
+			if err := insertProductAudit(ctx, tx, product.ID, entry); err != nil {
+				return false, err
+			}
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("commit upsert: %w", err)
+	}
+	return created, nil
+}
+
+// Each streams every product in creation order without loading the whole
+// catalogue into memory.
+func (r *pgProductRepo) Each(ctx context.Context, fn func(*model.Product) error) error {
+	rows, err := r.pool.Query(ctx, `SELECT `+productColumns+` FROM products ORDER BY created_at, id`)
+	if err != nil {
+		return fmt.Errorf("export products: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p model.Product
+		if err := scanProduct(rows, &p); err != nil {
+			return fmt.Errorf("scan product: %w", err)
+		}
+		if err := fn(&p); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate products: %w", err)
+	}
+	return nil
+}
+
+func (r *pgProductRepo) ListAudit(ctx context.Context, productID uuid.UUID, p pagination.Params) ([]model.ProductAuditEntry, pagination.Page, error) {
+	cond, tail, args := keyset(p, "", []any{productID})
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, product_id, actor_id, action, changes, created_at FROM product_audit_log `+
+			whereClause([]string{"product_id = $1", cond})+` `+tail, args...,
+	)
+	if err != nil {
+		return nil, pagination.Page{}, fmt.Errorf("list product audit: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []model.ProductAuditEntry
+	for rows.Next() {
+		var e model.ProductAuditEntry
+		var changes []byte
+		if err := rows.Scan(&e.ID, &e.ProductID, &e.ActorID, &e.Action, &changes, &e.CreatedAt); err != nil {
+			return nil, pagination.Page{}, fmt.Errorf("scan product audit: %w", err)
+		}
+		if err := json.Unmarshal(changes, &e.Changes); err != nil {
+			return nil, pagination.Page{}, fmt.Errorf("decode audit changes: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, pagination.Page{}, fmt.Errorf("iterate product audit: %w", err)
+	}
+	entries, page := pagination.Build(entries, p, auditKey)
+	return entries, page, nil
+}
+
+func auditKey(e model.ProductAuditEntry) (time.Time, uuid.UUID) { return e.CreatedAt, e.ID }
@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
 )
 
 func setupTestDB(t *testing.T) *pgxpool.Pool {
@@ -35,9 +36,9 @@ func TestProductRepository_Integration(t *testing.T) {
 	// Create
 	p := &model.Product{
 		Name: "Integration Test Product", Description: "test",
-		Price: decimal.NewFromFloat(19.99), Stock: 50,
+		Price: decimal.NewFromFloat(19.99), Stock: 50, Status: model.ProductStatusActive,
 	}
-	err := repo.Create(ctx, p)
+	err := repo.Create(ctx, p, nil)
 	require.NoError(t, err)
 	assert.NotEmpty(t, p.ID)
 
@@ -49,24 +50,62 @@ func TestProductRepository_Integration(t *testing.T) {
 	assert.True(t, p.Price.Equal(found.Price))
 
 	// Update
-	found.Stock = 42
-	err = repo.Update(ctx, found)
+	stale := *found
+	found.Name = "Renamed"
+	audit := &model.ProductAuditEntry{
+		Action:  model.ProductAuditUpdate,
+		Changes: []model.FieldChange{{Field: "name", Old: stale.Name, New: found.Name}},
+	}
+	err = repo.Update(ctx, found, audit)
+	require.NoError(t, err)
+	assert.Equal(t, stale.Version+1, found.Version)
+	assert.ErrorIs(t, repo.Update(ctx, &stale, nil), ErrVersionConflict)
+
+	entries, _, err := repo.ListAudit(ctx, p.ID, pagination.Params{Limit: 10})
 	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "Renamed", entries[0].Changes[0].New)
+
+	inventory := NewInventoryRepository(pool)
+	m := &model.InventoryMovement{ProductID: p.ID, Kind: model.MovementAdjustment, Quantity: -8, Reason: "damaged"}
+	require.NoError(t, inventory.Adjust(ctx, m))
+	assert.Equal(t, 42, m.StockAfter)
+	err = inventory.Adjust(ctx, &model.InventoryMovement{ProductID: p.ID, Kind: model.MovementAdjustment, Quantity: -100, Reason: "x"})
+	assert.ErrorIs(t, err, ErrInsufficientStock)
+
+	// Initial stock plus the adjustment add up to the on-hand level.
+	movements, _, err := inventory.ListByProductID(ctx, p.ID, pagination.Params{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, movements, 2)
+	assert.Equal(t, 42, movements[0].Quantity+movements[1].Quantity)
 
 	updated, _ := repo.GetByID(ctx, p.ID)
+	assert.Equal(t, "Renamed", updated.Name)
 	assert.Equal(t, 42, updated.Stock)
+	assert.Equal(t, found.Version, updated.Version)
 
 	// List
-	products, total, err := repo.List(ctx, 10, 0)
+	products, page, err := repo.List(ctx, ProductFilter{Status: model.ProductStatusActive}, pagination.Params{Limit: 10, WithTotal: true})
 	require.NoError(t, err)
-	assert.GreaterOrEqual(t, total, 1)
+	require.NotNil(t, page.Total)
+	assert.GreaterOrEqual(t, *page.Total, 1)
 	assert.GreaterOrEqual(t, len(products), 1)
 
-	// Delete
-	err = repo.Delete(ctx, p.ID)
+	// Archive
+	err = repo.Archive(ctx, p.ID)
+	require.NoError(t, err)
+
+	archived, err := repo.GetByID(ctx, p.ID)
 	require.NoError(t, err)
+	require.NotNil(t, archived)
+	assert.Equal(t, model.ProductStatusArchived, archived.Status)
+	assert.NotNil(t, archived.DeletedAt)
 
-	deleted, err := repo.GetByID(ctx, p.ID)
+	// Restore
+	err = repo.Restore(ctx, p.ID)
 	require.NoError(t, err)
-	assert.Nil(t, deleted)
+
+	restored, _ := repo.GetByID(ctx, p.ID)
+	assert.Equal(t, model.ProductStatusActive, restored.Status)
+	assert.Nil(t, restored.DeletedAt)
 }
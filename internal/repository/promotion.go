@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+)
+
+var (
+	ErrDuplicatePromotionCode = errors.New("duplicate promotion code")
+	// ErrPromotionUsedUp means redeeming the promotion would go over its
+	// total or per-customer usage limit.
+	ErrPromotionUsedUp = errors.New("promotion usage limit reached")
+)
+
+type PromotionRepository interface {
+	Create(ctx context.Context, p *model.Promotion) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Promotion, error)
+	GetByCode(ctx context.Context, code string) (*model.Promotion, error)
+	List(ctx context.Context, p pagination.Params) ([]model.Promotion, pagination.Page, error)
+	SetActive(ctx context.Context, id uuid.UUID, active bool) error
+	CustomerUses(ctx context.Context, promotionID, userID uuid.UUID) (int, error)
+}
+
+type pgPromotionRepo struct{ pool *pgxpool.Pool }
+
+func NewPromotionRepository(pool *pgxpool.Pool) PromotionRepository {
+	return &pgPromotionRepo{pool: pool}
+}
+
+// Redemptions by orders that failed or were cancelled do not count as uses.
+const promotionColumns = `p.id, p.code, p.description, p.kind, p.value, p.buy_quantity, p.get_quantity,
+	p.min_subtotal, p.product_ids, p.categories, p.max_uses, p.max_uses_per_customer, p.starts_at, p.ends_at,
+	p.active, p.created_at,
+	(SELECT COUNT(*) FROM promotion_redemptions pr JOIN orders o ON o.id = pr.order_id
+	 WHERE pr.promotion_id = p.id AND o.status NOT IN ('failed', 'cancelled'))`
+
+func scanPromotion(row pgx.Row, p *model.Promotion) error {
+	return row.Scan(&p.ID, &p.Code, &p.Description, &p.Kind, &p.Value, &p.BuyQuantity, &p.GetQuantity,
+		&p.MinSubtotal, &p.ProductIDs, &p.Categories, &p.MaxUses, &p.MaxUsesPerCustomer, &p.StartsAt, &p.EndsAt,
+		&p.Active, &p.CreatedAt, &p.Uses)
+}
+
+func (r *pgPromotionRepo) Create(ctx context.Context, p *model.Promotion) error {
+	p.ID = uuid.New()
+	if p.ProductIDs == nil {
+		p.ProductIDs = []uuid.UUID{}
+	}
+	if p.Categories == nil {
+		p.Categories = []string{}
+	}
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO promotions (id, code, description, kind, value, buy_quantity, get_quantity, min_subtotal,
+		   product_ids, categories, max_uses, max_uses_per_customer, starts_at, ends_at, active, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NOW(), NOW())
+		 RETURNING created_at`,
+		p.ID, p.Code, p.Description, p.Kind, p.Value, p.BuyQuantity, p.GetQuantity, p.MinSubtotal,
+		p.ProductIDs, p.Categories, p.MaxUses, p.MaxUsesPerCustomer, p.StartsAt, p.EndsAt, p.Active,
+	).Scan(&p.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err, "promotions_code_key") {
+			return ErrDuplicatePromotionCode
+		}
+		return fmt.Errorf("insert promotion: %w", err)
+	}
+	return nil
+}
+
+func (r *pgPromotionRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.Promotion, error) {
+	return r.get(ctx, `p.id = $1`, id)
+}
+
+// GetByCode looks a promotion up by its upper-case code.
+func (r *pgPromotionRepo) GetByCode(ctx context.Context, code string) (*model.Promotion, error) {
+	return r.get(ctx, `p.code = $1`, code)
+}
+
+func (r *pgPromotionRepo) get(ctx context.Context, cond string, arg any) (*model.Promotion, error) {
+	p := &model.Promotion{}
+	err := scanPromotion(r.pool.QueryRow(ctx, `SELECT `+promotionColumns+` FROM promotions p WHERE `+cond, arg), p)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get promotion: %w", err)
+	}
+	return p, nil
+}
+
+// List returns promotions newest first.
+func (r *pgPromotionRepo) List(ctx context.Context, p pagination.Params) ([]model.Promotion, pagination.Page, error) {
+	cond, tail, args := keyset(p, "p.", nil)
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+promotionColumns+` FROM promotions p `+whereClause([]string{cond})+` `+tail, args...,
+	)
+	if err != nil {
+		return nil, pagination.Page{}, fmt.Errorf("list promotions: %w", err)
+	}
+	defer rows.Close()
+
+	var promotions []model.Promotion
+	for rows.Next() {
+		var promo model.Promotion
+		if err := scanPromotion(rows, &promo); err != nil {
+			return nil, pagination.Page{}, fmt.Errorf("scan promotion: %w", err)
+		}
+		promotions = append(promotions, promo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, pagination.Page{}, fmt.Errorf("iterate promotions: %w", err)
+	}
+	promotions, page := pagination.Build(promotions, p, promotionKey)
+	return promotions, page, nil
+}
+
+func (r *pgPromotionRepo) SetActive(ctx context.Context, id uuid.UUID, active bool) error {
+	ct, err := r.pool.Exec(ctx,
+		`UPDATE promotions SET active = $2, updated_at = NOW() WHERE id = $1`, id, active,
+	)
+	if err != nil {
+		return fmt.Errorf("set promotion active: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CustomerUses counts the user's redemptions of the promotion.
+func (r *pgPromotionRepo) CustomerUses(ctx context.Context, promotionID, userID uuid.UUID) (int, error) {
+	var n int
+	err := r.pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM promotion_redemptions pr JOIN orders o ON o.id = pr.order_id
+		 WHERE pr.promotion_id = $1 AND pr.user_id = $2 AND o.status NOT IN ('failed', 'cancelled')`, promotionID, userID,
+	).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count promotion uses: %w", err)
+	}
+	return n, nil
+}
+
+// redeemPromotion records the order's use of its promotion, holding a lock
+// on the promotion so concurrent orders cannot both take the last use.
+func redeemPromotion(ctx context.Context, tx pgx.Tx, order *model.Order) error {
+	var maxUses, maxPerCustomer *int
+	err := tx.QueryRow(ctx,
+		`SELECT max_uses, max_uses_per_customer FROM promotions WHERE id = $1 FOR UPDATE`, order.PromotionID,
+	).Scan(&maxUses, &maxPerCustomer)
+	if err != nil {
+		return fmt.Errorf("lock promotion: %w", err)
+	}
+	var total, byCustomer int
+	err = tx.QueryRow(ctx,
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE pr.user_id = $2) FROM promotion_redemptions pr
+		 JOIN orders o ON o.id = pr.order_id WHERE pr.promotion_id = $1 AND o.status NOT IN ('failed', 'cancelled')`,
+		order.PromotionID, order.UserID,
+	).Scan(&total, &byCustomer)
+	if err != nil {
+		return fmt.Errorf("count promotion uses: %w", err)
+	}
+	if (maxUses != nil && total >= *maxUses) || (maxPerCustomer != nil && byCustomer >= *maxPerCustomer) {
+		return ErrPromotionUsedUp
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO promotion_redemptions (id, promotion_id, order_id, user_id, created_at)
+		 VALUES ($1, $2, $3, $4, NOW())`,
+		uuid.New(), order.PromotionID, order.ID, order.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("insert promotion redemption: %w", err)
+	}
+	return nil
+}
+
+// releasePromotion deletes the order's redemption, if any, so that its use
+// of the promotion counts no longer.
+func releasePromotion(ctx context.Context, tx pgx.Tx, orderID uuid.UUID) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM promotion_redemptions WHERE order_id = $1`, orderID); err != nil {
+		return fmt.Errorf("release promotion redemption: %w", err)
+	}
+	return nil
+}
+
+func promotionKey(p model.Promotion) (time.Time, uuid.UUID) { return p.CreatedAt, p.ID }
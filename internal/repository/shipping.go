@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+var (
+	ErrDuplicateZoneName = errors.New("duplicate shipping zone name")
+	// ErrZoneNotFound means a rate names a shipping zone that does not exist.
+	ErrZoneNotFound = errors.New("shipping zone not found")
+)
+
+// ShippingRepository stores the shipping rate table. It satisfies
+// shipping.RateSource.
+type ShippingRepository interface {
+	CreateZone(ctx context.Context, z *model.ShippingZone) error
+	ListZones(ctx context.Context) ([]model.ShippingZone, error)
+	DeleteZone(ctx context.Context, id uuid.UUID) error
+	CreateRate(ctx context.Context, r *model.ShippingRate) error
+	ListRates(ctx context.Context) ([]model.ShippingRate, error)
+	DeleteRate(ctx context.Context, id uuid.UUID) error
+	RatesFor(ctx context.Context, country string) ([]model.ShippingRate, error)
+}
+
+type pgShippingRepo struct{ pool *pgxpool.Pool }
+
+func NewShippingRepository(pool *pgxpool.Pool) ShippingRepository {
+	return &pgShippingRepo{pool: pool}
+}
+
+func (r *pgShippingRepo) CreateZone(ctx context.Context, z *model.ShippingZone) error {
+	z.ID = uuid.New()
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO shipping_zones (id, name, countries, created_at) VALUES ($1, $2, $3, NOW()) RETURNING created_at`,
+		z.ID, z.Name, z.Countries,
+	).Scan(&z.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err, "shipping_zones_name_key") {
+			return ErrDuplicateZoneName
+		}
+		return fmt.Errorf("insert shipping zone: %w", err)
+	}
+	return nil
+}
+
+func (r *pgShippingRepo) ListZones(ctx context.Context) ([]model.ShippingZone, error) {
+	rows, err := r.pool.Query(ctx, `SELECT id, name, countries, created_at FROM shipping_zones ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("list shipping zones: %w", err)
+	}
+	defer rows.Close()
+
+	var zones []model.ShippingZone
+	for rows.Next() {
+		var z model.ShippingZone
+		if err := rows.Scan(&z.ID, &z.Name, &z.Countries, &z.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan shipping zone: %w", err)
+		}
+		zones = append(zones, z)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate shipping zones: %w", err)
+	}
+	return zones, nil
+}
+
+// DeleteZone removes the zone together with its rates.
+func (r *pgShippingRepo) DeleteZone(ctx context.Context, id uuid.UUID) error {
+	ct, err := r.pool.Exec(ctx, `DELETE FROM shipping_zones WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete shipping zone: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+const shippingRateColumns = `id, method, zone_id, min_weight_grams, max_weight_grams, min_subtotal, max_subtotal,
+	price, price_per_kg, created_at`
+
+func scanShippingRate(row pgx.Row, sr *model.ShippingRate) error {
+	return row.Scan(&sr.ID, &sr.Method, &sr.ZoneID, &sr.MinWeightGrams, &sr.MaxWeightGrams, &sr.MinSubtotal,
+		&sr.MaxSubtotal, &sr.Price, &sr.PricePerKg, &sr.CreatedAt)
+}
+
+func (r *pgShippingRepo) CreateRate(ctx context.Context, sr *model.ShippingRate) error {
+	sr.ID = uuid.New()
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO shipping_rates (id, method, zone_id, min_weight_grams, max_weight_grams, min_subtotal,
+		   max_subtotal, price, price_per_kg, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW()) RETURNING created_at`,
+		sr.ID, sr.Method, sr.ZoneID, sr.MinWeightGrams, sr.MaxWeightGrams, sr.MinSubtotal, sr.MaxSubtotal,
+		sr.Price, sr.PricePerKg,
+	).Scan(&sr.CreatedAt)
+	if err != nil {
+		if isForeignKeyViolation(err, "shipping_rates_zone_id_fkey") {
+			return ErrZoneNotFound
+		}
+		return fmt.Errorf("insert shipping rate: %w", err)
+	}
+	return nil
+}
+
+func (r *pgShippingRepo) ListRates(ctx context.Context) ([]model.ShippingRate, error) {
+	return r.listRates(ctx, `SELECT `+shippingRateColumns+` FROM shipping_rates
+		ORDER BY zone_id NULLS FIRST, method, min_weight_grams, min_subtotal`)
+}
+
+// RatesFor returns the rates of the zone country belongs to and the rates
+// that apply to every destination.
+func (r *pgShippingRepo) RatesFor(ctx context.Context, country string) ([]model.ShippingRate, error) {
+	return r.listRates(ctx, `SELECT `+shippingRateColumns+` FROM shipping_rates
+		WHERE zone_id IS NULL OR zone_id IN (SELECT id FROM shipping_zones WHERE $1 = ANY(countries))`, country)
+}
+
+func (r *pgShippingRepo) listRates(ctx context.Context, sql string, args ...any) ([]model.ShippingRate, error) {
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list shipping rates: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []model.ShippingRate
+	for rows.Next() {
+		var sr model.ShippingRate
+		if err := scanShippingRate(rows, &sr); err != nil {
+			return nil, fmt.Errorf("scan shipping rate: %w", err)
+		}
+		rates = append(rates, sr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate shipping rates: %w", err)
+	}
+	return rates, nil
+}
+
+func (r *pgShippingRepo) DeleteRate(ctx context.Context, id uuid.UUID) error {
+	ct, err := r.pool.Exec(ctx, `DELETE FROM shipping_rates WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete shipping rate: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+type StockAlertRepository interface {
+	SetThreshold(ctx context.Context, productID uuid.UUID, threshold *int) error
+	Thresholds(ctx context.Context, productIDs []uuid.UUID) (map[uuid.UUID]int, error)
+	Subscribe(ctx context.Context, sub *model.StockSubscription) error
+	Unsubscribe(ctx context.Context, productID, userID uuid.UUID) error
+}
+
+type pgStockAlertRepo struct{ pool *pgxpool.Pool }
+
+func NewStockAlertRepository(pool *pgxpool.Pool) StockAlertRepository {
+	return &pgStockAlertRepo{pool: pool}
+}
+
+// SetThreshold sets the product's reorder threshold; nil turns low-stock
+// alerts off.
+func (r *pgStockAlertRepo) SetThreshold(ctx context.Context, productID uuid.UUID, threshold *int) error {
+	ct, err := r.pool.Exec(ctx,
+		`UPDATE products SET reorder_threshold = $2 WHERE id = $1`, productID, threshold,
+	)
+	if err != nil {
+		return fmt.Errorf("set reorder threshold: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Thresholds returns the reorder thresholds of those products that have one.
+func (r *pgStockAlertRepo) Thresholds(ctx context.Context, productIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, reorder_threshold FROM products WHERE id = ANY($1) AND reorder_threshold IS NOT NULL`, productIDs,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get reorder thresholds: %w", err)
+	}
+	defer rows.Close()
+
+	thresholds := make(map[uuid.UUID]int)
+	for rows.Next() {
+		var id uuid.UUID
+		var threshold int
+		if err := rows.Scan(&id, &threshold); err != nil {
+			return nil, fmt.Errorf("scan reorder threshold: %w", err)
+		}
+		thresholds[id] = threshold
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate reorder thresholds: %w", err)
+	}
+	return thresholds, nil
+}
+
+// Subscribe stores a pending back-in-stock subscription. Subscribing twice
+// is harmless: sub is filled in from the pending subscription that exists.
+func (r *pgStockAlertRepo) Subscribe(ctx context.Context, sub *model.StockSubscription) error {
+	sub.ID = uuid.New()
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO stock_subscriptions (id, product_id, user_id, created_at) VALUES ($1, $2, $3, NOW())
+		 ON CONFLICT (product_id, user_id) WHERE notified_at IS NULL DO NOTHING
+		 RETURNING created_at`,
+		sub.ID, sub.ProductID, sub.UserID,
+	).Scan(&sub.CreatedAt)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("insert stock subscription: %w", err)
+	}
+	err = r.pool.QueryRow(ctx,
+		`SELECT id, created_at FROM stock_subscriptions
+		 WHERE product_id = $1 AND user_id = $2 AND notified_at IS NULL`,
+		sub.ProductID, sub.UserID,
+	).Scan(&sub.ID, &sub.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("get stock subscription: %w", err)
+	}
+	return nil
+}
+
+func (r *pgStockAlertRepo) Unsubscribe(ctx context.Context, productID, userID uuid.UUID) error {
+	ct, err := r.pool.Exec(ctx,
+		`DELETE FROM stock_subscriptions WHERE product_id = $1 AND user_id = $2 AND notified_at IS NULL`,
+		productID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("delete stock subscription: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
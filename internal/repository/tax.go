@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+// ErrDuplicateTaxRate means the country, region and tax class already have
+// a rate.
+var ErrDuplicateTaxRate = errors.New("duplicate tax rate")
+
+// TaxRepository stores the tax rate table. It satisfies tax.RateSource.
+type TaxRepository interface {
+	CreateRate(ctx context.Context, r *model.TaxRate) error
+	ListRates(ctx context.Context) ([]model.TaxRate, error)
+	DeleteRate(ctx context.Context, id uuid.UUID) error
+	TaxRatesFor(ctx context.Context, country string) ([]model.TaxRate, error)
+}
+
+type pgTaxRepo struct{ pool *pgxpool.Pool }
+
+func NewTaxRepository(pool *pgxpool.Pool) TaxRepository {
+	return &pgTaxRepo{pool: pool}
+}
+
+const taxRateColumns = `id, country, region, tax_class, name, rate, created_at`
+
+func (r *pgTaxRepo) CreateRate(ctx context.Context, tr *model.TaxRate) error {
+	tr.ID = uuid.New()
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO tax_rates (id, country, region, tax_class, name, rate, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, NOW()) RETURNING created_at`,
+		tr.ID, tr.Country, tr.Region, tr.TaxClass, tr.Name, tr.Rate,
+	).Scan(&tr.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err, "tax_rates_country_region_tax_class_key") {
+			return ErrDuplicateTaxRate
+		}
+		return fmt.Errorf("insert tax rate: %w", err)
+	}
+	return nil
+}
+
+func (r *pgTaxRepo) ListRates(ctx context.Context) ([]model.TaxRate, error) {
+	return r.listRates(ctx, `SELECT `+taxRateColumns+` FROM tax_rates ORDER BY country, region, tax_class`)
+}
+
+func (r *pgTaxRepo) TaxRatesFor(ctx context.Context, country string) ([]model.TaxRate, error) {
+	return r.listRates(ctx, `SELECT `+taxRateColumns+` FROM tax_rates WHERE country = $1`, country)
+}
+
+func (r *pgTaxRepo) listRates(ctx context.Context, sql string, args ...any) ([]model.TaxRate, error) {
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list tax rates: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []model.TaxRate
+	for rows.Next() {
+		var tr model.TaxRate
+		if err := rows.Scan(&tr.ID, &tr.Country, &tr.Region, &tr.TaxClass, &tr.Name, &tr.Rate, &tr.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan tax rate: %w", err)
+		}
+		rates = append(rates, tr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate tax rates: %w", err)
+	}
+	return rates, nil
+}
+
+func (r *pgTaxRepo) DeleteRate(ctx context.Context, id uuid.UUID) error {
+	ct, err := r.pool.Exec(ctx, `DELETE FROM tax_rates WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete tax rate: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+var ErrDuplicateWarehouseCode = errors.New("duplicate warehouse code")
+
+type WarehouseRepository interface {
+	Create(ctx context.Context, w *model.Warehouse) error
+	List(ctx context.Context) ([]model.Warehouse, error)
+	StockByProductID(ctx context.Context, productID uuid.UUID) ([]model.WarehouseStock, error)
+}
+
+type pgWarehouseRepo struct{ pool *pgxpool.Pool }
+
+func NewWarehouseRepository(pool *pgxpool.Pool) WarehouseRepository {
+	return &pgWarehouseRepo{pool: pool}
+}
+
+func (r *pgWarehouseRepo) Create(ctx context.Context, w *model.Warehouse) error {
+	w.ID = uuid.New()
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO warehouses (id, code, name, priority, active, created_at)
+		 VALUES ($1, $2, $3, $4, $5, NOW()) RETURNING created_at`,
+		w.ID, w.Code, w.Name, w.Priority, w.Active,
+	).Scan(&w.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err, "warehouses_code_key") {
+			return ErrDuplicateWarehouseCode
+		}
+		return fmt.Errorf("insert warehouse: %w", err)
+	}
+	return nil
+}
+
+// List returns all warehouses in allocation order.
+func (r *pgWarehouseRepo) List(ctx context.Context) ([]model.Warehouse, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, code, name, priority, active, created_at FROM warehouses ORDER BY priority, code`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list warehouses: %w", err)
+	}
+	defer rows.Close()
+
+	var warehouses []model.Warehouse
+	for rows.Next() {
+		var w model.Warehouse
+		if err := rows.Scan(&w.ID, &w.Code, &w.Name, &w.Priority, &w.Active, &w.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan warehouse: %w", err)
+		}
+		warehouses = append(warehouses, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate warehouses: %w", err)
+	}
+	return warehouses, nil
+}
+
+// StockByProductID returns the product's stock in every warehouse that has
+// held it, in allocation order.
+func (r *pgWarehouseRepo) StockByProductID(ctx context.Context, productID uuid.UUID) ([]model.WarehouseStock, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT ws.warehouse_id, w.code, ws.quantity
+		 FROM warehouse_stock ws JOIN warehouses w ON w.id = ws.warehouse_id
+		 WHERE ws.product_id = $1 ORDER BY w.priority, w.code`, productID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get warehouse stock: %w", err)
+	}
+	defer rows.Close()
+
+	var levels []model.WarehouseStock
+	for rows.Next() {
+		s := model.WarehouseStock{ProductID: productID}
+		if err := rows.Scan(&s.WarehouseID, &s.WarehouseCode, &s.Quantity); err != nil {
+			return nil, fmt.Errorf("scan warehouse stock: %w", err)
+		}
+		levels = append(levels, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate warehouse stock: %w", err)
+	}
+	return levels, nil
+}
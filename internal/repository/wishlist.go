@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+var ErrDuplicateWishlistName = errors.New("duplicate wishlist name")
+
+type WishlistRepository interface {
+	Create(ctx context.Context, w *model.Wishlist) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Wishlist, error)
+	GetByShareToken(ctx context.Context, token string) (*model.Wishlist, error)
+	ListByUserID(ctx context.Context, userID uuid.UUID) ([]model.Wishlist, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	SetShareToken(ctx context.Context, id uuid.UUID, token *string) error
+	AddItem(ctx context.Context, item *model.WishlistItem) error
+	DeleteItem(ctx context.Context, wishlistID, itemID uuid.UUID) error
+	MoveToCart(ctx context.Context, itemID uuid.UUID, cartItem *model.CartItem) error
+	MoveFromCart(ctx context.Context, cartItemID, wishlistID uuid.UUID) error
+}
+
+type pgWishlistRepo struct{ pool *pgxpool.Pool }
+
+func NewWishlistRepository(pool *pgxpool.Pool) WishlistRepository {
+	return &pgWishlistRepo{pool: pool}
+}
+
+func (r *pgWishlistRepo) Create(ctx context.Context, w *model.Wishlist) error {
+	w.ID = uuid.New()
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO wishlists (id, user_id, name, created_at, updated_at) VALUES ($1, $2, $3, NOW(), NOW())
+		 RETURNING created_at`,
+		w.ID, w.UserID, w.Name,
+	).Scan(&w.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err, "wishlists_user_id_name_key") {
+			return ErrDuplicateWishlistName
+		}
+		return fmt.Errorf("insert wishlist: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns the wishlist with its items, or nil if there is none.
+func (r *pgWishlistRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.Wishlist, error) {
+	return r.get(ctx, `id = $1`, id)
+}
+
+// GetByShareToken returns the wishlist shared under token, with its items.
+func (r *pgWishlistRepo) GetByShareToken(ctx context.Context, token string) (*model.Wishlist, error) {
+	return r.get(ctx, `share_token = $1`, token)
+}
+
+func (r *pgWishlistRepo) get(ctx context.Context, cond string, arg any) (*model.Wishlist, error) {
+	w := &model.Wishlist{}
+	err := r.pool.QueryRow(ctx,
+		`SELECT id, user_id, name, share_token, created_at FROM wishlists WHERE `+cond, arg,
+	).Scan(&w.ID, &w.UserID, &w.Name, &w.ShareToken, &w.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get wishlist: %w", err)
+	}
+	items, err := r.items(ctx, []uuid.UUID{w.ID})
+	if err != nil {
+		return nil, err
+	}
+	w.Items = items[w.ID]
+	return w, nil
+}
+
+// ListByUserID returns the user's wishlists with their items, by name.
+func (r *pgWishlistRepo) ListByUserID(ctx context.Context, userID uuid.UUID) ([]model.Wishlist, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, user_id, name, share_token, created_at FROM wishlists WHERE user_id = $1 ORDER BY name`, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list wishlists: %w", err)
+	}
+	defer rows.Close()
+
+	var wishlists []model.Wishlist
+	var ids []uuid.UUID
+	for rows.Next() {
+		var w model.Wishlist
+		if err := rows.Scan(&w.ID, &w.UserID, &w.Name, &w.ShareToken, &w.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan wishlist: %w", err)
+		}
+		wishlists = append(wishlists, w)
+		ids = append(ids, w.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate wishlists: %w", err)
+	}
+	items, err := r.items(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range wishlists {
+		wishlists[i].Items = items[wishlists[i].ID]
+	}
+	return wishlists, nil
+}
+
+// items returns the wishlists' items joined with their products, in the
+// order they were added.
+func (r *pgWishlistRepo) items(ctx context.Context, wishlistIDs []uuid.UUID) (map[uuid.UUID][]model.WishlistItem, error) {
+	result := make(map[uuid.UUID][]model.WishlistItem, len(wishlistIDs))
+	if len(wishlistIDs) == 0 {
+		return result, nil
+	}
+	rows, err := r.pool.Query(ctx,
+		`SELECT wi.id, wi.wishlist_id, wi.product_id, p.name, p.price, p.stock, p.status, wi.created_at
+		 FROM wishlist_items wi JOIN products p ON p.id = wi.product_id
+		 WHERE wi.wishlist_id = ANY($1)
+		 ORDER BY wi.created_at, wi.id`, wishlistIDs,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list wishlist items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item model.WishlistItem
+		if err := rows.Scan(&item.ID, &item.WishlistID, &item.ProductID, &item.ProductName, &item.Price,
+			&item.Stock, &item.ProductStatus, &item.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan wishlist item: %w", err)
+		}
+		result[item.WishlistID] = append(result[item.WishlistID], item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate wishlist items: %w", err)
+	}
+	return result, nil
+}
+
+func (r *pgWishlistRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	ct, err := r.pool.Exec(ctx, `DELETE FROM wishlists WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete wishlist: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetShareToken shares the wishlist under token; nil stops sharing it.
+func (r *pgWishlistRepo) SetShareToken(ctx context.Context, id uuid.UUID, token *string) error {
+	ct, err := r.pool.Exec(ctx,
+		`UPDATE wishlists SET share_token = $2, updated_at = NOW() WHERE id = $1`, id, token,
+	)
+	if err != nil {
+		return fmt.Errorf("set wishlist share token: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AddItem puts a product on the wishlist. A product already there keeps its
+// item, whose ID is returned in item.
+func (r *pgWishlistRepo) AddItem(ctx context.Context, item *model.WishlistItem) error {
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO wishlist_items (id, wishlist_id, product_id, created_at) VALUES ($1, $2, $3, NOW())
+		 ON CONFLICT (wishlist_id, product_id) DO UPDATE SET wishlist_id = EXCLUDED.wishlist_id
+		 RETURNING id, created_at`,
+		uuid.New(), item.WishlistID, item.ProductID,
+	).Scan(&item.ID, &item.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("add wishlist item: %w", err)
+	}
+	return nil
+}
+
+func (r *pgWishlistRepo) DeleteItem(ctx context.Context, wishlistID, itemID uuid.UUID) error {
+	ct, err := r.pool.Exec(ctx, `DELETE FROM wishlist_items WHERE id = $1 AND wishlist_id = $2`, itemID, wishlistID)
+	if err != nil {
+		return fmt.Errorf("delete wishlist item: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MoveToCart adds cartItem to its cart as CartRepository.AddItem does and
+// takes the wishlist item off its wishlist, in one transaction.
+func (r *pgWishlistRepo) MoveToCart(ctx context.Context, itemID uuid.UUID, cartItem *model.CartItem) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	ct, err := tx.Exec(ctx, `DELETE FROM wishlist_items WHERE id = $1`, itemID)
+	if err != nil {
+		return fmt.Errorf("delete wishlist item: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	cartItem.ID = uuid.New()
+	_, err = tx.Exec(ctx, addCartItemSQL,
+		cartItem.ID, cartItem.CartID, cartItem.ProductID, cartItem.Quantity, cartItem.PriceAtAdd,
+	)
+	if err != nil {
+		return fmt.Errorf("add cart item: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// MoveFromCart puts a cart item's product on the wishlist and removes the
+// item from its cart, in one transaction.
+func (r *pgWishlistRepo) MoveFromCart(ctx context.Context, cartItemID, wishlistID uuid.UUID) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit is no-op
+
+	var productID uuid.UUID
+	err = tx.QueryRow(ctx, `DELETE FROM cart_items WHERE id = $1 RETURNING product_id`, cartItemID).Scan(&productID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("delete cart item: %w", err)
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO wishlist_items (id, wishlist_id, product_id, created_at) VALUES ($1, $2, $3, NOW())
+		 ON CONFLICT (wishlist_id, product_id) DO NOTHING`,
+		uuid.New(), wishlistID, productID,
+	)
+	if err != nil {
+		return fmt.Errorf("add wishlist item: %w", err)
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+var (
+	ErrAddressNotFound = errors.New("address not found")
+	ErrInvalidAddress  = errors.New("invalid address")
+)
+
+// AddressService manages users' address books. Another user's address is
+// reported as not found.
+type AddressService struct {
+	repo repository.AddressRepository
+}
+
+func NewAddressService(repo repository.AddressRepository) *AddressService {
+	return &AddressService{repo: repo}
+}
+
+// Create adds an address. The user's first address becomes their default
+// shipping and billing address.
+func (s *AddressService) Create(ctx context.Context, userID uuid.UUID, req dto.AddressRequest) (*dto.AddressResponse, error) {
+	a := &model.Address{
+		UserID: userID, Label: strings.TrimSpace(req.Label), PostalAddress: toPostalAddress(req.PostalAddress),
+		DefaultShipping: req.DefaultShipping, DefaultBilling: req.DefaultBilling,
+	}
+	if err := s.repo.Create(ctx, a); err != nil {
+		return nil, fmt.Errorf("create address: %w", err)
+	}
+	resp := toAddressResponse(a)
+	return &resp, nil
+}
+
+func (s *AddressService) List(ctx context.Context, userID uuid.UUID) (*dto.AddressListResponse, error) {
+	addresses, err := s.repo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list addresses: %w", err)
+	}
+	resp := &dto.AddressListResponse{Addresses: make([]dto.AddressResponse, len(addresses))}
+	for i := range addresses {
+		resp.Addresses[i] = toAddressResponse(&addresses[i])
+	}
+	return resp, nil
+}
+
+func (s *AddressService) Get(ctx context.Context, userID, id uuid.UUID) (*dto.AddressResponse, error) {
+	a, err := userAddress(ctx, s.repo, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	resp := toAddressResponse(a)
+	return &resp, nil
+}
+
+// Update replaces an address. Orders already placed keep their copy.
+func (s *AddressService) Update(ctx context.Context, userID, id uuid.UUID, req dto.AddressRequest) (*dto.AddressResponse, error) {
+	a, err := userAddress(ctx, s.repo, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	a.Label, a.PostalAddress = strings.TrimSpace(req.Label), toPostalAddress(req.PostalAddress)
+	a.DefaultShipping, a.DefaultBilling = req.DefaultShipping, req.DefaultBilling
+	if err := s.repo.Update(ctx, a); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrAddressNotFound
+		}
+		return nil, fmt.Errorf("update address: %w", err)
+	}
+	resp := toAddressResponse(a)
+	return &resp, nil
+}
+
+func (s *AddressService) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	if _, err := userAddress(ctx, s.repo, userID, id); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrAddressNotFound
+		}
+		return fmt.Errorf("delete address: %w", err)
+	}
+	return nil
+}
+
+// userAddress returns the user's address, or ErrAddressNotFound.
+func userAddress(ctx context.Context, repo repository.AddressRepository, userID, id uuid.UUID) (*model.Address, error) {
+	a, err := repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get address: %w", err)
+	}
+	if a == nil || a.UserID != userID {
+		return nil, ErrAddressNotFound
+	}
+	return a, nil
+}
+
+// toPostalAddress trims the fields and upper-cases the country code.
+func toPostalAddress(a dto.PostalAddress) model.PostalAddress {
+	return model.PostalAddress{
+		FullName: strings.TrimSpace(a.FullName), Line1: strings.TrimSpace(a.Line1), Line2: strings.TrimSpace(a.Line2),
+		City: strings.TrimSpace(a.City), Region: strings.TrimSpace(a.Region),
+		PostalCode: strings.TrimSpace(a.PostalCode), Country: strings.ToUpper(a.Country),
+		Phone: strings.TrimSpace(a.Phone),
+	}
+}
+
+func toAddressResponse(a *model.Address) dto.AddressResponse {
+	return dto.AddressResponse{
+		ID: a.ID, Label: a.Label, PostalAddress: dto.PostalAddress(a.PostalAddress),
+		DefaultShipping: a.DefaultShipping, DefaultBilling: a.DefaultBilling, CreatedAt: a.CreatedAt,
+	}
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+// mockAddressRepo keeps one default of each kind per user, as the unique
+// indexes do.
+type mockAddressRepo struct {
+	addresses map[uuid.UUID]*model.Address
+}
+
+func newMockAddressRepo() *mockAddressRepo {
+	return &mockAddressRepo{addresses: make(map[uuid.UUID]*model.Address)}
+}
+
+func (m *mockAddressRepo) Create(_ context.Context, a *model.Address) error {
+	var hasShipping, hasBilling bool
+	for _, existing := range m.addresses {
+		if existing.UserID == a.UserID {
+			hasShipping = hasShipping || existing.DefaultShipping
+			hasBilling = hasBilling || existing.DefaultBilling
+		}
+	}
+	a.DefaultShipping = a.DefaultShipping || !hasShipping
+	a.DefaultBilling = a.DefaultBilling || !hasBilling
+	a.ID, a.CreatedAt = uuid.New(), time.Now()
+	m.clearDefaults(a)
+	stored := *a
+	m.addresses[a.ID] = &stored
+	return nil
+}
+
+func (m *mockAddressRepo) clearDefaults(a *model.Address) {
+	for _, other := range m.addresses {
+		if other.UserID == a.UserID && other.ID != a.ID {
+			other.DefaultShipping = other.DefaultShipping && !a.DefaultShipping
+			other.DefaultBilling = other.DefaultBilling && !a.DefaultBilling
+		}
+	}
+}
+
+func (m *mockAddressRepo) GetByID(_ context.Context, id uuid.UUID) (*model.Address, error) {
+	a, ok := m.addresses[id]
+	if !ok {
+		return nil, nil
+	}
+	out := *a
+	return &out, nil
+}
+
+func (m *mockAddressRepo) ListByUserID(_ context.Context, userID uuid.UUID) ([]model.Address, error) {
+	var out []model.Address
+	for _, a := range m.addresses {
+		if a.UserID == userID {
+			out = append(out, *a)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (m *mockAddressRepo) Update(_ context.Context, a *model.Address) error {
+	if _, ok := m.addresses[a.ID]; !ok {
+		return repository.ErrNotFound
+	}
+	m.clearDefaults(a)
+	stored := *a
+	m.addresses[a.ID] = &stored
+	return nil
+}
+
+func (m *mockAddressRepo) Delete(_ context.Context, id uuid.UUID) error {
+	if _, ok := m.addresses[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(m.addresses, id)
+	return nil
+}
+
+func testPostalAddress(city string) dto.PostalAddress {
+	return dto.PostalAddress{FullName: "Ann Smith", Line1: "1 Main St", City: city, PostalCode: "10115", Country: "de"}
+}
+
+// testOrderRequest ships an order to an inline address.
+func testOrderRequest() dto.CreateOrderRequest {
+	a := testPostalAddress("Berlin")
+	return dto.CreateOrderRequest{ShippingAddress: &a}
+}
+
+func TestAddressService_Defaults(t *testing.T) {
+	svc := NewAddressService(newMockAddressRepo())
+	ctx := context.Background()
+	owner, other := uuid.New(), uuid.New()
+
+	home, err := svc.Create(ctx, owner, dto.AddressRequest{Label: " Home ", PostalAddress: testPostalAddress(" Berlin ")})
+	require.NoError(t, err)
+	assert.Equal(t, "Home", home.Label)
+	assert.Equal(t, "Berlin", home.City)
+	assert.Equal(t, "DE", home.Country)
+	assert.True(t, home.DefaultShipping, "first address is the default")
+	assert.True(t, home.DefaultBilling)
+
+	// A new default shipping address takes over only that role.
+	work, err := svc.Create(ctx, owner, dto.AddressRequest{Label: "Work", PostalAddress: testPostalAddress("Munich"), DefaultShipping: true})
+	require.NoError(t, err)
+	assert.True(t, work.DefaultShipping)
+	assert.False(t, work.DefaultBilling)
+	home, err = svc.Get(ctx, owner, home.ID)
+	require.NoError(t, err)
+	assert.False(t, home.DefaultShipping)
+	assert.True(t, home.DefaultBilling)
+
+	_, err = svc.Update(ctx, owner, work.ID, dto.AddressRequest{Label: "Work", PostalAddress: testPostalAddress("Hamburg"), DefaultShipping: true, DefaultBilling: true})
+	require.NoError(t, err)
+	list, err := svc.List(ctx, owner)
+	require.NoError(t, err)
+	require.Len(t, list.Addresses, 2)
+	assert.False(t, list.Addresses[0].DefaultBilling)
+	assert.Equal(t, "Hamburg", list.Addresses[1].City)
+
+	// Other users cannot see or change it.
+	_, err = svc.Get(ctx, other, home.ID)
+	assert.ErrorIs(t, err, ErrAddressNotFound)
+	_, err = svc.Update(ctx, other, home.ID, dto.AddressRequest{PostalAddress: testPostalAddress("Paris")})
+	assert.ErrorIs(t, err, ErrAddressNotFound)
+	assert.ErrorIs(t, svc.Delete(ctx, other, home.ID), ErrAddressNotFound)
+
+	require.NoError(t, svc.Delete(ctx, owner, home.ID))
+	_, err = svc.Get(ctx, owner, home.ID)
+	assert.ErrorIs(t, err, ErrAddressNotFound)
+}
+
+func TestOrderService_CreateOrder_Addresses(t *testing.T) {
+	cartRepo, productRepo, addressRepo := newMockCartRepo(), newMockProductRepo(), newMockAddressRepo()
+	cartRepo.products = productRepo
+	pid := newActiveProduct(productRepo, 100)
+	ctx := context.Background()
+	userID := uuid.New()
+	carts := NewCartService(cartRepo, productRepo, newMockPromotionRepo(), nil, nil, newTestPrices(), time.Hour, "")
+	orders := NewOrderService(newMockOrderRepo(), cartRepo, productRepo, newMockPromotionRepo(), addressRepo, newFlatShipping(), newTaxTable(false), newTestPrices(), nil)
+	addresses := NewAddressService(addressRepo)
+	checkout := func(req dto.CreateOrderRequest) (*model.Order, error) {
+		t.Helper()
+		_, err := carts.AddItem(ctx, CartRef{UserID: userID}, pid, 1)
+		require.NoError(t, err)
+		return orders.CreateOrder(ctx, userID, req)
+	}
+
+	_, err := checkout(dto.CreateOrderRequest{})
+	assert.ErrorIs(t, err, ErrShippingAddressRequired)
+
+	// Without a saved address billing falls back to shipping.
+	order, err := checkout(testOrderRequest())
+	require.NoError(t, err)
+	require.NotNil(t, order.ShippingAddress)
+	assert.Equal(t, "DE", order.ShippingAddress.Country)
+	assert.Equal(t, order.ShippingAddress, order.BillingAddress)
+
+	home, err := addresses.Create(ctx, userID, dto.AddressRequest{Label: "Home", PostalAddress: testPostalAddress("Berlin")})
+	require.NoError(t, err)
+	office, err := addresses.Create(ctx, userID, dto.AddressRequest{Label: "Office", PostalAddress: testPostalAddress("Munich")})
+	require.NoError(t, err)
+
+	// The defaults are used when the request names none.
+	order, err = checkout(dto.CreateOrderRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "Berlin", order.ShippingAddress.City)
+	assert.Equal(t, "Berlin", order.BillingAddress.City)
+
+	order, err = checkout(dto.CreateOrderRequest{ShippingAddressID: &office.ID})
+	require.NoError(t, err)
+	assert.Equal(t, "Munich", order.ShippingAddress.City)
+	assert.Equal(t, "Berlin", order.BillingAddress.City)
+
+	// The order keeps its copy when the address changes.
+	_, err = addresses.Update(ctx, userID, office.ID, dto.AddressRequest{Label: "Office", PostalAddress: testPostalAddress("Hamburg")})
+	require.NoError(t, err)
+	assert.Equal(t, "Munich", order.ShippingAddress.City)
+
+	inline := testPostalAddress("Paris")
+	_, err = checkout(dto.CreateOrderRequest{ShippingAddressID: &home.ID, ShippingAddress: &inline})
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+
+	theirs, err := addresses.Create(ctx, uuid.New(), dto.AddressRequest{PostalAddress: testPostalAddress("Rome")})
+	require.NoError(t, err)
+	_, err = checkout(dto.CreateOrderRequest{BillingAddressID: &theirs.ID})
+	assert.ErrorIs(t, err, ErrAddressNotFound)
+}
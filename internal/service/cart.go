@@ -2,77 +2,645 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 
+	"github.com/flicky/go-ecommerce-api/internal/currency"
+	"github.com/flicky/go-ecommerce-api/internal/dto"
 	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/promotion"
 	"github.com/flicky/go-ecommerce-api/internal/repository"
+	"github.com/flicky/go-ecommerce-api/internal/shipping"
+	"github.com/flicky/go-ecommerce-api/internal/tax"
 )
 
+var (
+	ErrCartItemNotFound    = errors.New("cart item not found")
+	ErrMaxPerOrderExceeded = errors.New("max per order exceeded")
+	// ErrCartLocked means the cart is in an open checkout session and
+	// cannot change until the session ends or expires.
+	ErrCartLocked = errors.New("cart is locked by checkout")
+)
+
+// CartRef names a user's or guest's cart (GuestCartID is uuid.Nil until the
+// guest has one) and what to price it for; empty fields mean the defaults.
+type CartRef struct {
+	UserID      uuid.UUID
+	GuestCartID uuid.UUID
+	Currency    string
+	Country     string
+}
+
+func (r CartRef) IsGuest() bool { return r.UserID == uuid.Nil }
+
 type CartService struct {
-	cartRepo    repository.CartRepository
-	productRepo repository.ProductRepository
+	cartRepo      repository.CartRepository
+	productRepo   repository.ProductRepository
+	promotionRepo repository.PromotionRepository
+	shipping      shipping.Provider
+	taxes         tax.Calculator
+	prices        *currency.Converter
+	guestTTL      time.Duration
+	country       string
 }
 
-func NewCartService(cartRepo repository.CartRepository, productRepo repository.ProductRepository) *CartService {
-	return &CartService{cartRepo: cartRepo, productRepo: productRepo}
+// NewCartService returns a cart service; country is the default destination of
+// tax and shipping estimates.
+func NewCartService(cartRepo repository.CartRepository, productRepo repository.ProductRepository, promotionRepo repository.PromotionRepository, shipper shipping.Provider, taxes tax.Calculator, prices *currency.Converter, guestTTL time.Duration, country string) *CartService {
+	return &CartService{cartRepo: cartRepo, productRepo: productRepo, promotionRepo: promotionRepo, shipping: shipper, taxes: taxes, prices: prices, guestTTL: guestTTL, country: country}
 }
 
-func (s *CartService) GetCart(ctx context.Context, userID uuid.UUID) (*model.Cart, error) {
-	cart, err := s.cartRepo.GetOrCreateCart(ctx, userID)
+// cart returns the referenced cart. With create set, a guest whose cart is
+// missing or expired gets a new one; otherwise the result is nil.
+func (s *CartService) cart(ctx context.Context, ref CartRef, create bool) (*model.Cart, error) {
+	if !ref.IsGuest() {
+		cart, err := s.cartRepo.GetOrCreateCart(ctx, ref.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("get cart: %w", err)
+		}
+		return cart, nil
+	}
+	if ref.GuestCartID != uuid.Nil {
+		cart, err := s.cartRepo.GetGuestCart(ctx, ref.GuestCartID, s.guestTTL)
+		if err != nil {
+			return nil, fmt.Errorf("get guest cart: %w", err)
+		}
+		if cart != nil || !create {
+			return cart, nil
+		}
+	}
+	if !create {
+		return nil, nil
+	}
+	cart, err := s.cartRepo.CreateGuestCart(ctx, s.guestTTL)
 	if err != nil {
-		return nil, fmt.Errorf("get cart: %w", err)
+		return nil, fmt.Errorf("create guest cart: %w", err)
 	}
-	return s.cartRepo.GetCartWithItems(ctx, cart.ID)
+	return cart, nil
 }
 
-func (s *CartService) AddItem(ctx context.Context, userID, productID uuid.UUID, quantity int) error {
-	product, err := s.productRepo.GetByID(ctx, productID)
+// unlocked returns ErrCartLocked if the cart is in an open checkout.
+func unlocked(cart *model.Cart) error {
+	if cart != nil && cart.Locked(time.Now()) {
+		return ErrCartLocked
+	}
+	return nil
+}
+
+// Cart line warning codes.
+const (
+	CartWarningPriceChanged      = "price_changed"
+	CartWarningInsufficientStock = "insufficient_stock"
+	CartWarningUnavailable       = "unavailable"
+	CartWarningMaxPerOrder       = "max_per_order_exceeded"
+)
+
+// GetCart returns the cart priced at current product prices, creating an
+// empty one if needed.
+func (s *CartService) GetCart(ctx context.Context, ref CartRef) (*dto.CartResponse, error) {
+	pc, err := pricing(ctx, s.prices, ref.Currency)
+	if err != nil {
+		return nil, err
+	}
+	cart, err := s.cart(ctx, ref, true)
 	if err != nil {
-		return fmt.Errorf("get product: %w", err)
+		return nil, err
 	}
-	if product == nil {
-		return ErrProductNotFound
+	lines, err := s.cartRepo.GetCartLines(ctx, cart.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get cart lines: %w", err)
 	}
+	return s.price(ctx, cart, lines, pc, ref)
+}
 
-	cart, err := s.cartRepo.GetOrCreateCart(ctx, userID)
+// ApplyCoupon puts a coupon on the cart and returns the repriced cart, or a
+// *CouponError if the cart does not qualify.
+func (s *CartService) ApplyCoupon(ctx context.Context, ref CartRef, code string) (*dto.CartResponse, error) {
+	p, err := s.promotionRepo.GetByCode(ctx, normalizeCouponCode(code))
 	if err != nil {
-		return fmt.Errorf("get cart: %w", err)
+		return nil, fmt.Errorf("get promotion: %w", err)
+	}
+	if p == nil {
+		return nil, &CouponError{Code: CouponNotFound, Message: "coupon not found"}
 	}
-	return s.cartRepo.AddItem(ctx, &model.CartItem{CartID: cart.ID, ProductID: productID, Quantity: quantity})
+	pc, err := pricing(ctx, s.prices, ref.Currency)
+	if err != nil {
+		return nil, err
+	}
+	cart, err := s.cart(ctx, ref, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := unlocked(cart); err != nil {
+		return nil, err
+	}
+	lines, err := s.cartRepo.GetCartLines(ctx, cart.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get cart lines: %w", err)
+	}
+	local, err := s.localLines(ctx, pc, lines)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := evaluateCoupon(ctx, s.promotionRepo, p, pc, promotionLines(local), ref.UserID); err != nil {
+		return nil, err
+	}
+	if err := s.cartRepo.SetPromotion(ctx, cart.ID, &p.ID); err != nil {
+		return nil, err
+	}
+	cart.PromotionID = &p.ID
+	return s.price(ctx, cart, lines, pc, ref)
 }
 
-func (s *CartService) UpdateItem(ctx context.Context, userID, itemID uuid.UUID, quantity int) error {
-	cart, err := s.cartRepo.GetOrCreateCart(ctx, userID)
+// RemoveCoupon takes the coupon off the cart and returns the repriced cart.
+func (s *CartService) RemoveCoupon(ctx context.Context, ref CartRef) (*dto.CartResponse, error) {
+	pc, err := pricing(ctx, s.prices, ref.Currency)
 	if err != nil {
-		return fmt.Errorf("get cart: %w", err)
+		return nil, err
 	}
-	cartWithItems, err := s.cartRepo.GetCartWithItems(ctx, cart.ID)
+	cart, err := s.cart(ctx, ref, true)
 	if err != nil {
-		return fmt.Errorf("get cart items: %w", err)
+		return nil, err
+	}
+	if err := unlocked(cart); err != nil {
+		return nil, err
 	}
-	for _, item := range cartWithItems.Items {
-		if item.ID == itemID {
-			return s.cartRepo.UpdateItem(ctx, &model.CartItem{ID: itemID, Quantity: quantity})
+	if cart.PromotionID != nil {
+		if err := s.cartRepo.SetPromotion(ctx, cart.ID, nil); err != nil {
+			return nil, err
 		}
+		cart.PromotionID = nil
+	}
+	lines, err := s.cartRepo.GetCartLines(ctx, cart.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get cart lines: %w", err)
 	}
-	return fmt.Errorf("cart item not found")
+	return s.price(ctx, cart, lines, pc, ref)
 }
 
-func (s *CartService) DeleteItem(ctx context.Context, userID, itemID uuid.UUID) error {
-	cart, err := s.cartRepo.GetOrCreateCart(ctx, userID)
+// price builds the cart response in pc's currency, applies the cart's
+// coupon and estimates tax and shipping to ref's country.
+func (s *CartService) price(ctx context.Context, cart *model.Cart, lines []model.CartLine, pc currency.Pricing, ref CartRef) (*dto.CartResponse, error) {
+	lines, err := s.localLines(ctx, pc, lines)
 	if err != nil {
-		return fmt.Errorf("get cart: %w", err)
+		return nil, err
+	}
+	resp, err := s.discount(ctx, cart, lines, pc, ref.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.estimate(ctx, resp, lines, pc, ref); err != nil {
+		return nil, err
+	}
+	resp.Total = resp.Subtotal.Sub(resp.Discount).Add(resp.Shipping)
+	if !resp.PricesIncludeTax {
+		resp.Total = resp.Total.Add(resp.Tax)
+	}
+	return resp, nil
+}
+
+// discount totals lines already in pc's currency and applies the cart's coupon.
+func (s *CartService) discount(ctx context.Context, cart *model.Cart, lines []model.CartLine, pc currency.Pricing, userID uuid.UUID) (*dto.CartResponse, error) {
+	resp := priceCart(cart.ID, lines, pc)
+	resp.Currency = pc.Currency
+	if cart.Locked(time.Now()) {
+		resp.LockedUntil = cart.LockedUntil
+	}
+	if cart.PromotionID != nil {
+		p, err := s.promotionRepo.GetByID(ctx, *cart.PromotionID)
+		if err != nil {
+			return nil, fmt.Errorf("get promotion: %w", err)
+		}
+		if p != nil {
+			result, err := evaluateCoupon(ctx, s.promotionRepo, p, pc, promotionLines(lines), userID)
+			var ce *CouponError
+			switch {
+			case errors.As(err, &ce):
+				resp.Coupon = &dto.CartCouponResponse{
+					Code: p.Code, Description: p.Description, Reason: ce.Code, Message: ce.Message,
+				}
+			case err != nil:
+				return nil, err
+			default:
+				resp.Coupon = &dto.CartCouponResponse{
+					Code: p.Code, Description: p.Description, Applied: true,
+					Discount: result.Discount, FreeShipping: result.FreeShipping,
+				}
+				resp.Discount = result.Discount
+			}
+		}
+	}
+	return resp, nil
+}
+
+// estimate fills in tax and standard shipping to ref's country as checkout
+// would charge them; shipping stays zero if it cannot be quoted.
+func (s *CartService) estimate(ctx context.Context, resp *dto.CartResponse, lines []model.CartLine, pc currency.Pricing, ref CartRef) error {
+	country := strings.ToUpper(strings.TrimSpace(ref.Country))
+	if country == "" {
+		country = s.country
+	}
+	if country == "" || !slices.ContainsFunc(lines, orderable) {
+		return nil
+	}
+	resp.Estimate = &dto.CartEstimate{Country: country}
+
+	freeShipping := resp.Coupon != nil && resp.Coupon.Applied && resp.Coupon.FreeShipping
+	shipment := shipping.Shipment{
+		Country: country, WeightGrams: cartWeight(lines), Subtotal: pc.ToBase(resp.Subtotal.Sub(resp.Discount)),
+	}
+	if quotes, err := quoteShipping(ctx, s.shipping, shipment, freeShipping); err == nil {
+		if q, ok := shipping.Find(quotes, model.ShippingStandard); ok {
+			resp.Estimate.ShippingMethod, resp.Shipping = q.Method, pc.Convert(q.Cost)
+		}
+	}
+
+	var amounts []decimal.Decimal
+	var classes []string
+	for _, l := range lines {
+		if orderable(l) {
+			amounts = append(amounts, l.UnitPrice.Mul(decimal.NewFromInt(int64(l.Quantity))))
+			classes = append(classes, l.TaxClass)
+		}
+	}
+	discounts := tax.SpreadDiscount(amounts, resp.Discount)
+	taxLines := make([]tax.Line, len(amounts))
+	for i := range taxLines {
+		taxLines[i] = tax.Line{Class: classes[i], Amount: amounts[i].Sub(discounts[i])}
+	}
+	result, err := s.taxes.Calculate(ctx, tax.Address{Country: country}, taxLines)
+	if err != nil {
+		return fmt.Errorf("calculate tax: %w", err)
+	}
+	for _, l := range result.Lines {
+		resp.Tax = resp.Tax.Add(pc.Round(l.Amount))
+	}
+	resp.PricesIncludeTax = result.Inclusive
+	return nil
+}
+
+// localLines prices lines in pc's currency.
+func (s *CartService) localLines(ctx context.Context, pc currency.Pricing, lines []model.CartLine) ([]model.CartLine, error) {
+	if pc.IsBase() {
+		return lines, nil
+	}
+	base := make(map[uuid.UUID]decimal.Decimal, len(lines))
+	for _, l := range lines {
+		base[l.ProductID] = l.UnitPrice
+	}
+	prices, err := s.prices.Prices(ctx, pc, base)
+	if err != nil {
+		return nil, err
+	}
+	out := slices.Clone(lines)
+	for i := range out {
+		l := &out[i]
+		if l.PriceAtAdd.Equal(l.UnitPrice) {
+			l.PriceAtAdd = prices[l.ProductID]
+		} else {
+			l.PriceAtAdd = pc.Convert(l.PriceAtAdd)
+		}
+		l.UnitPrice = prices[l.ProductID]
+	}
+	return out, nil
+}
+
+// ShippingQuotes quotes shipping the cart to country by every method.
+func (s *CartService) ShippingQuotes(ctx context.Context, ref CartRef, country string) (*dto.ShippingQuoteResponse, error) {
+	pc, err := pricing(ctx, s.prices, ref.Currency)
+	if err != nil {
+		return nil, err
+	}
+	cart, err := s.cart(ctx, ref, false)
+	if err != nil {
+		return nil, err
+	}
+	if cart == nil {
+		return nil, ErrEmptyCart
+	}
+	lines, err := s.cartRepo.GetCartLines(ctx, cart.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get cart lines: %w", err)
+	}
+	local, err := s.localLines(ctx, pc, lines)
+	if err != nil {
+		return nil, err
+	}
+	priced, err := s.discount(ctx, cart, local, pc, ref.UserID)
+	if err != nil {
+		return nil, err
+	}
+	shipment := shipping.Shipment{
+		Country: strings.ToUpper(country), WeightGrams: cartWeight(lines), Subtotal: pc.ToBase(priced.Subtotal.Sub(priced.Discount)),
+	}
+	if !slices.ContainsFunc(lines, orderable) {
+		return nil, ErrEmptyCart
+	}
+	freeShipping := priced.Coupon != nil && priced.Coupon.Applied && priced.Coupon.FreeShipping
+	quotes, err := quoteShipping(ctx, s.shipping, shipment, freeShipping)
+	if err != nil {
+		return nil, err
+	}
+	resp := &dto.ShippingQuoteResponse{
+		Country: shipment.Country, Currency: pc.Currency, WeightGrams: shipment.WeightGrams,
+		Quotes: make([]dto.ShippingQuote, len(quotes)),
+	}
+	for i, q := range quotes {
+		resp.Quotes[i] = dto.ShippingQuote{Method: q.Method, Cost: pc.Convert(q.Cost), Provider: q.Provider}
+	}
+	return resp, nil
+}
+
+// cartWeight is the shipping weight of the lines that would be ordered.
+func cartWeight(lines []model.CartLine) int {
+	weight := 0
+	for _, l := range lines {
+		if orderable(l) {
+			weight += l.WeightGrams * l.Quantity
+		}
+	}
+	return weight
+}
+
+// orderable reports whether the line would go into an order placed now.
+func orderable(l model.CartLine) bool {
+	return !l.SavedForLater && l.ProductStatus == model.ProductStatusActive
+}
+
+// promotionLines returns the lines a coupon can apply to: those in the
+// active cart whose product is still on sale.
+func promotionLines(lines []model.CartLine) []promotion.Line {
+	var out []promotion.Line
+	for _, l := range lines {
+		if orderable(l) {
+			out = append(out, promotion.Line{
+				ProductID: l.ProductID, Category: l.Category, UnitPrice: l.UnitPrice, Quantity: l.Quantity,
+			})
+		}
+	}
+	return out
+}
+
+// priceCart lists and totals the cart's lines, already in pc's currency.
+// Unavailable lines and items saved for later are shown but not counted.
+func priceCart(cartID uuid.UUID, lines []model.CartLine, pc currency.Pricing) *dto.CartResponse {
+	resp := &dto.CartResponse{ID: cartID, Items: []dto.CartItemResponse{}, SavedForLater: []dto.CartItemResponse{}}
+	for _, l := range lines {
+		item := dto.CartItemResponse{
+			ID: l.ID, ProductID: l.ProductID, Name: l.ProductName, Quantity: l.Quantity,
+			UnitPrice: l.UnitPrice, LineTotal: l.UnitPrice.Mul(decimal.NewFromInt(int64(l.Quantity))),
+			Warnings: cartLineWarnings(l, pc),
+		}
+		if l.SavedForLater {
+			resp.SavedForLater = append(resp.SavedForLater, item)
+			continue
+		}
+		if l.ProductStatus == model.ProductStatusActive {
+			resp.Subtotal = resp.Subtotal.Add(item.LineTotal)
+		}
+		resp.Items = append(resp.Items, item)
+	}
+	return resp
+}
+
+func cartLineWarnings(l model.CartLine, pc currency.Pricing) []dto.CartWarning {
+	if l.ProductStatus != model.ProductStatusActive {
+		return []dto.CartWarning{{Code: CartWarningUnavailable, Message: "product is no longer available"}}
+	}
+	var warnings []dto.CartWarning
+	if !l.UnitPrice.Equal(l.PriceAtAdd) {
+		previous, decimals := l.PriceAtAdd, currency.Decimals(pc.Currency)
+		warnings = append(warnings, dto.CartWarning{
+			Code: CartWarningPriceChanged,
+			Message: fmt.Sprintf("price changed from %s %s to %s %s",
+				previous.StringFixed(decimals), pc.Currency, l.UnitPrice.StringFixed(decimals), pc.Currency),
+			PreviousPrice: &previous,
+		})
+	}
+	if l.MaxPerOrder != nil && *l.MaxPerOrder < l.Quantity {
+		limit := *l.MaxPerOrder
+		warnings = append(warnings, dto.CartWarning{
+			Code:        CartWarningMaxPerOrder,
+			Message:     fmt.Sprintf("at most %d per order", limit),
+			MaxPerOrder: &limit,
+		})
+	}
+	if l.Stock < l.Quantity {
+		available := l.Stock
+		warnings = append(warnings, dto.CartWarning{
+			Code:      CartWarningInsufficientStock,
+			Message:   fmt.Sprintf("only %d in stock", l.Stock),
+			Available: &available,
+		})
+	}
+	return warnings
+}
+
+// AddItem adds a product to the cart and returns the cart's ID, which is new
+// for a guest without a cart.
+func (s *CartService) AddItem(ctx context.Context, ref CartRef, productID uuid.UUID, quantity int) (uuid.UUID, error) {
+	product, err := s.activeProduct(ctx, productID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	cart, err := s.cart(ctx, ref, false)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if err := unlocked(cart); err != nil {
+		return uuid.Nil, err
+	}
+	inCart := 0
+	if cart != nil {
+		existing, err := s.findItem(ctx, cart.ID, func(item *model.CartItem) bool { return item.ProductID == productID })
+		if err != nil {
+			return uuid.Nil, err
+		}
+		if existing != nil {
+			inCart = existing.Quantity
+		}
+	}
+	if err := checkQuantity(product, inCart+quantity); err != nil {
+		return uuid.Nil, err
+	}
+
+	if cart == nil {
+		if cart, err = s.cart(ctx, ref, true); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	item := &model.CartItem{CartID: cart.ID, ProductID: productID, Quantity: quantity, PriceAtAdd: product.Price}
+	if err := s.cartRepo.AddItem(ctx, item); err != nil {
+		return uuid.Nil, err
+	}
+	return cart.ID, nil
+}
+
+// UpdateItem sets the quantity of a cart line, subject to the same checks
+// as AddItem.
+func (s *CartService) UpdateItem(ctx context.Context, ref CartRef, itemID uuid.UUID, quantity int) error {
+	item, err := s.item(ctx, ref, itemID)
+	if err != nil {
+		return err
+	}
+	product, err := s.activeProduct(ctx, item.ProductID)
+	if err != nil {
+		return err
+	}
+	if err := checkQuantity(product, quantity); err != nil {
+		return err
+	}
+	return s.cartRepo.UpdateItem(ctx, &model.CartItem{ID: itemID, Quantity: quantity})
+}
+
+func (s *CartService) DeleteItem(ctx context.Context, ref CartRef, itemID uuid.UUID) error {
+	if _, err := s.item(ctx, ref, itemID); err != nil {
+		return err
+	}
+	return s.cartRepo.DeleteItem(ctx, itemID)
+}
+
+// SaveForLater moves a line out of the active cart, keeping it in the cart's
+// saved-for-later section, and returns the cart.
+func (s *CartService) SaveForLater(ctx context.Context, ref CartRef, itemID uuid.UUID) (*dto.CartResponse, error) {
+	if _, err := s.item(ctx, ref, itemID); err != nil {
+		return nil, err
+	}
+	if err := s.cartRepo.SetSavedForLater(ctx, itemID, true); err != nil {
+		return nil, s.itemWriteError(err)
+	}
+	return s.GetCart(ctx, ref)
+}
+
+// MoveToCart moves a saved-for-later line back into the cart.
+func (s *CartService) MoveToCart(ctx context.Context, ref CartRef, itemID uuid.UUID) (*dto.CartResponse, error) {
+	item, err := s.item(ctx, ref, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if item.SavedForLater {
+		product, err := s.activeProduct(ctx, item.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkQuantity(product, item.Quantity); err != nil {
+			return nil, err
+		}
+		if err := s.cartRepo.SetSavedForLater(ctx, itemID, false); err != nil {
+			return nil, s.itemWriteError(err)
+		}
 	}
-	cartWithItems, err := s.cartRepo.GetCartWithItems(ctx, cart.ID)
+	return s.GetCart(ctx, ref)
+}
+
+func (s *CartService) itemWriteError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrCartItemNotFound
+	}
+	return fmt.Errorf("update cart item: %w", err)
+}
+
+// item returns a line of the referenced cart for changing it.
+func (s *CartService) item(ctx context.Context, ref CartRef, itemID uuid.UUID) (*model.CartItem, error) {
+	cart, err := s.cart(ctx, ref, false)
 	if err != nil {
-		return fmt.Errorf("get cart items: %w", err)
+		return nil, err
+	}
+	if cart == nil {
+		return nil, ErrCartItemNotFound
 	}
-	for _, item := range cartWithItems.Items {
-		if item.ID == itemID {
-			return s.cartRepo.DeleteItem(ctx, itemID)
+	if err := unlocked(cart); err != nil {
+		return nil, err
+	}
+	item, err := s.findItem(ctx, cart.ID, func(item *model.CartItem) bool { return item.ID == itemID })
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrCartItemNotFound
+	}
+	return item, nil
+}
+
+func (s *CartService) findItem(ctx context.Context, cartID uuid.UUID, match func(*model.CartItem) bool) (*model.CartItem, error) {
+	cart, err := s.cartRepo.GetCartWithItems(ctx, cartID)
+	if err != nil {
+		return nil, fmt.Errorf("get cart items: %w", err)
+	}
+	if cart == nil {
+		return nil, nil
+	}
+	for i := range cart.Items {
+		if match(&cart.Items[i]) {
+			return &cart.Items[i], nil
 		}
 	}
-	return fmt.Errorf("cart item not found")
+	return nil, nil
+}
+
+func (s *CartService) activeProduct(ctx context.Context, productID uuid.UUID) (*model.Product, error) {
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("get product: %w", err)
+	}
+	if product == nil || product.Status != model.ProductStatusActive {
+		return nil, ErrProductNotFound
+	}
+	return product, nil
+}
+
+// QuantityError rejects a line quantity; Allowed is the most the line may hold.
+type QuantityError struct {
+	Err       error
+	ProductID uuid.UUID
+	Allowed   int
+}
+
+func (e *QuantityError) Error() string {
+	return fmt.Sprintf("%v: at most %d allowed", e.Err, e.Allowed)
+}
+
+func (e *QuantityError) Unwrap() error { return e.Err }
+
+// checkQuantity returns a *QuantityError if quantity exceeds the product's
+// available stock or per-order limit.
+func checkQuantity(product *model.Product, quantity int) error {
+	allowed, reason := product.Available(), ErrInsufficientStock
+	if product.MaxPerOrder != nil && *product.MaxPerOrder < allowed {
+		allowed, reason = *product.MaxPerOrder, ErrMaxPerOrderExceeded
+	}
+	if quantity > allowed {
+		return &QuantityError{Err: reason, ProductID: product.ID, Allowed: max(allowed, 0)}
+	}
+	return nil
+}
+
+// MergeGuestCart moves a guest cart into the user's cart after they sign in.
+func (s *CartService) MergeGuestCart(ctx context.Context, guestCartID, userID uuid.UUID) error {
+	cart, err := s.cartRepo.GetOrCreateCart(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get cart: %w", err)
+	}
+	if err := unlocked(cart); err != nil {
+		return err
+	}
+	if err := s.cartRepo.MergeCarts(ctx, guestCartID, cart.ID); err != nil {
+		return fmt.Errorf("merge guest cart: %w", err)
+	}
+	return nil
+}
+
+// SweepGuestCarts deletes guest carts that have expired.
+func (s *CartService) SweepGuestCarts(ctx context.Context) (int, error) {
+	n, err := s.cartRepo.DeleteExpiredGuestCarts(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("sweep guest carts: %w", err)
+	}
+	return n, nil
 }
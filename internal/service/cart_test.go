@@ -2,27 +2,36 @@ package service
 
 import (
 	"context"
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/flicky/go-ecommerce-api/internal/dto"
 	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
 )
 
+// mockCartRepo keeps guest carts alongside user carts; a guest cart expires
+// once now is past its ExpiresAt. Cart lines are joined against products.
 type mockCartRepo struct {
-	carts map[uuid.UUID]*model.Cart
-	items map[uuid.UUID]*model.CartItem
+	carts    map[uuid.UUID]*model.Cart
+	items    map[uuid.UUID]*model.CartItem
+	products *mockProductRepo
+	now      time.Time
 }
 
 func newMockCartRepo() *mockCartRepo {
-	return &mockCartRepo{carts: make(map[uuid.UUID]*model.Cart), items: make(map[uuid.UUID]*model.CartItem)}
+	return &mockCartRepo{carts: make(map[uuid.UUID]*model.Cart), items: make(map[uuid.UUID]*model.CartItem), now: time.Now()}
 }
 
 func (m *mockCartRepo) GetOrCreateCart(_ context.Context, userID uuid.UUID) (*model.Cart, error) {
 	for _, c := range m.carts {
-		if c.UserID == userID {
+		if c.ExpiresAt == nil && c.UserID == userID {
 			return c, nil
 		}
 	}
@@ -45,7 +54,33 @@ func (m *mockCartRepo) GetCartWithItems(_ context.Context, cartID uuid.UUID) (*m
 	return cart, nil
 }
 
+func (m *mockCartRepo) GetCartLines(_ context.Context, cartID uuid.UUID) ([]model.CartLine, error) {
+	var lines []model.CartLine
+	for _, item := range m.items {
+		p, ok := m.products.products[item.ProductID]
+		if item.CartID != cartID || !ok {
+			continue
+		}
+		lines = append(lines, model.CartLine{
+			CartItem: *item, ProductName: p.Name, Category: p.Category, UnitPrice: p.Price, Stock: p.Stock, MaxPerOrder: p.MaxPerOrder,
+			WeightGrams:   p.WeightGrams,
+			ProductStatus: p.Status,
+			TaxClass:      p.TaxClass,
+		})
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].ProductName < lines[j].ProductName })
+	return lines, nil
+}
+
 func (m *mockCartRepo) AddItem(_ context.Context, item *model.CartItem) error {
+	for _, existing := range m.items {
+		if existing.CartID == item.CartID && existing.ProductID == item.ProductID {
+			existing.Quantity += item.Quantity
+			existing.PriceAtAdd = item.PriceAtAdd
+			existing.SavedForLater = false
+			return nil
+		}
+	}
 	item.ID = uuid.New()
 	m.items[item.ID] = item
 	return nil
@@ -63,40 +98,419 @@ func (m *mockCartRepo) DeleteItem(_ context.Context, itemID uuid.UUID) error {
 	return nil
 }
 
+func (m *mockCartRepo) SetSavedForLater(_ context.Context, itemID uuid.UUID, saved bool) error {
+	item, ok := m.items[itemID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	item.SavedForLater = saved
+	return nil
+}
+
 func (m *mockCartRepo) ClearCart(_ context.Context, cartID uuid.UUID) error {
 	for id, item := range m.items {
-		if item.CartID == cartID {
+		if item.CartID == cartID && !item.SavedForLater {
 			delete(m.items, id)
 		}
 	}
 	return nil
 }
 
+func (m *mockCartRepo) SetPromotion(_ context.Context, cartID uuid.UUID, promotionID *uuid.UUID) error {
+	if cart, ok := m.carts[cartID]; ok {
+		cart.PromotionID = promotionID
+	}
+	return nil
+}
+
+func (m *mockCartRepo) CreateGuestCart(_ context.Context, ttl time.Duration) (*model.Cart, error) {
+	expires := m.now.Add(ttl)
+	cart := &model.Cart{ID: uuid.New(), ExpiresAt: &expires}
+	m.carts[cart.ID] = cart
+	return cart, nil
+}
+
+func (m *mockCartRepo) GetGuestCart(_ context.Context, cartID uuid.UUID, ttl time.Duration) (*model.Cart, error) {
+	cart, ok := m.carts[cartID]
+	if !ok || cart.ExpiresAt == nil || !cart.ExpiresAt.After(m.now) {
+		return nil, nil
+	}
+	expires := m.now.Add(ttl)
+	cart.ExpiresAt = &expires
+	return cart, nil
+}
+
+func (m *mockCartRepo) MergeCarts(_ context.Context, guestCartID, userCartID uuid.UUID) error {
+	guest, ok := m.carts[guestCartID]
+	if !ok || guest.ExpiresAt == nil || !guest.ExpiresAt.After(m.now) {
+		return nil
+	}
+	for id, item := range m.items {
+		if item.CartID != guestCartID {
+			continue
+		}
+		delete(m.items, id)
+		p, ok := m.products.products[item.ProductID]
+		if !ok || p.Status != model.ProductStatusActive || p.Available() <= 0 {
+			continue
+		}
+		limit := p.Available()
+		if p.MaxPerOrder != nil {
+			limit = min(limit, *p.MaxPerOrder)
+		}
+		item.Quantity = min(item.Quantity, limit)
+		merged := false
+		for _, existing := range m.items {
+			if existing.CartID == userCartID && existing.ProductID == item.ProductID {
+				existing.Quantity = min(max(existing.Quantity, item.Quantity), limit)
+				existing.SavedForLater = existing.SavedForLater && item.SavedForLater
+				merged = true
+			}
+		}
+		if !merged {
+			item.CartID = userCartID
+			m.items[id] = item
+		}
+	}
+	if user := m.carts[userCartID]; user.PromotionID == nil {
+		user.PromotionID = guest.PromotionID
+	}
+	delete(m.carts, guestCartID)
+	return nil
+}
+
+func (m *mockCartRepo) DeleteExpiredGuestCarts(_ context.Context) (int, error) {
+	n := 0
+	for id, cart := range m.carts {
+		if cart.ExpiresAt != nil && !cart.ExpiresAt.After(m.now) {
+			delete(m.carts, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func newActiveProduct(products *mockProductRepo, stock int) uuid.UUID {
+	id := uuid.New()
+	products.products[id] = &model.Product{ID: id, Stock: stock, Status: model.ProductStatusActive}
+	return id
+}
+
 func TestCartService_AddItem(t *testing.T) {
 	cartRepo := newMockCartRepo()
 	productRepo := newMockProductRepo()
-	pid := uuid.New()
-	productRepo.products[pid] = &model.Product{ID: pid, Stock: 100}
-	svc := NewCartService(cartRepo, productRepo)
-	err := svc.AddItem(context.Background(), uuid.New(), pid, 2)
+	pid := newActiveProduct(productRepo, 100)
+	svc := NewCartService(cartRepo, productRepo, newMockPromotionRepo(), nil, nil, newTestPrices(), time.Hour, "")
+	_, err := svc.AddItem(context.Background(), CartRef{UserID: uuid.New()}, pid, 2)
 	require.NoError(t, err)
 	assert.Len(t, cartRepo.items, 1)
 }
 
 func TestCartService_AddItem_ProductNotFound(t *testing.T) {
-	svc := NewCartService(newMockCartRepo(), newMockProductRepo())
-	err := svc.AddItem(context.Background(), uuid.New(), uuid.New(), 2)
+	svc := NewCartService(newMockCartRepo(), newMockProductRepo(), newMockPromotionRepo(), nil, nil, newTestPrices(), time.Hour, "")
+	_, err := svc.AddItem(context.Background(), CartRef{UserID: uuid.New()}, uuid.New(), 2)
 	assert.ErrorIs(t, err, ErrProductNotFound)
 }
 
 func TestCartService_DeleteItem(t *testing.T) {
 	cartRepo := newMockCartRepo()
-	svc := NewCartService(cartRepo, newMockProductRepo())
+	svc := NewCartService(cartRepo, newMockProductRepo(), newMockPromotionRepo(), nil, nil, newTestPrices(), time.Hour, "")
 	userID := uuid.New()
 	cart, _ := cartRepo.GetOrCreateCart(context.Background(), userID)
 	item := &model.CartItem{ID: uuid.New(), CartID: cart.ID, ProductID: uuid.New(), Quantity: 1}
 	cartRepo.items[item.ID] = item
-	err := svc.DeleteItem(context.Background(), userID, item.ID)
+	err := svc.DeleteItem(context.Background(), CartRef{UserID: userID}, item.ID)
 	require.NoError(t, err)
 	assert.Empty(t, cartRepo.items)
 }
+
+func TestCartService_AddItem_ArchivedProduct(t *testing.T) {
+	productRepo := newMockProductRepo()
+	pid := uuid.New()
+	productRepo.products[pid] = &model.Product{ID: pid, Stock: 100, Status: model.ProductStatusArchived}
+	svc := NewCartService(newMockCartRepo(), productRepo, newMockPromotionRepo(), nil, nil, newTestPrices(), time.Hour, "")
+	_, err := svc.AddItem(context.Background(), CartRef{UserID: uuid.New()}, pid, 1)
+	assert.ErrorIs(t, err, ErrProductNotFound)
+}
+
+func TestCartService_GuestCart(t *testing.T) {
+	cartRepo := newMockCartRepo()
+	productRepo := newMockProductRepo()
+	cartRepo.products = productRepo
+	pid := newActiveProduct(productRepo, 10)
+	svc := NewCartService(cartRepo, productRepo, newMockPromotionRepo(), nil, nil, newTestPrices(), time.Hour, "")
+	ctx := context.Background()
+
+	cartID, err := svc.AddItem(ctx, CartRef{}, pid, 1)
+	require.NoError(t, err)
+	require.NotEqual(t, uuid.Nil, cartID)
+
+	// The token's cart is reused while it lives.
+	again, err := svc.AddItem(ctx, CartRef{GuestCartID: cartID}, pid, 1)
+	require.NoError(t, err)
+	assert.Equal(t, cartID, again)
+
+	// Another guest cannot touch it without the token.
+	var itemID uuid.UUID
+	for id := range cartRepo.items {
+		itemID = id
+	}
+	assert.ErrorIs(t, svc.DeleteItem(ctx, CartRef{}, itemID), ErrCartItemNotFound)
+
+	// Once expired, the guest starts over and the sweep removes the old cart.
+	cartRepo.now = cartRepo.now.Add(2 * time.Hour)
+	cart, err := svc.GetCart(ctx, CartRef{GuestCartID: cartID})
+	require.NoError(t, err)
+	assert.NotEqual(t, cartID, cart.ID)
+	assert.Empty(t, cart.Items)
+
+	cartRepo.now = cartRepo.now.Add(2 * time.Hour)
+	n, err := svc.SweepGuestCarts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+}
+
+func TestCartService_MergeGuestCart(t *testing.T) {
+	cartRepo := newMockCartRepo()
+	productRepo := newMockProductRepo()
+	cartRepo.products = productRepo
+	both, guestOnly := newActiveProduct(productRepo, 10), newActiveProduct(productRepo, 10)
+	scarce, archived := newActiveProduct(productRepo, 10), newActiveProduct(productRepo, 10)
+	guestMore := newActiveProduct(productRepo, 10)
+	svc := NewCartService(cartRepo, productRepo, newMockPromotionRepo(), nil, nil, newTestPrices(), time.Hour, "")
+	ctx := context.Background()
+	user := CartRef{UserID: uuid.New()}
+
+	_, err := svc.AddItem(ctx, user, both, 3)
+	require.NoError(t, err)
+	guestCartID, err := svc.AddItem(ctx, CartRef{}, both, 2)
+	require.NoError(t, err)
+	_, err = svc.AddItem(ctx, CartRef{GuestCartID: guestCartID}, guestOnly, 1)
+	require.NoError(t, err)
+	_, err = svc.AddItem(ctx, user, guestMore, 1)
+	require.NoError(t, err)
+	_, err = svc.AddItem(ctx, CartRef{GuestCartID: guestCartID}, guestMore, 5)
+	require.NoError(t, err)
+	_, err = svc.AddItem(ctx, CartRef{GuestCartID: guestCartID}, scarce, 5)
+	require.NoError(t, err)
+	_, err = svc.AddItem(ctx, CartRef{GuestCartID: guestCartID}, archived, 1)
+	require.NoError(t, err)
+	productRepo.products[scarce].Reserved = 8
+	productRepo.products[archived].Status = model.ProductStatusArchived
+
+	require.NoError(t, svc.MergeGuestCart(ctx, guestCartID, user.UserID))
+
+	cart, err := svc.GetCart(ctx, user)
+	require.NoError(t, err)
+	quantities := make(map[uuid.UUID]int)
+	for _, item := range cart.Items {
+		quantities[item.ProductID] = item.Quantity
+	}
+	assert.Equal(t, map[uuid.UUID]int{both: 3, guestOnly: 1, scarce: 2, guestMore: 5}, quantities)
+	assert.NotContains(t, cartRepo.carts, guestCartID)
+
+	// Merging again is a no-op.
+	require.NoError(t, svc.MergeGuestCart(ctx, guestCartID, user.UserID))
+}
+
+func TestCartService_GetCart_Priced(t *testing.T) {
+	cartRepo := newMockCartRepo()
+	productRepo := newMockProductRepo()
+	cartRepo.products = productRepo
+	svc := NewCartService(cartRepo, productRepo, newMockPromotionRepo(), nil, nil, newTestPrices(), time.Hour, "")
+	ctx := context.Background()
+	user := CartRef{UserID: uuid.New()}
+
+	add := func(name string, price int64, stock, qty int) *model.Product {
+		p := &model.Product{ID: uuid.New(), Name: name, Price: decimal.NewFromInt(price), Stock: stock, Status: model.ProductStatusActive}
+		productRepo.products[p.ID] = p
+		_, err := svc.AddItem(ctx, user, p.ID, qty)
+		require.NoError(t, err)
+		return p
+	}
+	add("A mug", 10, 5, 2)
+	pricier := add("B plate", 20, 5, 1)
+	scarce := add("C bowl", 5, 5, 3)
+	archived := add("D jug", 30, 5, 1)
+
+	pricier.Price = decimal.NewFromInt(25)
+	scarce.Stock = 1
+	archived.Status = model.ProductStatusArchived
+
+	cart, err := svc.GetCart(ctx, user)
+	require.NoError(t, err)
+	require.Len(t, cart.Items, 4)
+
+	assert.Empty(t, cart.Items[0].Warnings)
+	assert.True(t, decimal.NewFromInt(20).Equal(cart.Items[0].LineTotal))
+
+	require.Len(t, cart.Items[1].Warnings, 1)
+	assert.Equal(t, CartWarningPriceChanged, cart.Items[1].Warnings[0].Code)
+	assert.True(t, decimal.NewFromInt(20).Equal(*cart.Items[1].Warnings[0].PreviousPrice))
+	assert.Equal(t, "price changed from 20.00 USD to 25.00 USD", cart.Items[1].Warnings[0].Message)
+
+	require.Len(t, cart.Items[2].Warnings, 1)
+	assert.Equal(t, CartWarningInsufficientStock, cart.Items[2].Warnings[0].Code)
+	assert.Equal(t, ptr(1), cart.Items[2].Warnings[0].Available)
+
+	require.Len(t, cart.Items[3].Warnings, 1)
+	assert.Equal(t, CartWarningUnavailable, cart.Items[3].Warnings[0].Code)
+
+	// 2*10 + 25 + 3*5; the archived jug is not counted.
+	assert.True(t, decimal.NewFromInt(60).Equal(cart.Subtotal), cart.Subtotal.String())
+	assert.True(t, cart.Total.Equal(cart.Subtotal))
+}
+
+func TestCartService_GetCart_Estimates(t *testing.T) {
+	cartRepo := newMockCartRepo()
+	productRepo := newMockProductRepo()
+	cartRepo.products = productRepo
+	vat := model.TaxRate{Country: "DE", TaxClass: model.TaxClassStandard, Name: "VAT", Rate: decimal.NewFromInt(19)}
+	svc := NewCartService(cartRepo, productRepo, newMockPromotionRepo(), newFlatShipping(), newTaxTable(false, vat), newTestPrices(), time.Hour, "US")
+	ctx := context.Background()
+	user := CartRef{UserID: uuid.New()}
+
+	// An empty cart has nothing to estimate.
+	cart, err := svc.GetCart(ctx, user)
+	require.NoError(t, err)
+	assert.Nil(t, cart.Estimate)
+
+	pid := newActiveProduct(productRepo, 5)
+	productRepo.products[pid].Price, productRepo.products[pid].TaxClass = decimal.NewFromInt(10), model.TaxClassStandard
+	_, err = svc.AddItem(ctx, user, pid, 2)
+	require.NoError(t, err)
+
+	// By default the cart ships to the configured country, untaxed there.
+	cart, err = svc.GetCart(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, &dto.CartEstimate{Country: "US", ShippingMethod: model.ShippingStandard}, cart.Estimate)
+	assert.True(t, cart.Shipping.Equal(decimal.RequireFromString("4.90")), cart.Shipping.String())
+	assert.True(t, cart.Tax.IsZero())
+	assert.True(t, cart.Total.Equal(decimal.RequireFromString("24.90")), cart.Total.String())
+
+	// A named country is taxed at its rates, in the cart's currency.
+	cart, err = svc.GetCart(ctx, CartRef{UserID: user.UserID, Country: "de", Currency: "EUR"})
+	require.NoError(t, err)
+	assert.Equal(t, "DE", cart.Estimate.Country)
+	assert.True(t, cart.Tax.Equal(decimal.RequireFromString("3.42")), cart.Tax.String())
+	assert.True(t, cart.Shipping.Equal(decimal.RequireFromString("4.41")), cart.Shipping.String())
+	assert.True(t, cart.Total.Equal(decimal.RequireFromString("25.83")), cart.Total.String())
+
+	// Without a default or a country nothing is estimated.
+	svc.country = ""
+	cart, err = svc.GetCart(ctx, user)
+	require.NoError(t, err)
+	assert.Nil(t, cart.Estimate)
+	assert.True(t, cart.Total.Equal(cart.Subtotal))
+}
+
+func TestCartService_QuantityLimits(t *testing.T) {
+	cartRepo := newMockCartRepo()
+	productRepo := newMockProductRepo()
+	pid := newActiveProduct(productRepo, 3)
+	svc := NewCartService(cartRepo, productRepo, newMockPromotionRepo(), nil, nil, newTestPrices(), time.Hour, "")
+	ctx := context.Background()
+	user := CartRef{UserID: uuid.New()}
+
+	_, err := svc.AddItem(ctx, user, pid, 2)
+	require.NoError(t, err)
+
+	// Adding on top of what is already in the cart counts the total.
+	_, err = svc.AddItem(ctx, user, pid, 2)
+	var qe *QuantityError
+	require.ErrorAs(t, err, &qe)
+	assert.ErrorIs(t, err, ErrInsufficientStock)
+	assert.Equal(t, QuantityError{Err: ErrInsufficientStock, ProductID: pid, Allowed: 3}, *qe)
+
+	// The tighter of stock and the per-order limit wins.
+	productRepo.products[pid].MaxPerOrder = ptr(2)
+	_, err = svc.AddItem(ctx, user, pid, 1)
+	require.ErrorAs(t, err, &qe)
+	assert.ErrorIs(t, err, ErrMaxPerOrderExceeded)
+	assert.Equal(t, 2, qe.Allowed)
+
+	var itemID uuid.UUID
+	for id := range cartRepo.items {
+		itemID = id
+	}
+	assert.ErrorIs(t, svc.UpdateItem(ctx, user, itemID, 3), ErrMaxPerOrderExceeded)
+	require.NoError(t, svc.UpdateItem(ctx, user, itemID, 1))
+	assert.Equal(t, 1, cartRepo.items[itemID].Quantity)
+
+	// A guest turned away does not get an empty cart.
+	_, err = svc.AddItem(ctx, CartRef{}, pid, 5)
+	assert.ErrorIs(t, err, ErrMaxPerOrderExceeded)
+	assert.Len(t, cartRepo.carts, 1)
+}
+
+func TestCartService_UnknownItem(t *testing.T) {
+	cartRepo := newMockCartRepo()
+	productRepo := newMockProductRepo()
+	pid := newActiveProduct(productRepo, 10)
+	svc := NewCartService(cartRepo, productRepo, newMockPromotionRepo(), nil, nil, newTestPrices(), time.Hour, "")
+	ctx := context.Background()
+	owner, other := CartRef{UserID: uuid.New()}, CartRef{UserID: uuid.New()}
+
+	_, err := svc.AddItem(ctx, owner, pid, 1)
+	require.NoError(t, err)
+	var itemID uuid.UUID
+	for id := range cartRepo.items {
+		itemID = id
+	}
+
+	assert.ErrorIs(t, svc.UpdateItem(ctx, owner, uuid.New(), 1), ErrCartItemNotFound)
+	assert.ErrorIs(t, svc.UpdateItem(ctx, other, itemID, 1), ErrCartItemNotFound)
+	assert.ErrorIs(t, svc.DeleteItem(ctx, other, itemID), ErrCartItemNotFound)
+	assert.ErrorIs(t, svc.DeleteItem(ctx, CartRef{GuestCartID: uuid.New()}, itemID), ErrCartItemNotFound)
+	assert.Len(t, cartRepo.items, 1)
+}
+
+func TestCartService_SaveForLater(t *testing.T) {
+	cartRepo := newMockCartRepo()
+	productRepo := newMockProductRepo()
+	cartRepo.products = productRepo
+	svc := NewCartService(cartRepo, productRepo, newMockPromotionRepo(), nil, nil, newTestPrices(), time.Hour, "")
+	ctx := context.Background()
+	user := CartRef{UserID: uuid.New()}
+
+	mug := &model.Product{ID: uuid.New(), Name: "A mug", Price: decimal.NewFromInt(10), Stock: 5, Status: model.ProductStatusActive}
+	plate := &model.Product{ID: uuid.New(), Name: "B plate", Price: decimal.NewFromInt(20), Stock: 5, Status: model.ProductStatusActive}
+	productRepo.products[mug.ID], productRepo.products[plate.ID] = mug, plate
+	_, err := svc.AddItem(ctx, user, mug.ID, 1)
+	require.NoError(t, err)
+	_, err = svc.AddItem(ctx, user, plate.ID, 3)
+	require.NoError(t, err)
+	cart, err := svc.GetCart(ctx, user)
+	require.NoError(t, err)
+	plateItem := cart.Items[1].ID
+
+	cart, err = svc.SaveForLater(ctx, user, plateItem)
+	require.NoError(t, err)
+	require.Len(t, cart.Items, 1)
+	require.Len(t, cart.SavedForLater, 1)
+	assert.Equal(t, 3, cart.SavedForLater[0].Quantity)
+	assert.True(t, decimal.NewFromInt(10).Equal(cart.Total), cart.Total.String())
+
+	// Stock ran down while the plate was saved.
+	plate.Stock = 2
+	_, err = svc.MoveToCart(ctx, user, plateItem)
+	assert.ErrorIs(t, err, ErrInsufficientStock)
+	assert.True(t, cartRepo.items[plateItem].SavedForLater)
+
+	plate.Stock = 5
+	cart, err = svc.MoveToCart(ctx, user, plateItem)
+	require.NoError(t, err)
+	assert.Len(t, cart.Items, 2)
+	assert.Empty(t, cart.SavedForLater)
+
+	// Adding a saved product brings it back with the quantities combined.
+	_, err = svc.SaveForLater(ctx, user, plateItem)
+	require.NoError(t, err)
+	_, err = svc.AddItem(ctx, user, plate.ID, 1)
+	require.NoError(t, err)
+	assert.False(t, cartRepo.items[plateItem].SavedForLater)
+	assert.Equal(t, 4, cartRepo.items[plateItem].Quantity)
+
+	_, err = svc.SaveForLater(ctx, CartRef{UserID: uuid.New()}, plateItem)
+	assert.ErrorIs(t, err, ErrCartItemNotFound)
+}
@@ -0,0 +1,372 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+var (
+	ErrCheckoutNotFound = errors.New("checkout not found")
+	// ErrCheckoutClosed means the session was already completed or
+	// cancelled.
+	ErrCheckoutClosed  = errors.New("checkout closed")
+	ErrCheckoutExpired = errors.New("checkout expired")
+	// ErrCheckoutIncomplete means a step the request depends on has not
+	// been done yet.
+	ErrCheckoutIncomplete = errors.New("checkout incomplete")
+	// ErrCheckoutChanged means the session was repriced since the customer
+	// last saw it and needs confirming again.
+	ErrCheckoutChanged = errors.New("checkout total changed")
+)
+
+// Checkout steps, reported as missing until they are done.
+const (
+	checkoutStepShippingAddress = "shipping_address"
+	checkoutStepShippingMethod  = "shipping_method"
+	checkoutStepPaymentIntent   = "payment_intent"
+)
+
+// CheckoutService takes a user's cart to an order through a checkout
+// session, pricing it with the order service's rules.
+type CheckoutService struct {
+	repo   repository.CheckoutRepository
+	orders *OrderService
+	ttl    time.Duration
+}
+
+// NewCheckoutService returns a checkout service whose sessions expire ttl
+// after they start.
+func NewCheckoutService(repo repository.CheckoutRepository, orders *OrderService, ttl time.Duration) *CheckoutService {
+	return &CheckoutService{repo: repo, orders: orders, ttl: ttl}
+}
+
+// Start opens a checkout session for the user's cart and locks the cart.
+func (s *CheckoutService) Start(ctx context.Context, userID uuid.UUID, currencyCode string) (*dto.CheckoutResponse, error) {
+	pc, err := pricing(ctx, s.orders.prices, currencyCode)
+	if err != nil {
+		return nil, err
+	}
+	cart, err := s.orders.cartRepo.GetOrCreateCart(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get cart: %w", err)
+	}
+	cartWithItems, err := s.orders.cartRepo.GetCartWithItems(ctx, cart.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get cart items: %w", err)
+	}
+	if cartWithItems == nil {
+		return nil, ErrEmptyCart
+	}
+	cs := &model.CheckoutSession{
+		UserID: userID, CartID: cart.ID, Currency: pc.Currency, PromotionID: cartWithItems.PromotionID,
+	}
+	for _, ci := range cartWithItems.Items {
+		if !ci.SavedForLater {
+			cs.Items = append(cs.Items, model.CheckoutItem{CartItemID: ci.ID, ProductID: ci.ProductID, Quantity: ci.Quantity})
+		}
+	}
+	if len(cs.Items) == 0 {
+		return nil, ErrEmptyCart
+	}
+	cs.ShippingAddress, cs.BillingAddress, err = s.orders.resolveAddresses(ctx, userID, dto.CheckoutAddressRequest{})
+	if err != nil && !errors.Is(err, ErrShippingAddressRequired) {
+		return nil, err
+	}
+	if _, err := s.price(ctx, cs); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, cs, s.ttl); err != nil {
+		return nil, fmt.Errorf("create checkout: %w", err)
+	}
+	return toCheckoutResponse(cs), nil
+}
+
+// Get returns one of the user's checkout sessions as of its last change.
+func (s *CheckoutService) Get(ctx context.Context, userID, id uuid.UUID) (*dto.CheckoutResponse, error) {
+	cs, err := s.session(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return toCheckoutResponse(cs), nil
+}
+
+// SetAddresses sets the session's addresses.
+func (s *CheckoutService) SetAddresses(ctx context.Context, userID, id uuid.UUID, req dto.CheckoutAddressRequest) (*dto.CheckoutResponse, error) {
+	return s.change(ctx, userID, id, func(cs *model.CheckoutSession) error {
+		shipTo, billTo, err := s.orders.resolveAddresses(ctx, userID, req)
+		if err != nil {
+			return err
+		}
+		cs.ShippingAddress, cs.BillingAddress = shipTo, billTo
+		return nil
+	})
+}
+
+// SetShippingMethod ships the session by method, which must ship to its
+// address.
+func (s *CheckoutService) SetShippingMethod(ctx context.Context, userID, id uuid.UUID, method string) (*dto.CheckoutResponse, error) {
+	cs, err := s.open(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if cs.ShippingAddress == nil {
+		return nil, fmt.Errorf("%w: %s required", ErrCheckoutIncomplete, checkoutStepShippingAddress)
+	}
+	cs.ShippingMethod = method
+	if _, err := s.price(ctx, cs); err != nil {
+		return nil, err
+	}
+	if cs.ShippingMethod != method {
+		return nil, fmt.Errorf("%w: %s to %s", ErrShippingMethodUnavailable, method, cs.ShippingAddress.Country)
+	}
+	return s.save(ctx, cs)
+}
+
+// ApplyCoupon puts a coupon on the session in place of any other. A coupon
+// the session does not qualify for is rejected with a *CouponError.
+func (s *CheckoutService) ApplyCoupon(ctx context.Context, userID, id uuid.UUID, code string) (*dto.CheckoutResponse, error) {
+	p, err := s.orders.promotionRepo.GetByCode(ctx, normalizeCouponCode(code))
+	if err != nil {
+		return nil, fmt.Errorf("get promotion: %w", err)
+	}
+	if p == nil {
+		return nil, &CouponError{Code: CouponNotFound, Message: "coupon not found"}
+	}
+	return s.change(ctx, userID, id, func(cs *model.CheckoutSession) error {
+		cs.PromotionID = &p.ID
+		return nil
+	})
+}
+
+// RemoveCoupon takes the coupon off the session.
+func (s *CheckoutService) RemoveCoupon(ctx context.Context, userID, id uuid.UUID) (*dto.CheckoutResponse, error) {
+	return s.change(ctx, userID, id, func(cs *model.CheckoutSession) error {
+		cs.PromotionID, cs.PromotionCode = nil, ""
+		return nil
+	})
+}
+
+// SetPaymentIntent attaches the payment the customer authorised for the
+// session's total.
+func (s *CheckoutService) SetPaymentIntent(ctx context.Context, userID, id uuid.UUID, intent string) (*dto.CheckoutResponse, error) {
+	cs, err := s.open(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if missing := missingSteps(cs, false); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s required", ErrCheckoutIncomplete, strings.Join(missing, ", "))
+	}
+	if _, err := s.reprice(ctx, cs); err != nil {
+		return nil, err
+	}
+	cs.PaymentIntent = intent
+	return s.save(ctx, cs)
+}
+
+// Complete places the order for a session with every step done.
+func (s *CheckoutService) Complete(ctx context.Context, userID, id uuid.UUID) (*model.Order, error) {
+	cs, err := s.open(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if missing := missingSteps(cs, true); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s required", ErrCheckoutIncomplete, strings.Join(missing, ", "))
+	}
+	order, err := s.reprice(ctx, cs)
+	if err != nil {
+		return nil, err
+	}
+	order.PaymentIntent = cs.PaymentIntent
+	if err := s.repo.Complete(ctx, cs, order); err != nil {
+		if errors.Is(err, repository.ErrCheckoutClosed) {
+			return nil, ErrCheckoutClosed
+		}
+		return nil, placeError(err)
+	}
+	s.orders.queue(ctx, order)
+	return order, nil
+}
+
+// Cancel ends an open session and unlocks the cart.
+func (s *CheckoutService) Cancel(ctx context.Context, userID, id uuid.UUID) error {
+	if _, err := s.session(ctx, userID, id); err != nil {
+		return err
+	}
+	if err := s.repo.Cancel(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrCheckoutClosed) {
+			return ErrCheckoutClosed
+		}
+		return fmt.Errorf("cancel checkout: %w", err)
+	}
+	return nil
+}
+
+// session returns one of the user's sessions, in any state.
+func (s *CheckoutService) session(ctx context.Context, userID, id uuid.UUID) (*model.CheckoutSession, error) {
+	cs, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get checkout: %w", err)
+	}
+	if cs == nil || cs.UserID != userID {
+		return nil, ErrCheckoutNotFound
+	}
+	return cs, nil
+}
+
+// open returns one of the user's sessions that can still change.
+func (s *CheckoutService) open(ctx context.Context, userID, id uuid.UUID) (*model.CheckoutSession, error) {
+	cs, err := s.session(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if cs.Status != model.CheckoutOpen {
+		return nil, ErrCheckoutClosed
+	}
+	if cs.Expired(time.Now()) {
+		return nil, ErrCheckoutExpired
+	}
+	return cs, nil
+}
+
+// change applies fn to an open session, reprices it and saves it.
+func (s *CheckoutService) change(ctx context.Context, userID, id uuid.UUID, fn func(*model.CheckoutSession) error) (*dto.CheckoutResponse, error) {
+	cs, err := s.open(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(cs); err != nil {
+		return nil, err
+	}
+	if _, err := s.price(ctx, cs); err != nil {
+		return nil, err
+	}
+	return s.save(ctx, cs)
+}
+
+// reprice prices the session afresh and returns its order.
+func (s *CheckoutService) reprice(ctx context.Context, cs *model.CheckoutSession) (*model.Order, error) {
+	total, method := cs.Total, cs.ShippingMethod
+	order, err := s.price(ctx, cs)
+	if err != nil {
+		return nil, err
+	}
+	if !cs.Total.Equal(total) || cs.ShippingMethod != method {
+		if _, err := s.save(ctx, cs); err != nil {
+			return nil, err
+		}
+		return nil, ErrCheckoutChanged
+	}
+	return order, nil
+}
+
+func (s *CheckoutService) save(ctx context.Context, cs *model.CheckoutSession) (*dto.CheckoutResponse, error) {
+	if err := s.repo.Update(ctx, cs); err != nil {
+		if errors.Is(err, repository.ErrCheckoutClosed) {
+			return nil, ErrCheckoutClosed
+		}
+		return nil, fmt.Errorf("update checkout: %w", err)
+	}
+	return toCheckoutResponse(cs), nil
+}
+
+// price works out the session's order at current prices and rates.
+func (s *CheckoutService) price(ctx context.Context, cs *model.CheckoutSession) (*model.Order, error) {
+	pc, err := pricing(ctx, s.orders.prices, cs.Currency)
+	if err != nil {
+		return nil, err
+	}
+	build := func(method string) (*model.Order, error) {
+		items := make([]model.OrderItem, len(cs.Items))
+		for i, item := range cs.Items {
+			items[i] = model.OrderItem{ProductID: item.ProductID, Quantity: item.Quantity}
+		}
+		d, err := s.orders.newDraft(ctx, cs.UserID, pc, items)
+		if err != nil {
+			return nil, err
+		}
+		d.order.ShippingAddress, d.order.BillingAddress = cs.ShippingAddress, cs.BillingAddress
+		if err := s.orders.total(ctx, d, cs.PromotionID, method); err != nil {
+			return nil, err
+		}
+		return d.order, nil
+	}
+	order, err := build(cs.ShippingMethod)
+	if errors.Is(err, ErrShippingMethodUnavailable) {
+		cs.ShippingMethod = ""
+		order, err = build("")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range cs.Items {
+		cs.Items[i].Price = order.Items[i].Price
+	}
+	if !order.TotalPrice.Equal(cs.Total) {
+		cs.PaymentIntent = ""
+	}
+	cs.PromotionID, cs.PromotionCode = order.PromotionID, order.PromotionCode
+	cs.Subtotal, cs.Discount, cs.ShippingCost = order.Subtotal, order.Discount, order.ShippingCost
+	cs.Tax, cs.PricesIncludeTax, cs.Total = order.Tax, order.PricesIncludeTax, order.TotalPrice
+	return order, nil
+}
+
+// missingSteps lists the steps the session has yet to do, leaving out the
+// payment unless withPayment is set.
+func missingSteps(cs *model.CheckoutSession, withPayment bool) []string {
+	missing := []string{}
+	if cs.ShippingAddress == nil {
+		missing = append(missing, checkoutStepShippingAddress)
+	}
+	if cs.ShippingMethod == "" {
+		missing = append(missing, checkoutStepShippingMethod)
+	}
+	if withPayment && cs.PaymentIntent == "" {
+		missing = append(missing, checkoutStepPaymentIntent)
+	}
+	return missing
+}
+
+func toCheckoutResponse(cs *model.CheckoutSession) *dto.CheckoutResponse {
+	status := cs.Status
+	if cs.Expired(time.Now()) {
+		status = model.CheckoutExpired
+	}
+	items := make([]dto.CheckoutItemResponse, len(cs.Items))
+	for i, item := range cs.Items {
+		items[i] = dto.CheckoutItemResponse{
+			ProductID: item.ProductID, Quantity: item.Quantity, UnitPrice: item.Price,
+			LineTotal: item.Price.Mul(decimal.NewFromInt(int64(item.Quantity))),
+		}
+	}
+	missing := []string{}
+	if cs.Status == model.CheckoutOpen {
+		missing = missingSteps(cs, true)
+	}
+	return &dto.CheckoutResponse{
+		ID: cs.ID, Status: status, Currency: cs.Currency, Items: items,
+		ShippingAddress: toPostalAddressResponse(cs.ShippingAddress), BillingAddress: toPostalAddressResponse(cs.BillingAddress),
+		ShippingMethod: cs.ShippingMethod, CouponCode: cs.PromotionCode, PaymentIntent: cs.PaymentIntent,
+		Subtotal: cs.Subtotal, Discount: cs.Discount, ShippingCost: cs.ShippingCost, Tax: cs.Tax,
+		PricesIncludeTax: cs.PricesIncludeTax, Total: cs.Total, Missing: missing, OrderID: cs.OrderID,
+		ExpiresAt: cs.ExpiresAt,
+	}
+}
+
+func toPostalAddressResponse(a *model.PostalAddress) *dto.PostalAddress {
+	if a == nil {
+		return nil
+	}
+	resp := dto.PostalAddress(*a)
+	return &resp
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+	"github.com/flicky/go-ecommerce-api/internal/shipping"
+)
+
+// mockCheckoutRepo locks, completes and empties carts like the real one,
+// placing orders in orders and reserving stock from the carts' products.
+type mockCheckoutRepo struct {
+	sessions map[uuid.UUID]*model.CheckoutSession
+	carts    *mockCartRepo
+	orders   *mockOrderRepo
+}
+
+func newMockCheckoutRepo(carts *mockCartRepo, orders *mockOrderRepo) *mockCheckoutRepo {
+	return &mockCheckoutRepo{sessions: make(map[uuid.UUID]*model.CheckoutSession), carts: carts, orders: orders}
+}
+
+func (m *mockCheckoutRepo) store(s *model.CheckoutSession) {
+	stored := *s
+	stored.Items = slices.Clone(s.Items)
+	m.sessions[s.ID] = &stored
+}
+
+func (m *mockCheckoutRepo) Create(_ context.Context, s *model.CheckoutSession, ttl time.Duration) error {
+	for _, other := range m.sessions {
+		if other.CartID == s.CartID && other.Status == model.CheckoutOpen {
+			other.Status = model.CheckoutCancelled
+		}
+	}
+	s.ID, s.Status = uuid.New(), model.CheckoutOpen
+	s.CreatedAt = time.Now()
+	s.ExpiresAt = s.CreatedAt.Add(ttl)
+	m.store(s)
+	expires := s.ExpiresAt
+	m.carts.carts[s.CartID].LockedUntil = &expires
+	return nil
+}
+
+func (m *mockCheckoutRepo) GetByID(_ context.Context, id uuid.UUID) (*model.CheckoutSession, error) {
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	out := *s
+	out.Items = slices.Clone(s.Items)
+	return &out, nil
+}
+
+func (m *mockCheckoutRepo) open(id uuid.UUID) (*model.CheckoutSession, error) {
+	s, ok := m.sessions[id]
+	if !ok || s.Status != model.CheckoutOpen || s.Expired(time.Now()) {
+		return nil, repository.ErrCheckoutClosed
+	}
+	return s, nil
+}
+
+func (m *mockCheckoutRepo) Update(_ context.Context, s *model.CheckoutSession) error {
+	if _, err := m.open(s.ID); err != nil {
+		return err
+	}
+	m.store(s)
+	return nil
+}
+
+func (m *mockCheckoutRepo) Cancel(_ context.Context, id uuid.UUID) error {
+	s, ok := m.sessions[id]
+	if !ok || s.Status != model.CheckoutOpen {
+		return repository.ErrCheckoutClosed
+	}
+	s.Status = model.CheckoutCancelled
+	m.carts.carts[s.CartID].LockedUntil = nil
+	return nil
+}
+
+func (m *mockCheckoutRepo) Complete(ctx context.Context, s *model.CheckoutSession, order *model.Order) error {
+	if _, err := m.open(s.ID); err != nil {
+		return err
+	}
+	for _, item := range order.Items {
+		if m.carts.products.products[item.ProductID].Available() < item.Quantity {
+			return repository.ErrInsufficientStock
+		}
+	}
+	if err := m.orders.Create(ctx, order); err != nil {
+		return err
+	}
+	for _, item := range order.Items {
+		m.carts.products.products[item.ProductID].Reserved += item.Quantity
+	}
+	for _, item := range s.Items {
+		delete(m.carts.items, item.CartItemID)
+	}
+	m.carts.carts[s.CartID].LockedUntil = nil
+	s.Status, s.OrderID = model.CheckoutCompleted, &order.ID
+	m.store(s)
+	return nil
+}
+
+type checkoutFixture struct {
+	checkouts *CheckoutService
+	carts     *CartService
+	orders    *OrderService
+	cartRepo  *mockCartRepo
+	orderRepo *mockOrderRepo
+	products  *mockProductRepo
+	shipper   *shipping.Fake
+	user      CartRef
+	product   uuid.UUID
+}
+
+// newCheckoutFixture gives a user a cart holding two of a product at 20.00
+// with ten in stock, and checkout sessions that last ttl.
+func newCheckoutFixture(t *testing.T, ttl time.Duration) *checkoutFixture {
+	t.Helper()
+	f := &checkoutFixture{
+		cartRepo: newMockCartRepo(), orderRepo: newMockOrderRepo(), products: newMockProductRepo(),
+		shipper: newFlatShipping(), user: CartRef{UserID: uuid.New()},
+	}
+	f.cartRepo.products = f.products
+	f.product = newActiveProduct(f.products, 10)
+	f.products.products[f.product].Price = decimal.NewFromInt(20)
+	f.carts = NewCartService(f.cartRepo, f.products, newMockPromotionRepo(), f.shipper, nil, newTestPrices(), time.Hour, "")
+	f.orders = NewOrderService(f.orderRepo, f.cartRepo, f.products, newMockPromotionRepo(), newMockAddressRepo(), f.shipper, newTaxTable(false), newTestPrices(), nil)
+	f.checkouts = NewCheckoutService(newMockCheckoutRepo(f.cartRepo, f.orderRepo), f.orders, ttl)
+	_, err := f.carts.AddItem(context.Background(), f.user, f.product, 2)
+	require.NoError(t, err)
+	return f
+}
+
+// ready takes a new session through every step.
+func (f *checkoutFixture) ready(t *testing.T) *dto.CheckoutResponse {
+	t.Helper()
+	ctx, userID := context.Background(), f.user.UserID
+	cs, err := f.checkouts.Start(ctx, userID, "")
+	require.NoError(t, err)
+	address := testPostalAddress("Berlin")
+	_, err = f.checkouts.SetAddresses(ctx, userID, cs.ID, dto.CheckoutAddressRequest{ShippingAddress: &address})
+	require.NoError(t, err)
+	_, err = f.checkouts.SetShippingMethod(ctx, userID, cs.ID, model.ShippingStandard)
+	require.NoError(t, err)
+	cs, err = f.checkouts.SetPaymentIntent(ctx, userID, cs.ID, "pi_123")
+	require.NoError(t, err)
+	return cs
+}
+
+func TestCheckoutService_Steps(t *testing.T) {
+	f := newCheckoutFixture(t, time.Hour)
+	ctx, userID := context.Background(), f.user.UserID
+
+	cs, err := f.checkouts.Start(ctx, userID, "")
+	require.NoError(t, err)
+	assert.Equal(t, model.CheckoutOpen, cs.Status)
+	assert.Equal(t, []string{"shipping_address", "shipping_method", "payment_intent"}, cs.Missing)
+	assert.Equal(t, "40.00", cs.Total.StringFixed(2))
+
+	// The cart is locked while the session is open.
+	_, err = f.carts.AddItem(ctx, f.user, f.product, 1)
+	assert.ErrorIs(t, err, ErrCartLocked)
+	_, err = f.orders.CreateOrder(ctx, userID, testOrderRequest())
+	assert.ErrorIs(t, err, ErrCartLocked)
+	cart, err := f.carts.GetCart(ctx, f.user)
+	require.NoError(t, err)
+	assert.NotNil(t, cart.LockedUntil)
+
+	// Steps are taken in order.
+	_, err = f.checkouts.SetShippingMethod(ctx, userID, cs.ID, model.ShippingExpress)
+	assert.ErrorIs(t, err, ErrCheckoutIncomplete)
+	_, err = f.checkouts.SetPaymentIntent(ctx, userID, cs.ID, "pi_123")
+	assert.ErrorIs(t, err, ErrCheckoutIncomplete)
+	_, err = f.checkouts.Complete(ctx, userID, cs.ID)
+	assert.ErrorIs(t, err, ErrCheckoutIncomplete)
+
+	address := testPostalAddress("Berlin")
+	cs, err = f.checkouts.SetAddresses(ctx, userID, cs.ID, dto.CheckoutAddressRequest{ShippingAddress: &address})
+	require.NoError(t, err)
+	assert.Equal(t, "Berlin", cs.ShippingAddress.City)
+	assert.Equal(t, cs.ShippingAddress, cs.BillingAddress)
+	assert.Equal(t, []string{"shipping_method", "payment_intent"}, cs.Missing)
+
+	cs, err = f.checkouts.SetShippingMethod(ctx, userID, cs.ID, model.ShippingExpress)
+	require.NoError(t, err)
+	assert.Equal(t, "9.90", cs.ShippingCost.StringFixed(2))
+	assert.Equal(t, "49.90", cs.Total.StringFixed(2))
+
+	cs, err = f.checkouts.SetPaymentIntent(ctx, userID, cs.ID, "pi_123")
+	require.NoError(t, err)
+	assert.Empty(t, cs.Missing)
+
+	// Other users cannot see the session.
+	_, err = f.checkouts.Get(ctx, uuid.New(), cs.ID)
+	assert.ErrorIs(t, err, ErrCheckoutNotFound)
+
+	order, err := f.checkouts.Complete(ctx, userID, cs.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "pi_123", order.PaymentIntent)
+	assert.Equal(t, model.ShippingExpress, order.ShippingMethod)
+	assert.Equal(t, "49.90", order.TotalPrice.StringFixed(2))
+	require.Len(t, order.Items, 1)
+	assert.Equal(t, 2, order.Items[0].Quantity)
+
+	// The order reserved its stock and took the lines out of the cart,
+	// which is unlocked again.
+	assert.Equal(t, 2, f.products.products[f.product].Reserved)
+	assert.Empty(t, f.cartRepo.items)
+	_, err = f.carts.AddItem(ctx, f.user, f.product, 1)
+	require.NoError(t, err)
+
+	cs, err = f.checkouts.Get(ctx, userID, cs.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.CheckoutCompleted, cs.Status)
+	assert.Equal(t, &order.ID, cs.OrderID)
+	_, err = f.checkouts.Complete(ctx, userID, cs.ID)
+	assert.ErrorIs(t, err, ErrCheckoutClosed)
+}
+
+func TestCheckoutService_TotalChanged(t *testing.T) {
+	f := newCheckoutFixture(t, time.Hour)
+	ctx, userID := context.Background(), f.user.UserID
+	cs := f.ready(t)
+	assert.Equal(t, "44.90", cs.Total.StringFixed(2))
+
+	// The price went up after the customer authorised the payment.
+	f.products.products[f.product].Price = decimal.NewFromInt(25)
+	_, err := f.checkouts.Complete(ctx, userID, cs.ID)
+	assert.ErrorIs(t, err, ErrCheckoutChanged)
+	assert.Empty(t, f.orderRepo.orders)
+
+	cs, err = f.checkouts.Get(ctx, userID, cs.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "54.90", cs.Total.StringFixed(2))
+	assert.Equal(t, []string{"payment_intent"}, cs.Missing, "the old payment no longer covers the total")
+
+	_, err = f.checkouts.SetPaymentIntent(ctx, userID, cs.ID, "pi_456")
+	require.NoError(t, err)
+	order, err := f.checkouts.Complete(ctx, userID, cs.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "54.90", order.TotalPrice.StringFixed(2))
+	assert.Equal(t, "pi_456", order.PaymentIntent)
+}
+
+func TestCheckoutService_DropsUnavailableMethod(t *testing.T) {
+	f := newCheckoutFixture(t, time.Hour)
+	ctx, userID := context.Background(), f.user.UserID
+	cs := f.ready(t)
+
+	// Standard shipping does not reach the new address.
+	f.shipper.Quotes = slices.DeleteFunc(f.shipper.Quotes, func(q shipping.Quote) bool { return q.Method == model.ShippingStandard })
+	address := testPostalAddress("Vienna")
+	cs, err := f.checkouts.SetAddresses(ctx, userID, cs.ID, dto.CheckoutAddressRequest{ShippingAddress: &address})
+	require.NoError(t, err)
+	assert.Empty(t, cs.ShippingMethod)
+	assert.Equal(t, []string{"shipping_method", "payment_intent"}, cs.Missing)
+
+	_, err = f.checkouts.SetShippingMethod(ctx, userID, cs.ID, model.ShippingStandard)
+	assert.ErrorIs(t, err, ErrShippingMethodUnavailable)
+}
+
+func TestCheckoutService_Expired(t *testing.T) {
+	f := newCheckoutFixture(t, -time.Minute)
+	ctx, userID := context.Background(), f.user.UserID
+
+	cs, err := f.checkouts.Start(ctx, userID, "")
+	require.NoError(t, err)
+	cs, err = f.checkouts.Get(ctx, userID, cs.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.CheckoutExpired, cs.Status)
+
+	address := testPostalAddress("Berlin")
+	_, err = f.checkouts.SetAddresses(ctx, userID, cs.ID, dto.CheckoutAddressRequest{ShippingAddress: &address})
+	assert.ErrorIs(t, err, ErrCheckoutExpired)
+	_, err = f.checkouts.Complete(ctx, userID, cs.ID)
+	assert.ErrorIs(t, err, ErrCheckoutExpired)
+
+	// The cart's lock ran out with the session.
+	_, err = f.carts.AddItem(ctx, f.user, f.product, 1)
+	require.NoError(t, err)
+}
+
+func TestCheckoutService_Cancel(t *testing.T) {
+	f := newCheckoutFixture(t, time.Hour)
+	ctx, userID := context.Background(), f.user.UserID
+
+	first, err := f.checkouts.Start(ctx, userID, "")
+	require.NoError(t, err)
+	// Starting again replaces the open session.
+	second, err := f.checkouts.Start(ctx, userID, "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, "EUR", second.Currency)
+	assert.Equal(t, "36.00", second.Total.StringFixed(2))
+	_, err = f.checkouts.SetPaymentIntent(ctx, userID, first.ID, "pi_123")
+	assert.ErrorIs(t, err, ErrCheckoutClosed)
+
+	require.NoError(t, f.checkouts.Cancel(ctx, userID, second.ID))
+	assert.ErrorIs(t, f.checkouts.Cancel(ctx, userID, second.ID), ErrCheckoutClosed)
+	_, err = f.carts.AddItem(ctx, f.user, f.product, 1)
+	require.NoError(t, err)
+}
+
+func TestCheckoutService_ReservedStock(t *testing.T) {
+	f := newCheckoutFixture(t, time.Hour)
+	ctx, userID := context.Background(), f.user.UserID
+	cs := f.ready(t)
+
+	// Other orders reserved most of the stock in the meantime.
+	f.products.products[f.product].Reserved = 9
+	_, err := f.checkouts.Complete(ctx, userID, cs.ID)
+	assert.ErrorIs(t, err, ErrInsufficientStock)
+	assert.Empty(t, f.orderRepo.orders)
+
+	cs, err = f.checkouts.Get(ctx, userID, cs.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.CheckoutOpen, cs.Status)
+}
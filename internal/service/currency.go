@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/currency"
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+var (
+	// ErrUnsupportedCurrency means a request asked for a currency the shop
+	// does not sell in: neither the base currency nor one with a rate.
+	ErrUnsupportedCurrency  = errors.New("unsupported currency")
+	ErrInvalidCurrency      = errors.New("invalid currency")
+	ErrExchangeRateNotFound = errors.New("exchange rate not found")
+	ErrProductPriceNotFound = errors.New("product price not found")
+)
+
+// CurrencyService manages exchange rates and product price lists.
+type CurrencyService struct {
+	repo repository.CurrencyRepository
+	base string
+}
+
+// NewCurrencyService returns a service for the rates from, and the price
+// lists next to, the base currency.
+func NewCurrencyService(repo repository.CurrencyRepository, base string) *CurrencyService {
+	return &CurrencyService{repo: repo, base: base}
+}
+
+func (s *CurrencyService) ListRates(ctx context.Context) (*dto.ExchangeRateListResponse, error) {
+	rates, err := s.repo.ListRates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list exchange rates: %w", err)
+	}
+	resp := &dto.ExchangeRateListResponse{Base: s.base, Rates: make([]dto.ExchangeRateResponse, len(rates))}
+	for i, r := range rates {
+		resp.Rates[i] = dto.ExchangeRateResponse{Currency: r.Currency, Rate: r.Rate, UpdatedAt: r.UpdatedAt}
+	}
+	return resp, nil
+}
+
+// SetRate creates or replaces the exchange rate for code, which makes it a
+// currency the shop sells in.
+func (s *CurrencyService) SetRate(ctx context.Context, code string, req dto.ExchangeRateRequest) (*dto.ExchangeRateResponse, error) {
+	code, err := s.foreignCurrency(code)
+	if err != nil {
+		return nil, err
+	}
+	if !req.Rate.IsPositive() {
+		return nil, fmt.Errorf("%w: rate must be positive", ErrInvalidCurrency)
+	}
+	r := &model.ExchangeRate{Currency: code, Rate: req.Rate}
+	if err := s.repo.SetRate(ctx, r); err != nil {
+		return nil, fmt.Errorf("set exchange rate: %w", err)
+	}
+	return &dto.ExchangeRateResponse{Currency: r.Currency, Rate: r.Rate, UpdatedAt: r.UpdatedAt}, nil
+}
+
+// DeleteRate stops selling in code. Its price lists are kept in case the
+// rate comes back.
+func (s *CurrencyService) DeleteRate(ctx context.Context, code string) error {
+	code, _ = currency.Normalize(code)
+	if err := s.repo.DeleteRate(ctx, code); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrExchangeRateNotFound
+		}
+		return fmt.Errorf("delete exchange rate: %w", err)
+	}
+	return nil
+}
+
+func (s *CurrencyService) ListPrices(ctx context.Context, productID uuid.UUID) (*dto.ProductPriceListResponse, error) {
+	prices, err := s.repo.ListPrices(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("list product prices: %w", err)
+	}
+	resp := &dto.ProductPriceListResponse{ProductID: productID, Prices: make([]dto.ProductPriceResponse, len(prices))}
+	for i, p := range prices {
+		resp.Prices[i] = dto.ProductPriceResponse{Currency: p.Currency, Price: p.Price, UpdatedAt: p.UpdatedAt}
+	}
+	return resp, nil
+}
+
+// SetPrice lists a product at a price of its own in code, instead of its
+// base price converted.
+func (s *CurrencyService) SetPrice(ctx context.Context, productID uuid.UUID, code string, req dto.ProductPriceRequest) (*dto.ProductPriceResponse, error) {
+	code, err := s.foreignCurrency(code)
+	if err != nil {
+		return nil, err
+	}
+	if req.Price.IsNegative() {
+		return nil, fmt.Errorf("%w: price cannot be negative", ErrInvalidCurrency)
+	}
+	p := &model.ProductPrice{ProductID: productID, Currency: code, Price: req.Price}
+	if err := s.repo.SetPrice(ctx, p); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, fmt.Errorf("set product price: %w", err)
+	}
+	return &dto.ProductPriceResponse{Currency: p.Currency, Price: p.Price, UpdatedAt: p.UpdatedAt}, nil
+}
+
+func (s *CurrencyService) DeletePrice(ctx context.Context, productID uuid.UUID, code string) error {
+	code, _ = currency.Normalize(code)
+	if err := s.repo.DeletePrice(ctx, productID, code); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrProductPriceNotFound
+		}
+		return fmt.Errorf("delete product price: %w", err)
+	}
+	return nil
+}
+
+// foreignCurrency normalizes a currency code that rates and price lists can
+// be set for: any but the base currency, whose prices are the products' own.
+func (s *CurrencyService) foreignCurrency(code string) (string, error) {
+	code, ok := currency.Normalize(code)
+	switch {
+	case !ok:
+		return "", fmt.Errorf("%w: %q is not an ISO 4217 code", ErrInvalidCurrency, code)
+	case code == s.base:
+		return "", fmt.Errorf("%w: %s is the base currency", ErrInvalidCurrency, code)
+	}
+	return code, nil
+}
+
+// pricing resolves the currency a request asked for; an empty code is the
+// base currency.
+func pricing(ctx context.Context, prices *currency.Converter, code string) (currency.Pricing, error) {
+	p, err := prices.Pricing(ctx, code)
+	if err != nil {
+		if errors.Is(err, currency.ErrUnsupported) {
+			return currency.Pricing{}, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, code)
+		}
+		return currency.Pricing{}, err
+	}
+	return p, nil
+}
+
+// promotionIn returns p with its amounts, set in the base currency,
+// converted to pc's currency.
+func promotionIn(pc currency.Pricing, p *model.Promotion) *model.Promotion {
+	if pc.IsBase() {
+		return p
+	}
+	local := *p
+	local.MinSubtotal = pc.Convert(p.MinSubtotal)
+	if p.Kind == model.PromotionFixed {
+		local.Value = pc.Convert(p.Value)
+	}
+	return &local
+}
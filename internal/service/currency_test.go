@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/currency"
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+type mockCurrencyRepo struct {
+	rates  map[string]decimal.Decimal
+	prices map[string]map[uuid.UUID]decimal.Decimal
+}
+
+func newMockCurrencyRepo() *mockCurrencyRepo {
+	return &mockCurrencyRepo{rates: make(map[string]decimal.Decimal), prices: make(map[string]map[uuid.UUID]decimal.Decimal)}
+}
+
+func (m *mockCurrencyRepo) SetRate(_ context.Context, r *model.ExchangeRate) error {
+	m.rates[r.Currency], r.UpdatedAt = r.Rate, time.Now()
+	return nil
+}
+
+func (m *mockCurrencyRepo) GetRate(_ context.Context, code string) (*model.ExchangeRate, error) {
+	rate, ok := m.rates[code]
+	if !ok {
+		return nil, nil
+	}
+	return &model.ExchangeRate{Currency: code, Rate: rate}, nil
+}
+
+func (m *mockCurrencyRepo) ListRates(_ context.Context) ([]model.ExchangeRate, error) {
+	var out []model.ExchangeRate
+	for code, rate := range m.rates {
+		out = append(out, model.ExchangeRate{Currency: code, Rate: rate})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Currency < out[j].Currency })
+	return out, nil
+}
+
+func (m *mockCurrencyRepo) DeleteRate(_ context.Context, code string) error {
+	if _, ok := m.rates[code]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(m.rates, code)
+	return nil
+}
+
+func (m *mockCurrencyRepo) SetPrice(_ context.Context, p *model.ProductPrice) error {
+	if m.prices[p.Currency] == nil {
+		m.prices[p.Currency] = make(map[uuid.UUID]decimal.Decimal)
+	}
+	m.prices[p.Currency][p.ProductID], p.UpdatedAt = p.Price, time.Now()
+	return nil
+}
+
+func (m *mockCurrencyRepo) ListPrices(_ context.Context, productID uuid.UUID) ([]model.ProductPrice, error) {
+	var out []model.ProductPrice
+	for code, prices := range m.prices {
+		if price, ok := prices[productID]; ok {
+			out = append(out, model.ProductPrice{ProductID: productID, Currency: code, Price: price})
+		}
+	}
+	return out, nil
+}
+
+func (m *mockCurrencyRepo) DeletePrice(_ context.Context, productID uuid.UUID, code string) error {
+	if _, ok := m.prices[code][productID]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(m.prices[code], productID)
+	return nil
+}
+
+func (m *mockCurrencyRepo) PricesFor(_ context.Context, code string, productIDs []uuid.UUID) (map[uuid.UUID]decimal.Decimal, error) {
+	out := make(map[uuid.UUID]decimal.Decimal)
+	for _, id := range productIDs {
+		if price, ok := m.prices[code][id]; ok {
+			out[id] = price
+		}
+	}
+	return out, nil
+}
+
+// newTestPrices prices in USD, and in EUR at 0.9 and JPY at 150.3 to the
+// dollar. repos, if given, supplies the price lists.
+func newTestPrices(repos ...*mockCurrencyRepo) *currency.Converter {
+	repo := newMockCurrencyRepo()
+	if len(repos) > 0 {
+		repo = repos[0]
+	}
+	repo.rates["EUR"], repo.rates["JPY"] = decimal.RequireFromString("0.9"), decimal.RequireFromString("150.3")
+	return currency.NewConverter("USD", repo)
+}
+
+func TestCurrencyService(t *testing.T) {
+	svc := NewCurrencyService(newMockCurrencyRepo(), "USD")
+	ctx := context.Background()
+
+	rate, err := svc.SetRate(ctx, "eur", dto.ExchangeRateRequest{Rate: decimal.RequireFromString("0.92")})
+	require.NoError(t, err)
+	assert.Equal(t, "EUR", rate.Currency)
+	for code, r := range map[string]string{"USD": "1", "EURO": "1", "GBP": "0"} {
+		_, err := svc.SetRate(ctx, code, dto.ExchangeRateRequest{Rate: decimal.RequireFromString(r)})
+		assert.ErrorIs(t, err, ErrInvalidCurrency, code)
+	}
+	list, err := svc.ListRates(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "USD", list.Base)
+	assert.Len(t, list.Rates, 1)
+	require.NoError(t, svc.DeleteRate(ctx, "eur"))
+	assert.ErrorIs(t, svc.DeleteRate(ctx, "EUR"), ErrExchangeRateNotFound)
+
+	productID := uuid.New()
+	_, err = svc.SetPrice(ctx, productID, "eur", dto.ProductPriceRequest{Price: decimal.RequireFromString("8.99")})
+	require.NoError(t, err)
+	_, err = svc.SetPrice(ctx, productID, "EUR", dto.ProductPriceRequest{Price: decimal.NewFromInt(-1)})
+	assert.ErrorIs(t, err, ErrInvalidCurrency)
+	prices, err := svc.ListPrices(ctx, productID)
+	require.NoError(t, err)
+	require.Len(t, prices.Prices, 1)
+	assert.Equal(t, "EUR", prices.Prices[0].Currency)
+	require.NoError(t, svc.DeletePrice(ctx, productID, "EUR"))
+	assert.ErrorIs(t, svc.DeletePrice(ctx, productID, "EUR"), ErrProductPriceNotFound)
+}
+
+func TestProductService_Currency(t *testing.T) {
+	repo, currencies := newMockProductRepo(), newMockCurrencyRepo()
+	converted, listed := newActiveProduct(repo, 1), newActiveProduct(repo, 1)
+	repo.products[converted].Price, repo.products[listed].Price = decimal.RequireFromString("10.01"), decimal.NewFromInt(10)
+	currencies.prices["EUR"] = map[uuid.UUID]decimal.Decimal{listed: decimal.RequireFromString("8.5")}
+	svc := NewProductService(repo, nil, nil, newTestPrices(currencies))
+	ctx := context.Background()
+
+	base, err := svc.GetByID(ctx, converted, false, "")
+	require.NoError(t, err)
+	assert.Equal(t, "USD", base.Currency)
+	eur, err := svc.GetByID(ctx, converted, false, "eur")
+	require.NoError(t, err)
+	assert.Equal(t, "EUR", eur.Currency)
+	assert.Equal(t, "9.01", eur.Price.StringFixed(2))
+	assert.NotEqual(t, ProductETag(base), ProductETag(eur))
+	jpy, err := svc.GetByID(ctx, converted, false, "JPY")
+	require.NoError(t, err)
+	assert.Equal(t, "1505", jpy.Price.String())
+	_, err = svc.GetByID(ctx, converted, false, "GBP")
+	assert.ErrorIs(t, err, ErrUnsupportedCurrency)
+
+	list, err := svc.List(ctx, model.ProductStatusActive, "EUR", pagination.Params{Limit: 10})
+	require.NoError(t, err)
+	got := make(map[uuid.UUID]string)
+	for _, p := range list.Products {
+		got[p.ID] = p.Price.StringFixed(2)
+	}
+	assert.Equal(t, map[uuid.UUID]string{converted: "9.01", listed: "8.50"}, got)
+}
+
+func TestCartService_Currency(t *testing.T) {
+	cartRepo, productRepo := newMockCartRepo(), newMockProductRepo()
+	cartRepo.products = productRepo
+	pid := newActiveProduct(productRepo, 10)
+	productRepo.products[pid].Price = decimal.NewFromInt(10)
+	promotions := newMockPromotionRepo()
+	ctx := context.Background()
+	require.NoError(t, promotions.Create(ctx, &model.Promotion{Code: "FIVE", Kind: model.PromotionFixed, Value: decimal.NewFromInt(5), Active: true}))
+	carts := NewCartService(cartRepo, productRepo, promotions, newFlatShipping(), nil, newTestPrices(), time.Hour, "")
+	ref := CartRef{UserID: uuid.New(), Currency: "EUR"}
+	_, err := carts.AddItem(ctx, ref, pid, 2)
+	require.NoError(t, err)
+
+	cart, err := carts.ApplyCoupon(ctx, ref, "FIVE")
+	require.NoError(t, err)
+	assert.Equal(t, "EUR", cart.Currency)
+	assert.Equal(t, "9.00", cart.Items[0].UnitPrice.StringFixed(2))
+	assert.Empty(t, cart.Items[0].Warnings)
+	assert.Equal(t, "4.50", cart.Discount.StringFixed(2))
+	assert.Equal(t, "13.50", cart.Total.StringFixed(2))
+
+	quotes, err := carts.ShippingQuotes(ctx, ref, "DE")
+	require.NoError(t, err)
+	assert.Equal(t, "EUR", quotes.Currency)
+	assert.Equal(t, "4.41", quotes.Quotes[0].Cost.StringFixed(2))
+
+	_, err = carts.GetCart(ctx, CartRef{UserID: ref.UserID, Currency: "GBP"})
+	assert.ErrorIs(t, err, ErrUnsupportedCurrency)
+}
+
+func TestOrderService_CreateOrder_Currency(t *testing.T) {
+	vat := model.TaxRate{Country: "DE", TaxClass: model.TaxClassStandard, Name: "VAT", Rate: decimal.NewFromInt(19)}
+	ctx := context.Background()
+	checkout := func(t *testing.T, code string) (*model.Order, error) {
+		t.Helper()
+		cartRepo, productRepo := newMockCartRepo(), newMockProductRepo()
+		cartRepo.products = productRepo
+		pid := newActiveProduct(productRepo, 10)
+		productRepo.products[pid].Price, productRepo.products[pid].TaxClass = decimal.RequireFromString("10.01"), model.TaxClassStandard
+		userID := uuid.New()
+		_, err := NewCartService(cartRepo, productRepo, newMockPromotionRepo(), nil, nil, newTestPrices(), time.Hour, "").
+			AddItem(ctx, CartRef{UserID: userID}, pid, 1)
+		require.NoError(t, err)
+		req := testOrderRequest()
+		req.Currency = code
+		orders := NewOrderService(newMockOrderRepo(), cartRepo, productRepo, newMockPromotionRepo(), newMockAddressRepo(), newFlatShipping(), newTaxTable(false, vat), newTestPrices(), nil)
+		return orders.CreateOrder(ctx, userID, req)
+	}
+
+	order, err := checkout(t, "")
+	require.NoError(t, err)
+	assert.Equal(t, "USD", order.Currency)
+	assert.Equal(t, "1", order.ExchangeRate.String())
+
+	// Every amount is in yen, rounded to whole yen: the item, the shipping
+	// and the tax.
+	order, err = checkout(t, "jpy")
+	require.NoError(t, err)
+	assert.Equal(t, "JPY", order.Currency)
+	assert.Equal(t, "150.3", order.ExchangeRate.String())
+	assert.Equal(t, "1505", order.Items[0].Price.String())
+	assert.Equal(t, "736", order.ShippingCost.String())
+	assert.Equal(t, "286", order.Tax.String())
+	assert.Equal(t, "2527", order.TotalPrice.String())
+
+	_, err = checkout(t, "GBP")
+	assert.ErrorIs(t, err, ErrUnsupportedCurrency)
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+)
+
+// ProductETag is the strong entity tag of a single product representation.
+func ProductETag(p *dto.ProductResponse) string {
+	return productETag(p.ID, p.UpdatedAt, p.Currency, p.Price)
+}
+
+func productETag(id uuid.UUID, updatedAt time.Time, currency string, price decimal.Decimal) string {
+	return `"` + hashParts(id.String(), strconv.FormatInt(updatedAt.UnixMicro(), 10), currency, price.String()) + `"`
+}
+
+// ProductListETag is a weak tag for a list page: it changes whenever any
+// product on the page or the page boundaries change.
+func ProductListETag(l *dto.ProductListResponse) string {
+	parts := make([]string, 0, len(l.Products)+3)
+	for i := range l.Products {
+		parts = append(parts, ProductETag(&l.Products[i]))
+	}
+	parts = append(parts, l.NextCursor, l.PrevCursor)
+	if l.Total != nil {
+		parts = append(parts, strconv.Itoa(*l.Total))
+	}
+	return `W/"` + hashParts(parts...) + `"`
+}
+
+func hashParts(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// MatchETag checks etag against an If-Match / If-None-Match header value.
+func MatchETag(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+			continue
+		}
+		if !strings.HasPrefix(candidate, "W/") && candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+)
+
+func TestMatchETag(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		etag   string
+		weak   bool
+		want   bool
+	}{
+		{"exact strong", `"abc"`, `"abc"`, false, true},
+		{"list strong", `"x", "abc"`, `"abc"`, false, true},
+		{"wildcard", `*`, `"abc"`, false, true},
+		{"weak header never matches strongly", `W/"abc"`, `"abc"`, false, false},
+		{"weak comparison ignores prefix", `W/"abc"`, `"abc"`, true, true},
+		{"weak etag against strong header", `"abc"`, `W/"abc"`, true, true},
+		{"mismatch", `"def"`, `"abc"`, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, MatchETag(tt.header, tt.etag, tt.weak))
+		})
+	}
+}
+
+func TestProductListETag_ChangesWithContent(t *testing.T) {
+	p := dto.ProductResponse{ID: uuid.New()}
+	list := &dto.ProductListResponse{Products: []dto.ProductResponse{p}}
+	before := ProductListETag(list)
+	assert.Equal(t, before, ProductListETag(list))
+
+	list.Products[0].UpdatedAt = list.Products[0].UpdatedAt.Add(time.Microsecond)
+	assert.NotEqual(t, before, ProductListETag(list))
+
+	list.NextCursor = "next"
+	assert.NotEqual(t, before, ProductListETag(list))
+}
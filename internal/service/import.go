@@ -0,0 +1,500 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/shopspring/decimal"
+
+	"github.com/flicky/go-ecommerce-api/internal/cache"
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+
+	MaxImportSize       = 20 << 20
+	maxImportErrors     = 1000
+	importProgressEvery = 500
+)
+
+var (
+	ErrImportJobNotFound = errors.New("import job not found")
+	ErrUnsupportedFormat = errors.New("unsupported format")
+	ErrImportTooLarge    = errors.New("import file too large")
+)
+
+// productFields is the column order for CSV export and the set of columns
+// understood by CSV import.
+var productFields = []string{"id", "sku", "name", "description", "price", "stock", "status"}
+
+type ImportService struct {
+	jobRepo       repository.ImportJobRepository
+	productRepo   repository.ProductRepository
+	inventoryRepo repository.InventoryRepository
+	cache         *cache.Cache
+	alerts        *StockAlertService
+	amqpCh        *amqp.Channel
+}
+
+func NewImportService(jobRepo repository.ImportJobRepository, productRepo repository.ProductRepository, inventoryRepo repository.InventoryRepository, productCache *cache.Cache, alerts *StockAlertService, amqpCh *amqp.Channel) *ImportService {
+	return &ImportService{jobRepo: jobRepo, productRepo: productRepo, inventoryRepo: inventoryRepo, cache: productCache, alerts: alerts, amqpCh: amqpCh}
+}
+
+// CreateJob stores the uploaded file and queues it for the import worker.
+func (s *ImportService) CreateJob(ctx context.Context, userID uuid.UUID, format string, data []byte) (*dto.ImportJobResponse, error) {
+	if format != FormatCSV && format != FormatNDJSON {
+		return nil, ErrUnsupportedFormat
+	}
+	if len(data) > MaxImportSize {
+		return nil, ErrImportTooLarge
+	}
+
+	job := &model.ImportJob{Format: format, Status: "pending", Payload: data, CreatedBy: userID}
+	if err := s.jobRepo.Create(ctx, job); err != nil {
+		return nil, fmt.Errorf("create import job: %w", err)
+	}
+
+	msg, err := json.Marshal(model.ImportMessage{JobID: job.ID})
+	if err != nil {
+		return nil, fmt.Errorf("marshal import message: %w", err)
+	}
+	if s.amqpCh != nil {
+		if err := s.amqpCh.PublishWithContext(ctx, "", "product_imports", false, false, amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         msg,
+			DeliveryMode: amqp.Persistent,
+		}); err != nil {
+			// Nothing will pick the job up, so it must not stay pending.
+			failJob(job, errors.New("could not queue the import"))
+			_ = s.jobRepo.UpdateProgress(ctx, job)
+			return nil, fmt.Errorf("publish import job: %w", err)
+		}
+	}
+
+	resp := toImportJobResponse(job)
+	return &resp, nil
+}
+
+func (s *ImportService) GetJob(ctx context.Context, id uuid.UUID) (*dto.ImportJobResponse, error) {
+	job, err := s.jobRepo.GetByID(ctx, id, false)
+	if err != nil {
+		return nil, fmt.Errorf("get import job: %w", err)
+	}
+	if job == nil {
+		return nil, ErrImportJobNotFound
+	}
+	resp := toImportJobResponse(job)
+	return &resp, nil
+}
+
+// Process runs an import job, resuming one that was cut short. A job that
+// cannot run to the end is marked failed.
+func (s *ImportService) Process(ctx context.Context, jobID uuid.UUID) error {
+	job, err := s.jobRepo.GetByID(ctx, jobID, true)
+	if err != nil {
+		return fmt.Errorf("get import job: %w", err)
+	}
+	if job == nil {
+		return ErrImportJobNotFound
+	}
+	if job.Status == "completed" || job.Status == "failed" {
+		return nil
+	}
+
+	skip := 0
+	if job.Status == "running" {
+		skip = job.TotalRows
+	} else {
+		job.Status = "running"
+		job.TotalRows, job.Created, job.Updated, job.Failed, job.Errors = 0, 0, 0, 0, nil
+		if err := s.jobRepo.UpdateProgress(ctx, job); err != nil {
+			return err
+		}
+	}
+
+	// Touched products are invalidated in batches alongside progress updates.
+	var touched []uuid.UUID
+	seen := 0
+	readErr := readImportRows(job.Format, job.Payload, func(line int, row importRow, rowErr error) error {
+		seen++
+		if seen <= skip {
+			return ctx.Err()
+		}
+		job.TotalRows++
+		if rowErr == nil {
+			var id uuid.UUID
+			id, rowErr = s.importRow(ctx, job, line, row)
+			if rowErr == nil {
+				touched = append(touched, id)
+			}
+		}
+		if rowErr != nil {
+			job.Failed++
+			if len(job.Errors) < maxImportErrors {
+				job.Errors = append(job.Errors, model.ImportRowError{Row: line, Error: rowErr.Error()})
+			}
+		}
+		if job.TotalRows%importProgressEvery == 0 {
+			s.cache.InvalidateProducts(ctx, touched...)
+			touched = touched[:0]
+			return s.jobRepo.UpdateProgress(ctx, job)
+		}
+		return ctx.Err()
+	})
+	s.cache.InvalidateProducts(ctx, touched...)
+	if readErr != nil && ctx.Err() != nil {
+		return readErr
+	}
+
+	if readErr != nil {
+		failJob(job, readErr)
+	} else {
+		now := time.Now()
+		job.Status, job.FinishedAt = "completed", &now
+	}
+	if err := s.jobRepo.UpdateProgress(ctx, job); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Fail marks a job that will not be processed again as failed with cause.
+// Finished jobs are left as they are.
+func (s *ImportService) Fail(ctx context.Context, jobID uuid.UUID, cause error) error {
+	job, err := s.jobRepo.GetByID(ctx, jobID, false)
+	if err != nil {
+		return fmt.Errorf("get import job: %w", err)
+	}
+	if job == nil || job.Status == "completed" || job.Status == "failed" {
+		return nil
+	}
+	failJob(job, cause)
+	return s.jobRepo.UpdateProgress(ctx, job)
+}
+
+func failJob(job *model.ImportJob, cause error) {
+	now := time.Now()
+	job.Status, job.FinishedAt = "failed", &now
+	job.Errors = append(job.Errors, model.ImportRowError{Error: cause.Error()})
+}
+
+// importRow applies one row of the job's file; applying it twice is harmless.
+func (s *ImportService) importRow(ctx context.Context, job *model.ImportJob, line int, row importRow) (uuid.UUID, error) {
+	product := &model.Product{
+		SKU: row.SKU, Name: row.Name, Description: row.Description,
+		Price: *row.Price, Stock: *row.Stock, Status: row.Status,
+	}
+
+	switch {
+	case row.ID != uuid.Nil:
+		existing, err := s.productRepo.GetByID(ctx, row.ID)
+		if err != nil {
+			return uuid.Nil, errors.New("lookup failed")
+		}
+		if existing == nil {
+			return uuid.Nil, fmt.Errorf("product %s not found", row.ID)
+		}
+		// The file only carries some catalogue fields; the rest keep their
+		// stored values.
+		updated := *existing
+		updated.Name, updated.Description, updated.Price = row.Name, row.Description, *row.Price
+		if row.SKU != "" {
+			updated.SKU = row.SKU
+		}
+		if row.Status != "" {
+			updated.Status = row.Status
+		}
+		product = &updated
+		// Rows that only re-state the current catalogue fields skip the
+		// write, so re-importing an export does not bump every version.
+		if changes := diffProduct(existing, product); len(changes) > 0 {
+			audit := &model.ProductAuditEntry{Action: model.ProductAuditImport, ActorID: &job.CreatedBy, Changes: changes}
+			if err := s.productRepo.Update(ctx, product, audit); err != nil {
+				switch {
+				case errors.Is(err, repository.ErrDuplicateSKU):
+					return uuid.Nil, ErrSKUAlreadyExists
+				case errors.Is(err, repository.ErrVersionConflict):
+					return uuid.Nil, errors.New("product was modified during import")
+				}
+				return uuid.Nil, errors.New("update failed")
+			}
+		}
+		if err := s.bookStock(ctx, job, product.ID, *row.Stock-existing.Stock); err != nil {
+			return uuid.Nil, err
+		}
+		job.Updated++
+	case row.SKU != "":
+		created, err := s.productRepo.UpsertBySKU(ctx, product, &job.CreatedBy, func(old, updated *model.Product) *model.ProductAuditEntry {
+			changes := diffProduct(old, updated)
+			if len(changes) == 0 {
+				return nil
+			}
+			return &model.ProductAuditEntry{Action: model.ProductAuditImport, ActorID: &job.CreatedBy, Changes: changes}
+		})
+		if err != nil {
+			return uuid.Nil, errors.New("upsert failed")
+		}
+		if created {
+			job.Created++
+			break
+		}
+		if err := s.bookStock(ctx, job, product.ID, *row.Stock-product.Stock); err != nil {
+			return uuid.Nil, err
+		}
+		job.Updated++
+	default:
+		product.ID = uuid.NewSHA1(job.ID, []byte(strconv.Itoa(line)))
+		existing, err := s.productRepo.GetByID(ctx, product.ID)
+		if err != nil {
+			return uuid.Nil, errors.New("lookup failed")
+		}
+		if existing == nil {
+			if product.Status == "" {
+				product.Status = model.ProductStatusActive
+			}
+			if err := s.productRepo.Create(ctx, product, &job.CreatedBy); err != nil {
+				return uuid.Nil, errors.New("create failed")
+			}
+		}
+		job.Created++
+	}
+	return product.ID, nil
+}
+
+// bookStock books delta as a ledger correction by the importer.
+func (s *ImportService) bookStock(ctx context.Context, job *model.ImportJob, productID uuid.UUID, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	m := &model.InventoryMovement{
+		ProductID: productID, Kind: model.MovementCorrection, Quantity: delta,
+		Reason: "import " + job.ID.String(), ActorID: &job.CreatedBy,
+	}
+	if err := s.inventoryRepo.Adjust(ctx, m); err != nil {
+		if errors.Is(err, repository.ErrInsufficientStock) {
+			return errors.New("stock changed during import")
+		}
+		return errors.New("stock update failed")
+	}
+	_ = s.alerts.Observe(ctx, []model.InventoryMovement{*m})
+	return nil
+}
+
+// Export streams the whole catalogue to w in the given format.
+func (s *ImportService) Export(ctx context.Context, format string, w io.Writer) error {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(productFields); err != nil {
+			return err
+		}
+		err := s.productRepo.Each(ctx, func(p *model.Product) error {
+			return cw.Write([]string{
+				p.ID.String(), p.SKU, p.Name, p.Description, p.Price.StringFixed(2), strconv.Itoa(p.Stock), p.Status,
+			})
+		})
+		if err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+	case FormatNDJSON:
+		enc := json.NewEncoder(w)
+		return s.productRepo.Each(ctx, func(p *model.Product) error {
+			return enc.Encode(exportRow{
+				ID: p.ID, SKU: p.SKU, Name: p.Name, Description: p.Description,
+				Price: p.Price, Stock: p.Stock, Status: p.Status,
+			})
+		})
+	default:
+		return ErrUnsupportedFormat
+	}
+}
+
+type exportRow struct {
+	ID          uuid.UUID       `json:"id"`
+	SKU         string          `json:"sku,omitempty"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Price       decimal.Decimal `json:"price"`
+	Stock       int             `json:"stock"`
+	Status      string          `json:"status"`
+}
+
+type importRow struct {
+	ID          uuid.UUID        `json:"id"`
+	SKU         string           `json:"sku"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Price       *decimal.Decimal `json:"price"`
+	Stock       *int             `json:"stock"`
+	Status      string           `json:"status"`
+}
+
+func (r importRow) validate() error {
+	switch {
+	case strings.TrimSpace(r.Name) == "":
+		return errors.New("name is required")
+	case len(r.Name) > 255:
+		return errors.New("name is too long")
+	case len(r.SKU) > 64:
+		return errors.New("sku is too long")
+	case r.Price == nil:
+		return errors.New("price is required")
+	case r.Price.IsNegative():
+		return errors.New("price must not be negative")
+	case !r.Price.Equal(r.Price.Round(2)):
+		return errors.New("price must have at most 2 decimal places")
+	case r.Stock == nil:
+		return errors.New("stock is required")
+	case *r.Stock < 0:
+		return errors.New("stock must not be negative")
+	case r.Status == model.ProductStatusArchived:
+		return errors.New("archived products cannot be imported")
+	case r.Status != "" && r.Status != model.ProductStatusDraft && r.Status != model.ProductStatusActive:
+		return errors.New("status must be draft or active")
+	}
+	return nil
+}
+
+// readImportRows calls fn for every data row with its 1-based line number.
+// Row-level problems are passed to fn; only an unreadable file is returned.
+func readImportRows(format string, data []byte, fn func(line int, row importRow, rowErr error) error) error {
+	switch format {
+	case FormatCSV:
+		return readCSVRows(data, fn)
+	case FormatNDJSON:
+		return readNDJSONRows(data, fn)
+	default:
+		return ErrUnsupportedFormat
+	}
+}
+
+func readCSVRows(data []byte, fn func(int, importRow, error) error) error {
+	r := csv.NewReader(bytes.NewReader(data))
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("read header: %w", err)
+	}
+	cols := make(map[string]int, len(header))
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, required := range []string{"name", "price", "stock"} {
+		if _, ok := cols[required]; !ok {
+			return fmt.Errorf("missing column %q", required)
+		}
+	}
+	get := func(rec []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		line, _ := r.FieldPos(0)
+		if err != nil {
+			if !errors.Is(err, csv.ErrFieldCount) {
+				return err
+			}
+			if err := fn(line, importRow{}, errors.New("wrong number of fields")); err != nil {
+				return err
+			}
+			continue
+		}
+
+		row := importRow{
+			SKU: get(rec, "sku"), Name: get(rec, "name"), Description: get(rec, "description"),
+			Status: strings.ToLower(get(rec, "status")),
+		}
+		rowErr := parseCSVRow(&row, get(rec, "id"), get(rec, "price"), get(rec, "stock"))
+		if rowErr == nil {
+			rowErr = row.validate()
+		}
+		if err := fn(line, row, rowErr); err != nil {
+			return err
+		}
+	}
+}
+
+func parseCSVRow(row *importRow, id, price, stock string) error {
+	if id != "" {
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			return errors.New("invalid id")
+		}
+		row.ID = parsed
+	}
+	if price != "" {
+		p, err := decimal.NewFromString(price)
+		if err != nil {
+			return errors.New("invalid price")
+		}
+		row.Price = &p
+	}
+	if stock != "" {
+		n, err := strconv.Atoi(stock)
+		if err != nil {
+			return errors.New("invalid stock")
+		}
+		row.Stock = &n
+	}
+	return nil
+}
+
+func readNDJSONRows(data []byte, fn func(int, importRow, error) error) error {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	line := 0
+	for sc.Scan() {
+		line++
+		raw := bytes.TrimSpace(sc.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		var row importRow
+		rowErr := json.Unmarshal(raw, &row)
+		if rowErr != nil {
+			rowErr = errors.New("invalid json")
+		} else {
+			row.SKU, row.Name = strings.TrimSpace(row.SKU), strings.TrimSpace(row.Name)
+			rowErr = row.validate()
+		}
+		if err := fn(line, row, rowErr); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+func toImportJobResponse(job *model.ImportJob) dto.ImportJobResponse {
+	errs := make([]dto.ImportRowErrorResult, len(job.Errors))
+	for i, e := range job.Errors {
+		errs[i] = dto.ImportRowErrorResult{Row: e.Row, Error: e.Error}
+	}
+	return dto.ImportJobResponse{
+		ID: job.ID, Format: job.Format, Status: job.Status, TotalRows: job.TotalRows,
+		Created: job.Created, Updated: job.Updated, Failed: job.Failed, Errors: errs,
+		CreatedAt: job.CreatedAt, FinishedAt: job.FinishedAt,
+	}
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+// mockImportJobRepo fails the update numbered failUpdate, counting from 1.
+type mockImportJobRepo struct {
+	jobs       map[uuid.UUID]*model.ImportJob
+	updates    int
+	failUpdate int
+}
+
+func newMockImportJobRepo() *mockImportJobRepo {
+	return &mockImportJobRepo{jobs: make(map[uuid.UUID]*model.ImportJob)}
+}
+
+func (m *mockImportJobRepo) Create(_ context.Context, job *model.ImportJob) error {
+	job.ID = uuid.New()
+	job.CreatedAt = time.Now()
+	m.jobs[job.ID] = job
+	return nil
+}
+
+func (m *mockImportJobRepo) GetByID(_ context.Context, id uuid.UUID, _ bool) (*model.ImportJob, error) {
+	return m.jobs[id], nil
+}
+
+func (m *mockImportJobRepo) UpdateProgress(_ context.Context, job *model.ImportJob) error {
+	m.updates++
+	if m.updates == m.failUpdate {
+		return errors.New("connection reset")
+	}
+	cp := *job
+	m.jobs[job.ID] = &cp
+	return nil
+}
+
+func runImport(t *testing.T, productRepo *mockProductRepo, format, data string) *model.ImportJob {
+	t.Helper()
+	jobRepo := newMockImportJobRepo()
+	svc := NewImportService(jobRepo, productRepo, &mockInventoryRepo{products: productRepo}, nil, nil, nil)
+	resp, err := svc.CreateJob(context.Background(), uuid.New(), format, []byte(data))
+	require.NoError(t, err)
+	require.NoError(t, svc.Process(context.Background(), resp.ID))
+	return jobRepo.jobs[resp.ID]
+}
+
+func TestImportService_CSV(t *testing.T) {
+	productRepo := newMockProductRepo()
+	existing := &model.Product{SKU: "MUG-1", Name: "Old mug", Price: decimal.NewFromInt(5), Stock: 1}
+	require.NoError(t, productRepo.Create(context.Background(), existing, nil))
+
+	job := runImport(t, productRepo, FormatCSV, strings.Join([]string{
+		"sku,name,price,stock,description",
+		"MUG-1,Mug,9.99,10,Ceramic",
+		"TEE-1,T-shirt,19.50,5,",
+		",No price,,3,",
+		"BAD-1,Bad stock,1.00,-1,",
+		"BAD-2,Too many,1.00,1,x,extra",
+	}, "\n"))
+
+	assert.Equal(t, "completed", job.Status)
+	assert.Equal(t, 5, job.TotalRows)
+	assert.Equal(t, 1, job.Created)
+	assert.Equal(t, 1, job.Updated)
+	assert.Equal(t, 3, job.Failed)
+	require.Len(t, job.Errors, 3)
+	assert.Equal(t, 4, job.Errors[0].Row)
+	assert.Equal(t, "price is required", job.Errors[0].Error)
+	assert.Equal(t, "Mug", productRepo.products[existing.ID].Name)
+	assert.Equal(t, 10, productRepo.products[existing.ID].Stock)
+	assert.Len(t, productRepo.products, 2)
+	assert.NotNil(t, job.FinishedAt)
+	require.Len(t, productRepo.audits, 1)
+	assert.Equal(t, existing.ID, productRepo.audits[0].ProductID)
+	assert.Equal(t, model.ProductAuditImport, productRepo.audits[0].Action)
+}
+
+func TestImportService_NDJSON_ByID(t *testing.T) {
+	productRepo := newMockProductRepo()
+	existing := &model.Product{
+		Name: "Lamp", Price: decimal.NewFromInt(30), Stock: 2,
+		Category: "lighting", WeightGrams: 1200, TaxClass: "reduced",
+	}
+	require.NoError(t, productRepo.Create(context.Background(), existing, nil))
+
+	job := runImport(t, productRepo, FormatNDJSON,
+		`{"id":"`+existing.ID.String()+`","name":"Desk lamp","price":"35.00","stock":4}`+"\n"+
+			`{"id":"`+uuid.NewString()+`","name":"Ghost","price":1,"stock":1}`+"\n"+
+			`not json`+"\n")
+
+	assert.Equal(t, 1, job.Updated)
+	assert.Equal(t, 2, job.Failed)
+	assert.Equal(t, "Desk lamp", productRepo.products[existing.ID].Name)
+	assert.Equal(t, 4, productRepo.products[existing.ID].Stock)
+	// Fields the file does not carry keep their stored values.
+	assert.Equal(t, "lighting", productRepo.products[existing.ID].Category)
+	assert.Equal(t, 1200, productRepo.products[existing.ID].WeightGrams)
+	assert.Equal(t, "reduced", productRepo.products[existing.ID].TaxClass)
+	// The stock change is booked in the ledger as a correction by the importer.
+	last := productRepo.movements[len(productRepo.movements)-1]
+	assert.Equal(t, model.MovementCorrection, last.Kind)
+	assert.Equal(t, 2, last.Quantity)
+	assert.NotNil(t, last.ActorID)
+}
+
+func TestImportService_CSV_MissingColumn(t *testing.T) {
+	job := runImport(t, newMockProductRepo(), FormatCSV, "name,price\nMug,1.00\n")
+	assert.Equal(t, "failed", job.Status)
+	require.Len(t, job.Errors, 1)
+	assert.Contains(t, job.Errors[0].Error, `missing column "stock"`)
+}
+
+func TestImportService_ResumesRunningJob(t *testing.T) {
+	productRepo := newMockProductRepo()
+	jobRepo := newMockImportJobRepo()
+	svc := NewImportService(jobRepo, productRepo, &mockInventoryRepo{products: productRepo}, nil, nil, nil)
+	ctx := context.Background()
+	resp, err := svc.CreateJob(ctx, uuid.New(), FormatNDJSON, []byte(
+		`{"name":"Mug","price":1,"stock":1}`+"\n"+
+			`{"name":"Cup","price":1,"stock":1}`+"\n"+
+			`{"name":"Jug","price":1,"stock":1}`+"\n"))
+	require.NoError(t, err)
+
+	// The worker died after recording the first row and applying the
+	// second.
+	job := jobRepo.jobs[resp.ID]
+	job.Status, job.TotalRows, job.Created = "running", 1, 1
+	mug := &model.Product{ID: uuid.NewSHA1(job.ID, []byte("1")), Name: "Mug"}
+	cup := &model.Product{ID: uuid.NewSHA1(job.ID, []byte("2")), Name: "Cup"}
+	require.NoError(t, productRepo.Create(ctx, mug, nil))
+	require.NoError(t, productRepo.Create(ctx, cup, nil))
+
+	require.NoError(t, svc.Process(ctx, resp.ID))
+	job = jobRepo.jobs[resp.ID]
+	assert.Equal(t, "completed", job.Status)
+	assert.Equal(t, 3, job.TotalRows)
+	assert.Equal(t, 3, job.Created)
+	assert.Len(t, productRepo.products, 3)
+}
+
+func TestImportService_MarksFailedJob(t *testing.T) {
+	productRepo := newMockProductRepo()
+	jobRepo := newMockImportJobRepo()
+	svc := NewImportService(jobRepo, productRepo, &mockInventoryRepo{products: productRepo}, nil, nil, nil)
+	ctx := context.Background()
+	var data strings.Builder
+	for range importProgressEvery + 1 {
+		data.WriteString(`{"name":"Mug","price":1,"stock":0}` + "\n")
+	}
+	resp, err := svc.CreateJob(ctx, uuid.New(), FormatNDJSON, []byte(data.String()))
+	require.NoError(t, err)
+
+	// Progress cannot be recorded midway: the job fails rather than staying
+	// running.
+	jobRepo.failUpdate = 2
+	require.NoError(t, svc.Process(ctx, resp.ID))
+	job := jobRepo.jobs[resp.ID]
+	assert.Equal(t, "failed", job.Status)
+	assert.NotNil(t, job.FinishedAt)
+	assert.Contains(t, job.Errors[len(job.Errors)-1].Error, "connection reset")
+
+	// A job whose end cannot be recorded either is left to be resumed.
+	resp, err = svc.CreateJob(ctx, uuid.New(), FormatNDJSON, []byte(`{"name":"Cup","price":1,"stock":0}`))
+	require.NoError(t, err)
+	jobRepo.updates, jobRepo.failUpdate = 0, 2
+	require.Error(t, svc.Process(ctx, resp.ID))
+	assert.Equal(t, "running", jobRepo.jobs[resp.ID].Status)
+	require.NoError(t, svc.Process(ctx, resp.ID))
+	assert.Equal(t, "completed", jobRepo.jobs[resp.ID].Status)
+	assert.Equal(t, 1, jobRepo.jobs[resp.ID].Created)
+}
+
+func TestImportService_Fail(t *testing.T) {
+	productRepo := newMockProductRepo()
+	jobRepo := newMockImportJobRepo()
+	svc := NewImportService(jobRepo, productRepo, &mockInventoryRepo{products: productRepo}, nil, nil, nil)
+	ctx := context.Background()
+	resp, err := svc.CreateJob(ctx, uuid.New(), FormatNDJSON, []byte(`{"name":"Mug","price":1,"stock":0}`))
+	require.NoError(t, err)
+	jobRepo.jobs[resp.ID].Status = "running"
+
+	require.NoError(t, svc.Fail(ctx, resp.ID, errors.New("connection reset")))
+	job := jobRepo.jobs[resp.ID]
+	assert.Equal(t, "failed", job.Status)
+	assert.NotNil(t, job.FinishedAt)
+	assert.Equal(t, "connection reset", job.Errors[0].Error)
+
+	// A dropped redelivery of a job that did finish leaves it alone.
+	resp, err = svc.CreateJob(ctx, uuid.New(), FormatNDJSON, []byte(`{"name":"Cup","price":1,"stock":0}`))
+	require.NoError(t, err)
+	require.NoError(t, svc.Process(ctx, resp.ID))
+	require.NoError(t, svc.Fail(ctx, resp.ID, errors.New("late")))
+	assert.Equal(t, "completed", jobRepo.jobs[resp.ID].Status)
+}
+
+func TestImportService_Export(t *testing.T) {
+	productRepo := newMockProductRepo()
+	require.NoError(t, productRepo.Create(context.Background(), &model.Product{
+		SKU: "MUG-1", Name: "Mug", Price: decimal.NewFromFloat(9.5), Stock: 3,
+	}, nil))
+	svc := NewImportService(newMockImportJobRepo(), productRepo, &mockInventoryRepo{products: productRepo}, nil, nil, nil)
+
+	var buf bytes.Buffer
+	require.NoError(t, svc.Export(context.Background(), FormatCSV, &buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, "id,sku,name,description,price,stock,status", lines[0])
+	assert.True(t, strings.HasSuffix(lines[1], ",MUG-1,Mug,,9.50,3,active"))
+
+	// An export can be fed straight back into an import.
+	job := runImport(t, productRepo, FormatCSV, buf.String())
+	assert.Equal(t, 1, job.Updated)
+	assert.Zero(t, job.Failed)
+
+	buf.Reset()
+	require.NoError(t, svc.Export(context.Background(), FormatNDJSON, &buf))
+	assert.Contains(t, buf.String(), `"sku":"MUG-1"`)
+	assert.ErrorIs(t, svc.Export(context.Background(), "xml", &buf), ErrUnsupportedFormat)
+}
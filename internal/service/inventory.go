@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/cache"
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrInvalidAdjustment = errors.New("invalid adjustment")
+)
+
+// InventoryService owns every stock change that is not a sale: each one is
+// booked as a movement in the inventory ledger together with the new level.
+type InventoryService struct {
+	repo        repository.InventoryRepository
+	productRepo repository.ProductRepository
+	orderRepo   repository.OrderRepository
+	cache       *cache.Cache
+	alerts      *StockAlertService
+}
+
+func NewInventoryService(repo repository.InventoryRepository, productRepo repository.ProductRepository, orderRepo repository.OrderRepository, productCache *cache.Cache, alerts *StockAlertService) *InventoryService {
+	return &InventoryService{repo: repo, productRepo: productRepo, orderRepo: orderRepo, cache: productCache, alerts: alerts}
+}
+
+// Adjust applies a manual movement. Restocks and returns must add stock;
+// adjustments and corrections can go either way but need a reason.
+func (s *InventoryService) Adjust(ctx context.Context, actorID uuid.UUID, req dto.InventoryAdjustmentRequest) (*dto.InventoryMovementResponse, error) {
+	switch req.Kind {
+	case model.MovementRestock, model.MovementReturn:
+		if req.Quantity < 0 {
+			return nil, fmt.Errorf("%w: %s quantity must be positive", ErrInvalidAdjustment, req.Kind)
+		}
+	case model.MovementAdjustment, model.MovementCorrection:
+		if req.Reason == "" {
+			return nil, fmt.Errorf("%w: %s needs a reason", ErrInvalidAdjustment, req.Kind)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidAdjustment, req.Kind)
+	}
+	if req.OrderID != nil {
+		order, err := s.orderRepo.GetByID(ctx, *req.OrderID)
+		if err != nil {
+			return nil, fmt.Errorf("get order: %w", err)
+		}
+		if order == nil {
+			return nil, ErrOrderNotFound
+		}
+	}
+
+	m := &model.InventoryMovement{
+		ProductID: req.ProductID, Kind: req.Kind, Quantity: req.Quantity, Reason: req.Reason,
+		WarehouseID: req.WarehouseID, ActorID: actorRef(actorID), OrderID: req.OrderID,
+	}
+	if err := s.repo.Adjust(ctx, m); err != nil {
+		return nil, inventoryError("adjust stock", err)
+	}
+	s.cache.InvalidateProducts(ctx, m.ProductID)
+	// The movement is booked; a lost alert is not worth failing it for.
+	_ = s.alerts.Observe(ctx, []model.InventoryMovement{*m})
+	resp := toInventoryMovementResponse(m)
+	return &resp, nil
+}
+
+// Transfer moves stock between warehouses; the product's total stock does
+// not change, so cached products stay valid.
+func (s *InventoryService) Transfer(ctx context.Context, actorID uuid.UUID, req dto.StockTransferRequest) (*dto.StockTransferResponse, error) {
+	if req.FromWarehouseID == req.ToWarehouseID {
+		return nil, fmt.Errorf("%w: source and destination are the same warehouse", ErrInvalidAdjustment)
+	}
+	movements, err := s.repo.Transfer(ctx, &model.StockTransfer{
+		ProductID: req.ProductID, FromWarehouseID: req.FromWarehouseID, ToWarehouseID: req.ToWarehouseID,
+		Quantity: req.Quantity, Reason: req.Reason, ActorID: actorRef(actorID),
+	})
+	if err != nil {
+		return nil, inventoryError("transfer stock", err)
+	}
+	resp := &dto.StockTransferResponse{Movements: make([]dto.InventoryMovementResponse, len(movements))}
+	for i := range movements {
+		resp.Movements[i] = toInventoryMovementResponse(&movements[i])
+	}
+	return resp, nil
+}
+
+func inventoryError(op string, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrProductNotFound
+	case errors.Is(err, repository.ErrWarehouseNotFound):
+		return ErrWarehouseNotFound
+	case errors.Is(err, repository.ErrInsufficientStock):
+		return ErrInsufficientStock
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+// Movements returns a product's ledger, newest first.
+func (s *InventoryService) Movements(ctx context.Context, productID uuid.UUID, params pagination.Params) (*dto.InventoryMovementListResponse, error) {
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("get product: %w", err)
+	}
+	if product == nil {
+		return nil, ErrProductNotFound
+	}
+	movements, page, err := s.repo.ListByProductID(ctx, productID, params)
+	if err != nil {
+		return nil, fmt.Errorf("list inventory movements: %w", err)
+	}
+	resp := &dto.InventoryMovementListResponse{
+		Movements: make([]dto.InventoryMovementResponse, len(movements)),
+		PageInfo:  toPageInfo(page),
+	}
+	for i := range movements {
+		resp.Movements[i] = toInventoryMovementResponse(&movements[i])
+	}
+	return resp, nil
+}
+
+// Discrepancies reports products whose stock no longer matches the ledger.
+func (s *InventoryService) Discrepancies(ctx context.Context) (*dto.ReconciliationResponse, error) {
+	found, err := s.repo.Discrepancies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("find stock discrepancies: %w", err)
+	}
+	return toReconciliationResponse(found), nil
+}
+
+// Reconcile books a correction for every discrepancy so the ledger matches
+// on-hand stock again, and returns the discrepancies it fixed.
+func (s *InventoryService) Reconcile(ctx context.Context, actorID uuid.UUID) (*dto.ReconciliationResponse, error) {
+	found, err := s.repo.Reconcile(ctx, actorRef(actorID))
+	if err != nil {
+		return nil, fmt.Errorf("reconcile inventory: %w", err)
+	}
+	return toReconciliationResponse(found), nil
+}
+
+func toInventoryMovementResponse(m *model.InventoryMovement) dto.InventoryMovementResponse {
+	return dto.InventoryMovementResponse{
+		ID: m.ID, ProductID: m.ProductID, Kind: m.Kind, Quantity: m.Quantity, StockAfter: m.StockAfter,
+		Reason: m.Reason, WarehouseID: m.WarehouseID, ActorID: m.ActorID, OrderID: m.OrderID, CreatedAt: m.CreatedAt,
+	}
+}
+
+func toReconciliationResponse(found []model.StockDiscrepancy) *dto.ReconciliationResponse {
+	resp := &dto.ReconciliationResponse{Discrepancies: make([]dto.StockDiscrepancyResponse, len(found))}
+	for i, d := range found {
+		resp.Discrepancies[i] = dto.StockDiscrepancyResponse{ProductID: d.ProductID, Stock: d.Stock, LedgerStock: d.LedgerStock}
+	}
+	return resp
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+// mockInventoryRepo keeps its ledger on the product mock, so products
+// created there come with their initial movement. levels holds per-warehouse
+// stock (warehouse -> product -> quantity) for transfers.
+type mockInventoryRepo struct {
+	products *mockProductRepo
+	levels   map[uuid.UUID]map[uuid.UUID]int
+}
+
+func (m *mockInventoryRepo) Adjust(_ context.Context, mv *model.InventoryMovement) error {
+	p, ok := m.products.products[mv.ProductID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if p.Stock+mv.Quantity < 0 {
+		return repository.ErrInsufficientStock
+	}
+	p.Stock += mv.Quantity
+	mv.ID, mv.StockAfter, mv.CreatedAt = uuid.New(), p.Stock, time.Now()
+	m.products.movements = append(m.products.movements, *mv)
+	return nil
+}
+
+func (m *mockInventoryRepo) Transfer(_ context.Context, t *model.StockTransfer) ([]model.InventoryMovement, error) {
+	p, ok := m.products.products[t.ProductID]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	from, ok := m.levels[t.FromWarehouseID]
+	if !ok {
+		return nil, repository.ErrWarehouseNotFound
+	}
+	to, ok := m.levels[t.ToWarehouseID]
+	if !ok {
+		return nil, repository.ErrWarehouseNotFound
+	}
+	if from[t.ProductID] < t.Quantity {
+		return nil, repository.ErrInsufficientStock
+	}
+	from[t.ProductID] -= t.Quantity
+	to[t.ProductID] += t.Quantity
+	out := []model.InventoryMovement{
+		{WarehouseID: &t.FromWarehouseID, Quantity: -t.Quantity},
+		{WarehouseID: &t.ToWarehouseID, Quantity: t.Quantity},
+	}
+	for i := range out {
+		out[i].ID, out[i].ProductID, out[i].Kind = uuid.New(), t.ProductID, model.MovementTransfer
+		out[i].StockAfter, out[i].Reason, out[i].ActorID, out[i].CreatedAt = p.Stock, t.Reason, t.ActorID, time.Now()
+		m.products.movements = append(m.products.movements, out[i])
+	}
+	return out, nil
+}
+
+func (m *mockInventoryRepo) ListByProductID(_ context.Context, productID uuid.UUID, params pagination.Params) ([]model.InventoryMovement, pagination.Page, error) {
+	var all []model.InventoryMovement
+	for _, mv := range m.products.movements {
+		if mv.ProductID == productID {
+			all = append(all, mv)
+		}
+	}
+	sortNewestFirst(all, movementKey)
+	items, page := pagination.Build(afterCursor(all, params, movementKey), params, movementKey)
+	return items, page, nil
+}
+
+func (m *mockInventoryRepo) Discrepancies(_ context.Context) ([]model.StockDiscrepancy, error) {
+	ledger := make(map[uuid.UUID]int)
+	for _, mv := range m.products.movements {
+		ledger[mv.ProductID] += mv.Quantity
+	}
+	var found []model.StockDiscrepancy
+	for id, p := range m.products.products {
+		if p.Stock != ledger[id] {
+			found = append(found, model.StockDiscrepancy{ProductID: id, Stock: p.Stock, LedgerStock: ledger[id]})
+		}
+	}
+	return found, nil
+}
+
+func (m *mockInventoryRepo) Reconcile(ctx context.Context, actorID *uuid.UUID) ([]model.StockDiscrepancy, error) {
+	found, _ := m.Discrepancies(ctx)
+	for _, d := range found {
+		m.products.movements = append(m.products.movements, model.InventoryMovement{
+			ID: uuid.New(), ProductID: d.ProductID, Kind: model.MovementCorrection,
+			Quantity: d.Stock - d.LedgerStock, StockAfter: d.Stock, ActorID: actorID, CreatedAt: time.Now(),
+		})
+	}
+	return found, nil
+}
+
+func movementKey(m model.InventoryMovement) (time.Time, uuid.UUID) { return m.CreatedAt, m.ID }
+
+func newInventoryFixture(t *testing.T, stock int) (*InventoryService, *mockProductRepo, *mockOrderRepo, uuid.UUID) {
+	t.Helper()
+	products, orders := newMockProductRepo(), newMockOrderRepo()
+	p := &model.Product{Name: "Mug", Price: decimal.NewFromInt(10), Stock: stock, Status: model.ProductStatusActive}
+	require.NoError(t, products.Create(context.Background(), p, nil))
+	svc := NewInventoryService(&mockInventoryRepo{products: products}, products, orders, nil, nil)
+	return svc, products, orders, p.ID
+}
+
+func TestInventoryService_Adjust(t *testing.T) {
+	svc, products, _, id := newInventoryFixture(t, 5)
+	ctx := context.Background()
+	actor := uuid.New()
+
+	resp, err := svc.Adjust(ctx, actor, dto.InventoryAdjustmentRequest{
+		ProductID: id, Kind: model.MovementRestock, Quantity: 10, Reason: "delivery #42",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 15, resp.StockAfter)
+	assert.Equal(t, &actor, resp.ActorID)
+	assert.Equal(t, 15, products.products[id].Stock)
+
+	resp, err = svc.Adjust(ctx, actor, dto.InventoryAdjustmentRequest{
+		ProductID: id, Kind: model.MovementAdjustment, Quantity: -3, Reason: "damaged",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 12, resp.StockAfter)
+}
+
+func TestInventoryService_Adjust_Rejects(t *testing.T) {
+	svc, products, _, id := newInventoryFixture(t, 5)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		req  dto.InventoryAdjustmentRequest
+		want error
+	}{
+		{"negative restock", dto.InventoryAdjustmentRequest{ProductID: id, Kind: model.MovementRestock, Quantity: -1}, ErrInvalidAdjustment},
+		{"adjustment without reason", dto.InventoryAdjustmentRequest{ProductID: id, Kind: model.MovementAdjustment, Quantity: 1}, ErrInvalidAdjustment},
+		{"manual sale", dto.InventoryAdjustmentRequest{ProductID: id, Kind: model.MovementSale, Quantity: -1}, ErrInvalidAdjustment},
+		{"below zero", dto.InventoryAdjustmentRequest{ProductID: id, Kind: model.MovementCorrection, Quantity: -6, Reason: "count"}, ErrInsufficientStock},
+		{"unknown product", dto.InventoryAdjustmentRequest{ProductID: uuid.New(), Kind: model.MovementRestock, Quantity: 1}, ErrProductNotFound},
+		{"unknown order", dto.InventoryAdjustmentRequest{ProductID: id, Kind: model.MovementReturn, Quantity: 1, OrderID: ptr(uuid.New())}, ErrOrderNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.Adjust(ctx, uuid.Nil, tt.req)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+	assert.Equal(t, 5, products.products[id].Stock)
+	assert.Len(t, products.movements, 1)
+}
+
+func TestInventoryService_Movements(t *testing.T) {
+	svc, _, orders, id := newInventoryFixture(t, 5)
+	ctx := context.Background()
+
+	order := &model.Order{UserID: uuid.New()}
+	require.NoError(t, orders.Create(ctx, order))
+	_, err := svc.Adjust(ctx, uuid.Nil, dto.InventoryAdjustmentRequest{
+		ProductID: id, Kind: model.MovementReturn, Quantity: 1, OrderID: &order.ID,
+	})
+	require.NoError(t, err)
+
+	resp, err := svc.Movements(ctx, id, pagination.Params{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, resp.Movements, 2)
+	assert.Equal(t, model.MovementReturn, resp.Movements[0].Kind)
+	assert.Equal(t, &order.ID, resp.Movements[0].OrderID)
+	assert.Equal(t, model.MovementRestock, resp.Movements[1].Kind)
+
+	_, err = svc.Movements(ctx, uuid.New(), pagination.Params{Limit: 10})
+	assert.ErrorIs(t, err, ErrProductNotFound)
+}
+
+func TestInventoryService_Reconcile(t *testing.T) {
+	svc, products, _, id := newInventoryFixture(t, 5)
+	ctx := context.Background()
+
+	// Stock changed behind the ledger's back.
+	products.products[id].Stock = 8
+	report, err := svc.Discrepancies(ctx)
+	require.NoError(t, err)
+	require.Len(t, report.Discrepancies, 1)
+	assert.Equal(t, dto.StockDiscrepancyResponse{ProductID: id, Stock: 8, LedgerStock: 5}, report.Discrepancies[0])
+
+	fixed, err := svc.Reconcile(ctx, uuid.New())
+	require.NoError(t, err)
+	assert.Len(t, fixed.Discrepancies, 1)
+	assert.Equal(t, 8, products.products[id].Stock)
+
+	report, err = svc.Discrepancies(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, report.Discrepancies)
+}
+
+func TestInventoryService_Transfer(t *testing.T) {
+	products := newMockProductRepo()
+	ctx := context.Background()
+	p := &model.Product{Name: "Mug", Price: decimal.NewFromInt(10), Stock: 5, Status: model.ProductStatusActive}
+	require.NoError(t, products.Create(ctx, p, nil))
+	central, east := uuid.New(), uuid.New()
+	repo := &mockInventoryRepo{products: products, levels: map[uuid.UUID]map[uuid.UUID]int{
+		central: {p.ID: 5},
+		east:    {},
+	}}
+	svc := NewInventoryService(repo, products, newMockOrderRepo(), nil, nil)
+
+	resp, err := svc.Transfer(ctx, uuid.Nil, dto.StockTransferRequest{
+		ProductID: p.ID, FromWarehouseID: central, ToWarehouseID: east, Quantity: 3, Reason: "rebalance",
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Movements, 2)
+	assert.Equal(t, -3, resp.Movements[0].Quantity)
+	assert.Equal(t, &central, resp.Movements[0].WarehouseID)
+	assert.Equal(t, 3, resp.Movements[1].Quantity)
+	assert.Equal(t, 5, resp.Movements[1].StockAfter)
+	assert.Equal(t, 2, repo.levels[central][p.ID])
+	assert.Equal(t, 3, repo.levels[east][p.ID])
+	assert.Equal(t, 5, products.products[p.ID].Stock)
+
+	tests := []struct {
+		name string
+		req  dto.StockTransferRequest
+		want error
+	}{
+		{"same warehouse", dto.StockTransferRequest{ProductID: p.ID, FromWarehouseID: central, ToWarehouseID: central, Quantity: 1}, ErrInvalidAdjustment},
+		{"more than available", dto.StockTransferRequest{ProductID: p.ID, FromWarehouseID: central, ToWarehouseID: east, Quantity: 3}, ErrInsufficientStock},
+		{"unknown warehouse", dto.StockTransferRequest{ProductID: p.ID, FromWarehouseID: central, ToWarehouseID: uuid.New(), Quantity: 1}, ErrWarehouseNotFound},
+		{"unknown product", dto.StockTransferRequest{ProductID: uuid.New(), FromWarehouseID: central, ToWarehouseID: east, Quantity: 1}, ErrProductNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.Transfer(ctx, uuid.Nil, tt.req)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/flicky/go-ecommerce-api/internal/currency"
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/invoice"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+	"github.com/flicky/go-ecommerce-api/internal/tax"
+)
+
+var (
+	ErrInvoiceNotFound = errors.New("invoice not found")
+	// ErrInvalidInvoiceQuery wraps what is wrong with an invoice list query.
+	ErrInvalidInvoiceQuery = errors.New("invalid invoice query")
+)
+
+// invoicedStatuses are the statuses of orders that have completed and so
+// have an invoice.
+var invoicedStatuses = []string{model.OrderCompleted, model.OrderShipped, model.OrderDelivered}
+
+var invoiceKinds = []string{model.InvoiceKindInvoice, model.InvoiceKindCreditNote}
+
+// InvoiceService renders invoice and credit note PDFs.
+type InvoiceService struct {
+	repo   repository.InvoiceRepository
+	admin  repository.OrderAdminRepository
+	orders *OrderService
+	seller invoice.Party
+}
+
+// NewInvoiceService returns an InvoiceService.
+func NewInvoiceService(repo repository.InvoiceRepository, adminRepo repository.OrderAdminRepository, orders *OrderService, seller invoice.Party) *InvoiceService {
+	return &InvoiceService{repo: repo, admin: adminRepo, orders: orders, seller: seller}
+}
+
+// OrderInvoice returns the invoice of the user's order with its PDF.
+func (s *InvoiceService) OrderInvoice(ctx context.Context, userID, orderID uuid.UUID) (*model.Invoice, []byte, error) {
+	order, err := s.orders.GetByID(ctx, orderID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	inv, err := s.repo.GetByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get invoice: %w", err)
+	}
+	if inv == nil {
+		if !slices.Contains(invoicedStatuses, order.Status) {
+			return nil, nil, ErrInvoiceNotFound
+		}
+		if inv, err = s.repo.Issue(ctx, orderID); err != nil {
+			return nil, nil, fmt.Errorf("issue invoice: %w", err)
+		}
+	}
+	s.baseCurrency(inv)
+	pdf, err := s.pdf(ctx, inv, order)
+	if err != nil {
+		return nil, nil, err
+	}
+	return inv, pdf, nil
+}
+
+// List pages through the invoices and credit notes, newest first.
+func (s *InvoiceService) List(ctx context.Context, q dto.InvoiceListQuery, params pagination.Params) ([]model.Invoice, dto.PageInfo, error) {
+	var f repository.InvoiceFilter
+	if q.OrderID != "" {
+		id, err := uuid.Parse(q.OrderID)
+		if err != nil {
+			return nil, dto.PageInfo{}, fmt.Errorf("%w: order_id must be an order ID", ErrInvalidInvoiceQuery)
+		}
+		f.OrderID = &id
+	}
+	if q.Kind != "" && !slices.Contains(invoiceKinds, q.Kind) {
+		return nil, dto.PageInfo{}, fmt.Errorf("%w: kind must be one of %s", ErrInvalidInvoiceQuery, strings.Join(invoiceKinds, ", "))
+	}
+	f.Kind = q.Kind
+
+	invoices, page, err := s.repo.List(ctx, f, params)
+	if err != nil {
+		return nil, dto.PageInfo{}, fmt.Errorf("list invoices: %w", err)
+	}
+	for i := range invoices {
+		s.baseCurrency(&invoices[i])
+	}
+	return invoices, toPageInfo(page), nil
+}
+
+// PDF returns any invoice or credit note with its PDF.
+func (s *InvoiceService) PDF(ctx context.Context, id uuid.UUID) (*model.Invoice, []byte, error) {
+	inv, err := s.get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	pdf, err := s.pdf(ctx, inv, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return inv, pdf, nil
+}
+
+// Regenerate renders the document again from its order, as after the
+// seller's details change. Its number, date and total stay as issued.
+func (s *InvoiceService) Regenerate(ctx context.Context, id uuid.UUID) (*model.Invoice, error) {
+	inv, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	order, err := s.orders.order(ctx, inv.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.render(ctx, inv, order); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+func (s *InvoiceService) get(ctx context.Context, id uuid.UUID) (*model.Invoice, error) {
+	inv, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get invoice: %w", err)
+	}
+	if inv == nil {
+		return nil, ErrInvoiceNotFound
+	}
+	s.baseCurrency(inv)
+	return inv, nil
+}
+
+// baseCurrency fills in the currency of a document of an order placed
+// before orders recorded one.
+func (s *InvoiceService) baseCurrency(inv *model.Invoice) {
+	if inv.Currency == "" {
+		inv.Currency = s.orders.prices.Base()
+	}
+}
+
+// pdf returns the document's kept PDF, rendering it the first time; order
+// is loaded if it is not given.
+func (s *InvoiceService) pdf(ctx context.Context, inv *model.Invoice, order *model.Order) ([]byte, error) {
+	data, err := s.repo.GetPDF(ctx, inv.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get invoice pdf: %w", err)
+	}
+	if data != nil {
+		return data, nil
+	}
+	if order == nil {
+		if order, err = s.orders.order(ctx, inv.OrderID); err != nil {
+			return nil, err
+		}
+	}
+	return s.render(ctx, inv, order)
+}
+
+// render renders the document and keeps the PDF.
+func (s *InvoiceService) render(ctx context.Context, inv *model.Invoice, order *model.Order) ([]byte, error) {
+	doc, err := s.document(ctx, inv, order)
+	if err != nil {
+		return nil, err
+	}
+	data, err := invoice.Render(doc)
+	if err != nil {
+		return nil, fmt.Errorf("render %s: %w", inv.Code(), err)
+	}
+	renderedAt, err := s.repo.SavePDF(ctx, inv.ID, data)
+	if err != nil {
+		return nil, fmt.Errorf("save %s: %w", inv.Code(), err)
+	}
+	inv.RenderedAt = &renderedAt
+	return data, nil
+}
+
+// document lays out inv, an invoice or credit note of order.
+func (s *InvoiceService) document(ctx context.Context, inv *model.Invoice, order *model.Order) (*invoice.Document, error) {
+	d := &invoice.Document{
+		Number: inv.Code(), IssuedAt: inv.CreatedAt, Seller: s.seller, Currency: inv.Currency,
+		References: []string{"Order " + order.ID.String(), "Order date: " + order.CreatedAt.Format("2006-01-02")},
+		ShipTo:     addressParty(order.ShippingAddress),
+	}
+	billTo := order.BillingAddress
+	if billTo == nil {
+		billTo = order.ShippingAddress
+	}
+	if d.BillTo = addressParty(billTo); d.BillTo == nil {
+		customer, err := s.admin.GetCustomer(ctx, order.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if customer != nil {
+			d.BillTo = &invoice.Party{
+				Name: strings.TrimSpace(customer.FirstName + " " + customer.LastName), Lines: []string{customer.Email},
+			}
+		}
+	}
+
+	if inv.Kind == model.InvoiceKindCreditNote {
+		d.Title = "Credit note"
+		original, err := s.repo.GetByID(ctx, *inv.InvoiceID)
+		if err != nil {
+			return nil, fmt.Errorf("get invoice: %w", err)
+		}
+		if original != nil {
+			d.References = append(d.References, "Credits invoice "+original.Code())
+		}
+		refunds, err := s.admin.ListRefunds(ctx, order.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, rf := range refunds {
+			if rf.ID == *inv.RefundID && rf.Reference != "" {
+				d.References = append(d.References, "Refund "+rf.Reference)
+			}
+		}
+		d.Lines, d.Taxes, d.Tax = creditNoteLines(order, inv.Total, inv.Currency)
+		d.Subtotal, d.Total, d.TaxIncluded = inv.Total, inv.Total, true
+		return d, nil
+	}
+
+	d.Title = "Invoice"
+	d.Lines = invoiceLines(order.Items)
+	lineTaxes := make([]tax.LineTax, len(order.Items))
+	for i, item := range order.Items {
+		lineTaxes[i] = tax.LineTax{Name: item.TaxName, Rate: item.TaxRate, Amount: item.TaxAmount}
+	}
+	for _, l := range tax.Breakdown(lineTaxes) {
+		d.Taxes = append(d.Taxes, invoice.TaxLine{Name: l.Name, Rate: l.Rate, Amount: l.Amount})
+	}
+	d.Subtotal, d.Discount, d.Shipping = order.Subtotal, order.Discount, order.ShippingCost
+	d.Tax, d.TaxIncluded, d.Total = order.Tax, order.PricesIncludeTax, inv.Total
+	return d, nil
+}
+
+// invoiceLines turns order items into invoice lines, joining the parts of
+// a line split over several warehouses back together.
+func invoiceLines(items []model.OrderItem) []invoice.Line {
+	type key struct {
+		productID   uuid.UUID
+		name        string
+		price, rate string
+	}
+	var lines []invoice.Line
+	index := make(map[key]int)
+	for _, item := range items {
+		k := key{item.ProductID, item.Product.Name, item.Price.String(), item.TaxRate.String()}
+		i, ok := index[k]
+		if !ok {
+			i = len(lines)
+			index[k] = i
+			lines = append(lines, invoice.Line{
+				Description: item.Product.Name, SKU: item.Product.SKU, UnitPrice: item.Price, TaxRate: item.TaxRate,
+			})
+		}
+		lines[i].Quantity += item.Quantity
+		lines[i].Amount = lines[i].UnitPrice.Mul(decimal.NewFromInt(int64(lines[i].Quantity)))
+	}
+	return lines
+}
+
+// creditNoteLines credits amount, tax included, at the order's tax rates.
+func creditNoteLines(order *model.Order, amount decimal.Decimal, code string) ([]invoice.Line, []invoice.TaxLine, decimal.Decimal) {
+	type group struct {
+		name  string
+		rate  decimal.Decimal
+		gross decimal.Decimal
+	}
+	var groups []group
+	var gross decimal.Decimal
+	for _, item := range order.Items {
+		g := item.Price.Mul(decimal.NewFromInt(int64(item.Quantity)))
+		if !order.PricesIncludeTax {
+			g = g.Add(item.TaxAmount)
+		}
+		gross = gross.Add(g)
+		i := slices.IndexFunc(groups, func(gr group) bool { return gr.name == item.TaxName && gr.rate.Equal(item.TaxRate) })
+		if i < 0 {
+			groups = append(groups, group{name: item.TaxName, rate: item.TaxRate})
+			i = len(groups) - 1
+		}
+		groups[i].gross = groups[i].gross.Add(g)
+	}
+	if len(groups) == 0 || !gross.IsPositive() {
+		groups, gross = []group{{gross: decimal.NewFromInt(1)}}, decimal.NewFromInt(1)
+	}
+
+	var lines []invoice.Line
+	var taxes []invoice.TaxLine
+	var totalTax decimal.Decimal
+	left := amount
+	for i, g := range groups {
+		// The last share takes what is left, so the shares add up exactly.
+		share := left
+		if i < len(groups)-1 {
+			share = currency.Round(amount.Mul(g.gross).Div(gross), code)
+		}
+		left = left.Sub(share)
+		desc := "Refund"
+		if len(groups) > 1 && g.name != "" {
+			desc = fmt.Sprintf("Refund, %s %s%%", g.name, g.rate)
+		}
+		lines = append(lines, invoice.Line{Description: desc, Quantity: 1, UnitPrice: share, TaxRate: g.rate, Amount: share})
+		if g.rate.IsPositive() {
+			t := currency.Round(share.Mul(g.rate).Div(g.rate.Add(decimal.NewFromInt(100))), code)
+			taxes = append(taxes, invoice.TaxLine{Name: g.name, Rate: g.rate, Amount: t})
+			totalTax = totalTax.Add(t)
+		}
+	}
+	return lines, taxes, totalTax
+}
+
+// addressParty is the party a postal address names, nil without one.
+func addressParty(a *model.PostalAddress) *invoice.Party {
+	if a == nil {
+		return nil
+	}
+	p := &invoice.Party{Name: a.FullName, Lines: []string{a.Line1}}
+	if a.Line2 != "" {
+		p.Lines = append(p.Lines, a.Line2)
+	}
+	p.Lines = append(p.Lines, strings.TrimSpace(a.PostalCode+" "+a.City))
+	if a.Region != "" {
+		p.Lines = append(p.Lines, a.Region)
+	}
+	p.Lines = append(p.Lines, a.Country)
+	return p
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/invoice"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+// mockInvoiceRepo issues invoices numbered from 1 and counts the PDFs it
+// is given.
+type mockInvoiceRepo struct {
+	invoices []*model.Invoice
+	pdfs     map[uuid.UUID][]byte
+	saves    int
+}
+
+func newMockInvoiceRepo() *mockInvoiceRepo {
+	return &mockInvoiceRepo{pdfs: make(map[uuid.UUID][]byte)}
+}
+
+func (m *mockInvoiceRepo) add(inv *model.Invoice) *model.Invoice {
+	inv.ID, inv.CreatedAt = uuid.New(), time.Now()
+	for _, other := range m.invoices {
+		if other.Kind == inv.Kind {
+			inv.Number = max(inv.Number, other.Number)
+		}
+	}
+	inv.Number++
+	m.invoices = append(m.invoices, inv)
+	return inv
+}
+
+func (m *mockInvoiceRepo) Issue(ctx context.Context, orderID uuid.UUID) (*model.Invoice, error) {
+	if inv, _ := m.GetByOrderID(ctx, orderID); inv != nil {
+		return inv, nil
+	}
+	return m.add(&model.Invoice{Kind: model.InvoiceKindInvoice, OrderID: orderID}), nil
+}
+
+func (m *mockInvoiceRepo) GetByID(_ context.Context, id uuid.UUID) (*model.Invoice, error) {
+	for _, inv := range m.invoices {
+		if inv.ID == id {
+			c := *inv
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockInvoiceRepo) GetByOrderID(_ context.Context, orderID uuid.UUID) (*model.Invoice, error) {
+	for _, inv := range m.invoices {
+		if inv.OrderID == orderID && inv.Kind == model.InvoiceKindInvoice {
+			c := *inv
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockInvoiceRepo) List(_ context.Context, f repository.InvoiceFilter, p pagination.Params) ([]model.Invoice, pagination.Page, error) {
+	var invoices []model.Invoice
+	for _, inv := range m.invoices {
+		if f.OrderID != nil && inv.OrderID != *f.OrderID || f.Kind != "" && inv.Kind != f.Kind {
+			continue
+		}
+		invoices = append(invoices, *inv)
+	}
+	sortNewestFirst(invoices, invoiceKey)
+	items, page := pagination.Build(afterCursor(invoices, p, invoiceKey), p, invoiceKey)
+	return items, page, nil
+}
+
+func invoiceKey(inv model.Invoice) (time.Time, uuid.UUID) { return inv.CreatedAt, inv.ID }
+
+func (m *mockInvoiceRepo) GetPDF(_ context.Context, id uuid.UUID) ([]byte, error) {
+	return m.pdfs[id], nil
+}
+
+func (m *mockInvoiceRepo) SavePDF(_ context.Context, id uuid.UUID, pdf []byte) (time.Time, error) {
+	m.pdfs[id] = pdf
+	m.saves++
+	return time.Now(), nil
+}
+
+type invoiceFixture struct {
+	svc    *InvoiceService
+	repo   *mockInvoiceRepo
+	orders *mockOrderRepo
+}
+
+func newInvoiceFixture() *invoiceFixture {
+	orders := newMockOrderRepo()
+	repo := newMockInvoiceRepo()
+	orderSvc := NewOrderService(orders, nil, nil, newMockPromotionRepo(), newMockAddressRepo(), newFlatShipping(), newTaxTable(false), newTestPrices(), nil)
+	svc := NewInvoiceService(repo, newMockOrderAdminRepo(orders), orderSvc, invoice.Party{Name: "Shop Ltd"})
+	return &invoiceFixture{svc: svc, repo: repo, orders: orders}
+}
+
+func (f *invoiceFixture) add(status string) *model.Order {
+	address := model.PostalAddress{FullName: "Анна Смирнова", Line1: "1 Main St", City: "Berlin", PostalCode: "10115", Country: "DE"}
+	o := &model.Order{
+		ID: uuid.New(), UserID: uuid.New(), Status: status, Currency: "EUR", CreatedAt: time.Now(),
+		Subtotal: decimal.NewFromInt(20), TotalPrice: decimal.NewFromInt(20), ShippingAddress: &address,
+		Items: []model.OrderItem{{
+			ProductID: uuid.New(), Quantity: 2, Price: decimal.NewFromInt(10), Product: model.ProductSnapshot{Name: "Mug"},
+		}},
+	}
+	f.orders.orders[o.ID] = o
+	return o
+}
+
+func TestInvoiceService_OrderInvoice(t *testing.T) {
+	f := newInvoiceFixture()
+	ctx := context.Background()
+	order := f.add(model.OrderCompleted)
+
+	// An order completed before invoicing gets its invoice when asked.
+	inv, pdf, err := f.svc.OrderInvoice(ctx, order.UserID, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "INV-000001", inv.Code())
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-")))
+	assert.NotNil(t, inv.RenderedAt)
+
+	// The PDF is rendered once and kept.
+	again, pdf2, err := f.svc.OrderInvoice(ctx, order.UserID, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, inv.ID, again.ID)
+	assert.Equal(t, pdf, pdf2)
+	assert.Equal(t, 1, f.repo.saves)
+
+	_, _, err = f.svc.OrderInvoice(ctx, uuid.New(), order.ID)
+	assert.ErrorIs(t, err, ErrOrderAccessDenied)
+
+	pending := f.add(model.OrderPending)
+	_, _, err = f.svc.OrderInvoice(ctx, pending.UserID, pending.ID)
+	assert.ErrorIs(t, err, ErrInvoiceNotFound)
+	assert.Len(t, f.repo.invoices, 1)
+}
+
+func TestInvoiceService_PDFAndRegenerate(t *testing.T) {
+	f := newInvoiceFixture()
+	ctx := context.Background()
+	order := f.add(model.OrderRefunded)
+	inv := f.repo.add(&model.Invoice{Kind: model.InvoiceKindInvoice, OrderID: order.ID, Total: order.TotalPrice})
+	refund := model.OrderRefund{ID: uuid.New(), OrderID: order.ID, Amount: decimal.NewFromInt(20), Reference: "re_1"}
+	credit := f.repo.add(&model.Invoice{
+		Kind: model.InvoiceKindCreditNote, OrderID: order.ID, InvoiceID: &inv.ID, RefundID: &refund.ID, Total: refund.Amount,
+	})
+
+	got, pdf, err := f.svc.PDF(ctx, credit.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "CN-000001", got.Code())
+	assert.Equal(t, "USD", got.Currency, "documents without a currency are in the base currency")
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-")))
+
+	_, err = f.svc.Regenerate(ctx, credit.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, f.repo.saves)
+
+	_, err = f.svc.Regenerate(ctx, uuid.New())
+	assert.ErrorIs(t, err, ErrInvoiceNotFound)
+}
+
+func TestInvoiceService_List(t *testing.T) {
+	f := newInvoiceFixture()
+	ctx := context.Background()
+	order := f.add(model.OrderCompleted)
+	inv := f.repo.add(&model.Invoice{Kind: model.InvoiceKindInvoice, OrderID: order.ID, Currency: "EUR"})
+	f.repo.add(&model.Invoice{Kind: model.InvoiceKindCreditNote, OrderID: order.ID, InvoiceID: &inv.ID, Currency: "EUR"})
+	f.repo.add(&model.Invoice{Kind: model.InvoiceKindInvoice, OrderID: uuid.New(), Currency: "EUR"})
+
+	got, _, err := f.svc.List(ctx, dto.InvoiceListQuery{OrderID: order.ID.String()}, pagination.Params{Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, got, 2)
+
+	got, _, err = f.svc.List(ctx, dto.InvoiceListQuery{Kind: model.InvoiceKindInvoice}, pagination.Params{Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, got, 2)
+
+	_, _, err = f.svc.List(ctx, dto.InvoiceListQuery{Kind: "receipt"}, pagination.Params{Limit: 10})
+	assert.ErrorIs(t, err, ErrInvalidInvoiceQuery)
+	_, _, err = f.svc.List(ctx, dto.InvoiceListQuery{OrderID: "42"}, pagination.Params{Limit: 10})
+	assert.ErrorIs(t, err, ErrInvalidInvoiceQuery)
+}
+
+func TestInvoiceLines_JoinsSplitItems(t *testing.T) {
+	mug, cup := uuid.New(), uuid.New()
+	price := decimal.NewFromInt(10)
+	lines := invoiceLines([]model.OrderItem{
+		{ProductID: mug, Quantity: 2, Price: price, Product: model.ProductSnapshot{Name: "Mug", SKU: "MUG"}},
+		{ProductID: cup, Quantity: 1, Price: price, Product: model.ProductSnapshot{Name: "Cup"}},
+		{ProductID: mug, Quantity: 3, Price: price, Product: model.ProductSnapshot{Name: "Mug", SKU: "MUG"}},
+	})
+	require.Len(t, lines, 2)
+	assert.Equal(t, "Mug", lines[0].Description)
+	assert.Equal(t, 5, lines[0].Quantity)
+	assert.True(t, lines[0].Amount.Equal(decimal.NewFromInt(50)))
+	assert.Equal(t, 1, lines[1].Quantity)
+}
+
+func TestCreditNoteLines(t *testing.T) {
+	vat := decimal.NewFromInt(20)
+	order := &model.Order{Items: []model.OrderItem{
+		{Quantity: 1, Price: decimal.NewFromInt(100), TaxName: "VAT", TaxRate: vat, TaxAmount: decimal.NewFromInt(20)},
+		{Quantity: 1, Price: decimal.NewFromInt(50)},
+	}}
+
+	// 85 of 170 charged: 120 of it at 20% VAT, 50 untaxed.
+	lines, taxes, total := creditNoteLines(order, decimal.NewFromInt(85), "EUR")
+	require.Len(t, lines, 2)
+	assert.Equal(t, "Refund, VAT 20%", lines[0].Description)
+	assert.True(t, lines[0].Amount.Equal(decimal.NewFromInt(60)), lines[0].Amount.String())
+	assert.True(t, lines[1].Amount.Equal(decimal.NewFromInt(25)), lines[1].Amount.String())
+	require.Len(t, taxes, 1)
+	assert.True(t, taxes[0].Amount.Equal(decimal.NewFromInt(10)), taxes[0].Amount.String())
+	assert.True(t, total.Equal(decimal.NewFromInt(10)))
+
+	// The shares add up to the refund exactly.
+	lines, _, _ = creditNoteLines(order, decimal.RequireFromString("33.33"), "EUR")
+	assert.True(t, lines[0].Amount.Add(lines[1].Amount).Equal(decimal.RequireFromString("33.33")))
+
+	// An order without lines is credited in one untaxed line.
+	lines, taxes, _ = creditNoteLines(&model.Order{}, decimal.NewFromInt(5), "EUR")
+	require.Len(t, lines, 1)
+	assert.Empty(t, taxes)
+}
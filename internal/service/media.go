@@ -0,0 +1,211 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // register GIF decoder
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"github.com/google/uuid"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register WebP decoder
+
+	"github.com/flicky/go-ecommerce-api/internal/cache"
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+	"github.com/flicky/go-ecommerce-api/internal/storage"
+)
+
+var (
+	ErrMediaNotFound = errors.New("media not found")
+	ErrMediaTooLarge = errors.New("media too large")
+	// ErrImageTooLarge means the image has more than maxImagePixels pixels.
+	ErrImageTooLarge        = errors.New("image dimensions too large")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+)
+
+const thumbnailSize = 320
+
+// maxImagePixels caps the pixels of an uploaded image. A small file can
+// declare huge dimensions, so an image is measured before it is decoded.
+const maxImagePixels = 50_000_000
+
+// allowedMediaTypes maps sniffed content types to file extensions.
+var allowedMediaTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+type MediaService struct {
+	repo        repository.ProductMediaRepository
+	productRepo repository.ProductRepository
+	store       storage.Storage
+	cache       *cache.Cache
+	maxSize     int64
+}
+
+func NewMediaService(repo repository.ProductMediaRepository, productRepo repository.ProductRepository, store storage.Storage, productCache *cache.Cache, maxSize int64) *MediaService {
+	return &MediaService{repo: repo, productRepo: productRepo, store: store, cache: productCache, maxSize: maxSize}
+}
+
+func (s *MediaService) MaxSize() int64 { return s.maxSize }
+
+// Upload validates an image and stores it with a thumbnail in the product's
+// gallery.
+func (s *MediaService) Upload(ctx context.Context, productID uuid.UUID, data []byte, altText string, primary bool) (*dto.ProductMediaResponse, error) {
+	if int64(len(data)) > s.maxSize {
+		return nil, ErrMediaTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := allowedMediaTypes[contentType]
+	if !ok {
+		return nil, ErrUnsupportedMediaType
+	}
+
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("get product: %w", err)
+	}
+	if product == nil {
+		return nil, ErrProductNotFound
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedMediaType
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+		return nil, fmt.Errorf("%w: %dx%d", ErrImageTooLarge, cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedMediaType
+	}
+	thumb, thumbType, thumbExt, err := makeThumbnail(img, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("make thumbnail: %w", err)
+	}
+
+	mediaID := uuid.New()
+	key := fmt.Sprintf("products/%s/%s.%s", productID, mediaID, ext)
+	thumbKey := fmt.Sprintf("products/%s/%s_thumb.%s", productID, mediaID, thumbExt)
+	if err := s.store.Put(ctx, key, contentType, data); err != nil {
+		return nil, fmt.Errorf("store media: %w", err)
+	}
+	if err := s.store.Put(ctx, thumbKey, thumbType, thumb); err != nil {
+		_ = s.store.Delete(ctx, key)
+		return nil, fmt.Errorf("store thumbnail: %w", err)
+	}
+
+	media := &model.ProductMedia{
+		ProductID: productID, StorageKey: key, ThumbnailKey: thumbKey,
+		URL: s.store.URL(key), ThumbnailURL: s.store.URL(thumbKey),
+		ContentType: contentType, SizeBytes: int64(len(data)),
+		Width: img.Bounds().Dx(), Height: img.Bounds().Dy(),
+		AltText: altText, IsPrimary: primary,
+	}
+	if err := s.repo.Create(ctx, media); err != nil {
+		_ = s.store.Delete(ctx, key)
+		_ = s.store.Delete(ctx, thumbKey)
+		return nil, fmt.Errorf("create media: %w", err)
+	}
+	s.cache.InvalidateProducts(ctx, productID)
+
+	resp := toProductMediaResponse(media)
+	return &resp, nil
+}
+
+func (s *MediaService) Update(ctx context.Context, productID, mediaID uuid.UUID, req dto.UpdateProductMediaRequest) (*dto.ProductMediaResponse, error) {
+	media, err := s.get(ctx, productID, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	if req.AltText != nil {
+		media.AltText = *req.AltText
+	}
+	if req.Position != nil {
+		media.Position = *req.Position
+	}
+	if req.IsPrimary != nil {
+		media.IsPrimary = *req.IsPrimary
+	}
+	if err := s.repo.Update(ctx, media); err != nil {
+		return nil, fmt.Errorf("update media: %w", err)
+	}
+	s.cache.InvalidateProducts(ctx, productID)
+
+	resp := toProductMediaResponse(media)
+	return &resp, nil
+}
+
+func (s *MediaService) Delete(ctx context.Context, productID, mediaID uuid.UUID) error {
+	media, err := s.get(ctx, productID, mediaID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, mediaID); err != nil {
+		return fmt.Errorf("delete media: %w", err)
+	}
+	for _, key := range []string{media.StorageKey, media.ThumbnailKey} {
+		if err := s.store.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("delete media object: %w", err)
+		}
+	}
+	s.cache.InvalidateProducts(ctx, productID)
+	return nil
+}
+
+func (s *MediaService) get(ctx context.Context, productID, mediaID uuid.UUID) (*model.ProductMedia, error) {
+	media, err := s.repo.GetByID(ctx, mediaID)
+	if err != nil {
+		return nil, fmt.Errorf("get media: %w", err)
+	}
+	if media == nil || media.ProductID != productID {
+		return nil, ErrMediaNotFound
+	}
+	return media, nil
+}
+
+// makeThumbnail scales img to fit into thumbnailSize×thumbnailSize. PNG and
+// GIF sources keep transparency as PNG, everything else becomes JPEG.
+func makeThumbnail(img image.Image, contentType string) ([]byte, string, string, error) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > thumbnailSize || h > thumbnailSize {
+		if w >= h {
+			w, h = thumbnailSize, max(1, h*thumbnailSize/w)
+		} else {
+			w, h = max(1, w*thumbnailSize/h), thumbnailSize
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if contentType == "image/png" || contentType == "image/gif" {
+		if err := png.Encode(&buf, dst); err != nil {
+			return nil, "", "", err
+		}
+		return buf.Bytes(), "image/png", "png", nil
+	}
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, "", "", err
+	}
+	return buf.Bytes(), "image/jpeg", "jpg", nil
+}
+
+func toProductMediaResponse(m *model.ProductMedia) dto.ProductMediaResponse {
+	return dto.ProductMediaResponse{
+		ID: m.ID, URL: m.URL, ThumbnailURL: m.ThumbnailURL, ContentType: m.ContentType,
+		Width: m.Width, Height: m.Height, AltText: m.AltText,
+		Position: m.Position, IsPrimary: m.IsPrimary,
+	}
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/storage"
+)
+
+type mockMediaRepo struct {
+	media    map[uuid.UUID]*model.ProductMedia
+	products *mockProductRepo
+}
+
+// touch bumps the product's updated_at as the repository does on every
+// media change.
+func (m *mockMediaRepo) touch(productID uuid.UUID) {
+	if m.products == nil {
+		return
+	}
+	if p, ok := m.products.products[productID]; ok {
+		p.UpdatedAt = p.UpdatedAt.Add(time.Second)
+	}
+}
+
+func newMockMediaRepo() *mockMediaRepo {
+	return &mockMediaRepo{media: make(map[uuid.UUID]*model.ProductMedia)}
+}
+
+func (m *mockMediaRepo) Create(_ context.Context, media *model.ProductMedia) error {
+	media.Position = 0
+	for _, other := range m.media {
+		if other.ProductID == media.ProductID {
+			media.Position++
+		}
+	}
+	media.IsPrimary = media.IsPrimary || media.Position == 0
+	media.ID = uuid.New()
+	media.CreatedAt = time.Now()
+	m.media[media.ID] = media
+	m.touch(media.ProductID)
+	return nil
+}
+
+func (m *mockMediaRepo) GetByID(_ context.Context, id uuid.UUID) (*model.ProductMedia, error) {
+	return m.media[id], nil
+}
+
+func (m *mockMediaRepo) ListByProductIDs(_ context.Context, ids []uuid.UUID) (map[uuid.UUID][]model.ProductMedia, error) {
+	result := make(map[uuid.UUID][]model.ProductMedia)
+	for _, id := range ids {
+		for _, media := range m.media {
+			if media.ProductID == id {
+				result[id] = append(result[id], *media)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (m *mockMediaRepo) Update(_ context.Context, media *model.ProductMedia) error {
+	m.media[media.ID] = media
+	m.touch(media.ProductID)
+	return nil
+}
+
+func (m *mockMediaRepo) Delete(_ context.Context, id uuid.UUID) error {
+	deleted, ok := m.media[id]
+	if !ok {
+		return nil
+	}
+	delete(m.media, id)
+	m.touch(deleted.ProductID)
+	if !deleted.IsPrimary {
+		return nil
+	}
+	var next *model.ProductMedia
+	for _, media := range m.media {
+		if media.ProductID == deleted.ProductID && (next == nil || media.Position < next.Position) {
+			next = media
+		}
+	}
+	if next != nil {
+		next.IsPrimary = true
+	}
+	return nil
+}
+
+type memStorage struct {
+	objects map[string][]byte
+}
+
+func (s *memStorage) Put(_ context.Context, key, _ string, data []byte) error {
+	s.objects[key] = data
+	return nil
+}
+
+func (s *memStorage) Delete(_ context.Context, key string) error {
+	if _, ok := s.objects[key]; !ok {
+		return storage.ErrNotFound
+	}
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *memStorage) URL(key string) string { return "http://cdn.test/" + key }
+
+func pngImage(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))))
+	return buf.Bytes()
+}
+
+func newMediaFixture() (*MediaService, *mockMediaRepo, *memStorage, uuid.UUID) {
+	productRepo := newMockProductRepo()
+	pid := uuid.New()
+	productRepo.products[pid] = &model.Product{ID: pid}
+	repo := newMockMediaRepo()
+	repo.products = productRepo
+	store := &memStorage{objects: make(map[string][]byte)}
+	return NewMediaService(repo, productRepo, store, nil, 1<<20), repo, store, pid
+}
+
+func TestMediaService_Upload(t *testing.T) {
+	svc, repo, store, pid := newMediaFixture()
+	resp, err := svc.Upload(context.Background(), pid, pngImage(t, 800, 400), "front", true)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", resp.ContentType)
+	assert.Equal(t, 800, resp.Width)
+	assert.Len(t, repo.media, 1)
+	assert.Len(t, store.objects, 2)
+
+	thumbKey := repo.media[resp.ID].ThumbnailKey
+	thumb, _, err := image.DecodeConfig(bytes.NewReader(store.objects[thumbKey]))
+	require.NoError(t, err)
+	assert.Equal(t, 320, thumb.Width)
+	assert.Equal(t, 160, thumb.Height)
+}
+
+func TestMediaService_Upload_Validation(t *testing.T) {
+	svc, _, _, pid := newMediaFixture()
+	_, err := svc.Upload(context.Background(), pid, []byte("plain text, not an image"), "", false)
+	assert.ErrorIs(t, err, ErrUnsupportedMediaType)
+
+	_, err = svc.Upload(context.Background(), pid, make([]byte, 2<<20), "", false)
+	assert.ErrorIs(t, err, ErrMediaTooLarge)
+
+	_, err = svc.Upload(context.Background(), uuid.New(), pngImage(t, 10, 10), "", false)
+	assert.ErrorIs(t, err, ErrProductNotFound)
+
+	// A tiny PNG that declares 50000x50000 pixels is refused before decoding.
+	bomb := pngImage(t, 1, 1)
+	binary.BigEndian.PutUint32(bomb[16:], 50000)
+	binary.BigEndian.PutUint32(bomb[20:], 50000)
+	binary.BigEndian.PutUint32(bomb[29:], crc32.ChecksumIEEE(bomb[12:29]))
+	_, err = svc.Upload(context.Background(), pid, bomb, "", false)
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+}
+
+func TestMediaService_Delete(t *testing.T) {
+	svc, repo, store, pid := newMediaFixture()
+	resp, err := svc.Upload(context.Background(), pid, pngImage(t, 10, 10), "", false)
+	require.NoError(t, err)
+
+	second, err := svc.Upload(context.Background(), pid, pngImage(t, 10, 10), "", false)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, svc.Delete(context.Background(), uuid.New(), resp.ID), ErrMediaNotFound)
+	require.NoError(t, svc.Delete(context.Background(), pid, resp.ID))
+	require.Len(t, repo.media, 1)
+	assert.True(t, repo.media[second.ID].IsPrimary, "next image is promoted to primary")
+
+	require.NoError(t, svc.Delete(context.Background(), pid, second.ID))
+	assert.Empty(t, repo.media)
+	assert.Empty(t, store.objects)
+}
+
+func TestProductService_GetByID_IncludesMedia(t *testing.T) {
+	mediaSvc, mediaRepo, _, pid := newMediaFixture()
+	_, err := mediaSvc.Upload(context.Background(), pid, pngImage(t, 10, 10), "alt", false)
+	require.NoError(t, err)
+
+	svc := NewProductService(mediaSvc.productRepo, mediaRepo, nil, newTestPrices())
+	resp, err := svc.GetByID(context.Background(), pid, true, "")
+	require.NoError(t, err)
+	require.Len(t, resp.Media, 1)
+	assert.Equal(t, "alt", resp.Media[0].AltText)
+	assert.Contains(t, resp.Media[0].URL, "http://cdn.test/products/")
+}
+
+func TestProductService_ETag_ChangesWithMedia(t *testing.T) {
+	mediaSvc, mediaRepo, _, pid := newMediaFixture()
+	svc := NewProductService(mediaSvc.productRepo, mediaRepo, nil, newTestPrices())
+	ctx := context.Background()
+	etag := func() string {
+		resp, err := svc.GetByID(ctx, pid, true, "")
+		require.NoError(t, err)
+		return ProductETag(resp)
+	}
+
+	before := etag()
+	media, err := mediaSvc.Upload(ctx, pid, pngImage(t, 10, 10), "", false)
+	require.NoError(t, err)
+	uploaded := etag()
+	assert.NotEqual(t, before, uploaded)
+
+	_, err = mediaSvc.Update(ctx, pid, media.ID, dto.UpdateProductMediaRequest{AltText: ptr("front")})
+	require.NoError(t, err)
+	updated := etag()
+	assert.NotEqual(t, uploaded, updated)
+
+	require.NoError(t, mediaSvc.Delete(ctx, pid, media.ID))
+	assert.NotEqual(t, updated, etag())
+}
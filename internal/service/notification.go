@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+type NotificationService struct {
+	repo repository.NotificationRepository
+}
+
+func NewNotificationService(repo repository.NotificationRepository) *NotificationService {
+	return &NotificationService{repo: repo}
+}
+
+// List returns the user's notifications, newest first.
+func (s *NotificationService) List(ctx context.Context, userID uuid.UUID, params pagination.Params) (*dto.NotificationListResponse, error) {
+	notifications, page, err := s.repo.ListByUserID(ctx, userID, params)
+	if err != nil {
+		return nil, fmt.Errorf("list notifications: %w", err)
+	}
+	resp := &dto.NotificationListResponse{
+		Notifications: make([]dto.NotificationResponse, len(notifications)),
+		PageInfo:      toPageInfo(page),
+	}
+	for i, n := range notifications {
+		resp.Notifications[i] = dto.NotificationResponse{
+			ID: n.ID, Kind: n.Kind, ProductID: n.ProductID, OrderID: n.OrderID, Message: n.Message, CreatedAt: n.CreatedAt, ReadAt: n.ReadAt,
+		}
+	}
+	return resp, nil
+}
+
+func (s *NotificationService) MarkRead(ctx context.Context, userID, id uuid.UUID) error {
+	if err := s.repo.MarkRead(ctx, id, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrNotificationNotFound
+		}
+		return fmt.Errorf("mark notification read: %w", err)
+	}
+	return nil
+}
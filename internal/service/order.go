@@ -5,83 +5,384 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/shopspring/decimal"
 
+	"github.com/flicky/go-ecommerce-api/internal/currency"
+	"github.com/flicky/go-ecommerce-api/internal/dto"
 	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+	"github.com/flicky/go-ecommerce-api/internal/promotion"
 	"github.com/flicky/go-ecommerce-api/internal/repository"
+	"github.com/flicky/go-ecommerce-api/internal/shipping"
+	"github.com/flicky/go-ecommerce-api/internal/tax"
 )
 
 var (
-	ErrEmptyCart         = errors.New("cart is empty")
-	ErrOrderNotFound     = errors.New("order not found")
-	ErrOrderAccessDenied = errors.New("access denied")
+	ErrEmptyCart          = errors.New("cart is empty")
+	ErrOrderNotFound      = errors.New("order not found")
+	ErrOrderAccessDenied  = errors.New("access denied")
+	ErrProductUnavailable = errors.New("product unavailable")
+	// ErrShippingAddressRequired means checkout was given no shipping
+	// address and the user has no default one.
+	ErrShippingAddressRequired = errors.New("shipping address required")
+	// ErrInvalidOrderQuery wraps what is wrong with an order list query.
+	ErrInvalidOrderQuery = errors.New("invalid order query")
 )
 
+var orderStatuses = []string{
+	model.OrderPending, model.OrderCompleted, model.OrderFailed, model.OrderShipped, model.OrderDelivered,
+	model.OrderCancelled, model.OrderRefunded,
+}
+
 type OrderService struct {
-	orderRepo   repository.OrderRepository
-	cartRepo    repository.CartRepository
-	productRepo repository.ProductRepository
-	amqpCh      *amqp.Channel
+	orderRepo     repository.OrderRepository
+	cartRepo      repository.CartRepository
+	productRepo   repository.ProductRepository
+	promotionRepo repository.PromotionRepository
+	addressRepo   repository.AddressRepository
+	shipping      shipping.Provider
+	taxes         tax.Calculator
+	prices        *currency.Converter
+	amqpCh        *amqp.Channel
 }
 
-func NewOrderService(orderRepo repository.OrderRepository, cartRepo repository.CartRepository, productRepo repository.ProductRepository, amqpCh *amqp.Channel) *OrderService {
-	return &OrderService{orderRepo: orderRepo, cartRepo: cartRepo, productRepo: productRepo, amqpCh: amqpCh}
+func NewOrderService(orderRepo repository.OrderRepository, cartRepo repository.CartRepository, productRepo repository.ProductRepository, promotionRepo repository.PromotionRepository, addressRepo repository.AddressRepository, shipper shipping.Provider, taxes tax.Calculator, prices *currency.Converter, amqpCh *amqp.Channel) *OrderService {
+	return &OrderService{orderRepo: orderRepo, cartRepo: cartRepo, productRepo: productRepo, promotionRepo: promotionRepo, addressRepo: addressRepo, shipping: shipper, taxes: taxes, prices: prices, amqpCh: amqpCh}
 }
 
-func (s *OrderService) CreateOrder(ctx context.Context, userID uuid.UUID) (*model.Order, error) {
+// CreateOrder places an order for the user's cart.
+func (s *OrderService) CreateOrder(ctx context.Context, userID uuid.UUID, req dto.CreateOrderRequest) (*model.Order, error) {
+	pc, err := pricing(ctx, s.prices, req.Currency)
+	if err != nil {
+		return nil, err
+	}
 	cart, err := s.cartRepo.GetOrCreateCart(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("get cart: %w", err)
 	}
+	if cart.Locked(time.Now()) {
+		return nil, ErrCartLocked
+	}
 	cartWithItems, err := s.cartRepo.GetCartWithItems(ctx, cart.ID)
 	if err != nil {
 		return nil, fmt.Errorf("get cart items: %w", err)
 	}
-	if cartWithItems == nil || len(cartWithItems.Items) == 0 {
+	if cartWithItems == nil {
 		return nil, ErrEmptyCart
 	}
 
-	var total decimal.Decimal
 	var items []model.OrderItem
 	for _, ci := range cartWithItems.Items {
-		product, err := s.productRepo.GetByID(ctx, ci.ProductID)
-		if err != nil || product == nil {
-			return nil, fmt.Errorf("product %s not found", ci.ProductID)
+		if !ci.SavedForLater {
+			items = append(items, model.OrderItem{ProductID: ci.ProductID, Quantity: ci.Quantity})
 		}
-		total = total.Add(product.Price.Mul(decimal.NewFromInt(int64(ci.Quantity))))
-		items = append(items, model.OrderItem{
-			ProductID: ci.ProductID, Quantity: ci.Quantity, Price: product.Price,
-		})
 	}
-
-	order := &model.Order{UserID: userID, Status: "pending", TotalPrice: total, Items: items}
+	if len(items) == 0 {
+		return nil, ErrEmptyCart
+	}
+	d, err := s.newDraft(ctx, userID, pc, items)
+	if err != nil {
+		return nil, err
+	}
+	order := d.order
+	order.ShippingAddress, order.BillingAddress, err = s.resolveAddresses(ctx, userID, dto.CheckoutAddressRequest{
+		ShippingAddressID: req.ShippingAddressID, ShippingAddress: req.ShippingAddress,
+		BillingAddressID: req.BillingAddressID, BillingAddress: req.BillingAddress,
+	})
+	if err != nil {
+		return nil, err
+	}
+	method := req.ShippingMethod
+	if method == "" {
+		method = model.ShippingStandard
+	}
+	if err := s.total(ctx, d, cartWithItems.PromotionID, method); err != nil {
+		return nil, err
+	}
 	if err := s.orderRepo.Create(ctx, order); err != nil {
-		return nil, fmt.Errorf("create order: %w", err)
+		return nil, placeError(err)
+	}
+	s.queue(ctx, order)
+
+	_ = s.cartRepo.ClearCart(ctx, cart.ID)
+	if order.PromotionID != nil {
+		_ = s.cartRepo.SetPromotion(ctx, cart.ID, nil)
+	}
+	return order, nil
+}
+
+// draft is an order being priced, with what pricing it needs to know about
+// its items that the order does not keep.
+type draft struct {
+	order      *model.Order
+	pc         currency.Pricing
+	weight     int
+	taxClasses []string
+	lines      []promotion.Line
+}
+
+// newDraft starts a pending order of items at current prices.
+func (s *OrderService) newDraft(ctx context.Context, userID uuid.UUID, pc currency.Pricing, items []model.OrderItem) (*draft, error) {
+	d := &draft{pc: pc}
+	basePrices := make(map[uuid.UUID]decimal.Decimal)
+	for i, item := range items {
+		product, err := s.productRepo.GetByID(ctx, item.ProductID)
+		if err != nil || product == nil {
+			return nil, fmt.Errorf("product %s not found", item.ProductID)
+		}
+		if product.Status != model.ProductStatusActive {
+			return nil, fmt.Errorf("product %s: %w", item.ProductID, ErrProductUnavailable)
+		}
+		if err := checkQuantity(product, item.Quantity); err != nil {
+			return nil, fmt.Errorf("product %s: %w", item.ProductID, err)
+		}
+		items[i].Product = product.Snapshot()
+		basePrices[item.ProductID] = product.Price
+		d.weight += product.WeightGrams * item.Quantity
+		d.taxClasses = append(d.taxClasses, product.TaxClass)
+		d.lines = append(d.lines, promotion.Line{ProductID: item.ProductID, Category: product.Category, Quantity: item.Quantity})
+	}
+	prices, err := s.prices.Prices(ctx, pc, basePrices)
+	if err != nil {
+		return nil, err
+	}
+	var subtotal decimal.Decimal
+	for i := range items {
+		items[i].Price = prices[items[i].ProductID]
+		d.lines[i].UnitPrice = items[i].Price
+		subtotal = subtotal.Add(items[i].Price.Mul(decimal.NewFromInt(int64(items[i].Quantity))))
+	}
+	d.order = &model.Order{
+		UserID: userID, Status: model.OrderPending, Subtotal: subtotal, Currency: pc.Currency, ExchangeRate: pc.Rate, Items: items,
 	}
+	return d, nil
+}
 
-	msg, err := json.Marshal(model.OrderMessage{OrderID: order.ID, UserID: userID})
+// total applies the coupon and shipping method to the draft and taxes it.
+func (s *OrderService) total(ctx context.Context, d *draft, promotionID *uuid.UUID, method string) error {
+	order := d.order
+	freeShipping, err := s.applyCoupon(ctx, order, d.pc, promotionID, d.lines)
 	if err != nil {
-		return nil, fmt.Errorf("marshal order message: %w", err)
+		return err
+	}
+	if order.ShippingAddress != nil {
+		if method != "" {
+			if err := s.applyShipping(ctx, order, d.pc, method, d.weight, freeShipping); err != nil {
+				return err
+			}
+		}
+		if err := s.applyTax(ctx, order, d.pc, d.taxClasses); err != nil {
+			return err
+		}
+	}
+	order.TotalPrice = order.Subtotal.Sub(order.Discount).Add(order.ShippingCost)
+	if !order.PricesIncludeTax {
+		order.TotalPrice = order.TotalPrice.Add(order.Tax)
+	}
+	return nil
+}
+
+// placeError translates a failure to store a new order.
+func placeError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrPromotionUsedUp):
+		return &CouponError{Code: CouponUsedUp, Message: "coupon has been used up"}
+	case errors.Is(err, repository.ErrInsufficientStock):
+		return ErrInsufficientStock
 	}
+	return fmt.Errorf("create order: %w", err)
+}
+
+// publishRetryAfter is how long an order may go unqueued before
+// PublishPending queues it, so it does not race the request that placed it.
+const publishRetryAfter = time.Minute
+
+// publishBatch caps the orders one PublishPending call queues.
+const publishBatch = 100
+
+// queue queues a placed order for processing. A failure is only logged, as
+// PublishPending queues the order later.
+func (s *OrderService) queue(ctx context.Context, order *model.Order) {
+	if err := s.send(ctx, model.OrderMessage{OrderID: order.ID, UserID: order.UserID}); err != nil {
+		slog.ErrorContext(ctx, "queue order", "error", err, "order_id", order.ID)
+	}
+}
 
+func (s *OrderService) send(ctx context.Context, m model.OrderMessage) error {
+	msg, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("marshal order message: %w", err)
+	}
 	if s.amqpCh != nil {
 		if err := s.amqpCh.PublishWithContext(ctx, "", "orders", false, false, amqp.Publishing{
 			ContentType:  "application/json",
 			Body:         msg,
 			DeliveryMode: amqp.Persistent,
 		}); err != nil {
-			return nil, fmt.Errorf("publish order: %w", err)
+			return fmt.Errorf("publish order: %w", err)
 		}
 	}
+	if err := s.orderRepo.MarkPublished(ctx, m.OrderID); err != nil {
+		return err
+	}
+	return nil
+}
 
-	_ = s.cartRepo.ClearCart(ctx, cart.ID)
-	return order, nil
+// PublishPending queues pending orders that never reached the queue.
+func (s *OrderService) PublishPending(ctx context.Context) (int, error) {
+	msgs, err := s.orderRepo.ListUnpublished(ctx, time.Now().Add(-publishRetryAfter), publishBatch)
+	if err != nil {
+		return 0, fmt.Errorf("list unpublished orders: %w", err)
+	}
+	for i, m := range msgs {
+		if err := s.send(ctx, m); err != nil {
+			return i, err
+		}
+	}
+	return len(msgs), nil
+}
+
+// applyTax taxes each item at the rates where the order ships to.
+func (s *OrderService) applyTax(ctx context.Context, order *model.Order, pc currency.Pricing, classes []string) error {
+	amounts := make([]decimal.Decimal, len(order.Items))
+	for i, item := range order.Items {
+		amounts[i] = item.Price.Mul(decimal.NewFromInt(int64(item.Quantity)))
+	}
+	discounts := tax.SpreadDiscount(amounts, order.Discount)
+	lines := make([]tax.Line, len(order.Items))
+	for i := range lines {
+		lines[i] = tax.Line{Class: classes[i], Amount: amounts[i].Sub(discounts[i])}
+	}
+	to := tax.Address{Country: order.ShippingAddress.Country, Region: order.ShippingAddress.Region}
+	result, err := s.taxes.Calculate(ctx, to, lines)
+	if err != nil {
+		return fmt.Errorf("calculate tax: %w", err)
+	}
+	if len(result.Lines) != len(lines) {
+		return fmt.Errorf("calculate tax: got %d lines, want %d", len(result.Lines), len(lines))
+	}
+	order.Tax = decimal.Zero
+	for i, l := range result.Lines {
+		amount := pc.Round(l.Amount)
+		order.Items[i].TaxName, order.Items[i].TaxRate, order.Items[i].TaxAmount = l.Name, l.Rate, amount
+		order.Tax = order.Tax.Add(amount)
+	}
+	order.PricesIncludeTax = result.Inclusive
+	return nil
+}
+
+// resolveAddresses returns the shipping and billing addresses for req.
+func (s *OrderService) resolveAddresses(ctx context.Context, userID uuid.UUID, req dto.CheckoutAddressRequest) (shipTo, billTo *model.PostalAddress, err error) {
+	shipTo, err = s.address(ctx, userID, "shipping", req.ShippingAddressID, req.ShippingAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	billTo, err = s.address(ctx, userID, "billing", req.BillingAddressID, req.BillingAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if shipTo == nil || billTo == nil {
+		addresses, err := s.addressRepo.ListByUserID(ctx, userID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("list addresses: %w", err)
+		}
+		for _, a := range addresses {
+			if a.DefaultShipping && shipTo == nil {
+				shipTo = &a.PostalAddress
+			}
+			if a.DefaultBilling && billTo == nil {
+				billTo = &a.PostalAddress
+			}
+		}
+	}
+	if shipTo == nil {
+		return nil, nil, ErrShippingAddressRequired
+	}
+	if billTo == nil {
+		billTo = shipTo
+	}
+	return shipTo, billTo, nil
+}
+
+// address returns the address given by ID or inline, or nil if neither.
+func (s *OrderService) address(ctx context.Context, userID uuid.UUID, kind string, id *uuid.UUID, inline *dto.PostalAddress) (*model.PostalAddress, error) {
+	switch {
+	case id != nil && inline != nil:
+		return nil, fmt.Errorf("%w: give %s_address_id or %s_address, not both", ErrInvalidAddress, kind, kind)
+	case id != nil:
+		a, err := userAddress(ctx, s.addressRepo, userID, *id)
+		if err != nil {
+			return nil, err
+		}
+		return &a.PostalAddress, nil
+	case inline != nil:
+		a := toPostalAddress(*inline)
+		return &a, nil
+	}
+	return nil, nil
+}
+
+// applyCoupon snapshots the cart's coupon and discount onto the order.
+func (s *OrderService) applyCoupon(ctx context.Context, order *model.Order, pc currency.Pricing, promotionID *uuid.UUID, lines []promotion.Line) (bool, error) {
+	if promotionID == nil {
+		return false, nil
+	}
+	p, err := s.promotionRepo.GetByID(ctx, *promotionID)
+	if err != nil {
+		return false, fmt.Errorf("get promotion: %w", err)
+	}
+	if p == nil {
+		return false, nil
+	}
+	result, err := evaluateCoupon(ctx, s.promotionRepo, p, pc, lines, order.UserID)
+	if err != nil {
+		return false, err
+	}
+	order.PromotionID, order.PromotionCode, order.Discount = &p.ID, p.Code, result.Discount
+	return result.FreeShipping, nil
+}
+
+// applyShipping prices method, standard by default, onto the order.
+func (s *OrderService) applyShipping(ctx context.Context, order *model.Order, pc currency.Pricing, method string, weightGrams int, freeShipping bool) error {
+	if method == "" {
+		method = model.ShippingStandard
+	}
+	shipment := shipping.Shipment{
+		Country: order.ShippingAddress.Country, WeightGrams: weightGrams, Subtotal: pc.ToBase(order.Subtotal.Sub(order.Discount)),
+	}
+	quotes, err := quoteShipping(ctx, s.shipping, shipment, freeShipping)
+	if err != nil {
+		return err
+	}
+	q, ok := shipping.Find(quotes, method)
+	if !ok {
+		return fmt.Errorf("%w: %s to %s", ErrShippingMethodUnavailable, method, shipment.Country)
+	}
+	order.ShippingMethod, order.ShippingCost = q.Method, pc.Convert(q.Cost)
+	return nil
 }
 
 func (s *OrderService) GetByID(ctx context.Context, orderID, userID uuid.UUID) (*model.Order, error) {
+	order, err := s.order(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.UserID != userID {
+		return nil, ErrOrderAccessDenied
+	}
+	return order, nil
+}
+
+// order returns any user's order.
+func (s *OrderService) order(ctx context.Context, orderID uuid.UUID) (*model.Order, error) {
 	order, err := s.orderRepo.GetByID(ctx, orderID)
 	if err != nil {
 		return nil, fmt.Errorf("get order: %w", err)
@@ -89,12 +390,73 @@ func (s *OrderService) GetByID(ctx context.Context, orderID, userID uuid.UUID) (
 	if order == nil {
 		return nil, ErrOrderNotFound
 	}
-	if order.UserID != userID {
-		return nil, ErrOrderAccessDenied
-	}
+	s.baseCurrency(order)
 	return order, nil
 }
 
-func (s *OrderService) ListByUserID(ctx context.Context, userID uuid.UUID) ([]model.Order, error) {
-	return s.orderRepo.ListByUserID(ctx, userID)
+// ListByUserID pages through the user's orders that match q.
+func (s *OrderService) ListByUserID(ctx context.Context, userID uuid.UUID, q dto.OrderListQuery, params pagination.Params) ([]model.Order, dto.PageInfo, error) {
+	f, err := orderFilter(q)
+	if err != nil {
+		return nil, dto.PageInfo{}, err
+	}
+	orders, page, err := s.orderRepo.ListByUserID(ctx, userID, f, params)
+	if err != nil {
+		return nil, dto.PageInfo{}, fmt.Errorf("list orders: %w", err)
+	}
+	for i := range orders {
+		s.baseCurrency(&orders[i])
+	}
+	return orders, toPageInfo(page), nil
+}
+
+// orderFilter validates an order list query.
+func orderFilter(q dto.OrderListQuery) (repository.OrderFilter, error) {
+	f := repository.OrderFilter{Oldest: q.Sort == dto.OrderSortOldest, WithItems: q.Include == dto.OrderIncludeItems}
+	if q.Status != "" {
+		for _, status := range strings.Split(q.Status, ",") {
+			if !slices.Contains(orderStatuses, status) {
+				return f, fmt.Errorf("%w: unknown status %q", ErrInvalidOrderQuery, status)
+			}
+			f.Statuses = append(f.Statuses, status)
+		}
+	}
+	var err error
+	if f.From, err = orderQueryTime(q.From, "from", false); err != nil {
+		return f, err
+	}
+	if f.To, err = orderQueryTime(q.To, "to", true); err != nil {
+		return f, err
+	}
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return f, fmt.Errorf("%w: from must be before to", ErrInvalidOrderQuery)
+	}
+	return f, nil
+}
+
+// orderQueryTime parses a from or to bound. A date means its start, or the
+// start of the next day when end is set.
+func orderQueryTime(v, name string, end bool) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be a date or an RFC 3339 time", ErrInvalidOrderQuery, name)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// baseCurrency fills in the currency of an order placed before orders
+// recorded one, which was the base currency.
+func (s *OrderService) baseCurrency(order *model.Order) {
+	if order.Currency == "" {
+		order.Currency = s.prices.Base()
+	}
 }
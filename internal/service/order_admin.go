@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/flicky/go-ecommerce-api/internal/cache"
+	"github.com/flicky/go-ecommerce-api/internal/currency"
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+var (
+	// ErrOrderTransition wraps why an order cannot move to a status.
+	ErrOrderTransition = errors.New("order status change not allowed")
+	// ErrOrderStatusChanged means someone else changed the order's status
+	// first.
+	ErrOrderStatusChanged = errors.New("order status changed")
+	// ErrInvalidRefund wraps why an order cannot be refunded as asked.
+	ErrInvalidRefund = errors.New("invalid refund")
+)
+
+// orderTransitions lists the statuses staff can move an order to.
+var orderTransitions = map[string][]string{
+	model.OrderPending:   {model.OrderFailed, model.OrderCancelled},
+	model.OrderFailed:    {model.OrderCancelled},
+	model.OrderCompleted: {model.OrderShipped, model.OrderCancelled},
+	model.OrderShipped:   {model.OrderDelivered},
+}
+
+// refundableStatuses are the statuses in which an order can be refunded.
+// A pending order has to be cancelled first.
+var refundableStatuses = []string{
+	model.OrderFailed, model.OrderCompleted, model.OrderShipped, model.OrderDelivered, model.OrderCancelled,
+}
+
+// OrderDetail is an order as staff see it: with its customer and what staff
+// have done to it.
+type OrderDetail struct {
+	Order   *model.Order
+	Events  []model.OrderEvent
+	Refunds []model.OrderRefund
+}
+
+// AdminOrderService lets staff find and act on any order.
+type AdminOrderService struct {
+	repo   repository.OrderAdminRepository
+	orders *OrderService
+	cache  *cache.Cache
+	alerts *StockAlertService
+}
+
+func NewAdminOrderService(repo repository.OrderAdminRepository, orders *OrderService, productCache *cache.Cache, alerts *StockAlertService) *AdminOrderService {
+	return &AdminOrderService{repo: repo, orders: orders, cache: productCache, alerts: alerts}
+}
+
+// List pages through every order that matches q, with its customer.
+func (s *AdminOrderService) List(ctx context.Context, q dto.AdminOrderListQuery, params pagination.Params) ([]model.Order, dto.PageInfo, error) {
+	f, err := orderFilter(q.OrderListQuery)
+	if err != nil {
+		return nil, dto.PageInfo{}, err
+	}
+	if q.ID != "" {
+		id, err := uuid.Parse(q.ID)
+		if err != nil {
+			return nil, dto.PageInfo{}, fmt.Errorf("%w: id must be an order ID", ErrInvalidOrderQuery)
+		}
+		f.ID = &id
+	}
+	f.Email = q.Email
+	if f.MinTotal, err = orderQueryAmount(q.MinTotal, "min_total"); err != nil {
+		return nil, dto.PageInfo{}, err
+	}
+	if f.MaxTotal, err = orderQueryAmount(q.MaxTotal, "max_total"); err != nil {
+		return nil, dto.PageInfo{}, err
+	}
+	if f.MinTotal != nil && f.MaxTotal != nil && f.MinTotal.GreaterThan(*f.MaxTotal) {
+		return nil, dto.PageInfo{}, fmt.Errorf("%w: min_total must not be above max_total", ErrInvalidOrderQuery)
+	}
+
+	orders, page, err := s.repo.Search(ctx, f, params)
+	if err != nil {
+		return nil, dto.PageInfo{}, fmt.Errorf("search orders: %w", err)
+	}
+	for i := range orders {
+		s.orders.baseCurrency(&orders[i])
+	}
+	return orders, toPageInfo(page), nil
+}
+
+func orderQueryAmount(v, name string) (*decimal.Decimal, error) {
+	if v == "" {
+		return nil, nil
+	}
+	d, err := decimal.NewFromString(v)
+	if err != nil || d.IsNegative() {
+		return nil, fmt.Errorf("%w: %s must be an amount", ErrInvalidOrderQuery, name)
+	}
+	return &d, nil
+}
+
+// Get returns any order with its customer, events and refunds.
+func (s *AdminOrderService) Get(ctx context.Context, id uuid.UUID) (*OrderDetail, error) {
+	order, err := s.orders.order(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if order.Customer, err = s.repo.GetCustomer(ctx, order.UserID); err != nil {
+		return nil, err
+	}
+	d := &OrderDetail{Order: order}
+	if d.Events, err = s.repo.ListEvents(ctx, id); err != nil {
+		return nil, err
+	}
+	if d.Refunds, err = s.repo.ListRefunds(ctx, id); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ChangeStatus moves the order to status if orderTransitions allows it.
+func (s *AdminOrderService) ChangeStatus(ctx context.Context, actorID, id uuid.UUID, status, note string) (*OrderDetail, error) {
+	order, err := s.orders.order(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(orderTransitions[order.Status], status) {
+		return nil, fmt.Errorf("%w: %s order cannot become %s", ErrOrderTransition, order.Status, status)
+	}
+	ev := &model.OrderEvent{
+		OrderID: id, Kind: model.OrderEventStatus, FromStatus: order.Status, ToStatus: status, Note: note,
+		ActorID: actorRef(actorID),
+	}
+	movements, err := s.repo.ChangeStatus(ctx, ev)
+	if err != nil {
+		return nil, adminOrderError("change order status", err)
+	}
+	if len(movements) > 0 {
+		productIDs := make([]uuid.UUID, len(movements))
+		for i, m := range movements {
+			productIDs[i] = m.ProductID
+		}
+		s.cache.InvalidateProducts(ctx, productIDs...)
+		// The order is cancelled; a lost alert is not worth failing it for.
+		_ = s.alerts.Observe(ctx, movements)
+	}
+	return s.Get(ctx, id)
+}
+
+// Cancel cancels the order.
+func (s *AdminOrderService) Cancel(ctx context.Context, actorID, id uuid.UUID, note string) (*OrderDetail, error) {
+	return s.ChangeStatus(ctx, actorID, id, model.OrderCancelled, note)
+}
+
+// Refund refunds req.Amount of the order, or all that is left.
+func (s *AdminOrderService) Refund(ctx context.Context, actorID, id uuid.UUID, req dto.OrderRefundRequest) (*OrderDetail, error) {
+	order, err := s.orders.order(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(refundableStatuses, order.Status) {
+		return nil, fmt.Errorf("%w: %s order cannot be refunded", ErrInvalidRefund, order.Status)
+	}
+	left := order.TotalPrice.Sub(order.Refunded)
+	amount := left
+	if req.Amount != nil {
+		amount = currency.Round(*req.Amount, order.Currency)
+	}
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("%w: nothing to refund", ErrInvalidRefund)
+	}
+	if amount.GreaterThan(left) {
+		return nil, fmt.Errorf("%w: only %s %s is left to refund", ErrInvalidRefund, left.StringFixed(currency.Decimals(order.Currency)), order.Currency)
+	}
+
+	refund := &model.OrderRefund{
+		OrderID: id, Amount: amount, Currency: order.Currency, Reference: req.Reference, Note: req.Note,
+		ActorID: actorRef(actorID),
+	}
+	ev := &model.OrderEvent{
+		OrderID: id, Kind: model.OrderEventRefund, FromStatus: order.Status, Note: req.Note, ActorID: actorRef(actorID),
+	}
+	if err := s.repo.Refund(ctx, refund, ev); err != nil {
+		return nil, adminOrderError("refund order", err)
+	}
+	return s.Get(ctx, id)
+}
+
+// ResendConfirmation sends the order's customer its confirmation again.
+func (s *AdminOrderService) ResendConfirmation(ctx context.Context, actorID, id uuid.UUID, note string) (*OrderDetail, error) {
+	order, err := s.orders.order(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	n := &model.Notification{
+		UserID: order.UserID, Kind: model.NotificationOrderConfirmation, OrderID: &order.ID,
+		Message: confirmationMessage(order),
+	}
+	ev := &model.OrderEvent{OrderID: id, Kind: model.OrderEventResend, Note: note, ActorID: actorRef(actorID)}
+	if err := s.repo.Resend(ctx, n, ev); err != nil {
+		return nil, fmt.Errorf("resend order confirmation: %w", err)
+	}
+	return s.Get(ctx, id)
+}
+
+func confirmationMessage(o *model.Order) string {
+	items := 0
+	for _, item := range o.Items {
+		items += item.Quantity
+	}
+	return fmt.Sprintf("Order %s (%d items, %s %s) is %s.", o.ID, items,
+		o.TotalPrice.StringFixed(currency.Decimals(o.Currency)), o.Currency, o.Status)
+}
+
+func adminOrderError(action string, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrOrderNotFound
+	case errors.Is(err, repository.ErrOrderStatusChanged):
+		return ErrOrderStatusChanged
+	case errors.Is(err, repository.ErrRefundExceedsTotal):
+		return fmt.Errorf("%w: refund is more than is left to refund", ErrInvalidRefund)
+	}
+	return fmt.Errorf("%s: %w", action, err)
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+// mockOrderAdminRepo acts on the orders of a mockOrderRepo. Cancelling a
+// completed order returns each item that has a warehouse.
+type mockOrderAdminRepo struct {
+	orders        *mockOrderRepo
+	customers     map[uuid.UUID]*model.OrderCustomer
+	events        []model.OrderEvent
+	refunds       []model.OrderRefund
+	notifications []model.Notification
+}
+
+func newMockOrderAdminRepo(orders *mockOrderRepo) *mockOrderAdminRepo {
+	return &mockOrderAdminRepo{orders: orders, customers: make(map[uuid.UUID]*model.OrderCustomer)}
+}
+
+func (m *mockOrderAdminRepo) Search(_ context.Context, f repository.OrderFilter, p pagination.Params) ([]model.Order, pagination.Page, error) {
+	var orders []model.Order
+	for _, o := range m.orders.orders {
+		c := m.customers[o.UserID]
+		if f.ID != nil && o.ID != *f.ID || len(f.Statuses) > 0 && !slices.Contains(f.Statuses, o.Status) ||
+			f.MinTotal != nil && o.TotalPrice.LessThan(*f.MinTotal) ||
+			f.MaxTotal != nil && o.TotalPrice.GreaterThan(*f.MaxTotal) ||
+			f.Email != "" && !strings.Contains(strings.ToLower(c.Email), strings.ToLower(f.Email)) {
+			continue
+		}
+		order := *o
+		order.Customer = c
+		orders = append(orders, order)
+	}
+	sortRows(orders, orderKey, f.Oldest)
+	items, page := pagination.Build(afterCursorSorted(orders, p, orderKey, f.Oldest), p, orderKey)
+	return items, page, nil
+}
+
+func (m *mockOrderAdminRepo) GetCustomer(_ context.Context, userID uuid.UUID) (*model.OrderCustomer, error) {
+	return m.customers[userID], nil
+}
+
+func (m *mockOrderAdminRepo) ListEvents(_ context.Context, orderID uuid.UUID) ([]model.OrderEvent, error) {
+	var events []model.OrderEvent
+	for _, ev := range m.events {
+		if ev.OrderID == orderID {
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
+
+func (m *mockOrderAdminRepo) ListRefunds(_ context.Context, orderID uuid.UUID) ([]model.OrderRefund, error) {
+	var refunds []model.OrderRefund
+	for _, rf := range m.refunds {
+		if rf.OrderID == orderID {
+			refunds = append(refunds, rf)
+		}
+	}
+	return refunds, nil
+}
+
+func (m *mockOrderAdminRepo) ChangeStatus(_ context.Context, ev *model.OrderEvent) ([]model.InventoryMovement, error) {
+	o, ok := m.orders.orders[ev.OrderID]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	if o.Status != ev.FromStatus {
+		return nil, repository.ErrOrderStatusChanged
+	}
+	var movements []model.InventoryMovement
+	if o.Status == model.OrderCompleted && ev.ToStatus == model.OrderCancelled {
+		for _, item := range o.Items {
+			if item.WarehouseID != nil {
+				movements = append(movements, model.InventoryMovement{
+					ProductID: item.ProductID, Kind: model.MovementReturn, Quantity: item.Quantity,
+					WarehouseID: item.WarehouseID, OrderID: &o.ID,
+				})
+			}
+		}
+	}
+	o.Status = ev.ToStatus
+	m.record(ev)
+	return movements, nil
+}
+
+func (m *mockOrderAdminRepo) Refund(_ context.Context, refund *model.OrderRefund, ev *model.OrderEvent) error {
+	o, ok := m.orders.orders[refund.OrderID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if o.Status != ev.FromStatus {
+		return repository.ErrOrderStatusChanged
+	}
+	if o.Refunded.Add(refund.Amount).GreaterThan(o.TotalPrice) {
+		return repository.ErrRefundExceedsTotal
+	}
+	refund.ID, refund.CreatedAt = uuid.New(), time.Now()
+	m.refunds = append(m.refunds, *refund)
+	o.Refunded = o.Refunded.Add(refund.Amount)
+	ev.FromStatus, ev.ToStatus = "", ""
+	if o.Refunded.Equal(o.TotalPrice) {
+		ev.FromStatus, ev.ToStatus = o.Status, model.OrderRefunded
+		o.Status = model.OrderRefunded
+	}
+	m.record(ev)
+	return nil
+}
+
+func (m *mockOrderAdminRepo) Resend(_ context.Context, n *model.Notification, ev *model.OrderEvent) error {
+	n.ID, n.CreatedAt = uuid.New(), time.Now()
+	m.notifications = append(m.notifications, *n)
+	m.record(ev)
+	return nil
+}
+
+func (m *mockOrderAdminRepo) record(ev *model.OrderEvent) {
+	ev.ID, ev.CreatedAt = uuid.New(), time.Now()
+	m.events = append(m.events, *ev)
+}
+
+type adminOrderFixture struct {
+	svc    *AdminOrderService
+	repo   *mockOrderAdminRepo
+	orders *mockOrderRepo
+}
+
+func newAdminOrderFixture() *adminOrderFixture {
+	orders := newMockOrderRepo()
+	repo := newMockOrderAdminRepo(orders)
+	orderSvc := NewOrderService(orders, nil, nil, newMockPromotionRepo(), newMockAddressRepo(), newFlatShipping(), newTaxTable(false), newTestPrices(), nil)
+	return &adminOrderFixture{svc: NewAdminOrderService(repo, orderSvc, nil, nil), repo: repo, orders: orders}
+}
+
+// add stores an order for a customer with email, totalling total.
+func (f *adminOrderFixture) add(email, status, total string, age time.Duration) *model.Order {
+	userID := uuid.New()
+	for id, c := range f.repo.customers {
+		if c.Email == email {
+			userID = id
+		}
+	}
+	f.repo.customers[userID] = &model.OrderCustomer{ID: userID, Email: email, FirstName: "Ada", LastName: "Lovelace"}
+	o := &model.Order{
+		ID: uuid.New(), UserID: userID, Status: status, TotalPrice: decimal.RequireFromString(total),
+		Currency: "USD", CreatedAt: time.Now().Add(-age),
+		Items: []model.OrderItem{{ProductID: uuid.New(), Quantity: 2}},
+	}
+	f.orders.orders[o.ID] = o
+	return o
+}
+
+func TestAdminOrderService_List(t *testing.T) {
+	f := newAdminOrderFixture()
+	ada := f.add("ada@example.com", model.OrderCompleted, "120.00", time.Hour)
+	f.add("ada@example.com", model.OrderPending, "15.00", 2*time.Hour)
+	grace := f.add("grace@example.org", model.OrderCompleted, "60.00", 3*time.Hour)
+	ctx := context.Background()
+	list := func(q dto.AdminOrderListQuery) []model.Order {
+		t.Helper()
+		orders, _, err := f.svc.List(ctx, q, pagination.Params{Limit: 10})
+		require.NoError(t, err)
+		return orders
+	}
+
+	all := list(dto.AdminOrderListQuery{})
+	require.Len(t, all, 3)
+	assert.Equal(t, ada.ID, all[0].ID)
+	require.NotNil(t, all[0].Customer)
+	assert.Equal(t, "ada@example.com", all[0].Customer.Email)
+
+	assert.Len(t, list(dto.AdminOrderListQuery{Email: "ADA@"}), 2)
+	byID := list(dto.AdminOrderListQuery{ID: grace.ID.String()})
+	require.Len(t, byID, 1)
+	assert.Equal(t, grace.ID, byID[0].ID)
+	ranged := list(dto.AdminOrderListQuery{
+		OrderListQuery: dto.OrderListQuery{Status: model.OrderCompleted}, MinTotal: "50", MaxTotal: "100",
+	})
+	require.Len(t, ranged, 1)
+	assert.Equal(t, grace.ID, ranged[0].ID)
+
+	for _, q := range []dto.AdminOrderListQuery{
+		{ID: "42"}, {MinTotal: "lots"}, {MaxTotal: "-1"}, {MinTotal: "100", MaxTotal: "50"},
+		{OrderListQuery: dto.OrderListQuery{Status: "lost"}},
+	} {
+		_, _, err := f.svc.List(ctx, q, pagination.Params{Limit: 10})
+		assert.ErrorIs(t, err, ErrInvalidOrderQuery, q)
+	}
+}
+
+func TestAdminOrderService_ChangeStatus(t *testing.T) {
+	f := newAdminOrderFixture()
+	order := f.add("ada@example.com", model.OrderCompleted, "40.00", time.Hour)
+	ctx, staff := context.Background(), uuid.New()
+
+	d, err := f.svc.ChangeStatus(ctx, staff, order.ID, model.OrderShipped, "DHL 123")
+	require.NoError(t, err)
+	assert.Equal(t, model.OrderShipped, d.Order.Status)
+	assert.Equal(t, "ada@example.com", d.Order.Customer.Email)
+	require.Len(t, d.Events, 1)
+	ev := d.Events[0]
+	assert.Equal(t, model.OrderEventStatus, ev.Kind)
+	assert.Equal(t, model.OrderCompleted, ev.FromStatus)
+	assert.Equal(t, model.OrderShipped, ev.ToStatus)
+	assert.Equal(t, "DHL 123", ev.Note)
+	assert.Equal(t, &staff, ev.ActorID)
+
+	// Shipped orders cannot be cancelled or go back.
+	_, err = f.svc.Cancel(ctx, staff, order.ID, "")
+	assert.ErrorIs(t, err, ErrOrderTransition)
+	_, err = f.svc.ChangeStatus(ctx, staff, order.ID, model.OrderCompleted, "")
+	assert.ErrorIs(t, err, ErrOrderTransition)
+	_, err = f.svc.ChangeStatus(ctx, staff, order.ID, model.OrderDelivered, "")
+	require.NoError(t, err)
+
+	_, err = f.svc.ChangeStatus(ctx, staff, uuid.New(), model.OrderShipped, "")
+	assert.ErrorIs(t, err, ErrOrderNotFound)
+}
+
+func TestAdminOrderService_Cancel(t *testing.T) {
+	f := newAdminOrderFixture()
+	ctx, staff := context.Background(), uuid.New()
+	for _, status := range []string{model.OrderPending, model.OrderFailed, model.OrderCompleted} {
+		order := f.add("ada@example.com", status, "40.00", time.Hour)
+		warehouseID := uuid.New()
+		order.Items[0].WarehouseID = &warehouseID
+
+		d, err := f.svc.Cancel(ctx, staff, order.ID, "customer called")
+		require.NoError(t, err, status)
+		assert.Equal(t, model.OrderCancelled, d.Order.Status)
+		require.Len(t, d.Events, 1)
+		assert.Equal(t, status, d.Events[0].FromStatus)
+		assert.Equal(t, "customer called", d.Events[0].Note)
+	}
+
+	// The status moved on after the order was read.
+	order := f.add("ada@example.com", model.OrderPending, "40.00", time.Hour)
+	f.repo.orders = &mockOrderRepo{orders: map[uuid.UUID]*model.Order{order.ID: {ID: order.ID, Status: model.OrderCompleted}}}
+	_, err := f.svc.Cancel(ctx, staff, order.ID, "")
+	assert.ErrorIs(t, err, ErrOrderStatusChanged)
+}
+
+func TestAdminOrderService_Refund(t *testing.T) {
+	f := newAdminOrderFixture()
+	order := f.add("ada@example.com", model.OrderDelivered, "40.00", time.Hour)
+	ctx, staff := context.Background(), uuid.New()
+	amount := decimal.RequireFromString("15.004")
+
+	d, err := f.svc.Refund(ctx, staff, order.ID, dto.OrderRefundRequest{Amount: &amount, Reference: "re_1", Note: "damaged"})
+	require.NoError(t, err)
+	assert.Equal(t, model.OrderDelivered, d.Order.Status)
+	assert.Equal(t, "15.00", d.Order.Refunded.StringFixed(2))
+	require.Len(t, d.Refunds, 1)
+	assert.Equal(t, "re_1", d.Refunds[0].Reference)
+	assert.Equal(t, "USD", d.Refunds[0].Currency)
+	require.Len(t, d.Events, 1)
+	assert.Equal(t, model.OrderEventRefund, d.Events[0].Kind)
+	assert.Empty(t, d.Events[0].ToStatus)
+
+	tooMuch := decimal.NewFromInt(26)
+	_, err = f.svc.Refund(ctx, staff, order.ID, dto.OrderRefundRequest{Amount: &tooMuch})
+	assert.ErrorIs(t, err, ErrInvalidRefund)
+
+	// Without an amount the rest is refunded, which refunds the order.
+	d, err = f.svc.Refund(ctx, staff, order.ID, dto.OrderRefundRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, model.OrderRefunded, d.Order.Status)
+	assert.Equal(t, "25.00", d.Refunds[1].Amount.StringFixed(2))
+	assert.Equal(t, model.OrderRefunded, d.Events[1].ToStatus)
+
+	_, err = f.svc.Refund(ctx, staff, order.ID, dto.OrderRefundRequest{})
+	assert.ErrorIs(t, err, ErrInvalidRefund)
+
+	pending := f.add("grace@example.org", model.OrderPending, "10.00", time.Hour)
+	_, err = f.svc.Refund(ctx, staff, pending.ID, dto.OrderRefundRequest{})
+	assert.ErrorIs(t, err, ErrInvalidRefund, "pending orders are cancelled first")
+}
+
+func TestAdminOrderService_ResendConfirmation(t *testing.T) {
+	f := newAdminOrderFixture()
+	order := f.add("ada@example.com", model.OrderCompleted, "40.00", time.Hour)
+
+	d, err := f.svc.ResendConfirmation(context.Background(), uuid.New(), order.ID, "lost the first one")
+	require.NoError(t, err)
+	require.Len(t, f.repo.notifications, 1)
+	n := f.repo.notifications[0]
+	assert.Equal(t, order.UserID, n.UserID)
+	assert.Equal(t, model.NotificationOrderConfirmation, n.Kind)
+	assert.Equal(t, &order.ID, n.OrderID)
+	assert.Contains(t, n.Message, "2 items, 40.00 USD")
+	require.Len(t, d.Events, 1)
+	assert.Equal(t, model.OrderEventResend, d.Events[0].Kind)
+	assert.Equal(t, "lost the first one", d.Events[0].Note)
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"testing"
 	"time"
 
@@ -10,26 +12,39 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/flicky/go-ecommerce-api/internal/dto"
 	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
 )
 
+// mockOrderRepo redeems coupons against promotions when it is given them.
+// Orders are marked published unless publishErr is set.
 type mockOrderRepo struct {
-	orders map[uuid.UUID]*model.Order
+	orders     map[uuid.UUID]*model.Order
+	promotions *mockPromotionRepo
+	published  map[uuid.UUID]bool
+	publishErr error
 }
 
 func newMockOrderRepo() *mockOrderRepo {
-	return &mockOrderRepo{orders: make(map[uuid.UUID]*model.Order)}
+	return &mockOrderRepo{orders: make(map[uuid.UUID]*model.Order), published: make(map[uuid.UUID]bool)}
 }
 
 func (m *mockOrderRepo) Create(_ context.Context, order *model.Order) error {
 	order.ID = uuid.New()
+	if order.PromotionID != nil && m.promotions != nil {
+		if err := m.promotions.redeem(order); err != nil {
+			return err
+		}
+	}
 	order.CreatedAt = time.Now()
 	m.orders[order.ID] = order
 	return nil
 }
 
-func (m *mockOrderRepo) ProcessOrder(_ context.Context, _ uuid.UUID, _ []model.OrderItem) error {
-	return nil
+func (m *mockOrderRepo) ProcessOrder(_ context.Context, _ uuid.UUID) ([]model.InventoryMovement, error) {
+	return nil, nil
 }
 
 func (m *mockOrderRepo) UpdateStatus(_ context.Context, id uuid.UUID, status string) error {
@@ -43,20 +58,151 @@ func (m *mockOrderRepo) GetByID(_ context.Context, id uuid.UUID) (*model.Order,
 	return m.orders[id], nil
 }
 
-func (m *mockOrderRepo) ListByUserID(_ context.Context, userID uuid.UUID) ([]model.Order, error) {
+func (m *mockOrderRepo) ListByUserID(_ context.Context, userID uuid.UUID, f repository.OrderFilter, params pagination.Params) ([]model.Order, pagination.Page, error) {
 	var orders []model.Order
 	for _, o := range m.orders {
-		if o.UserID == userID {
-			orders = append(orders, *o)
+		if o.UserID != userID || len(f.Statuses) > 0 && !slices.Contains(f.Statuses, o.Status) ||
+			f.From != nil && o.CreatedAt.Before(*f.From) || f.To != nil && !o.CreatedAt.Before(*f.To) {
+			continue
+		}
+		order := *o
+		if !f.WithItems {
+			order.Items = nil
+		}
+		orders = append(orders, order)
+	}
+	sortRows(orders, orderKey, f.Oldest)
+	items, page := pagination.Build(afterCursorSorted(orders, params, orderKey, f.Oldest), params, orderKey)
+	return items, page, nil
+}
+
+func (m *mockOrderRepo) MarkPublished(_ context.Context, id uuid.UUID) error {
+	if m.publishErr != nil {
+		return m.publishErr
+	}
+	m.published[id] = true
+	return nil
+}
+
+func (m *mockOrderRepo) ListUnpublished(_ context.Context, before time.Time, limit int) ([]model.OrderMessage, error) {
+	var msgs []model.OrderMessage
+	for _, o := range m.orders {
+		if !m.published[o.ID] && o.Status == model.OrderPending && o.CreatedAt.Before(before) && len(msgs) < limit {
+			msgs = append(msgs, model.OrderMessage{OrderID: o.ID, UserID: o.UserID})
 		}
 	}
-	return orders, nil
+	return msgs, nil
 }
 
+func orderKey(o model.Order) (time.Time, uuid.UUID) { return o.CreatedAt, o.ID }
+
 func TestOrderService_CreateOrder_EmptyCart(t *testing.T) {
-	svc := NewOrderService(newMockOrderRepo(), newMockCartRepo(), newMockProductRepo(), nil)
-	_, err := svc.CreateOrder(context.Background(), uuid.New())
+	svc := NewOrderService(newMockOrderRepo(), newMockCartRepo(), newMockProductRepo(), newMockPromotionRepo(), newMockAddressRepo(), newFlatShipping(), newTaxTable(false), newTestPrices(), nil)
+	_, err := svc.CreateOrder(context.Background(), uuid.New(), dto.CreateOrderRequest{})
+	assert.ErrorIs(t, err, ErrEmptyCart)
+}
+
+func TestOrderService_CreateOrder_RechecksQuantity(t *testing.T) {
+	cartRepo, productRepo, orderRepo := newMockCartRepo(), newMockProductRepo(), newMockOrderRepo()
+	pid := newActiveProduct(productRepo, 5)
+	userID := uuid.New()
+	_, err := NewCartService(cartRepo, productRepo, newMockPromotionRepo(), nil, nil, newTestPrices(), time.Hour, "").AddItem(context.Background(), CartRef{UserID: userID}, pid, 4)
+	require.NoError(t, err)
+
+	// The limit was lowered after the item went into the cart.
+	productRepo.products[pid].MaxPerOrder = ptr(3)
+	_, err = NewOrderService(orderRepo, cartRepo, productRepo, newMockPromotionRepo(), newMockAddressRepo(), newFlatShipping(), newTaxTable(false), newTestPrices(), nil).CreateOrder(context.Background(), userID, dto.CreateOrderRequest{})
+	assert.ErrorIs(t, err, ErrMaxPerOrderExceeded)
+	assert.Empty(t, orderRepo.orders)
+	assert.Len(t, cartRepo.items, 1)
+}
+
+func TestOrderService_CreateOrder_SkipsSavedForLater(t *testing.T) {
+	cartRepo, productRepo, orderRepo := newMockCartRepo(), newMockProductRepo(), newMockOrderRepo()
+	cartRepo.products = productRepo
+	ordered, saved := newActiveProduct(productRepo, 5), newActiveProduct(productRepo, 5)
+	user := CartRef{UserID: uuid.New()}
+	ctx := context.Background()
+	carts := NewCartService(cartRepo, productRepo, newMockPromotionRepo(), nil, nil, newTestPrices(), time.Hour, "")
+	orders := NewOrderService(orderRepo, cartRepo, productRepo, newMockPromotionRepo(), newMockAddressRepo(), newFlatShipping(), newTaxTable(false), newTestPrices(), nil)
+
+	_, err := carts.AddItem(ctx, user, saved, 1)
+	require.NoError(t, err)
+	var savedItem uuid.UUID
+	for id := range cartRepo.items {
+		savedItem = id
+	}
+	_, err = carts.SaveForLater(ctx, user, savedItem)
+	require.NoError(t, err)
+
+	// A cart holding only saved items is empty.
+	_, err = orders.CreateOrder(ctx, user.UserID, testOrderRequest())
 	assert.ErrorIs(t, err, ErrEmptyCart)
+
+	_, err = carts.AddItem(ctx, user, ordered, 2)
+	require.NoError(t, err)
+	order, err := orders.CreateOrder(ctx, user.UserID, testOrderRequest())
+	require.NoError(t, err)
+	require.Len(t, order.Items, 1)
+	assert.Equal(t, ordered, order.Items[0].ProductID)
+
+	// The saved item outlives the order.
+	require.Len(t, cartRepo.items, 1)
+	assert.True(t, cartRepo.items[savedItem].SavedForLater)
+}
+
+func TestOrderService_CreateOrder_SnapshotsProducts(t *testing.T) {
+	cartRepo, productRepo, orderRepo := newMockCartRepo(), newMockProductRepo(), newMockOrderRepo()
+	pid := newActiveProduct(productRepo, 5)
+	p := productRepo.products[pid]
+	p.Name, p.SKU, p.Category, p.WeightGrams, p.TaxClass = "Mug", "MUG-1", "kitchen", 350, "reduced"
+	userID := uuid.New()
+	ctx := context.Background()
+	_, err := NewCartService(cartRepo, productRepo, newMockPromotionRepo(), nil, nil, newTestPrices(), time.Hour, "").AddItem(ctx, CartRef{UserID: userID}, pid, 1)
+	require.NoError(t, err)
+
+	order, err := NewOrderService(orderRepo, cartRepo, productRepo, newMockPromotionRepo(), newMockAddressRepo(), newFlatShipping(), newTaxTable(false), newTestPrices(), nil).CreateOrder(ctx, userID, testOrderRequest())
+	require.NoError(t, err)
+	require.Len(t, order.Items, 1)
+
+	// Renaming the product later leaves the order line as it was.
+	p.Name, p.Category = "Big mug", "gifts"
+	assert.Equal(t, model.ProductSnapshot{
+		Name: "Mug", SKU: "MUG-1", TaxClass: "reduced",
+		Attributes: model.ProductAttributes{Category: "kitchen", WeightGrams: 350},
+	}, order.Items[0].Product)
+}
+
+func TestOrderService_CreateOrder_QueuesLater(t *testing.T) {
+	cartRepo, productRepo, orderRepo := newMockCartRepo(), newMockProductRepo(), newMockOrderRepo()
+	pid := newActiveProduct(productRepo, 5)
+	userID := uuid.New()
+	ctx := context.Background()
+	_, err := NewCartService(cartRepo, productRepo, newMockPromotionRepo(), nil, nil, newTestPrices(), time.Hour, "").AddItem(ctx, CartRef{UserID: userID}, pid, 1)
+	require.NoError(t, err)
+	svc := NewOrderService(orderRepo, cartRepo, productRepo, newMockPromotionRepo(), newMockAddressRepo(), newFlatShipping(), newTaxTable(false), newTestPrices(), nil)
+
+	// The order is placed even though it could not be queued.
+	orderRepo.publishErr = errors.New("queue unreachable")
+	order, err := svc.CreateOrder(ctx, userID, testOrderRequest())
+	require.NoError(t, err)
+	assert.False(t, orderRepo.published[order.ID])
+
+	// It is left to the request that placed it for a while, then queued.
+	orderRepo.publishErr = nil
+	n, err := svc.PublishPending(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	order.CreatedAt = time.Now().Add(-2 * publishRetryAfter)
+	n, err = svc.PublishPending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.True(t, orderRepo.published[order.ID])
+
+	n, err = svc.PublishPending(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
 }
 
 func TestOrderService_GetByID(t *testing.T) {
@@ -67,14 +213,118 @@ func TestOrderService_GetByID(t *testing.T) {
 		ID: orderID, UserID: userID, Status: "completed",
 		TotalPrice: decimal.NewFromFloat(99.99), CreatedAt: time.Now(),
 	}
-	svc := NewOrderService(repo, nil, nil, nil)
+	svc := NewOrderService(repo, nil, nil, newMockPromotionRepo(), newMockAddressRepo(), newFlatShipping(), newTaxTable(false), newTestPrices(), nil)
 	order, err := svc.GetByID(context.Background(), orderID, userID)
 	require.NoError(t, err)
 	assert.Equal(t, orderID, order.ID)
 }
 
 func TestOrderService_GetByID_NotFound(t *testing.T) {
-	svc := NewOrderService(newMockOrderRepo(), nil, nil, nil)
+	svc := NewOrderService(newMockOrderRepo(), nil, nil, newMockPromotionRepo(), newMockAddressRepo(), newFlatShipping(), newTaxTable(false), newTestPrices(), nil)
 	_, err := svc.GetByID(context.Background(), uuid.New(), uuid.New())
 	assert.ErrorIs(t, err, ErrOrderNotFound)
 }
+
+func TestOrderService_ListByUserID_Paginates(t *testing.T) {
+	repo := newMockOrderRepo()
+	userID := uuid.New()
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		id := uuid.New()
+		repo.orders[id] = &model.Order{ID: id, UserID: userID, CreatedAt: now.Add(-time.Duration(i) * time.Minute)}
+	}
+	svc := NewOrderService(repo, nil, nil, newMockPromotionRepo(), newMockAddressRepo(), newFlatShipping(), newTaxTable(false), newTestPrices(), nil)
+
+	first, page, err := svc.ListByUserID(context.Background(), userID, dto.OrderListQuery{}, pagination.Params{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, first, 2)
+	require.NotEmpty(t, page.NextCursor)
+	assert.Empty(t, page.PrevCursor)
+
+	cursor, err := pagination.Decode(page.NextCursor)
+	require.NoError(t, err)
+	second, page, err := svc.ListByUserID(context.Background(), userID, dto.OrderListQuery{}, pagination.Params{Limit: 2, Cursor: cursor})
+	require.NoError(t, err)
+	require.Len(t, second, 2)
+	assert.True(t, second[0].CreatedAt.Before(first[1].CreatedAt))
+	assert.NotEmpty(t, page.PrevCursor)
+
+	cursor, err = pagination.Decode(page.PrevCursor)
+	require.NoError(t, err)
+	back, _, err := svc.ListByUserID(context.Background(), userID, dto.OrderListQuery{}, pagination.Params{Limit: 2, Cursor: cursor})
+	require.NoError(t, err)
+	assert.Equal(t, first[0].ID, back[0].ID)
+	assert.Equal(t, first[1].ID, back[1].ID)
+}
+
+func TestOrderService_ListByUserID_Filters(t *testing.T) {
+	repo := newMockOrderRepo()
+	userID := uuid.New()
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	statuses := []string{model.OrderCompleted, model.OrderPending, model.OrderCompleted, model.OrderFailed}
+	for i, status := range statuses {
+		id := uuid.New()
+		repo.orders[id] = &model.Order{
+			ID: id, UserID: userID, Status: status, CreatedAt: day.AddDate(0, 0, -i),
+			Items: []model.OrderItem{{ProductID: uuid.New(), Quantity: 1}},
+		}
+	}
+	svc := NewOrderService(repo, nil, nil, newMockPromotionRepo(), newMockAddressRepo(), newFlatShipping(), newTaxTable(false), newTestPrices(), nil)
+	list := func(q dto.OrderListQuery) []model.Order {
+		t.Helper()
+		orders, _, err := svc.ListByUserID(context.Background(), userID, q, pagination.Params{Limit: 10})
+		require.NoError(t, err)
+		return orders
+	}
+
+	completed := list(dto.OrderListQuery{Status: "completed"})
+	require.Len(t, completed, 2)
+	assert.True(t, completed[0].CreatedAt.After(completed[1].CreatedAt))
+	assert.Empty(t, completed[0].Items)
+	assert.Len(t, list(dto.OrderListQuery{Status: "pending,failed"}), 2)
+
+	// A date in to takes in that whole day.
+	ranged := list(dto.OrderListQuery{From: "2026-03-08", To: "2026-03-09", Sort: dto.OrderSortOldest, Include: dto.OrderIncludeItems})
+	require.Len(t, ranged, 2)
+	assert.Equal(t, model.OrderCompleted, ranged[0].Status)
+	assert.Equal(t, model.OrderPending, ranged[1].Status)
+	assert.Len(t, ranged[0].Items, 1)
+	assert.Len(t, list(dto.OrderListQuery{From: "2026-03-09T12:00:00Z"}), 2)
+
+	for _, q := range []dto.OrderListQuery{
+		{Status: "lost"}, {From: "yesterday"}, {From: "2026-03-10", To: "2026-03-01"},
+	} {
+		_, _, err := svc.ListByUserID(context.Background(), userID, q, pagination.Params{Limit: 10})
+		assert.ErrorIs(t, err, ErrInvalidOrderQuery, q)
+	}
+}
+
+func TestOrderService_ListByUserID_OldestFirstPaginates(t *testing.T) {
+	repo := newMockOrderRepo()
+	userID := uuid.New()
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		id := uuid.New()
+		repo.orders[id] = &model.Order{ID: id, UserID: userID, CreatedAt: now.Add(-time.Duration(i) * time.Minute)}
+	}
+	svc := NewOrderService(repo, nil, nil, newMockPromotionRepo(), newMockAddressRepo(), newFlatShipping(), newTaxTable(false), newTestPrices(), nil)
+	q := dto.OrderListQuery{Sort: dto.OrderSortOldest}
+
+	first, page, err := svc.ListByUserID(context.Background(), userID, q, pagination.Params{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, first, 2)
+	assert.True(t, first[0].CreatedAt.Before(first[1].CreatedAt))
+
+	cursor, err := pagination.Decode(page.NextCursor)
+	require.NoError(t, err)
+	second, page, err := svc.ListByUserID(context.Background(), userID, q, pagination.Params{Limit: 2, Cursor: cursor})
+	require.NoError(t, err)
+	require.Len(t, second, 2)
+	assert.True(t, second[0].CreatedAt.After(first[1].CreatedAt))
+
+	cursor, err = pagination.Decode(page.PrevCursor)
+	require.NoError(t, err)
+	back, _, err := svc.ListByUserID(context.Background(), userID, q, pagination.Params{Limit: 2, Cursor: cursor})
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{first[0].ID, first[1].ID}, []uuid.UUID{back[0].ID, back[1].ID})
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+)
+
+// newer reports whether row a sorts before row b in (created_at DESC, id DESC) order.
+func newer(at time.Time, aid uuid.UUID, bt time.Time, bid uuid.UUID) bool {
+	if !at.Equal(bt) {
+		return at.After(bt)
+	}
+	return bytes.Compare(aid[:], bid[:]) > 0
+}
+
+func sortNewestFirst[T any](rows []T, key func(T) (time.Time, uuid.UUID)) {
+	sortRows(rows, key, false)
+}
+
+// sortRows sorts rows newest first, or oldest first with oldest.
+func sortRows[T any](rows []T, key func(T) (time.Time, uuid.UUID), oldest bool) {
+	sort.Slice(rows, func(i, j int) bool {
+		it, iid := key(rows[i])
+		jt, jid := key(rows[j])
+		return before(oldest, it, iid, jt, jid)
+	})
+}
+
+// before reports whether row a sorts before row b, newest first or, with
+// oldest, oldest first.
+func before(oldest bool, at time.Time, aid uuid.UUID, bt time.Time, bid uuid.UUID) bool {
+	if oldest {
+		return newer(bt, bid, at, aid)
+	}
+	return newer(at, aid, bt, bid)
+}
+
+// afterCursor mimics the keyset query of the Postgres repositories on rows
+// already sorted newest first, returning up to Limit+1 rows in query order.
+func afterCursor[T any](rows []T, p pagination.Params, key func(T) (time.Time, uuid.UUID)) []T {
+	return afterCursorSorted(rows, p, key, false)
+}
+
+// afterCursorSorted is afterCursor for rows sorted as sortRows sorts them.
+func afterCursorSorted[T any](rows []T, p pagination.Params, key func(T) (time.Time, uuid.UUID), oldest bool) []T {
+	var out []T
+	if p.Backward() {
+		for i := len(rows) - 1; i >= 0; i-- {
+			t, id := key(rows[i])
+			if before(oldest, t, id, p.Cursor.CreatedAt, p.Cursor.ID) {
+				out = append(out, rows[i])
+			}
+		}
+	} else {
+		for _, r := range rows {
+			t, id := key(r)
+			if p.Cursor == nil || before(oldest, p.Cursor.CreatedAt, p.Cursor.ID, t, id) {
+				out = append(out, r)
+			}
+		}
+	}
+	if len(out) > p.Limit+1 {
+		out = out[:p.Limit+1]
+	}
+	return out
+}
@@ -2,84 +2,185 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"time"
+	"slices"
+	"strconv"
 
 	"github.com/google/uuid"
-	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
 
+	"github.com/flicky/go-ecommerce-api/internal/cache"
+	"github.com/flicky/go-ecommerce-api/internal/currency"
 	"github.com/flicky/go-ecommerce-api/internal/dto"
 	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
 	"github.com/flicky/go-ecommerce-api/internal/repository"
 )
 
-var ErrProductNotFound = errors.New("product not found")
+var (
+	ErrProductNotFound  = errors.New("product not found")
+	ErrSKUAlreadyExists = errors.New("sku already exists")
+	// ErrPreconditionFailed means the caller's If-Match no longer matches
+	// the stored product, i.e. someone else changed it in the meantime.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrVersionConflict means the product was edited after the caller read
+	// it; the caller should reload and reapply its change.
+	ErrVersionConflict = errors.New("version conflict")
+	// ErrArchiveByPatch rejects archiving through an update; Delete archives.
+	ErrArchiveByPatch = errors.New("products are archived by deleting them")
+)
 
 type ProductService struct {
-	repo  repository.ProductRepository
-	cache *redis.Client
+	repo      repository.ProductRepository
+	mediaRepo repository.ProductMediaRepository
+	cache     *cache.Cache
+	prices    *currency.Converter
 }
 
-func NewProductService(repo repository.ProductRepository, cache *redis.Client) *ProductService {
-	return &ProductService{repo: repo, cache: cache}
+func NewProductService(repo repository.ProductRepository, mediaRepo repository.ProductMediaRepository, productCache *cache.Cache, prices *currency.Converter) *ProductService {
+	return &ProductService{repo: repo, mediaRepo: mediaRepo, cache: productCache, prices: prices}
 }
 
-func (s *ProductService) Create(ctx context.Context, req dto.CreateProductRequest) (*dto.ProductResponse, error) {
+// Create adds a product; its initial stock is booked in the inventory ledger
+// as a restock by actorID.
+func (s *ProductService) Create(ctx context.Context, actorID uuid.UUID, req dto.CreateProductRequest) (*dto.ProductResponse, error) {
 	product := &model.Product{
-		Name: req.Name, Description: req.Description,
-		Price: req.Price, Stock: req.Stock,
+		SKU: req.SKU, Name: req.Name, Description: req.Description, Category: req.Category,
+		Price: req.Price, Stock: req.Stock, Status: req.Status, WeightGrams: req.WeightGrams, TaxClass: req.TaxClass,
+	}
+	if product.Status == "" {
+		product.Status = model.ProductStatusActive
 	}
-	if err := s.repo.Create(ctx, product); err != nil {
+	if product.TaxClass == "" {
+		product.TaxClass = model.TaxClassStandard
+	}
+	if err := s.repo.Create(ctx, product, actorRef(actorID)); err != nil {
+		if errors.Is(err, repository.ErrDuplicateSKU) {
+			return nil, ErrSKUAlreadyExists
+		}
 		return nil, fmt.Errorf("create product: %w", err)
 	}
-	resp := toProductResponse(product)
+	s.cache.InvalidateProducts(ctx, product.ID)
+	resp := toProductResponse(product, s.prices.Base())
 	return &resp, nil
 }
 
-func (s *ProductService) GetByID(ctx context.Context, id uuid.UUID) (*dto.ProductResponse, error) {
-	cacheKey := "product:" + id.String()
-	if s.cache != nil {
-		if data, err := s.cache.Get(ctx, cacheKey).Result(); err == nil {
-			var resp dto.ProductResponse
-			if json.Unmarshal([]byte(data), &resp) == nil {
-				return &resp, nil
+// GetByID returns a product priced in currencyCode; hidden products need
+// includeHidden.
+func (s *ProductService) GetByID(ctx context.Context, id uuid.UUID, includeHidden bool, currencyCode string) (*dto.ProductResponse, error) {
+	resp, err := cache.GetOrLoad(ctx, s.cache, cache.ProductKey(id), s.cache.ProductTTL(),
+		func(ctx context.Context) (*dto.ProductResponse, error) {
+			product, err := s.repo.GetByID(ctx, id)
+			if err != nil {
+				return nil, fmt.Errorf("get product: %w", err)
 			}
-		}
-	}
-
-	product, err := s.repo.GetByID(ctx, id)
+			if product == nil {
+				return nil, ErrProductNotFound
+			}
+			if err := s.loadMedia(ctx, product); err != nil {
+				return nil, err
+			}
+			resp := toProductResponse(product, s.prices.Base())
+			return &resp, nil
+		})
 	if err != nil {
-		return nil, fmt.Errorf("get product: %w", err)
+		return nil, err
 	}
-	if product == nil {
+	if !includeHidden && resp.Status != model.ProductStatusActive {
 		return nil, ErrProductNotFound
 	}
-	resp := toProductResponse(product)
+	priced, err := s.inCurrency(ctx, currencyCode, []dto.ProductResponse{*resp})
+	if err != nil {
+		return nil, err
+	}
+	return &priced[0], nil
+}
 
-	if s.cache != nil {
-		if data, err := json.Marshal(resp); err == nil {
-			_ = s.cache.Set(ctx, cacheKey, data, time.Minute).Err()
-		}
+// List returns products with the given status, priced as in GetByID.
+func (s *ProductService) List(ctx context.Context, status, currencyCode string, params pagination.Params) (*dto.ProductListResponse, error) {
+	cursor := ""
+	if params.Cursor != nil {
+		cursor = params.Cursor.Encode()
 	}
-	return &resp, nil
+	key := s.cache.ProductListKey(ctx, status, strconv.Itoa(params.Limit), cursor, strconv.FormatBool(params.WithTotal))
+
+	resp, err := cache.GetOrLoad(ctx, s.cache, key, s.cache.ListTTL(),
+		func(ctx context.Context) (*dto.ProductListResponse, error) {
+			products, page, err := s.repo.List(ctx, repository.ProductFilter{Status: status}, params)
+			if err != nil {
+				return nil, fmt.Errorf("list products: %w", err)
+			}
+			ptrs := make([]*model.Product, len(products))
+			for i := range products {
+				ptrs[i] = &products[i]
+			}
+			if err := s.loadMedia(ctx, ptrs...); err != nil {
+				return nil, err
+			}
+			items := make([]dto.ProductResponse, len(products))
+			for i, p := range products {
+				items[i] = toProductResponse(&p, s.prices.Base())
+			}
+			return &dto.ProductListResponse{Products: items, PageInfo: toPageInfo(page)}, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	priced, err := s.inCurrency(ctx, currencyCode, resp.Products)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.ProductListResponse{Products: priced, PageInfo: resp.PageInfo}, nil
 }
 
-func (s *ProductService) List(ctx context.Context, page, limit int) (*dto.ProductListResponse, error) {
-	offset := (page - 1) * limit
-	products, total, err := s.repo.List(ctx, limit, offset)
+// inCurrency prices products in code. The results are copies, as products
+// may be shared with other callers by the cache.
+func (s *ProductService) inCurrency(ctx context.Context, code string, products []dto.ProductResponse) ([]dto.ProductResponse, error) {
+	p, err := pricing(ctx, s.prices, code)
 	if err != nil {
-		return nil, fmt.Errorf("list products: %w", err)
+		return nil, err
 	}
-	items := make([]dto.ProductResponse, len(products))
-	for i, p := range products {
-		items[i] = toProductResponse(&p)
+	if p.IsBase() {
+		return products, nil
 	}
-	return &dto.ProductListResponse{Products: items, Total: total}, nil
+	base := make(map[uuid.UUID]decimal.Decimal, len(products))
+	for _, product := range products {
+		base[product.ID] = product.Price
+	}
+	prices, err := s.prices.Prices(ctx, p, base)
+	if err != nil {
+		return nil, err
+	}
+	out := slices.Clone(products)
+	for i := range out {
+		out[i].Price, out[i].Currency = prices[out[i].ID], p.Currency
+	}
+	return out, nil
 }
 
-func (s *ProductService) Update(ctx context.Context, id uuid.UUID, req dto.UpdateProductRequest) (*dto.ProductResponse, error) {
+// Update overwrites the product's catalogue fields. A non-empty ifMatch is
+// compared against the product's current ETag before writing.
+func (s *ProductService) Update(ctx context.Context, id, actorID uuid.UUID, req dto.UpdateProductRequest, ifMatch string) (*dto.ProductResponse, error) {
+	patch := dto.PatchProductRequest{
+		SKU: &req.SKU, Name: &req.Name, Description: &req.Description, Category: &req.Category,
+		Price: &req.Price, WeightGrams: &req.WeightGrams, Version: req.Version,
+	}
+	if req.Status != "" {
+		patch.Status = &req.Status
+	}
+	if req.TaxClass != "" {
+		patch.TaxClass = &req.TaxClass
+	}
+	return s.Patch(ctx, id, actorID, patch, ifMatch)
+}
+
+// Patch applies a merge patch to the product's catalogue fields. Archiving is
+// left to Delete.
+func (s *ProductService) Patch(ctx context.Context, id, actorID uuid.UUID, req dto.PatchProductRequest, ifMatch string) (*dto.ProductResponse, error) {
+	if req.Status != nil && *req.Status == model.ProductStatusArchived {
+		return nil, ErrArchiveByPatch
+	}
 	product, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("get product: %w", err)
@@ -87,32 +188,201 @@ func (s *ProductService) Update(ctx context.Context, id uuid.UUID, req dto.Updat
 	if product == nil {
 		return nil, ErrProductNotFound
 	}
+	if ifMatch != "" && !MatchETag(ifMatch, productETag(product.ID, product.UpdatedAt, s.prices.Base(), product.Price), false) {
+		return nil, ErrPreconditionFailed
+	}
+	// Without an explicit version the one just read is used, which still
+	// catches edits landing between this read and the write below.
+	if req.Version != nil && *req.Version != product.Version {
+		return nil, ErrVersionConflict
+	}
 
-	product.Name = req.Name
-	product.Description = req.Description
-	product.Price = req.Price
-	product.Stock = req.Stock
+	updated := *product
+	if req.SKU != nil {
+		updated.SKU = *req.SKU
+	}
+	if req.Name != nil {
+		updated.Name = *req.Name
+	}
+	if req.Description != nil {
+		updated.Description = *req.Description
+	}
+	if req.Category != nil {
+		updated.Category = *req.Category
+	}
+	if req.Price != nil {
+		updated.Price = *req.Price
+	}
+	if req.Status != nil {
+		updated.Status = *req.Status
+	}
+	if req.WeightGrams != nil {
+		updated.WeightGrams = *req.WeightGrams
+	}
+	if req.TaxClass != nil {
+		updated.TaxClass = *req.TaxClass
+	}
 
-	if err := s.repo.Update(ctx, product); err != nil {
+	changes := diffProduct(product, &updated)
+	if len(changes) == 0 {
+		resp := toProductResponse(product, s.prices.Base())
+		return &resp, nil
+	}
+	audit := &model.ProductAuditEntry{Action: model.ProductAuditUpdate, ActorID: actorRef(actorID), Changes: changes}
+	if err := s.repo.Update(ctx, &updated, audit); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return nil, ErrVersionConflict
+		}
+		if errors.Is(err, repository.ErrDuplicateSKU) {
+			return nil, ErrSKUAlreadyExists
+		}
 		return nil, fmt.Errorf("update product: %w", err)
 	}
-	resp := toProductResponse(product)
+	s.cache.InvalidateProducts(ctx, updated.ID)
+	resp := toProductResponse(&updated, s.prices.Base())
 	return &resp, nil
 }
 
+// History returns the product's audit trail, newest first.
+func (s *ProductService) History(ctx context.Context, id uuid.UUID, params pagination.Params) (*dto.ProductAuditListResponse, error) {
+	product, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get product: %w", err)
+	}
+	if product == nil {
+		return nil, ErrProductNotFound
+	}
+	entries, page, err := s.repo.ListAudit(ctx, id, params)
+	if err != nil {
+		return nil, fmt.Errorf("list product audit: %w", err)
+	}
+	resp := &dto.ProductAuditListResponse{
+		Entries:  make([]dto.ProductAuditResponse, len(entries)),
+		PageInfo: toPageInfo(page),
+	}
+	for i, e := range entries {
+		changes := make([]dto.FieldChangeResult, len(e.Changes))
+		for j, c := range e.Changes {
+			changes[j] = dto.FieldChangeResult{Field: c.Field, Old: c.Old, New: c.New}
+		}
+		resp.Entries[i] = dto.ProductAuditResponse{
+			ID: e.ID, ActorID: e.ActorID, Action: e.Action, Changes: changes, CreatedAt: e.CreatedAt,
+		}
+	}
+	return resp, nil
+}
+
+// diffProduct lists the catalogue fields that differ between old and updated,
+// in a stable order. Prices are recorded as strings to keep their precision.
+func diffProduct(old, updated *model.Product) []model.FieldChange {
+	var changes []model.FieldChange
+	add := func(field string, from, to any) {
+		changes = append(changes, model.FieldChange{Field: field, Old: from, New: to})
+	}
+	if old.SKU != updated.SKU {
+		add("sku", old.SKU, updated.SKU)
+	}
+	if old.Name != updated.Name {
+		add("name", old.Name, updated.Name)
+	}
+	if old.Description != updated.Description {
+		add("description", old.Description, updated.Description)
+	}
+	if old.Category != updated.Category {
+		add("category", old.Category, updated.Category)
+	}
+	if !old.Price.Equal(updated.Price) {
+		add("price", old.Price.String(), updated.Price.String())
+	}
+	if old.Status != updated.Status {
+		add("status", old.Status, updated.Status)
+	}
+	if old.WeightGrams != updated.WeightGrams {
+		add("weight_grams", old.WeightGrams, updated.WeightGrams)
+	}
+	if old.TaxClass != updated.TaxClass {
+		add("tax_class", old.TaxClass, updated.TaxClass)
+	}
+	return changes
+}
+
+// Delete archives the product: it disappears from the storefront but stays
+// resolvable for historical orders and can be restored.
 func (s *ProductService) Delete(ctx context.Context, id uuid.UUID) error {
-	if err := s.repo.Delete(ctx, id); err != nil {
+	if err := s.repo.Archive(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrProductNotFound
+		}
 		return err
 	}
-	if s.cache != nil {
-		_ = s.cache.Del(ctx, "product:"+id.String()).Err()
+	s.cache.InvalidateProducts(ctx, id)
+	return nil
+}
+
+func (s *ProductService) Restore(ctx context.Context, id uuid.UUID) (*dto.ProductResponse, error) {
+	if err := s.repo.Restore(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+	s.cache.InvalidateProducts(ctx, id)
+	return s.GetByID(ctx, id, true, "")
+}
+
+// SetOrderLimit sets or clears the product's max-per-order quantity.
+func (s *ProductService) SetOrderLimit(ctx context.Context, id uuid.UUID, req dto.OrderLimitRequest) (*dto.ProductResponse, error) {
+	if err := s.repo.SetMaxPerOrder(ctx, id, req.MaxPerOrder); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, fmt.Errorf("set order limit: %w", err)
+	}
+	s.cache.InvalidateProducts(ctx, id)
+	return s.GetByID(ctx, id, true, "")
+}
+
+// loadMedia attaches gallery media to the products with a single query.
+func (s *ProductService) loadMedia(ctx context.Context, products ...*model.Product) error {
+	if s.mediaRepo == nil || len(products) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	media, err := s.mediaRepo.ListByProductIDs(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("load product media: %w", err)
+	}
+	for _, p := range products {
+		p.Media = media[p.ID]
 	}
 	return nil
 }
 
-func toProductResponse(p *model.Product) dto.ProductResponse {
+// actorRef turns an optional actor ID into the nullable form stored in
+// audit and ledger rows.
+func actorRef(id uuid.UUID) *uuid.UUID {
+	if id == uuid.Nil {
+		return nil
+	}
+	return &id
+}
+
+func toPageInfo(p pagination.Page) dto.PageInfo {
+	return dto.PageInfo{NextCursor: p.NextCursor, PrevCursor: p.PrevCursor, Total: p.Total}
+}
+
+func toProductResponse(p *model.Product, currency string) dto.ProductResponse {
+	media := make([]dto.ProductMediaResponse, len(p.Media))
+	for i := range p.Media {
+		media[i] = toProductMediaResponse(&p.Media[i])
+	}
 	return dto.ProductResponse{
-		ID: p.ID, Name: p.Name, Description: p.Description,
-		Price: p.Price, Stock: p.Stock, CreatedAt: p.CreatedAt,
+		ID: p.ID, SKU: p.SKU, Name: p.Name, Description: p.Description, Category: p.Category,
+		Price: p.Price, Stock: p.Stock, Status: p.Status, MaxPerOrder: p.MaxPerOrder,
+		WeightGrams: p.WeightGrams, TaxClass: p.TaxClass, Currency: currency, Version: p.Version, Media: media,
+		CreatedAt: p.CreatedAt, UpdatedAt: p.UpdatedAt, ArchivedAt: p.DeletedAt,
 	}
 }
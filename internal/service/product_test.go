@@ -5,56 +5,175 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/flicky/go-ecommerce-api/internal/cache"
 	"github.com/flicky/go-ecommerce-api/internal/dto"
 	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
 )
 
 type mockProductRepo struct {
-	products map[uuid.UUID]*model.Product
+	products  map[uuid.UUID]*model.Product
+	audits    []model.ProductAuditEntry
+	movements []model.InventoryMovement
 }
 
 func newMockProductRepo() *mockProductRepo {
 	return &mockProductRepo{products: make(map[uuid.UUID]*model.Product)}
 }
 
-func (m *mockProductRepo) Create(_ context.Context, p *model.Product) error {
-	p.ID = uuid.New()
+func (m *mockProductRepo) Create(_ context.Context, p *model.Product, actorID *uuid.UUID) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	if p.Status == "" {
+		p.Status = model.ProductStatusActive
+	}
+	p.Version = 1
 	p.CreatedAt = time.Now()
 	p.UpdatedAt = time.Now()
 	m.products[p.ID] = p
+	if p.Stock != 0 {
+		m.movements = append(m.movements, model.InventoryMovement{
+			ID: uuid.New(), ProductID: p.ID, Kind: model.MovementRestock, Quantity: p.Stock,
+			StockAfter: p.Stock, ActorID: actorID, CreatedAt: time.Now(),
+		})
+	}
 	return nil
 }
 
 func (m *mockProductRepo) GetByID(_ context.Context, id uuid.UUID) (*model.Product, error) {
-	return m.products[id], nil
+	p, ok := m.products[id]
+	if !ok {
+		return nil, nil
+	}
+	cp := *p
+	return &cp, nil
 }
 
-func (m *mockProductRepo) List(_ context.Context, limit, offset int) ([]model.Product, int, error) {
+func (m *mockProductRepo) List(_ context.Context, f repository.ProductFilter, params pagination.Params) ([]model.Product, pagination.Page, error) {
 	var all []model.Product
 	for _, p := range m.products {
-		all = append(all, *p)
+		if f.Status == "" || p.Status == f.Status {
+			all = append(all, *p)
+		}
+	}
+	sortNewestFirst(all, productKey)
+	items, page := pagination.Build(afterCursor(all, params, productKey), params, productKey)
+	if params.WithTotal {
+		total := len(all)
+		page.Total = &total
 	}
-	return all, len(all), nil
+	return items, page, nil
 }
 
-func (m *mockProductRepo) Update(_ context.Context, p *model.Product) error {
-	m.products[p.ID] = p
+func (m *mockProductRepo) Update(_ context.Context, p *model.Product, audit *model.ProductAuditEntry) error {
+	stored, ok := m.products[p.ID]
+	if !ok || stored.Version != p.Version {
+		return repository.ErrVersionConflict
+	}
+	p.Stock = stored.Stock
+	if p.TaxClass == "" {
+		p.TaxClass = stored.TaxClass
+	}
+	p.Version++
+	p.UpdatedAt = stored.UpdatedAt.Add(time.Second)
+	cp := *p
+	m.products[p.ID] = &cp
+	if audit != nil {
+		audit.ID, audit.ProductID, audit.CreatedAt = uuid.New(), p.ID, time.Now()
+		m.audits = append(m.audits, *audit)
+	}
+	return nil
+}
+
+func (m *mockProductRepo) Archive(_ context.Context, id uuid.UUID) error {
+	p, ok := m.products[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	now := time.Now()
+	p.Status, p.DeletedAt = model.ProductStatusArchived, &now
 	return nil
 }
 
-func (m *mockProductRepo) Delete(_ context.Context, id uuid.UUID) error {
-	delete(m.products, id)
+func (m *mockProductRepo) Restore(_ context.Context, id uuid.UUID) error {
+	p, ok := m.products[id]
+	if !ok || p.Status != model.ProductStatusArchived {
+		return repository.ErrNotFound
+	}
+	p.Status, p.DeletedAt = model.ProductStatusActive, nil
+	return nil
+}
+
+func (m *mockProductRepo) SetMaxPerOrder(_ context.Context, id uuid.UUID, limit *int) error {
+	p, ok := m.products[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	p.MaxPerOrder = limit
+	p.Version++
+	return nil
+}
+
+func (m *mockProductRepo) UpsertBySKU(ctx context.Context, p *model.Product, actorID *uuid.UUID, audit repository.AuditFunc) (bool, error) {
+	for _, existing := range m.products {
+		if existing.SKU == p.SKU {
+			p.ID, p.CreatedAt, p.Stock = existing.ID, existing.CreatedAt, existing.Stock
+			p.Category, p.WeightGrams, p.TaxClass = existing.Category, existing.WeightGrams, existing.TaxClass
+			if p.Status == "" {
+				p.Status = existing.Status
+			}
+			if entry := audit(existing, p); entry != nil {
+				entry.ID, entry.ProductID, entry.CreatedAt = uuid.New(), p.ID, time.Now()
+				m.audits = append(m.audits, *entry)
+			}
+			m.products[p.ID] = p
+			return false, nil
+		}
+	}
+	return true, m.Create(ctx, p, actorID)
+}
+
+func (m *mockProductRepo) Each(_ context.Context, fn func(*model.Product) error) error {
+	all := make([]model.Product, 0, len(m.products))
+	for _, p := range m.products {
+		all = append(all, *p)
+	}
+	sortNewestFirst(all, productKey)
+	for i := len(all) - 1; i >= 0; i-- {
+		if err := fn(&all[i]); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+func (m *mockProductRepo) ListAudit(_ context.Context, productID uuid.UUID, params pagination.Params) ([]model.ProductAuditEntry, pagination.Page, error) {
+	var all []model.ProductAuditEntry
+	for _, e := range m.audits {
+		if e.ProductID == productID {
+			all = append(all, e)
+		}
+	}
+	key := func(e model.ProductAuditEntry) (time.Time, uuid.UUID) { return e.CreatedAt, e.ID }
+	sortNewestFirst(all, key)
+	items, page := pagination.Build(afterCursor(all, params, key), params, key)
+	return items, page, nil
+}
+
+func productKey(p model.Product) (time.Time, uuid.UUID) { return p.CreatedAt, p.ID }
+
 func TestProductService_Create(t *testing.T) {
-	svc := NewProductService(newMockProductRepo(), nil)
-	resp, err := svc.Create(context.Background(), dto.CreateProductRequest{
+	svc := NewProductService(newMockProductRepo(), nil, nil, newTestPrices())
+	resp, err := svc.Create(context.Background(), uuid.Nil, dto.CreateProductRequest{
 		Name: "Test", Price: decimal.NewFromFloat(9.99), Stock: 100,
 	})
 	require.NoError(t, err)
@@ -63,17 +182,198 @@ func TestProductService_Create(t *testing.T) {
 }
 
 func TestProductService_GetByID_NotFound(t *testing.T) {
-	svc := NewProductService(newMockProductRepo(), nil)
-	_, err := svc.GetByID(context.Background(), uuid.New())
+	svc := NewProductService(newMockProductRepo(), nil, nil, newTestPrices())
+	_, err := svc.GetByID(context.Background(), uuid.New(), false, "")
 	assert.ErrorIs(t, err, ErrProductNotFound)
 }
 
 func TestProductService_Delete(t *testing.T) {
 	repo := newMockProductRepo()
 	id := uuid.New()
-	repo.products[id] = &model.Product{ID: id}
-	svc := NewProductService(repo, nil)
+	repo.products[id] = &model.Product{ID: id, Status: model.ProductStatusActive}
+	svc := NewProductService(repo, nil, nil, newTestPrices())
 	err := svc.Delete(context.Background(), id)
 	require.NoError(t, err)
-	assert.Empty(t, repo.products)
+
+	// Archived products stay resolvable for admins but vanish from the storefront.
+	require.Contains(t, repo.products, id)
+	_, err = svc.GetByID(context.Background(), id, false, "")
+	assert.ErrorIs(t, err, ErrProductNotFound)
+	resp, err := svc.GetByID(context.Background(), id, true, "")
+	require.NoError(t, err)
+	assert.Equal(t, model.ProductStatusArchived, resp.Status)
+	assert.NotNil(t, resp.ArchivedAt)
+
+	list, err := svc.List(context.Background(), model.ProductStatusActive, "", pagination.Params{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, list.Products)
+}
+
+func TestProductService_Restore(t *testing.T) {
+	repo := newMockProductRepo()
+	id := uuid.New()
+	repo.products[id] = &model.Product{ID: id, Status: model.ProductStatusActive}
+	svc := NewProductService(repo, nil, nil, newTestPrices())
+
+	_, err := svc.Restore(context.Background(), id)
+	assert.ErrorIs(t, err, ErrProductNotFound)
+
+	require.NoError(t, svc.Delete(context.Background(), id))
+	resp, err := svc.Restore(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, model.ProductStatusActive, resp.Status)
+	assert.Nil(t, resp.ArchivedAt)
+}
+
+func TestProductService_List_WithTotal(t *testing.T) {
+	repo := newMockProductRepo()
+	for i := 0; i < 3; i++ {
+		_ = repo.Create(context.Background(), &model.Product{Name: "P"}, nil)
+	}
+	svc := NewProductService(repo, nil, nil, newTestPrices())
+
+	resp, err := svc.List(context.Background(), "", "", pagination.Params{Limit: 2, WithTotal: true})
+	require.NoError(t, err)
+	assert.Len(t, resp.Products, 2)
+	assert.NotEmpty(t, resp.NextCursor)
+	require.NotNil(t, resp.Total)
+	assert.Equal(t, 3, *resp.Total)
+}
+
+func newTestCache(t *testing.T) *cache.Cache {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	return cache.New(rdb, time.Minute, time.Minute)
+}
+
+func TestProductService_Update_InvalidatesCache(t *testing.T) {
+	repo := newMockProductRepo()
+	svc := NewProductService(repo, nil, newTestCache(t), newTestPrices())
+	ctx := context.Background()
+
+	created, err := svc.Create(ctx, uuid.Nil, dto.CreateProductRequest{Name: "Mug", Price: decimal.NewFromInt(10), Stock: 5})
+	require.NoError(t, err)
+	cached, err := svc.GetByID(ctx, created.ID, false, "")
+	require.NoError(t, err)
+	list, err := svc.List(ctx, model.ProductStatusActive, "", pagination.Params{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, list.Products, 1)
+	assert.True(t, cached.Price.Equal(decimal.NewFromInt(10)))
+
+	_, err = svc.Update(ctx, created.ID, uuid.Nil, dto.UpdateProductRequest{Name: "Mug", Price: decimal.NewFromInt(12)}, "")
+	require.NoError(t, err)
+
+	fresh, err := svc.GetByID(ctx, created.ID, false, "")
+	require.NoError(t, err)
+	assert.True(t, fresh.Price.Equal(decimal.NewFromInt(12)))
+	list, err = svc.List(ctx, model.ProductStatusActive, "", pagination.Params{Limit: 10})
+	require.NoError(t, err)
+	assert.True(t, list.Products[0].Price.Equal(decimal.NewFromInt(12)))
+}
+
+func TestProductService_Update_IfMatch(t *testing.T) {
+	repo := newMockProductRepo()
+	svc := NewProductService(repo, nil, nil, newTestPrices())
+	ctx := context.Background()
+
+	created, err := svc.Create(ctx, uuid.Nil, dto.CreateProductRequest{Name: "Mug", Price: decimal.NewFromInt(10), Stock: 5})
+	require.NoError(t, err)
+	etag := ProductETag(created)
+	req := dto.UpdateProductRequest{Name: "Mug", Price: decimal.NewFromInt(12)}
+
+	updated, err := svc.Update(ctx, created.ID, uuid.Nil, req, etag)
+	require.NoError(t, err)
+	assert.NotEqual(t, etag, ProductETag(updated))
+
+	// The first write moved the tag on, so a second writer holding the old one loses.
+	_, err = svc.Update(ctx, created.ID, uuid.Nil, req, etag)
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	_, err = svc.Update(ctx, created.ID, uuid.Nil, req, "W/"+ProductETag(updated))
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	_, err = svc.Update(ctx, created.ID, uuid.Nil, req, "*")
+	assert.NoError(t, err)
+}
+
+func TestProductService_Update_VersionConflict(t *testing.T) {
+	repo := newMockProductRepo()
+	svc := NewProductService(repo, nil, nil, newTestPrices())
+	ctx := context.Background()
+
+	created, err := svc.Create(ctx, uuid.Nil, dto.CreateProductRequest{Name: "Mug", Price: decimal.NewFromInt(10), Stock: 5})
+	require.NoError(t, err)
+	stale := created.Version
+
+	updated, err := svc.Update(ctx, created.ID, uuid.Nil, dto.UpdateProductRequest{Name: "Mug", Price: decimal.NewFromInt(12), Version: &stale}, "")
+	require.NoError(t, err)
+	assert.Equal(t, stale+1, updated.Version)
+
+	_, err = svc.Update(ctx, created.ID, uuid.Nil, dto.UpdateProductRequest{Name: "Cup", Price: decimal.NewFromInt(11), Version: &stale}, "")
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	assert.Equal(t, "Mug", repo.products[created.ID].Name)
+}
+
+func TestProductService_Update_KeepsStock(t *testing.T) {
+	repo := newMockProductRepo()
+	svc := NewProductService(repo, nil, nil, newTestPrices())
+	ctx := context.Background()
+
+	created, err := svc.Create(ctx, uuid.Nil, dto.CreateProductRequest{Name: "Mug", Price: decimal.NewFromInt(10), Stock: 5})
+	require.NoError(t, err)
+	version := created.Version
+
+	// Stock sold between the admin's read and write must survive the edit.
+	repo.products[created.ID].Stock -= 2
+	updated, err := svc.Update(ctx, created.ID, uuid.Nil, dto.UpdateProductRequest{Name: "Mug", Price: decimal.NewFromInt(12), Version: &version}, "")
+	require.NoError(t, err)
+	assert.Equal(t, 3, updated.Stock)
+}
+
+func TestProductService_Patch(t *testing.T) {
+	repo := newMockProductRepo()
+	svc := NewProductService(repo, nil, nil, newTestPrices())
+	ctx := context.Background()
+	actor := uuid.New()
+
+	created, err := svc.Create(ctx, uuid.Nil, dto.CreateProductRequest{
+		SKU: "MUG-1", Name: "Mug", Description: "Blue", Price: decimal.NewFromInt(10), Stock: 5,
+	})
+	require.NoError(t, err)
+
+	desc, price := "Red", decimal.NewFromFloat(10.00)
+	resp, err := svc.Patch(ctx, created.ID, actor, dto.PatchProductRequest{Description: &desc, Price: &price}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "Red", resp.Description)
+	assert.Equal(t, "Mug", resp.Name)
+	assert.Equal(t, "MUG-1", resp.SKU)
+	assert.Equal(t, 5, resp.Stock)
+
+	// Price was re-sent unchanged, so only the description is recorded.
+	history, err := svc.History(ctx, created.ID, pagination.Params{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, history.Entries, 1)
+	entry := history.Entries[0]
+	assert.Equal(t, &actor, entry.ActorID)
+	assert.Equal(t, []dto.FieldChangeResult{{Field: "description", Old: "Blue", New: "Red"}}, entry.Changes)
+
+	// A patch that changes nothing writes nothing.
+	same, err := svc.Patch(ctx, created.ID, actor, dto.PatchProductRequest{Description: &desc}, "")
+	require.NoError(t, err)
+	assert.Equal(t, resp.Version, same.Version)
+	assert.Len(t, repo.audits, 1)
+
+	// Archiving goes through Delete, which also sets deleted_at.
+	archived := model.ProductStatusArchived
+	_, err = svc.Patch(ctx, created.ID, actor, dto.PatchProductRequest{Status: &archived}, "")
+	assert.ErrorIs(t, err, ErrArchiveByPatch)
+	assert.Equal(t, model.ProductStatusActive, repo.products[created.ID].Status)
+}
+
+func TestProductService_History_NotFound(t *testing.T) {
+	svc := NewProductService(newMockProductRepo(), nil, nil, newTestPrices())
+	_, err := svc.History(context.Background(), uuid.New(), pagination.Params{Limit: 10})
+	assert.ErrorIs(t, err, ErrProductNotFound)
 }
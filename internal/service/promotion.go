@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/flicky/go-ecommerce-api/internal/currency"
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+	"github.com/flicky/go-ecommerce-api/internal/promotion"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+var (
+	ErrPromotionNotFound      = errors.New("promotion not found")
+	ErrInvalidPromotion       = errors.New("invalid promotion")
+	ErrDuplicatePromotionCode = errors.New("promotion code already exists")
+)
+
+// Coupon rejection codes, returned by POST /cart/coupon and shown on a cart
+// whose coupon no longer applies.
+const (
+	CouponNotFound      = "coupon_not_found"
+	CouponNotStarted    = "coupon_not_started"
+	CouponExpired       = "coupon_expired"
+	CouponMinSubtotal   = "coupon_min_subtotal"
+	CouponNotApplicable = "coupon_not_applicable"
+	CouponUsedUp        = "coupon_used_up"
+)
+
+// CouponError rejects a coupon for the cart it was applied to. MinSubtotal
+// is set with CouponMinSubtotal.
+type CouponError struct {
+	Code        string
+	Message     string
+	MinSubtotal *decimal.Decimal
+}
+
+func (e *CouponError) Error() string { return e.Message }
+
+type PromotionService struct {
+	repo repository.PromotionRepository
+}
+
+func NewPromotionService(repo repository.PromotionRepository) *PromotionService {
+	return &PromotionService{repo: repo}
+}
+
+// Create adds an active promotion. Codes are case-insensitive and stored
+// upper-case.
+func (s *PromotionService) Create(ctx context.Context, req dto.CreatePromotionRequest) (*dto.PromotionResponse, error) {
+	p := &model.Promotion{
+		Code: normalizeCouponCode(req.Code), Description: req.Description, Kind: req.Kind, Value: req.Value,
+		BuyQuantity: req.BuyQuantity, GetQuantity: req.GetQuantity, MinSubtotal: req.MinSubtotal,
+		ProductIDs: req.ProductIDs, Categories: req.Categories, MaxUses: req.MaxUses,
+		MaxUsesPerCustomer: req.MaxUsesPerCustomer, StartsAt: req.StartsAt, EndsAt: req.EndsAt, Active: true,
+	}
+	if err := validatePromotion(p); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, p); err != nil {
+		if errors.Is(err, repository.ErrDuplicatePromotionCode) {
+			return nil, ErrDuplicatePromotionCode
+		}
+		return nil, fmt.Errorf("create promotion: %w", err)
+	}
+	resp := toPromotionResponse(p)
+	return &resp, nil
+}
+
+func validatePromotion(p *model.Promotion) error {
+	if p.Code == "" {
+		return fmt.Errorf("%w: code is empty", ErrInvalidPromotion)
+	}
+	if p.Value.IsNegative() || p.MinSubtotal.IsNegative() {
+		return fmt.Errorf("%w: value and min_subtotal cannot be negative", ErrInvalidPromotion)
+	}
+	switch p.Kind {
+	case model.PromotionPercentage:
+		if !p.Value.IsPositive() || p.Value.GreaterThan(decimal.NewFromInt(100)) {
+			return fmt.Errorf("%w: percentage must be between 0 and 100", ErrInvalidPromotion)
+		}
+	case model.PromotionFixed:
+		if !p.Value.IsPositive() {
+			return fmt.Errorf("%w: fixed amount must be positive", ErrInvalidPromotion)
+		}
+	case model.PromotionBuyXGetY:
+		if p.BuyQuantity < 1 || p.GetQuantity < 1 {
+			return fmt.Errorf("%w: buy_x_get_y needs buy_quantity and get_quantity", ErrInvalidPromotion)
+		}
+	}
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidPromotion)
+	}
+	return nil
+}
+
+func (s *PromotionService) List(ctx context.Context, params pagination.Params) (*dto.PromotionListResponse, error) {
+	promotions, page, err := s.repo.List(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("list promotions: %w", err)
+	}
+	resp := &dto.PromotionListResponse{
+		Promotions: make([]dto.PromotionResponse, len(promotions)),
+		PageInfo:   toPageInfo(page),
+	}
+	for i := range promotions {
+		resp.Promotions[i] = toPromotionResponse(&promotions[i])
+	}
+	return resp, nil
+}
+
+// SetActive switches a promotion on or off. Carts holding a deactivated
+// coupon keep it but get no discount.
+func (s *PromotionService) SetActive(ctx context.Context, id uuid.UUID, active bool) (*dto.PromotionResponse, error) {
+	if err := s.repo.SetActive(ctx, id, active); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrPromotionNotFound
+		}
+		return nil, fmt.Errorf("set promotion active: %w", err)
+	}
+	p, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get promotion: %w", err)
+	}
+	if p == nil {
+		return nil, ErrPromotionNotFound
+	}
+	resp := toPromotionResponse(p)
+	return &resp, nil
+}
+
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// evaluateCoupon works out what p gives the lines, checking its usage limits.
+func evaluateCoupon(ctx context.Context, repo repository.PromotionRepository, p *model.Promotion, pc currency.Pricing, lines []promotion.Line, userID uuid.UUID) (promotion.Result, error) {
+	local := promotionIn(pc, p)
+	result, err := promotion.Apply(local, lines, time.Now())
+	if err != nil {
+		return promotion.Result{}, couponError(err, local, pc.Currency)
+	}
+	result.Discount = pc.Round(result.Discount)
+	if p.MaxUses != nil && p.Uses >= *p.MaxUses {
+		return promotion.Result{}, &CouponError{Code: CouponUsedUp, Message: "coupon has been used up"}
+	}
+	if p.MaxUsesPerCustomer != nil && userID != uuid.Nil {
+		uses, err := repo.CustomerUses(ctx, p.ID, userID)
+		if err != nil {
+			return promotion.Result{}, fmt.Errorf("count coupon uses: %w", err)
+		}
+		if uses >= *p.MaxUsesPerCustomer {
+			return promotion.Result{}, &CouponError{Code: CouponUsedUp, Message: "coupon already used"}
+		}
+	}
+	return result, nil
+}
+
+// couponError turns a rule the cart does not meet into a CouponError, with
+// amounts in code. Inactive promotions look like unknown codes to customers.
+func couponError(err error, p *model.Promotion, code string) error {
+	switch {
+	case errors.Is(err, promotion.ErrInactive):
+		return &CouponError{Code: CouponNotFound, Message: "coupon not found"}
+	case errors.Is(err, promotion.ErrNotStarted):
+		return &CouponError{Code: CouponNotStarted, Message: "coupon is not valid yet"}
+	case errors.Is(err, promotion.ErrExpired):
+		return &CouponError{Code: CouponExpired, Message: "coupon has expired"}
+	case errors.Is(err, promotion.ErrMinSubtotal):
+		minSubtotal := p.MinSubtotal
+		return &CouponError{
+			Code: CouponMinSubtotal, MinSubtotal: &minSubtotal,
+			Message: fmt.Sprintf("coupon needs a spend of at least %s", minSubtotal.StringFixed(currency.Decimals(code))),
+		}
+	case errors.Is(err, promotion.ErrNotApplicable):
+		return &CouponError{Code: CouponNotApplicable, Message: "coupon does not apply to the items in the cart"}
+	}
+	return fmt.Errorf("apply coupon: %w", err)
+}
+
+func toPromotionResponse(p *model.Promotion) dto.PromotionResponse {
+	productIDs, categories := p.ProductIDs, p.Categories
+	if productIDs == nil {
+		productIDs = []uuid.UUID{}
+	}
+	if categories == nil {
+		categories = []string{}
+	}
+	return dto.PromotionResponse{
+		ID: p.ID, Code: p.Code, Description: p.Description, Kind: p.Kind, Value: p.Value,
+		BuyQuantity: p.BuyQuantity, GetQuantity: p.GetQuantity, MinSubtotal: p.MinSubtotal,
+		ProductIDs: productIDs, Categories: categories, MaxUses: p.MaxUses,
+		MaxUsesPerCustomer: p.MaxUsesPerCustomer, Uses: p.Uses, StartsAt: p.StartsAt, EndsAt: p.EndsAt,
+		Active: p.Active, CreatedAt: p.CreatedAt,
+	}
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+// mockPromotionRepo records redemptions as the user who made each, per
+// promotion.
+type mockPromotionRepo struct {
+	promotions  map[uuid.UUID]*model.Promotion
+	redemptions map[uuid.UUID][]uuid.UUID
+}
+
+func newMockPromotionRepo() *mockPromotionRepo {
+	return &mockPromotionRepo{promotions: make(map[uuid.UUID]*model.Promotion), redemptions: make(map[uuid.UUID][]uuid.UUID)}
+}
+
+func (m *mockPromotionRepo) Create(_ context.Context, p *model.Promotion) error {
+	for _, existing := range m.promotions {
+		if existing.Code == p.Code {
+			return repository.ErrDuplicatePromotionCode
+		}
+	}
+	p.ID, p.CreatedAt = uuid.New(), time.Now()
+	m.promotions[p.ID] = p
+	return nil
+}
+
+func (m *mockPromotionRepo) GetByID(_ context.Context, id uuid.UUID) (*model.Promotion, error) {
+	p, ok := m.promotions[id]
+	if !ok {
+		return nil, nil
+	}
+	p.Uses = len(m.redemptions[id])
+	return p, nil
+}
+
+func (m *mockPromotionRepo) GetByCode(ctx context.Context, code string) (*model.Promotion, error) {
+	for id, p := range m.promotions {
+		if p.Code == code {
+			return m.GetByID(ctx, id)
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockPromotionRepo) List(_ context.Context, params pagination.Params) ([]model.Promotion, pagination.Page, error) {
+	var all []model.Promotion
+	for _, p := range m.promotions {
+		all = append(all, *p)
+	}
+	sortNewestFirst(all, promotionKey)
+	items, page := pagination.Build(afterCursor(all, params, promotionKey), params, promotionKey)
+	return items, page, nil
+}
+
+func (m *mockPromotionRepo) SetActive(_ context.Context, id uuid.UUID, active bool) error {
+	p, ok := m.promotions[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	p.Active = active
+	return nil
+}
+
+func (m *mockPromotionRepo) CustomerUses(_ context.Context, promotionID, userID uuid.UUID) (int, error) {
+	n := 0
+	for _, u := range m.redemptions[promotionID] {
+		if u == userID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *mockPromotionRepo) redeem(order *model.Order) error {
+	p := m.promotions[*order.PromotionID]
+	uses, _ := m.CustomerUses(context.Background(), p.ID, order.UserID)
+	if (p.MaxUses != nil && len(m.redemptions[p.ID]) >= *p.MaxUses) || (p.MaxUsesPerCustomer != nil && uses >= *p.MaxUsesPerCustomer) {
+		return repository.ErrPromotionUsedUp
+	}
+	m.redemptions[p.ID] = append(m.redemptions[p.ID], order.UserID)
+	return nil
+}
+
+func promotionKey(p model.Promotion) (time.Time, uuid.UUID) { return p.CreatedAt, p.ID }
+
+func TestPromotionService_Create(t *testing.T) {
+	svc := NewPromotionService(newMockPromotionRepo())
+	ctx := context.Background()
+
+	resp, err := svc.Create(ctx, dto.CreatePromotionRequest{Code: " spring10 ", Kind: model.PromotionPercentage, Value: decimal.NewFromInt(10)})
+	require.NoError(t, err)
+	assert.Equal(t, "SPRING10", resp.Code)
+	assert.True(t, resp.Active)
+	assert.Equal(t, []string{}, resp.Categories)
+
+	_, err = svc.Create(ctx, dto.CreatePromotionRequest{Code: "Spring10", Kind: model.PromotionFixed, Value: decimal.NewFromInt(5)})
+	assert.ErrorIs(t, err, ErrDuplicatePromotionCode)
+
+	now := time.Now()
+	tests := []struct {
+		name string
+		req  dto.CreatePromotionRequest
+	}{
+		{"percentage over 100", dto.CreatePromotionRequest{Code: "A", Kind: model.PromotionPercentage, Value: decimal.NewFromInt(101)}},
+		{"fixed without amount", dto.CreatePromotionRequest{Code: "B", Kind: model.PromotionFixed}},
+		{"buy-x-get-y without quantities", dto.CreatePromotionRequest{Code: "C", Kind: model.PromotionBuyXGetY, BuyQuantity: 2}},
+		{"window ends before it starts", dto.CreatePromotionRequest{Code: "D", Kind: model.PromotionFreeShipping, StartsAt: &now, EndsAt: ptr(now.Add(-time.Hour))}},
+		{"blank code", dto.CreatePromotionRequest{Code: "  ", Kind: model.PromotionFreeShipping}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.Create(ctx, tt.req)
+			assert.ErrorIs(t, err, ErrInvalidPromotion)
+		})
+	}
+}
+
+// newCouponFixture returns a cart service and an order service sharing their
+// repositories and flat shipping, with a 20.00 product in the "kitchen"
+// category.
+func newCouponFixture(t *testing.T) (*CartService, *OrderService, *mockPromotionRepo, *mockCartRepo, uuid.UUID) {
+	t.Helper()
+	cartRepo, productRepo, promotions := newMockCartRepo(), newMockProductRepo(), newMockPromotionRepo()
+	cartRepo.products = productRepo
+	orders := newMockOrderRepo()
+	orders.promotions = promotions
+	pid := newActiveProduct(productRepo, 100)
+	productRepo.products[pid].Price, productRepo.products[pid].Category = decimal.NewFromInt(20), "kitchen"
+	shipper := newFlatShipping()
+	return NewCartService(cartRepo, productRepo, promotions, shipper, nil, newTestPrices(), time.Hour, ""),
+		NewOrderService(orders, cartRepo, productRepo, promotions, newMockAddressRepo(), shipper, newTaxTable(false), newTestPrices(), nil),
+		promotions, cartRepo, pid
+}
+
+func TestCartService_ApplyCoupon(t *testing.T) {
+	carts, _, promotions, cartRepo, pid := newCouponFixture(t)
+	ctx := context.Background()
+	user := CartRef{UserID: uuid.New()}
+	require.NoError(t, promotions.Create(ctx, &model.Promotion{
+		Code: "KITCHEN25", Kind: model.PromotionPercentage, Value: decimal.NewFromInt(25),
+		Categories: []string{"kitchen"}, MinSubtotal: decimal.NewFromInt(30), Active: true,
+	}))
+
+	_, err := carts.ApplyCoupon(ctx, user, "nope")
+	var ce *CouponError
+	require.ErrorAs(t, err, &ce)
+	assert.Equal(t, CouponNotFound, ce.Code)
+
+	// One item is below the minimum spend, so the code is refused.
+	_, err = carts.AddItem(ctx, user, pid, 1)
+	require.NoError(t, err)
+	_, err = carts.ApplyCoupon(ctx, user, "kitchen25")
+	require.ErrorAs(t, err, &ce)
+	assert.Equal(t, CouponMinSubtotal, ce.Code)
+	assert.True(t, decimal.NewFromInt(30).Equal(*ce.MinSubtotal))
+
+	_, err = carts.AddItem(ctx, user, pid, 1)
+	require.NoError(t, err)
+	cart, err := carts.ApplyCoupon(ctx, user, "kitchen25")
+	require.NoError(t, err)
+	require.NotNil(t, cart.Coupon)
+	assert.True(t, cart.Coupon.Applied)
+	assert.True(t, decimal.NewFromInt(10).Equal(cart.Discount), cart.Discount.String())
+	assert.True(t, decimal.NewFromInt(30).Equal(cart.Total), cart.Total.String())
+
+	// Dropping below the minimum keeps the coupon on the cart, unapplied.
+	for id := range cartRepo.items {
+		require.NoError(t, carts.UpdateItem(ctx, user, id, 1))
+	}
+	cart, err = carts.GetCart(ctx, user)
+	require.NoError(t, err)
+	require.NotNil(t, cart.Coupon)
+	assert.False(t, cart.Coupon.Applied)
+	assert.Equal(t, CouponMinSubtotal, cart.Coupon.Reason)
+	assert.True(t, cart.Discount.IsZero())
+	assert.True(t, decimal.NewFromInt(20).Equal(cart.Total))
+
+	cart, err = carts.RemoveCoupon(ctx, user)
+	require.NoError(t, err)
+	assert.Nil(t, cart.Coupon)
+}
+
+func TestOrderService_CreateOrder_Coupon(t *testing.T) {
+	carts, orders, promotions, _, pid := newCouponFixture(t)
+	ctx := context.Background()
+	p := &model.Promotion{
+		Code: "ONCE", Kind: model.PromotionFixed, Value: decimal.NewFromInt(5),
+		MaxUsesPerCustomer: ptr(1), Active: true,
+	}
+	require.NoError(t, promotions.Create(ctx, p))
+	userID := uuid.New()
+	user := CartRef{UserID: userID}
+
+	_, err := carts.AddItem(ctx, user, pid, 2)
+	require.NoError(t, err)
+	_, err = carts.ApplyCoupon(ctx, user, "once")
+	require.NoError(t, err)
+
+	order, err := orders.CreateOrder(ctx, userID, testOrderRequest())
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(40).Equal(order.Subtotal))
+	assert.True(t, decimal.NewFromInt(5).Equal(order.Discount))
+	assert.True(t, decimal.RequireFromString("4.90").Equal(order.ShippingCost))
+	assert.True(t, decimal.RequireFromString("39.90").Equal(order.TotalPrice))
+	assert.Equal(t, "ONCE", order.PromotionCode)
+	assert.Equal(t, []uuid.UUID{userID}, promotions.redemptions[p.ID])
+
+	// The coupon is spent: it left the cart and cannot be applied again.
+	cart, err := carts.GetCart(ctx, user)
+	require.NoError(t, err)
+	assert.Nil(t, cart.Coupon)
+	_, err = carts.AddItem(ctx, user, pid, 1)
+	require.NoError(t, err)
+	_, err = carts.ApplyCoupon(ctx, user, "ONCE")
+	var ce *CouponError
+	require.ErrorAs(t, err, &ce)
+	assert.Equal(t, CouponUsedUp, ce.Code)
+
+	// Deactivating a coupon already on a cart stops the order going through.
+	p.MaxUsesPerCustomer = nil
+	_, err = carts.ApplyCoupon(ctx, user, "ONCE")
+	require.NoError(t, err)
+	p.Active = false
+	_, err = orders.CreateOrder(ctx, userID, testOrderRequest())
+	require.ErrorAs(t, err, &ce)
+	assert.Equal(t, CouponNotFound, ce.Code)
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+	"github.com/flicky/go-ecommerce-api/internal/shipping"
+)
+
+var (
+	ErrShippingZoneNotFound = errors.New("shipping zone not found")
+	ErrShippingRateNotFound = errors.New("shipping rate not found")
+	ErrDuplicateZoneName    = errors.New("shipping zone name already exists")
+	// ErrZoneCountryTaken means a country is already in another zone.
+	ErrZoneCountryTaken    = errors.New("country already belongs to a shipping zone")
+	ErrInvalidShippingRate = errors.New("invalid shipping rate")
+	// ErrShippingMethodUnavailable means no provider can ship the order
+	// with the method asked for.
+	ErrShippingMethodUnavailable = errors.New("shipping method not available")
+)
+
+// ShippingService manages the shipping rate table.
+type ShippingService struct {
+	repo repository.ShippingRepository
+}
+
+func NewShippingService(repo repository.ShippingRepository) *ShippingService {
+	return &ShippingService{repo: repo}
+}
+
+// CreateZone adds a zone. Country codes are upper-cased, and a country
+// already in another zone is rejected.
+func (s *ShippingService) CreateZone(ctx context.Context, req dto.CreateShippingZoneRequest) (*dto.ShippingZoneResponse, error) {
+	z := &model.ShippingZone{Name: strings.TrimSpace(req.Name)}
+	for _, c := range req.Countries {
+		if c = strings.ToUpper(c); !slices.Contains(z.Countries, c) {
+			z.Countries = append(z.Countries, c)
+		}
+	}
+	zones, err := s.repo.ListZones(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list shipping zones: %w", err)
+	}
+	for _, other := range zones {
+		for _, c := range z.Countries {
+			if slices.Contains(other.Countries, c) {
+				return nil, fmt.Errorf("%w: %s is in %s", ErrZoneCountryTaken, c, other.Name)
+			}
+		}
+	}
+	if err := s.repo.CreateZone(ctx, z); err != nil {
+		if errors.Is(err, repository.ErrDuplicateZoneName) {
+			return nil, ErrDuplicateZoneName
+		}
+		return nil, fmt.Errorf("create shipping zone: %w", err)
+	}
+	resp := toShippingZoneResponse(z)
+	return &resp, nil
+}
+
+func (s *ShippingService) ListZones(ctx context.Context) (*dto.ShippingZoneListResponse, error) {
+	zones, err := s.repo.ListZones(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list shipping zones: %w", err)
+	}
+	resp := &dto.ShippingZoneListResponse{Zones: make([]dto.ShippingZoneResponse, len(zones))}
+	for i := range zones {
+		resp.Zones[i] = toShippingZoneResponse(&zones[i])
+	}
+	return resp, nil
+}
+
+// DeleteZone removes a zone and its rates.
+func (s *ShippingService) DeleteZone(ctx context.Context, id uuid.UUID) error {
+	if err := s.repo.DeleteZone(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrShippingZoneNotFound
+		}
+		return fmt.Errorf("delete shipping zone: %w", err)
+	}
+	return nil
+}
+
+func (s *ShippingService) CreateRate(ctx context.Context, req dto.CreateShippingRateRequest) (*dto.ShippingRateResponse, error) {
+	r := &model.ShippingRate{
+		Method: req.Method, ZoneID: req.ZoneID, MinWeightGrams: req.MinWeightGrams, MaxWeightGrams: req.MaxWeightGrams,
+		MinSubtotal: req.MinSubtotal, MaxSubtotal: req.MaxSubtotal, Price: req.Price, PricePerKg: req.PricePerKg,
+	}
+	if err := validateShippingRate(r); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateRate(ctx, r); err != nil {
+		if errors.Is(err, repository.ErrZoneNotFound) {
+			return nil, ErrShippingZoneNotFound
+		}
+		return nil, fmt.Errorf("create shipping rate: %w", err)
+	}
+	resp := toShippingRateResponse(r)
+	return &resp, nil
+}
+
+func validateShippingRate(r *model.ShippingRate) error {
+	switch {
+	case r.Price.IsNegative() || r.PricePerKg.IsNegative():
+		return fmt.Errorf("%w: prices cannot be negative", ErrInvalidShippingRate)
+	case r.MinSubtotal.IsNegative():
+		return fmt.Errorf("%w: min_subtotal cannot be negative", ErrInvalidShippingRate)
+	case r.MaxWeightGrams != nil && *r.MaxWeightGrams <= r.MinWeightGrams:
+		return fmt.Errorf("%w: max_weight_grams must be above min_weight_grams", ErrInvalidShippingRate)
+	case r.MaxSubtotal != nil && !r.MaxSubtotal.GreaterThan(r.MinSubtotal):
+		return fmt.Errorf("%w: max_subtotal must be above min_subtotal", ErrInvalidShippingRate)
+	}
+	return nil
+}
+
+func (s *ShippingService) ListRates(ctx context.Context) (*dto.ShippingRateListResponse, error) {
+	rates, err := s.repo.ListRates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list shipping rates: %w", err)
+	}
+	resp := &dto.ShippingRateListResponse{Rates: make([]dto.ShippingRateResponse, len(rates))}
+	for i := range rates {
+		resp.Rates[i] = toShippingRateResponse(&rates[i])
+	}
+	return resp, nil
+}
+
+func (s *ShippingService) DeleteRate(ctx context.Context, id uuid.UUID) error {
+	if err := s.repo.DeleteRate(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrShippingRateNotFound
+		}
+		return fmt.Errorf("delete shipping rate: %w", err)
+	}
+	return nil
+}
+
+// quoteShipping quotes the shipment with provider, making standard shipping
+// free when freeShipping is set.
+func quoteShipping(ctx context.Context, provider shipping.Provider, shipment shipping.Shipment, freeShipping bool) ([]shipping.Quote, error) {
+	quotes, err := provider.Quote(ctx, shipment)
+	if err != nil {
+		return nil, fmt.Errorf("quote shipping: %w", err)
+	}
+	if freeShipping {
+		quotes = shipping.FreeStandard(quotes)
+	}
+	return quotes, nil
+}
+
+func toShippingZoneResponse(z *model.ShippingZone) dto.ShippingZoneResponse {
+	return dto.ShippingZoneResponse{ID: z.ID, Name: z.Name, Countries: z.Countries, CreatedAt: z.CreatedAt}
+}
+
+func toShippingRateResponse(r *model.ShippingRate) dto.ShippingRateResponse {
+	return dto.ShippingRateResponse{
+		ID: r.ID, Method: r.Method, ZoneID: r.ZoneID, MinWeightGrams: r.MinWeightGrams, MaxWeightGrams: r.MaxWeightGrams,
+		MinSubtotal: r.MinSubtotal, MaxSubtotal: r.MaxSubtotal, Price: r.Price, PricePerKg: r.PricePerKg,
+		CreatedAt: r.CreatedAt,
+	}
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+	"github.com/flicky/go-ecommerce-api/internal/shipping"
+)
+
+type mockShippingRepo struct {
+	zones map[uuid.UUID]*model.ShippingZone
+	rates map[uuid.UUID]*model.ShippingRate
+}
+
+func newMockShippingRepo() *mockShippingRepo {
+	return &mockShippingRepo{zones: make(map[uuid.UUID]*model.ShippingZone), rates: make(map[uuid.UUID]*model.ShippingRate)}
+}
+
+func (m *mockShippingRepo) CreateZone(_ context.Context, z *model.ShippingZone) error {
+	for _, existing := range m.zones {
+		if existing.Name == z.Name {
+			return repository.ErrDuplicateZoneName
+		}
+	}
+	z.ID, z.CreatedAt = uuid.New(), time.Now()
+	m.zones[z.ID] = z
+	return nil
+}
+
+func (m *mockShippingRepo) ListZones(_ context.Context) ([]model.ShippingZone, error) {
+	var out []model.ShippingZone
+	for _, z := range m.zones {
+		out = append(out, *z)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (m *mockShippingRepo) DeleteZone(_ context.Context, id uuid.UUID) error {
+	if _, ok := m.zones[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(m.zones, id)
+	for rid, r := range m.rates {
+		if r.ZoneID != nil && *r.ZoneID == id {
+			delete(m.rates, rid)
+		}
+	}
+	return nil
+}
+
+func (m *mockShippingRepo) CreateRate(_ context.Context, r *model.ShippingRate) error {
+	if r.ZoneID != nil {
+		if _, ok := m.zones[*r.ZoneID]; !ok {
+			return repository.ErrZoneNotFound
+		}
+	}
+	r.ID, r.CreatedAt = uuid.New(), time.Now()
+	m.rates[r.ID] = r
+	return nil
+}
+
+func (m *mockShippingRepo) ListRates(_ context.Context) ([]model.ShippingRate, error) {
+	var out []model.ShippingRate
+	for _, r := range m.rates {
+		out = append(out, *r)
+	}
+	return out, nil
+}
+
+func (m *mockShippingRepo) DeleteRate(_ context.Context, id uuid.UUID) error {
+	if _, ok := m.rates[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(m.rates, id)
+	return nil
+}
+
+func (m *mockShippingRepo) RatesFor(_ context.Context, country string) ([]model.ShippingRate, error) {
+	var out []model.ShippingRate
+	for _, r := range m.rates {
+		if r.ZoneID == nil || slices.Contains(m.zones[*r.ZoneID].Countries, country) {
+			out = append(out, *r)
+		}
+	}
+	return out, nil
+}
+
+// newFlatShipping quotes 4.90 standard, 9.90 express and free pickup to
+// anywhere.
+func newFlatShipping() *shipping.Fake {
+	return &shipping.Fake{Quotes: []shipping.Quote{
+		{Method: model.ShippingStandard, Cost: decimal.RequireFromString("4.90"), Provider: "fake"},
+		{Method: model.ShippingExpress, Cost: decimal.RequireFromString("9.90"), Provider: "fake"},
+		{Method: model.ShippingPickup, Cost: decimal.Zero, Provider: "fake"},
+	}}
+}
+
+func TestShippingService_RateTable(t *testing.T) {
+	repo := newMockShippingRepo()
+	svc := NewShippingService(repo)
+	ctx := context.Background()
+
+	eu, err := svc.CreateZone(ctx, dto.CreateShippingZoneRequest{Name: "EU", Countries: []string{"de", "FR", "DE"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DE", "FR"}, eu.Countries)
+	_, err = svc.CreateZone(ctx, dto.CreateShippingZoneRequest{Name: "DACH", Countries: []string{"AT", "de"}})
+	assert.ErrorIs(t, err, ErrZoneCountryTaken)
+	_, err = svc.CreateZone(ctx, dto.CreateShippingZoneRequest{Name: "EU", Countries: []string{"IT"}})
+	assert.ErrorIs(t, err, ErrDuplicateZoneName)
+
+	for _, req := range []dto.CreateShippingRateRequest{
+		{Method: model.ShippingStandard, Price: decimal.NewFromInt(-1)},
+		{Method: model.ShippingStandard, MinWeightGrams: 1000, MaxWeightGrams: ptr(1000)},
+		{Method: model.ShippingStandard, MinSubtotal: decimal.NewFromInt(50), MaxSubtotal: ptr(decimal.NewFromInt(10))},
+	} {
+		_, err := svc.CreateRate(ctx, req)
+		assert.ErrorIs(t, err, ErrInvalidShippingRate)
+	}
+	missing := uuid.New()
+	_, err = svc.CreateRate(ctx, dto.CreateShippingRateRequest{Method: model.ShippingStandard, ZoneID: &missing})
+	assert.ErrorIs(t, err, ErrShippingZoneNotFound)
+
+	// Germany gets the EU rate and the worldwide pickup; Japan only pickup.
+	_, err = svc.CreateRate(ctx, dto.CreateShippingRateRequest{
+		Method: model.ShippingStandard, ZoneID: &eu.ID, Price: decimal.RequireFromString("3.00"), PricePerKg: decimal.NewFromInt(1),
+	})
+	require.NoError(t, err)
+	_, err = svc.CreateRate(ctx, dto.CreateShippingRateRequest{Method: model.ShippingPickup})
+	require.NoError(t, err)
+	table := shipping.NewTable(repo)
+	quotes, err := table.Quote(ctx, shipping.Shipment{Country: "DE", WeightGrams: 1500})
+	require.NoError(t, err)
+	require.Len(t, quotes, 2)
+	assert.Equal(t, "5.00", quotes[0].Cost.StringFixed(2))
+	quotes, err = table.Quote(ctx, shipping.Shipment{Country: "JP", WeightGrams: 1500})
+	require.NoError(t, err)
+	require.Len(t, quotes, 1)
+	assert.Equal(t, model.ShippingPickup, quotes[0].Method)
+
+	// Deleting the zone takes its rates with it.
+	require.NoError(t, svc.DeleteZone(ctx, eu.ID))
+	rates, err := svc.ListRates(ctx)
+	require.NoError(t, err)
+	assert.Len(t, rates.Rates, 1)
+	assert.ErrorIs(t, svc.DeleteZone(ctx, eu.ID), ErrShippingZoneNotFound)
+}
+
+func TestCartService_ShippingQuotes(t *testing.T) {
+	carts, _, promotions, _, pid := newCouponFixture(t)
+	ctx := context.Background()
+	user := CartRef{UserID: uuid.New()}
+
+	_, err := carts.ShippingQuotes(ctx, CartRef{}, "DE")
+	assert.ErrorIs(t, err, ErrEmptyCart)
+
+	_, err = carts.AddItem(ctx, user, pid, 3)
+	require.NoError(t, err)
+	quotes, err := carts.ShippingQuotes(ctx, user, "de")
+	require.NoError(t, err)
+	assert.Equal(t, "DE", quotes.Country)
+	require.Len(t, quotes.Quotes, 3)
+	assert.Equal(t, "4.90", quotes.Quotes[0].Cost.StringFixed(2))
+
+	// A free-shipping coupon makes standard free, and only standard.
+	require.NoError(t, promotions.Create(ctx, &model.Promotion{Code: "SHIPFREE", Kind: model.PromotionFreeShipping, Active: true}))
+	_, err = carts.ApplyCoupon(ctx, user, "shipfree")
+	require.NoError(t, err)
+	quotes, err = carts.ShippingQuotes(ctx, user, "DE")
+	require.NoError(t, err)
+	assert.True(t, quotes.Quotes[0].Cost.IsZero())
+	assert.Equal(t, "9.90", quotes.Quotes[1].Cost.StringFixed(2))
+}
+
+func TestOrderService_CreateOrder_Shipping(t *testing.T) {
+	cartRepo, productRepo := newMockCartRepo(), newMockProductRepo()
+	cartRepo.products = productRepo
+	pid := newActiveProduct(productRepo, 100)
+	productRepo.products[pid].Price, productRepo.products[pid].WeightGrams = decimal.NewFromInt(10), 400
+	shipper := newFlatShipping()
+	ctx := context.Background()
+	userID := uuid.New()
+	carts := NewCartService(cartRepo, productRepo, newMockPromotionRepo(), shipper, nil, newTestPrices(), time.Hour, "")
+	orders := NewOrderService(newMockOrderRepo(), cartRepo, productRepo, newMockPromotionRepo(), newMockAddressRepo(), shipper, newTaxTable(false), newTestPrices(), nil)
+	checkout := func(method string) (*model.Order, error) {
+		t.Helper()
+		_, err := carts.AddItem(ctx, CartRef{UserID: userID}, pid, 3)
+		require.NoError(t, err)
+		req := testOrderRequest()
+		req.ShippingMethod = method
+		return orders.CreateOrder(ctx, userID, req)
+	}
+
+	order, err := checkout("")
+	require.NoError(t, err)
+	assert.Equal(t, model.ShippingStandard, order.ShippingMethod)
+	assert.Equal(t, "34.90", order.TotalPrice.StringFixed(2))
+	shipment := shipper.Shipments[len(shipper.Shipments)-1]
+	assert.Equal(t, "DE", shipment.Country)
+	assert.Equal(t, 1200, shipment.WeightGrams)
+	assert.Equal(t, "30.00", shipment.Subtotal.StringFixed(2))
+
+	order, err = checkout(model.ShippingExpress)
+	require.NoError(t, err)
+	assert.Equal(t, "9.90", order.ShippingCost.StringFixed(2))
+	assert.Equal(t, "39.90", order.TotalPrice.StringFixed(2))
+
+	// Methods nobody quotes are refused, and so is checkout when every
+	// provider is down.
+	shipper.Quotes = shipper.Quotes[:1]
+	_, err = checkout(model.ShippingExpress)
+	assert.ErrorIs(t, err, ErrShippingMethodUnavailable)
+	shipper.Err = errors.New("carrier down")
+	_, err = checkout("")
+	assert.ErrorContains(t, err, "carrier down")
+}
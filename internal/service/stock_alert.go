@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+var (
+	ErrProductInStock        = errors.New("product is in stock")
+	ErrSubscriptionNotFound  = errors.New("subscription not found")
+	ErrUnknownStockEventType = errors.New("unknown stock event type")
+)
+
+// StockAlertService raises low-stock and back-in-stock events when stock
+// changes and turns them into notifications in the worker.
+type StockAlertService struct {
+	repo          repository.StockAlertRepository
+	notifications repository.NotificationRepository
+	productRepo   repository.ProductRepository
+	amqpCh        *amqp.Channel
+}
+
+func NewStockAlertService(repo repository.StockAlertRepository, notifications repository.NotificationRepository, productRepo repository.ProductRepository, amqpCh *amqp.Channel) *StockAlertService {
+	return &StockAlertService{repo: repo, notifications: notifications, productRepo: productRepo, amqpCh: amqpCh}
+}
+
+// SetThreshold sets the stock level at or below which admins are alerted;
+// a nil threshold turns alerts off.
+func (s *StockAlertService) SetThreshold(ctx context.Context, productID uuid.UUID, req dto.ReorderThresholdRequest) (*dto.ReorderThresholdResponse, error) {
+	if err := s.repo.SetThreshold(ctx, productID, req.Threshold); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, fmt.Errorf("set reorder threshold: %w", err)
+	}
+	return &dto.ReorderThresholdResponse{ProductID: productID, Threshold: req.Threshold}, nil
+}
+
+// Subscribe asks for a back-in-stock notification. Only sold-out products
+// on sale can be subscribed to.
+func (s *StockAlertService) Subscribe(ctx context.Context, userID, productID uuid.UUID) (*dto.StockSubscriptionResponse, error) {
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("get product: %w", err)
+	}
+	if product == nil || product.Status != model.ProductStatusActive {
+		return nil, ErrProductNotFound
+	}
+	if product.Stock > 0 {
+		return nil, ErrProductInStock
+	}
+	sub := &model.StockSubscription{ProductID: productID, UserID: userID}
+	if err := s.repo.Subscribe(ctx, sub); err != nil {
+		return nil, fmt.Errorf("subscribe: %w", err)
+	}
+	return &dto.StockSubscriptionResponse{ID: sub.ID, ProductID: sub.ProductID, CreatedAt: sub.CreatedAt}, nil
+}
+
+func (s *StockAlertService) Unsubscribe(ctx context.Context, userID, productID uuid.UUID) error {
+	if err := s.repo.Unsubscribe(ctx, productID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrSubscriptionNotFound
+		}
+		return fmt.Errorf("unsubscribe: %w", err)
+	}
+	return nil
+}
+
+// Observe publishes the stock events caused by movements that were just
+// booked. It is safe to call on a nil service, which does nothing.
+func (s *StockAlertService) Observe(ctx context.Context, movements []model.InventoryMovement) error {
+	if s == nil || len(movements) == 0 {
+		return nil
+	}
+	var productIDs []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	for _, m := range movements {
+		if !seen[m.ProductID] {
+			seen[m.ProductID] = true
+			productIDs = append(productIDs, m.ProductID)
+		}
+	}
+	thresholds, err := s.repo.Thresholds(ctx, productIDs)
+	if err != nil {
+		return fmt.Errorf("get reorder thresholds: %w", err)
+	}
+	for _, e := range stockEvents(movements, thresholds) {
+		if err := s.publish(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *StockAlertService) publish(ctx context.Context, e model.StockEvent) error {
+	if s.amqpCh == nil {
+		return nil
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal stock event: %w", err)
+	}
+	err = s.amqpCh.PublishWithContext(ctx, "product_events", e.Type, false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		Body:         body,
+		DeliveryMode: amqp.Persistent,
+	})
+	if err != nil {
+		return fmt.Errorf("publish stock event: %w", err)
+	}
+	return nil
+}
+
+// stockEvents finds the low-stock and back-in-stock crossings in movements.
+func stockEvents(movements []model.InventoryMovement, thresholds map[uuid.UUID]int) []model.StockEvent {
+	type span struct{ before, after int }
+	var order []uuid.UUID
+	spans := make(map[uuid.UUID]*span)
+	for _, m := range movements {
+		sp, ok := spans[m.ProductID]
+		if !ok {
+			sp = &span{before: m.StockAfter - m.Quantity}
+			spans[m.ProductID] = sp
+			order = append(order, m.ProductID)
+		}
+		sp.after = m.StockAfter
+	}
+
+	var events []model.StockEvent
+	for _, id := range order {
+		sp := spans[id]
+		if t, ok := thresholds[id]; ok && sp.before > t && sp.after <= t {
+			events = append(events, model.StockEvent{Type: model.EventLowStock, ProductID: id, Stock: sp.after, Threshold: t})
+		}
+		if sp.before <= 0 && sp.after > 0 {
+			events = append(events, model.StockEvent{Type: model.EventBackInStock, ProductID: id, Stock: sp.after})
+		}
+	}
+	return events
+}
+
+// HandleStockEvent notifies admins or the product's subscribers.
+func (s *StockAlertService) HandleStockEvent(ctx context.Context, e model.StockEvent) error {
+	product, err := s.productRepo.GetByID(ctx, e.ProductID)
+	if err != nil {
+		return fmt.Errorf("get product: %w", err)
+	}
+	if product == nil {
+		return nil
+	}
+	n := &model.Notification{ProductID: &product.ID}
+	switch e.Type {
+	case model.EventLowStock:
+		n.Kind = model.NotificationLowStock
+		n.Message = fmt.Sprintf("%s is running low: %d left (reorder threshold %d)", product.Name, e.Stock, e.Threshold)
+		_, err = s.notifications.NotifyRole(ctx, "admin", n)
+	case model.EventBackInStock:
+		if product.Status != model.ProductStatusActive {
+			return nil
+		}
+		n.Kind = model.NotificationBackInStock
+		n.Message = product.Name + " is back in stock"
+		_, err = s.notifications.NotifySubscribers(ctx, n)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownStockEventType, e.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("send %s notifications: %w", n.Kind, err)
+	}
+	return nil
+}
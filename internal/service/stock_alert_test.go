@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/pagination"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+type mockStockAlertRepo struct {
+	products   *mockProductRepo
+	thresholds map[uuid.UUID]int
+	subs       []model.StockSubscription
+}
+
+func (m *mockStockAlertRepo) SetThreshold(_ context.Context, productID uuid.UUID, threshold *int) error {
+	if _, ok := m.products.products[productID]; !ok {
+		return repository.ErrNotFound
+	}
+	if threshold == nil {
+		delete(m.thresholds, productID)
+	} else {
+		m.thresholds[productID] = *threshold
+	}
+	return nil
+}
+
+func (m *mockStockAlertRepo) Thresholds(_ context.Context, productIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	out := make(map[uuid.UUID]int)
+	for _, id := range productIDs {
+		if t, ok := m.thresholds[id]; ok {
+			out[id] = t
+		}
+	}
+	return out, nil
+}
+
+func (m *mockStockAlertRepo) Subscribe(_ context.Context, sub *model.StockSubscription) error {
+	for _, s := range m.subs {
+		if s.ProductID == sub.ProductID && s.UserID == sub.UserID && s.NotifiedAt == nil {
+			sub.ID, sub.CreatedAt = s.ID, s.CreatedAt
+			return nil
+		}
+	}
+	sub.ID, sub.CreatedAt = uuid.New(), time.Now()
+	m.subs = append(m.subs, *sub)
+	return nil
+}
+
+func (m *mockStockAlertRepo) Unsubscribe(_ context.Context, productID, userID uuid.UUID) error {
+	for i, s := range m.subs {
+		if s.ProductID == productID && s.UserID == userID && s.NotifiedAt == nil {
+			m.subs = append(m.subs[:i], m.subs[i+1:]...)
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+
+// mockNotificationRepo sends role notifications to the users in roles and
+// subscriber notifications through the stock alert mock's subscriptions.
+type mockNotificationRepo struct {
+	alerts *mockStockAlertRepo
+	roles  map[string][]uuid.UUID
+	sent   []model.Notification
+}
+
+func (m *mockNotificationRepo) send(userID uuid.UUID, n *model.Notification) {
+	c := *n
+	c.ID, c.UserID, c.CreatedAt = uuid.New(), userID, time.Now()
+	m.sent = append(m.sent, c)
+}
+
+func (m *mockNotificationRepo) NotifyRole(_ context.Context, role string, n *model.Notification) (int, error) {
+	for _, id := range m.roles[role] {
+		m.send(id, n)
+	}
+	return len(m.roles[role]), nil
+}
+
+func (m *mockNotificationRepo) NotifySubscribers(_ context.Context, n *model.Notification) (int, error) {
+	count := 0
+	now := time.Now()
+	for i := range m.alerts.subs {
+		s := &m.alerts.subs[i]
+		if s.ProductID == *n.ProductID && s.NotifiedAt == nil {
+			s.NotifiedAt = &now
+			m.send(s.UserID, n)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *mockNotificationRepo) ListByUserID(_ context.Context, userID uuid.UUID, params pagination.Params) ([]model.Notification, pagination.Page, error) {
+	var all []model.Notification
+	for _, n := range m.sent {
+		if n.UserID == userID {
+			all = append(all, n)
+		}
+	}
+	sortNewestFirst(all, notificationKey)
+	items, page := pagination.Build(afterCursor(all, params, notificationKey), params, notificationKey)
+	return items, page, nil
+}
+
+func (m *mockNotificationRepo) MarkRead(_ context.Context, id, userID uuid.UUID) error {
+	for i := range m.sent {
+		if m.sent[i].ID == id && m.sent[i].UserID == userID {
+			now := time.Now()
+			m.sent[i].ReadAt = &now
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+
+func notificationKey(n model.Notification) (time.Time, uuid.UUID) { return n.CreatedAt, n.ID }
+
+func newStockAlertFixture(t *testing.T, stock int) (*StockAlertService, *mockStockAlertRepo, *mockNotificationRepo, *model.Product) {
+	t.Helper()
+	products := newMockProductRepo()
+	p := &model.Product{Name: "Mug", Price: decimal.NewFromInt(10), Stock: stock, Status: model.ProductStatusActive}
+	require.NoError(t, products.Create(context.Background(), p, nil))
+	alerts := &mockStockAlertRepo{products: products, thresholds: make(map[uuid.UUID]int)}
+	notifications := &mockNotificationRepo{alerts: alerts, roles: make(map[string][]uuid.UUID)}
+	return NewStockAlertService(alerts, notifications, products, nil), alerts, notifications, p
+}
+
+func TestStockEvents(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	tests := []struct {
+		name      string
+		movements []model.InventoryMovement
+		want      []model.StockEvent
+	}{
+		{
+			name:      "sale crosses threshold",
+			movements: []model.InventoryMovement{{ProductID: a, Quantity: -3, StockAfter: 4}},
+			want:      []model.StockEvent{{Type: model.EventLowStock, ProductID: a, Stock: 4, Threshold: 5}},
+		},
+		{
+			name:      "already below threshold",
+			movements: []model.InventoryMovement{{ProductID: a, Quantity: -1, StockAfter: 3}},
+		},
+		{
+			name: "split sale of one product counts once",
+			movements: []model.InventoryMovement{
+				{ProductID: a, Quantity: -2, StockAfter: 6},
+				{ProductID: a, Quantity: -2, StockAfter: 4},
+			},
+			want: []model.StockEvent{{Type: model.EventLowStock, ProductID: a, Stock: 4, Threshold: 5}},
+		},
+		{
+			name:      "no threshold",
+			movements: []model.InventoryMovement{{ProductID: b, Quantity: -9, StockAfter: 1}},
+		},
+		{
+			name:      "restock from zero",
+			movements: []model.InventoryMovement{{ProductID: b, Quantity: 10, StockAfter: 10}},
+			want:      []model.StockEvent{{Type: model.EventBackInStock, ProductID: b, Stock: 10}},
+		},
+		{
+			name:      "restock of a product in stock",
+			movements: []model.InventoryMovement{{ProductID: b, Quantity: 10, StockAfter: 12}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, stockEvents(tt.movements, map[uuid.UUID]int{a: 5}))
+		})
+	}
+}
+
+func TestStockAlertService_Subscribe(t *testing.T) {
+	svc, alerts, _, p := newStockAlertFixture(t, 0)
+	ctx := context.Background()
+	user := uuid.New()
+
+	first, err := svc.Subscribe(ctx, user, p.ID)
+	require.NoError(t, err)
+	again, err := svc.Subscribe(ctx, user, p.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, again.ID)
+	assert.Len(t, alerts.subs, 1)
+
+	require.NoError(t, svc.Unsubscribe(ctx, user, p.ID))
+	assert.ErrorIs(t, svc.Unsubscribe(ctx, user, p.ID), ErrSubscriptionNotFound)
+
+	_, err = svc.Subscribe(ctx, user, uuid.New())
+	assert.ErrorIs(t, err, ErrProductNotFound)
+
+	inStock, _, _, q := newStockAlertFixture(t, 3)
+	_, err = inStock.Subscribe(ctx, user, q.ID)
+	assert.ErrorIs(t, err, ErrProductInStock)
+}
+
+func TestStockAlertService_HandleStockEvent(t *testing.T) {
+	svc, alerts, notifications, p := newStockAlertFixture(t, 0)
+	ctx := context.Background()
+	admin, alice, bob := uuid.New(), uuid.New(), uuid.New()
+	notifications.roles["admin"] = []uuid.UUID{admin}
+
+	for _, user := range []uuid.UUID{alice, bob} {
+		_, err := svc.Subscribe(ctx, user, p.ID)
+		require.NoError(t, err)
+	}
+	back := model.StockEvent{Type: model.EventBackInStock, ProductID: p.ID, Stock: 10}
+	require.NoError(t, svc.HandleStockEvent(ctx, back))
+	require.Len(t, notifications.sent, 2)
+	assert.Equal(t, model.NotificationBackInStock, notifications.sent[0].Kind)
+	assert.Equal(t, "Mug is back in stock", notifications.sent[0].Message)
+	for _, s := range alerts.subs {
+		assert.NotNil(t, s.NotifiedAt)
+	}
+
+	// A redelivered event finds nobody left to notify.
+	require.NoError(t, svc.HandleStockEvent(ctx, back))
+	assert.Len(t, notifications.sent, 2)
+
+	require.NoError(t, svc.HandleStockEvent(ctx, model.StockEvent{Type: model.EventLowStock, ProductID: p.ID, Stock: 2, Threshold: 5}))
+	require.Len(t, notifications.sent, 3)
+	assert.Equal(t, admin, notifications.sent[2].UserID)
+	assert.Equal(t, "Mug is running low: 2 left (reorder threshold 5)", notifications.sent[2].Message)
+
+	err := svc.HandleStockEvent(ctx, model.StockEvent{Type: "product.renamed", ProductID: p.ID})
+	assert.ErrorIs(t, err, ErrUnknownStockEventType)
+}
+
+func TestStockAlertService_SetThreshold(t *testing.T) {
+	svc, alerts, _, p := newStockAlertFixture(t, 10)
+	ctx := context.Background()
+
+	resp, err := svc.SetThreshold(ctx, p.ID, dto.ReorderThresholdRequest{Threshold: ptr(5)})
+	require.NoError(t, err)
+	assert.Equal(t, ptr(5), resp.Threshold)
+	assert.Equal(t, 5, alerts.thresholds[p.ID])
+
+	_, err = svc.SetThreshold(ctx, p.ID, dto.ReorderThresholdRequest{})
+	require.NoError(t, err)
+	assert.NotContains(t, alerts.thresholds, p.ID)
+
+	_, err = svc.SetThreshold(ctx, uuid.New(), dto.ReorderThresholdRequest{Threshold: ptr(1)})
+	assert.ErrorIs(t, err, ErrProductNotFound)
+}
+
+func TestNotificationService(t *testing.T) {
+	_, _, notifications, p := newStockAlertFixture(t, 0)
+	svc := NewNotificationService(notifications)
+	ctx := context.Background()
+	user := uuid.New()
+	notifications.send(user, &model.Notification{Kind: model.NotificationBackInStock, ProductID: &p.ID, Message: "Mug is back in stock"})
+
+	resp, err := svc.List(ctx, user, pagination.Params{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, resp.Notifications, 1)
+	assert.Nil(t, resp.Notifications[0].ReadAt)
+
+	require.NoError(t, svc.MarkRead(ctx, user, resp.Notifications[0].ID))
+	resp, err = svc.List(ctx, user, pagination.Params{Limit: 10})
+	require.NoError(t, err)
+	assert.NotNil(t, resp.Notifications[0].ReadAt)
+
+	assert.ErrorIs(t, svc.MarkRead(ctx, uuid.New(), resp.Notifications[0].ID), ErrNotificationNotFound)
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+var (
+	ErrTaxRateNotFound  = errors.New("tax rate not found")
+	ErrDuplicateTaxRate = errors.New("tax rate already exists")
+	ErrInvalidTaxRate   = errors.New("invalid tax rate")
+)
+
+// TaxService manages the tax rate table.
+type TaxService struct {
+	repo repository.TaxRepository
+}
+
+func NewTaxService(repo repository.TaxRepository) *TaxService {
+	return &TaxService{repo: repo}
+}
+
+var maxTaxRate = decimal.NewFromInt(100)
+
+// CreateRate adds a rate. A country, region and tax class have one rate at
+// most.
+func (s *TaxService) CreateRate(ctx context.Context, req dto.CreateTaxRateRequest) (*dto.TaxRateResponse, error) {
+	r := &model.TaxRate{
+		Country: strings.ToUpper(req.Country), Region: strings.TrimSpace(req.Region),
+		TaxClass: strings.TrimSpace(req.TaxClass), Name: strings.TrimSpace(req.Name), Rate: req.Rate,
+	}
+	switch {
+	case r.TaxClass == "" || r.Name == "":
+		return nil, fmt.Errorf("%w: tax_class and name cannot be blank", ErrInvalidTaxRate)
+	case r.Rate.IsNegative() || !r.Rate.LessThan(maxTaxRate):
+		return nil, fmt.Errorf("%w: rate must be a percentage from 0 up to 100", ErrInvalidTaxRate)
+	}
+	if err := s.repo.CreateRate(ctx, r); err != nil {
+		if errors.Is(err, repository.ErrDuplicateTaxRate) {
+			return nil, ErrDuplicateTaxRate
+		}
+		return nil, fmt.Errorf("create tax rate: %w", err)
+	}
+	resp := toTaxRateResponse(r)
+	return &resp, nil
+}
+
+func (s *TaxService) ListRates(ctx context.Context) (*dto.TaxRateListResponse, error) {
+	rates, err := s.repo.ListRates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list tax rates: %w", err)
+	}
+	resp := &dto.TaxRateListResponse{Rates: make([]dto.TaxRateResponse, len(rates))}
+	for i := range rates {
+		resp.Rates[i] = toTaxRateResponse(&rates[i])
+	}
+	return resp, nil
+}
+
+func (s *TaxService) DeleteRate(ctx context.Context, id uuid.UUID) error {
+	if err := s.repo.DeleteRate(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrTaxRateNotFound
+		}
+		return fmt.Errorf("delete tax rate: %w", err)
+	}
+	return nil
+}
+
+func toTaxRateResponse(r *model.TaxRate) dto.TaxRateResponse {
+	return dto.TaxRateResponse{
+		ID: r.ID, Country: r.Country, Region: r.Region, TaxClass: r.TaxClass, Name: r.Name, Rate: r.Rate,
+		CreatedAt: r.CreatedAt,
+	}
+}
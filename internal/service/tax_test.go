@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+	"github.com/flicky/go-ecommerce-api/internal/tax"
+)
+
+type mockTaxRepo struct {
+	rates []model.TaxRate
+}
+
+func (m *mockTaxRepo) CreateRate(_ context.Context, r *model.TaxRate) error {
+	for _, existing := range m.rates {
+		if existing.Country == r.Country && existing.Region == r.Region && existing.TaxClass == r.TaxClass {
+			return repository.ErrDuplicateTaxRate
+		}
+	}
+	r.ID, r.CreatedAt = uuid.New(), time.Now()
+	m.rates = append(m.rates, *r)
+	return nil
+}
+
+func (m *mockTaxRepo) ListRates(_ context.Context) ([]model.TaxRate, error) {
+	return m.rates, nil
+}
+
+func (m *mockTaxRepo) DeleteRate(_ context.Context, id uuid.UUID) error {
+	for i, r := range m.rates {
+		if r.ID == id {
+			m.rates = append(m.rates[:i], m.rates[i+1:]...)
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+
+func (m *mockTaxRepo) TaxRatesFor(_ context.Context, country string) ([]model.TaxRate, error) {
+	var out []model.TaxRate
+	for _, r := range m.rates {
+		if r.Country == country {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// newTaxTable is a rate table holding rates; with none, nothing is taxed.
+func newTaxTable(inclusive bool, rates ...model.TaxRate) *tax.Table {
+	return tax.NewTable(&mockTaxRepo{rates: rates}, inclusive)
+}
+
+func TestTaxService_Rates(t *testing.T) {
+	svc := NewTaxService(&mockTaxRepo{})
+	ctx := context.Background()
+
+	rate, err := svc.CreateRate(ctx, dto.CreateTaxRateRequest{Country: "de", TaxClass: "standard", Name: " VAT ", Rate: decimal.NewFromInt(19)})
+	require.NoError(t, err)
+	assert.Equal(t, "DE", rate.Country)
+	assert.Equal(t, "VAT", rate.Name)
+
+	_, err = svc.CreateRate(ctx, dto.CreateTaxRateRequest{Country: "DE", TaxClass: "standard", Name: "VAT", Rate: decimal.NewFromInt(7)})
+	assert.ErrorIs(t, err, ErrDuplicateTaxRate)
+	_, err = svc.CreateRate(ctx, dto.CreateTaxRateRequest{Country: "DE", Region: "BY", TaxClass: "standard", Name: "VAT", Rate: decimal.NewFromInt(7)})
+	require.NoError(t, err)
+
+	for _, r := range []decimal.Decimal{decimal.NewFromInt(-1), decimal.NewFromInt(100)} {
+		_, err = svc.CreateRate(ctx, dto.CreateTaxRateRequest{Country: "FR", TaxClass: "standard", Name: "TVA", Rate: r})
+		assert.ErrorIs(t, err, ErrInvalidTaxRate)
+	}
+	_, err = svc.CreateRate(ctx, dto.CreateTaxRateRequest{Country: "FR", TaxClass: " ", Name: "TVA", Rate: decimal.NewFromInt(20)})
+	assert.ErrorIs(t, err, ErrInvalidTaxRate)
+
+	list, err := svc.ListRates(ctx)
+	require.NoError(t, err)
+	assert.Len(t, list.Rates, 2)
+	require.NoError(t, svc.DeleteRate(ctx, rate.ID))
+	assert.ErrorIs(t, svc.DeleteRate(ctx, rate.ID), ErrTaxRateNotFound)
+}
+
+func TestOrderService_CreateOrder_Tax(t *testing.T) {
+	vat := model.TaxRate{Country: "DE", TaxClass: model.TaxClassStandard, Name: "VAT", Rate: decimal.NewFromInt(19)}
+	reduced := model.TaxRate{Country: "DE", TaxClass: "reduced", Name: "VAT reduced", Rate: decimal.NewFromInt(7)}
+	bavaria := model.TaxRate{Country: "DE", Region: "BY", TaxClass: model.TaxClassStandard, Name: "VAT BY", Rate: decimal.NewFromInt(10)}
+	ctx := context.Background()
+	checkout := func(t *testing.T, taxes tax.Calculator, promotions *mockPromotionRepo, coupon, region string) *model.Order {
+		t.Helper()
+		cartRepo, productRepo := newMockCartRepo(), newMockProductRepo()
+		cartRepo.products = productRepo
+		book, shirt := newActiveProduct(productRepo, 10), newActiveProduct(productRepo, 10)
+		productRepo.products[book].Price, productRepo.products[book].TaxClass = decimal.NewFromInt(30), "reduced"
+		productRepo.products[shirt].Price, productRepo.products[shirt].TaxClass = decimal.NewFromInt(70), model.TaxClassStandard
+		userID := uuid.New()
+		carts := NewCartService(cartRepo, productRepo, promotions, nil, nil, newTestPrices(), time.Hour, "")
+		for _, id := range []uuid.UUID{book, shirt} {
+			_, err := carts.AddItem(ctx, CartRef{UserID: userID}, id, 1)
+			require.NoError(t, err)
+		}
+		if coupon != "" {
+			_, err := carts.ApplyCoupon(ctx, CartRef{UserID: userID}, coupon)
+			require.NoError(t, err)
+		}
+		req := testOrderRequest()
+		req.ShippingAddress.Region = region
+		order, err := NewOrderService(newMockOrderRepo(), cartRepo, productRepo, promotions, newMockAddressRepo(), newFlatShipping(), taxes, newTestPrices(), nil).CreateOrder(ctx, userID, req)
+		require.NoError(t, err)
+		return order
+	}
+	taxByName := func(o *model.Order) map[string]string {
+		out := make(map[string]string)
+		for _, item := range o.Items {
+			out[item.TaxName] = item.TaxAmount.StringFixed(2)
+		}
+		return out
+	}
+
+	t.Run("exclusive adds tax to the total", func(t *testing.T) {
+		order := checkout(t, newTaxTable(false, vat, reduced), newMockPromotionRepo(), "", "")
+		assert.Equal(t, map[string]string{"VAT": "13.30", "VAT reduced": "2.10"}, taxByName(order))
+		assert.Equal(t, "15.40", order.Tax.StringFixed(2))
+		assert.False(t, order.PricesIncludeTax)
+		assert.Equal(t, "120.30", order.TotalPrice.StringFixed(2))
+	})
+
+	t.Run("inclusive leaves the total alone", func(t *testing.T) {
+		order := checkout(t, newTaxTable(true, vat, reduced), newMockPromotionRepo(), "", "")
+		assert.Equal(t, map[string]string{"VAT": "11.18", "VAT reduced": "1.96"}, taxByName(order))
+		assert.True(t, order.PricesIncludeTax)
+		assert.Equal(t, "104.90", order.TotalPrice.StringFixed(2))
+	})
+
+	t.Run("tax is charged after the discount", func(t *testing.T) {
+		promotions := newMockPromotionRepo()
+		require.NoError(t, promotions.Create(ctx, &model.Promotion{Code: "TENOFF", Kind: model.PromotionPercentage, Value: decimal.NewFromInt(10), Active: true}))
+		order := checkout(t, newTaxTable(false, vat, reduced), promotions, "TENOFF", "")
+		assert.Equal(t, map[string]string{"VAT": "11.97", "VAT reduced": "1.89"}, taxByName(order))
+		assert.Equal(t, "108.76", order.TotalPrice.StringFixed(2))
+	})
+
+	t.Run("a region rate beats the country rate", func(t *testing.T) {
+		order := checkout(t, newTaxTable(false, vat, reduced, bavaria), newMockPromotionRepo(), "", "by")
+		assert.Equal(t, map[string]string{"VAT BY": "7.00", "VAT reduced": "2.10"}, taxByName(order))
+	})
+
+	t.Run("no rate means no tax", func(t *testing.T) {
+		order := checkout(t, newTaxTable(false), newMockPromotionRepo(), "", "")
+		assert.True(t, order.Tax.IsZero())
+		assert.Equal(t, "104.90", order.TotalPrice.StringFixed(2))
+	})
+}
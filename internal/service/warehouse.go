@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+var (
+	ErrWarehouseNotFound      = errors.New("warehouse not found")
+	ErrDuplicateWarehouseCode = errors.New("warehouse code already exists")
+)
+
+type WarehouseService struct {
+	repo        repository.WarehouseRepository
+	productRepo repository.ProductRepository
+}
+
+func NewWarehouseService(repo repository.WarehouseRepository, productRepo repository.ProductRepository) *WarehouseService {
+	return &WarehouseService{repo: repo, productRepo: productRepo}
+}
+
+func (s *WarehouseService) Create(ctx context.Context, req dto.CreateWarehouseRequest) (*dto.WarehouseResponse, error) {
+	w := &model.Warehouse{Code: req.Code, Name: req.Name, Priority: req.Priority, Active: true}
+	if err := s.repo.Create(ctx, w); err != nil {
+		if errors.Is(err, repository.ErrDuplicateWarehouseCode) {
+			return nil, ErrDuplicateWarehouseCode
+		}
+		return nil, fmt.Errorf("create warehouse: %w", err)
+	}
+	resp := toWarehouseResponse(w)
+	return &resp, nil
+}
+
+func (s *WarehouseService) List(ctx context.Context) (*dto.WarehouseListResponse, error) {
+	warehouses, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list warehouses: %w", err)
+	}
+	resp := &dto.WarehouseListResponse{Warehouses: make([]dto.WarehouseResponse, len(warehouses))}
+	for i := range warehouses {
+		resp.Warehouses[i] = toWarehouseResponse(&warehouses[i])
+	}
+	return resp, nil
+}
+
+// ProductStock breaks a product's stock down by warehouse.
+func (s *WarehouseService) ProductStock(ctx context.Context, productID uuid.UUID) (*dto.ProductStockResponse, error) {
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("get product: %w", err)
+	}
+	if product == nil {
+		return nil, ErrProductNotFound
+	}
+	levels, err := s.repo.StockByProductID(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("get warehouse stock: %w", err)
+	}
+	resp := &dto.ProductStockResponse{
+		ProductID:  productID,
+		Stock:      product.Stock,
+		Warehouses: make([]dto.WarehouseStockResponse, len(levels)),
+	}
+	for i, l := range levels {
+		resp.Warehouses[i] = dto.WarehouseStockResponse{WarehouseID: l.WarehouseID, WarehouseCode: l.WarehouseCode, Quantity: l.Quantity}
+	}
+	return resp, nil
+}
+
+func toWarehouseResponse(w *model.Warehouse) dto.WarehouseResponse {
+	return dto.WarehouseResponse{
+		ID: w.ID, Code: w.Code, Name: w.Name, Priority: w.Priority, Active: w.Active, CreatedAt: w.CreatedAt,
+	}
+}
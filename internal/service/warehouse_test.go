@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+type mockWarehouseRepo struct {
+	warehouses []model.Warehouse
+	stock      []model.WarehouseStock
+}
+
+func (m *mockWarehouseRepo) Create(_ context.Context, w *model.Warehouse) error {
+	for _, existing := range m.warehouses {
+		if existing.Code == w.Code {
+			return repository.ErrDuplicateWarehouseCode
+		}
+	}
+	w.ID, w.CreatedAt = uuid.New(), time.Now()
+	m.warehouses = append(m.warehouses, *w)
+	return nil
+}
+
+func (m *mockWarehouseRepo) List(_ context.Context) ([]model.Warehouse, error) {
+	out := append([]model.Warehouse(nil), m.warehouses...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Priority < out[j].Priority })
+	return out, nil
+}
+
+func (m *mockWarehouseRepo) StockByProductID(_ context.Context, productID uuid.UUID) ([]model.WarehouseStock, error) {
+	var out []model.WarehouseStock
+	for _, s := range m.stock {
+		if s.ProductID == productID {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func TestWarehouseService_Create(t *testing.T) {
+	svc := NewWarehouseService(&mockWarehouseRepo{}, newMockProductRepo())
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, dto.CreateWarehouseRequest{Code: "EAST", Name: "East", Priority: 20})
+	require.NoError(t, err)
+	resp, err := svc.Create(ctx, dto.CreateWarehouseRequest{Code: "MAIN", Name: "Main", Priority: 10})
+	require.NoError(t, err)
+	assert.True(t, resp.Active)
+
+	_, err = svc.Create(ctx, dto.CreateWarehouseRequest{Code: "MAIN", Name: "Again"})
+	assert.ErrorIs(t, err, ErrDuplicateWarehouseCode)
+
+	list, err := svc.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list.Warehouses, 2)
+	assert.Equal(t, "MAIN", list.Warehouses[0].Code)
+}
+
+func TestWarehouseService_ProductStock(t *testing.T) {
+	products := newMockProductRepo()
+	ctx := context.Background()
+	p := &model.Product{Name: "Mug", Price: decimal.NewFromInt(10), Stock: 7, Status: model.ProductStatusActive}
+	require.NoError(t, products.Create(ctx, p, nil))
+	central, east := uuid.New(), uuid.New()
+	repo := &mockWarehouseRepo{stock: []model.WarehouseStock{
+		{WarehouseID: central, WarehouseCode: "MAIN", ProductID: p.ID, Quantity: 4},
+		{WarehouseID: east, WarehouseCode: "EAST", ProductID: p.ID, Quantity: 3},
+	}}
+	svc := NewWarehouseService(repo, products)
+
+	resp, err := svc.ProductStock(ctx, p.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 7, resp.Stock)
+	assert.Equal(t, []dto.WarehouseStockResponse{
+		{WarehouseID: central, WarehouseCode: "MAIN", Quantity: 4},
+		{WarehouseID: east, WarehouseCode: "EAST", Quantity: 3},
+	}, resp.Warehouses)
+
+	_, err = svc.ProductStock(ctx, uuid.New())
+	assert.ErrorIs(t, err, ErrProductNotFound)
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/flicky/go-ecommerce-api/internal/dto"
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+var (
+	ErrWishlistNotFound      = errors.New("wishlist not found")
+	ErrWishlistItemNotFound  = errors.New("wishlist item not found")
+	ErrDuplicateWishlistName = errors.New("wishlist name already exists")
+	ErrInvalidWishlistName   = errors.New("invalid wishlist name")
+)
+
+// WishlistService manages users' wishlists. Another user's wishlist is
+// reported as not found; shared wishlists are read through GetShared.
+type WishlistService struct {
+	wishlistRepo repository.WishlistRepository
+	cartRepo     repository.CartRepository
+	productRepo  repository.ProductRepository
+}
+
+func NewWishlistService(wishlistRepo repository.WishlistRepository, cartRepo repository.CartRepository, productRepo repository.ProductRepository) *WishlistService {
+	return &WishlistService{wishlistRepo: wishlistRepo, cartRepo: cartRepo, productRepo: productRepo}
+}
+
+func (s *WishlistService) Create(ctx context.Context, userID uuid.UUID, name string) (*dto.WishlistResponse, error) {
+	w := &model.Wishlist{UserID: userID, Name: strings.TrimSpace(name)}
+	if w.Name == "" {
+		return nil, fmt.Errorf("%w: name is empty", ErrInvalidWishlistName)
+	}
+	if err := s.wishlistRepo.Create(ctx, w); err != nil {
+		if errors.Is(err, repository.ErrDuplicateWishlistName) {
+			return nil, ErrDuplicateWishlistName
+		}
+		return nil, fmt.Errorf("create wishlist: %w", err)
+	}
+	resp := toWishlistResponse(w)
+	return &resp, nil
+}
+
+func (s *WishlistService) List(ctx context.Context, userID uuid.UUID) (*dto.WishlistListResponse, error) {
+	wishlists, err := s.wishlistRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list wishlists: %w", err)
+	}
+	resp := &dto.WishlistListResponse{Wishlists: make([]dto.WishlistResponse, len(wishlists))}
+	for i := range wishlists {
+		resp.Wishlists[i] = toWishlistResponse(&wishlists[i])
+	}
+	return resp, nil
+}
+
+func (s *WishlistService) Get(ctx context.Context, userID, id uuid.UUID) (*dto.WishlistResponse, error) {
+	w, err := s.wishlist(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	resp := toWishlistResponse(w)
+	return &resp, nil
+}
+
+func (s *WishlistService) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	if _, err := s.wishlist(ctx, userID, id); err != nil {
+		return err
+	}
+	if err := s.wishlistRepo.Delete(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrWishlistNotFound
+		}
+		return fmt.Errorf("delete wishlist: %w", err)
+	}
+	return nil
+}
+
+// AddItem puts an on-sale product on the wishlist and returns the wishlist.
+// Adding a product that is already there changes nothing.
+func (s *WishlistService) AddItem(ctx context.Context, userID, id, productID uuid.UUID) (*dto.WishlistResponse, error) {
+	if _, err := s.wishlist(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("get product: %w", err)
+	}
+	if product == nil || product.Status != model.ProductStatusActive {
+		return nil, ErrProductNotFound
+	}
+	if err := s.wishlistRepo.AddItem(ctx, &model.WishlistItem{WishlistID: id, ProductID: productID}); err != nil {
+		return nil, fmt.Errorf("add wishlist item: %w", err)
+	}
+	return s.Get(ctx, userID, id)
+}
+
+func (s *WishlistService) RemoveItem(ctx context.Context, userID, id, itemID uuid.UUID) error {
+	if _, err := s.wishlist(ctx, userID, id); err != nil {
+		return err
+	}
+	if err := s.wishlistRepo.DeleteItem(ctx, id, itemID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrWishlistItemNotFound
+		}
+		return fmt.Errorf("delete wishlist item: %w", err)
+	}
+	return nil
+}
+
+// Share gives the wishlist a public link, keeping the one it already has,
+// and returns the wishlist with its share token.
+func (s *WishlistService) Share(ctx context.Context, userID, id uuid.UUID) (*dto.WishlistResponse, error) {
+	w, err := s.wishlist(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if w.ShareToken == nil {
+		token := rand.Text()
+		if err := s.setShareToken(ctx, id, &token); err != nil {
+			return nil, err
+		}
+		w.ShareToken = &token
+	}
+	resp := toWishlistResponse(w)
+	return &resp, nil
+}
+
+// Unshare revokes the wishlist's public link. Sharing it again gives a new
+// link, so the old one stays dead.
+func (s *WishlistService) Unshare(ctx context.Context, userID, id uuid.UUID) error {
+	if _, err := s.wishlist(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.setShareToken(ctx, id, nil)
+}
+
+func (s *WishlistService) setShareToken(ctx context.Context, id uuid.UUID, token *string) error {
+	if err := s.wishlistRepo.SetShareToken(ctx, id, token); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrWishlistNotFound
+		}
+		return fmt.Errorf("set wishlist share token: %w", err)
+	}
+	return nil
+}
+
+// GetShared returns the wishlist shared under token, without its owner.
+func (s *WishlistService) GetShared(ctx context.Context, token string) (*dto.SharedWishlistResponse, error) {
+	w, err := s.wishlistRepo.GetByShareToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("get shared wishlist: %w", err)
+	}
+	if w == nil {
+		return nil, ErrWishlistNotFound
+	}
+	return &dto.SharedWishlistResponse{Name: w.Name, Items: toWishlistItemResponses(w.Items)}, nil
+}
+
+// MoveToCart moves a wishlist item into the user's cart.
+func (s *WishlistService) MoveToCart(ctx context.Context, userID, id, itemID uuid.UUID, quantity int) error {
+	quantity = max(quantity, 1)
+	w, err := s.wishlist(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	var item *model.WishlistItem
+	for i := range w.Items {
+		if w.Items[i].ID == itemID {
+			item = &w.Items[i]
+		}
+	}
+	if item == nil {
+		return ErrWishlistItemNotFound
+	}
+	product, err := s.productRepo.GetByID(ctx, item.ProductID)
+	if err != nil {
+		return fmt.Errorf("get product: %w", err)
+	}
+	if product == nil || product.Status != model.ProductStatusActive {
+		return ErrProductNotFound
+	}
+
+	cart, err := s.cartRepo.GetOrCreateCart(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get cart: %w", err)
+	}
+	if err := unlocked(cart); err != nil {
+		return err
+	}
+	cartWithItems, err := s.cartRepo.GetCartWithItems(ctx, cart.ID)
+	if err != nil {
+		return fmt.Errorf("get cart items: %w", err)
+	}
+	inCart := 0
+	if cartWithItems != nil {
+		for _, ci := range cartWithItems.Items {
+			if ci.ProductID == item.ProductID {
+				inCart = ci.Quantity
+			}
+		}
+	}
+	if err := checkQuantity(product, inCart+quantity); err != nil {
+		return err
+	}
+
+	cartItem := &model.CartItem{CartID: cart.ID, ProductID: item.ProductID, Quantity: quantity, PriceAtAdd: product.Price}
+	if err := s.wishlistRepo.MoveToCart(ctx, itemID, cartItem); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrWishlistItemNotFound
+		}
+		return fmt.Errorf("move wishlist item to cart: %w", err)
+	}
+	return nil
+}
+
+// MoveFromCart takes a line out of the user's cart, active or saved for
+// later, and puts its product on the wishlist.
+func (s *WishlistService) MoveFromCart(ctx context.Context, userID, cartItemID, id uuid.UUID) error {
+	if _, err := s.wishlist(ctx, userID, id); err != nil {
+		return err
+	}
+	cart, err := s.cartRepo.GetOrCreateCart(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get cart: %w", err)
+	}
+	if err := unlocked(cart); err != nil {
+		return err
+	}
+	cartWithItems, err := s.cartRepo.GetCartWithItems(ctx, cart.ID)
+	if err != nil {
+		return fmt.Errorf("get cart items: %w", err)
+	}
+	found := false
+	if cartWithItems != nil {
+		for _, ci := range cartWithItems.Items {
+			found = found || ci.ID == cartItemID
+		}
+	}
+	if !found {
+		return ErrCartItemNotFound
+	}
+	if err := s.wishlistRepo.MoveFromCart(ctx, cartItemID, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrCartItemNotFound
+		}
+		return fmt.Errorf("move cart item to wishlist: %w", err)
+	}
+	return nil
+}
+
+// wishlist returns the user's wishlist with its items, or
+// ErrWishlistNotFound.
+func (s *WishlistService) wishlist(ctx context.Context, userID, id uuid.UUID) (*model.Wishlist, error) {
+	w, err := s.wishlistRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get wishlist: %w", err)
+	}
+	if w == nil || w.UserID != userID {
+		return nil, ErrWishlistNotFound
+	}
+	return w, nil
+}
+
+func toWishlistResponse(w *model.Wishlist) dto.WishlistResponse {
+	resp := dto.WishlistResponse{ID: w.ID, Name: w.Name, Items: toWishlistItemResponses(w.Items), CreatedAt: w.CreatedAt}
+	if w.ShareToken != nil {
+		resp.ShareToken = *w.ShareToken
+	}
+	return resp
+}
+
+// toWishlistItemResponses lists wishlist items; an item is available while
+// its product is on sale and in stock.
+func toWishlistItemResponses(items []model.WishlistItem) []dto.WishlistItemResponse {
+	out := make([]dto.WishlistItemResponse, len(items))
+	for i, item := range items {
+		out[i] = dto.WishlistItemResponse{
+			ID: item.ID, ProductID: item.ProductID, Name: item.ProductName, Price: item.Price,
+			Available: item.ProductStatus == model.ProductStatusActive && item.Stock > 0, AddedAt: item.CreatedAt,
+		}
+	}
+	return out
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+	"github.com/flicky/go-ecommerce-api/internal/repository"
+)
+
+// mockWishlistRepo joins items against the cart mock's products and moves
+// items to and from its carts.
+type mockWishlistRepo struct {
+	wishlists map[uuid.UUID]*model.Wishlist
+	items     map[uuid.UUID]*model.WishlistItem
+	carts     *mockCartRepo
+}
+
+func newMockWishlistRepo(carts *mockCartRepo) *mockWishlistRepo {
+	return &mockWishlistRepo{wishlists: make(map[uuid.UUID]*model.Wishlist), items: make(map[uuid.UUID]*model.WishlistItem), carts: carts}
+}
+
+func (m *mockWishlistRepo) Create(_ context.Context, w *model.Wishlist) error {
+	for _, existing := range m.wishlists {
+		if existing.UserID == w.UserID && existing.Name == w.Name {
+			return repository.ErrDuplicateWishlistName
+		}
+	}
+	w.ID, w.CreatedAt = uuid.New(), time.Now()
+	m.wishlists[w.ID] = w
+	return nil
+}
+
+func (m *mockWishlistRepo) GetByID(_ context.Context, id uuid.UUID) (*model.Wishlist, error) {
+	w, ok := m.wishlists[id]
+	if !ok {
+		return nil, nil
+	}
+	return m.withItems(w), nil
+}
+
+func (m *mockWishlistRepo) GetByShareToken(_ context.Context, token string) (*model.Wishlist, error) {
+	for _, w := range m.wishlists {
+		if w.ShareToken != nil && *w.ShareToken == token {
+			return m.withItems(w), nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockWishlistRepo) ListByUserID(_ context.Context, userID uuid.UUID) ([]model.Wishlist, error) {
+	var out []model.Wishlist
+	for _, w := range m.wishlists {
+		if w.UserID == userID {
+			out = append(out, *m.withItems(w))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (m *mockWishlistRepo) withItems(w *model.Wishlist) *model.Wishlist {
+	out := *w
+	out.Items = nil
+	for _, item := range m.items {
+		if item.WishlistID != w.ID {
+			continue
+		}
+		joined := *item
+		if p, ok := m.carts.products.products[item.ProductID]; ok {
+			joined.ProductName, joined.Price, joined.Stock, joined.ProductStatus = p.Name, p.Price, p.Stock, p.Status
+		}
+		out.Items = append(out.Items, joined)
+	}
+	sort.Slice(out.Items, func(i, j int) bool { return out.Items[i].ProductName < out.Items[j].ProductName })
+	return &out
+}
+
+func (m *mockWishlistRepo) Delete(_ context.Context, id uuid.UUID) error {
+	if _, ok := m.wishlists[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(m.wishlists, id)
+	for itemID, item := range m.items {
+		if item.WishlistID == id {
+			delete(m.items, itemID)
+		}
+	}
+	return nil
+}
+
+func (m *mockWishlistRepo) SetShareToken(_ context.Context, id uuid.UUID, token *string) error {
+	w, ok := m.wishlists[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	w.ShareToken = token
+	return nil
+}
+
+func (m *mockWishlistRepo) AddItem(_ context.Context, item *model.WishlistItem) error {
+	for _, existing := range m.items {
+		if existing.WishlistID == item.WishlistID && existing.ProductID == item.ProductID {
+			item.ID, item.CreatedAt = existing.ID, existing.CreatedAt
+			return nil
+		}
+	}
+	item.ID, item.CreatedAt = uuid.New(), time.Now()
+	m.items[item.ID] = item
+	return nil
+}
+
+func (m *mockWishlistRepo) DeleteItem(_ context.Context, wishlistID, itemID uuid.UUID) error {
+	item, ok := m.items[itemID]
+	if !ok || item.WishlistID != wishlistID {
+		return repository.ErrNotFound
+	}
+	delete(m.items, itemID)
+	return nil
+}
+
+func (m *mockWishlistRepo) MoveToCart(ctx context.Context, itemID uuid.UUID, cartItem *model.CartItem) error {
+	if _, ok := m.items[itemID]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(m.items, itemID)
+	return m.carts.AddItem(ctx, cartItem)
+}
+
+func (m *mockWishlistRepo) MoveFromCart(ctx context.Context, cartItemID, wishlistID uuid.UUID) error {
+	cartItem, ok := m.carts.items[cartItemID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	delete(m.carts.items, cartItemID)
+	return m.AddItem(ctx, &model.WishlistItem{WishlistID: wishlistID, ProductID: cartItem.ProductID})
+}
+
+func newWishlistFixture() (*WishlistService, *mockWishlistRepo, *mockCartRepo, *mockProductRepo) {
+	cartRepo, productRepo := newMockCartRepo(), newMockProductRepo()
+	cartRepo.products = productRepo
+	wishlistRepo := newMockWishlistRepo(cartRepo)
+	return NewWishlistService(wishlistRepo, cartRepo, productRepo), wishlistRepo, cartRepo, productRepo
+}
+
+func TestWishlistService_Items(t *testing.T) {
+	svc, _, _, productRepo := newWishlistFixture()
+	ctx := context.Background()
+	owner, other := uuid.New(), uuid.New()
+	pid := newActiveProduct(productRepo, 0)
+	productRepo.products[pid].Price = decimal.NewFromInt(15)
+
+	w, err := svc.Create(ctx, owner, "  Birthday ")
+	require.NoError(t, err)
+	assert.Equal(t, "Birthday", w.Name)
+	_, err = svc.Create(ctx, owner, "Birthday")
+	assert.ErrorIs(t, err, ErrDuplicateWishlistName)
+	_, err = svc.Create(ctx, owner, "   ")
+	assert.ErrorIs(t, err, ErrInvalidWishlistName)
+
+	w, err = svc.AddItem(ctx, owner, w.ID, pid)
+	require.NoError(t, err)
+	// Adding the product again keeps the one item.
+	w, err = svc.AddItem(ctx, owner, w.ID, pid)
+	require.NoError(t, err)
+	require.Len(t, w.Items, 1)
+	assert.True(t, decimal.NewFromInt(15).Equal(w.Items[0].Price))
+	assert.False(t, w.Items[0].Available, "sold out")
+
+	_, err = svc.AddItem(ctx, owner, w.ID, uuid.New())
+	assert.ErrorIs(t, err, ErrProductNotFound)
+
+	// Other users cannot see or change it.
+	_, err = svc.Get(ctx, other, w.ID)
+	assert.ErrorIs(t, err, ErrWishlistNotFound)
+	_, err = svc.AddItem(ctx, other, w.ID, pid)
+	assert.ErrorIs(t, err, ErrWishlistNotFound)
+	assert.ErrorIs(t, svc.RemoveItem(ctx, other, w.ID, w.Items[0].ID), ErrWishlistNotFound)
+	list, err := svc.List(ctx, other)
+	require.NoError(t, err)
+	assert.Empty(t, list.Wishlists)
+
+	require.NoError(t, svc.RemoveItem(ctx, owner, w.ID, w.Items[0].ID))
+	assert.ErrorIs(t, svc.RemoveItem(ctx, owner, w.ID, w.Items[0].ID), ErrWishlistItemNotFound)
+	require.NoError(t, svc.Delete(ctx, owner, w.ID))
+	_, err = svc.Get(ctx, owner, w.ID)
+	assert.ErrorIs(t, err, ErrWishlistNotFound)
+}
+
+func TestWishlistService_Share(t *testing.T) {
+	svc, _, _, productRepo := newWishlistFixture()
+	ctx := context.Background()
+	owner := uuid.New()
+	pid := newActiveProduct(productRepo, 3)
+
+	w, err := svc.Create(ctx, owner, "Wedding")
+	require.NoError(t, err)
+	_, err = svc.AddItem(ctx, owner, w.ID, pid)
+	require.NoError(t, err)
+
+	shared, err := svc.Share(ctx, owner, w.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, shared.ShareToken)
+	again, err := svc.Share(ctx, owner, w.ID)
+	require.NoError(t, err)
+	assert.Equal(t, shared.ShareToken, again.ShareToken)
+
+	public, err := svc.GetShared(ctx, shared.ShareToken)
+	require.NoError(t, err)
+	assert.Equal(t, "Wedding", public.Name)
+	require.Len(t, public.Items, 1)
+	assert.True(t, public.Items[0].Available)
+
+	// Unsharing kills the link, and sharing again makes a new one.
+	require.NoError(t, svc.Unshare(ctx, owner, w.ID))
+	_, err = svc.GetShared(ctx, shared.ShareToken)
+	assert.ErrorIs(t, err, ErrWishlistNotFound)
+	reshared, err := svc.Share(ctx, owner, w.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, shared.ShareToken, reshared.ShareToken)
+}
+
+func TestWishlistService_MoveBetweenCart(t *testing.T) {
+	svc, wishlistRepo, cartRepo, productRepo := newWishlistFixture()
+	ctx := context.Background()
+	owner := uuid.New()
+	pid := newActiveProduct(productRepo, 3)
+	carts := NewCartService(cartRepo, productRepo, newMockPromotionRepo(), nil, nil, newTestPrices(), time.Hour, "")
+
+	w, err := svc.Create(ctx, owner, "Later")
+	require.NoError(t, err)
+	w, err = svc.AddItem(ctx, owner, w.ID, pid)
+	require.NoError(t, err)
+	itemID := w.Items[0].ID
+
+	// More than the stock stays on the wishlist.
+	var qe *QuantityError
+	require.ErrorAs(t, svc.MoveToCart(ctx, owner, w.ID, itemID, 4), &qe)
+	assert.Equal(t, 3, qe.Allowed)
+	assert.Contains(t, wishlistRepo.items, itemID)
+
+	require.NoError(t, svc.MoveToCart(ctx, owner, w.ID, itemID, 0))
+	assert.Empty(t, wishlistRepo.items)
+	cart, err := carts.GetCart(ctx, CartRef{UserID: owner})
+	require.NoError(t, err)
+	require.Len(t, cart.Items, 1)
+	assert.Equal(t, 1, cart.Items[0].Quantity)
+
+	// Nobody else can move the line, onto the owner's wishlist or their own.
+	cartItemID := cart.Items[0].ID
+	stranger := uuid.New()
+	assert.ErrorIs(t, svc.MoveFromCart(ctx, stranger, cartItemID, w.ID), ErrWishlistNotFound)
+	theirs, err := svc.Create(ctx, stranger, "Theirs")
+	require.NoError(t, err)
+	assert.ErrorIs(t, svc.MoveFromCart(ctx, stranger, cartItemID, theirs.ID), ErrCartItemNotFound)
+
+	require.NoError(t, svc.MoveFromCart(ctx, owner, cartItemID, w.ID))
+	assert.Empty(t, cartRepo.items)
+	w, err = svc.Get(ctx, owner, w.ID)
+	require.NoError(t, err)
+	require.Len(t, w.Items, 1)
+	assert.Equal(t, pid, w.Items[0].ProductID)
+}
@@ -0,0 +1,171 @@
+// Package shipping quotes what it costs to ship a cart or order.
+package shipping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+// Shipment is what is being shipped and where to.
+type Shipment struct {
+	// Country is the destination's ISO 3166-1 alpha-2 code.
+	Country     string
+	WeightGrams int
+	// Subtotal is the value of the goods after discounts.
+	Subtotal decimal.Decimal
+}
+
+// Quote is what one method costs for a shipment, and who quoted it.
+type Quote struct {
+	Method   string
+	Cost     decimal.Decimal
+	Provider string
+}
+
+// Provider quotes the methods it can ship a shipment with. A provider that
+// cannot ship it returns no quotes and no error.
+type Provider interface {
+	Quote(ctx context.Context, s Shipment) ([]Quote, error)
+}
+
+// methodOrder is the order quotes are listed in.
+var methodOrder = []string{model.ShippingStandard, model.ShippingExpress, model.ShippingPickup}
+
+// Quoter asks several providers and keeps the cheapest quote for each
+// method. It is itself a Provider.
+type Quoter struct {
+	providers []Provider
+}
+
+func NewQuoter(providers ...Provider) *Quoter {
+	return &Quoter{providers: providers}
+}
+
+// Quote returns the cheapest quote per method. A failing provider is
+// skipped so that one carrier being down does not stop checkout; the
+// errors are returned only when no provider gave a quote.
+func (q *Quoter) Quote(ctx context.Context, s Shipment) ([]Quote, error) {
+	var quotes []Quote
+	var errs []error
+	for _, p := range q.providers {
+		got, err := p.Quote(ctx, s)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		quotes = append(quotes, got...)
+	}
+	if len(quotes) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cheapest(quotes), nil
+}
+
+// cheapest keeps the cheapest quote per method, in methodOrder.
+func cheapest(quotes []Quote) []Quote {
+	best := make(map[string]Quote)
+	for _, q := range quotes {
+		if b, ok := best[q.Method]; !ok || q.Cost.LessThan(b.Cost) {
+			best[q.Method] = q
+		}
+	}
+	out := []Quote{}
+	for _, m := range methodOrder {
+		if q, ok := best[m]; ok {
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+// Find returns the quote for method.
+func Find(quotes []Quote, method string) (Quote, bool) {
+	i := slices.IndexFunc(quotes, func(q Quote) bool { return q.Method == method })
+	if i < 0 {
+		return Quote{}, false
+	}
+	return quotes[i], true
+}
+
+// FreeStandard makes standard shipping free, as a free-shipping coupon does.
+// Express and pickup keep their price.
+func FreeStandard(quotes []Quote) []Quote {
+	out := slices.Clone(quotes)
+	for i := range out {
+		if out[i].Method == model.ShippingStandard {
+			out[i].Cost = decimal.Zero
+		}
+	}
+	return out
+}
+
+// RateSource loads the rate table rows that may apply to a destination
+// country: those of its zone and those without a zone.
+type RateSource interface {
+	RatesFor(ctx context.Context, country string) ([]model.ShippingRate, error)
+}
+
+// TableProviderName is the Provider set on quotes from the rate table.
+const TableProviderName = "table"
+
+// Table is the Provider backed by the shop's own rate table.
+type Table struct {
+	rates RateSource
+}
+
+func NewTable(rates RateSource) *Table {
+	return &Table{rates: rates}
+}
+
+func (t *Table) Quote(ctx context.Context, s Shipment) ([]Quote, error) {
+	rates, err := t.rates.RatesFor(ctx, s.Country)
+	if err != nil {
+		return nil, fmt.Errorf("load shipping rates: %w", err)
+	}
+	return Rate(rates, s), nil
+}
+
+var gramsPerKg = decimal.NewFromInt(1000)
+
+// Rate prices the shipment with every rate whose weight and subtotal bands
+// contain it, keeping the cheapest per method. Lower bounds are inclusive
+// and upper bounds exclusive. Rates are assumed to apply to the
+// destination already.
+func Rate(rates []model.ShippingRate, s Shipment) []Quote {
+	kg := decimal.NewFromInt(int64(s.WeightGrams)).Div(gramsPerKg).Ceil()
+	var quotes []Quote
+	for _, r := range rates {
+		if s.WeightGrams < r.MinWeightGrams || (r.MaxWeightGrams != nil && s.WeightGrams >= *r.MaxWeightGrams) {
+			continue
+		}
+		if s.Subtotal.LessThan(r.MinSubtotal) || (r.MaxSubtotal != nil && !s.Subtotal.LessThan(*r.MaxSubtotal)) {
+			continue
+		}
+		quotes = append(quotes, Quote{
+			Method: r.Method, Cost: r.Price.Add(r.PricePerKg.Mul(kg)).Round(2), Provider: TableProviderName,
+		})
+	}
+	return cheapest(quotes)
+}
+
+// Fake is a Provider returning fixed quotes, for tests. It records the
+// shipments it was asked about.
+type Fake struct {
+	Quotes    []Quote
+	Err       error
+	Shipments []Shipment
+}
+
+func (f *Fake) Quote(_ context.Context, s Shipment) ([]Quote, error) {
+	f.Shipments = append(f.Shipments, s)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	return slices.Clone(f.Quotes), nil
+}
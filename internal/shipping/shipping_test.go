@@ -0,0 +1,105 @@
+package shipping
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+func TestRate(t *testing.T) {
+	d := decimal.RequireFromString
+	intPtr := func(v int) *int { return &v }
+	decPtr := func(s string) *decimal.Decimal { v := d(s); return &v }
+
+	rates := []model.ShippingRate{
+		// Standard: 4.90 up to 2 kg, then 3.00 plus 1.50 per started kg;
+		// free from 100.00.
+		{Method: model.ShippingStandard, MaxWeightGrams: intPtr(2000), MaxSubtotal: decPtr("100"), Price: d("4.90")},
+		{Method: model.ShippingStandard, MinWeightGrams: 2000, MaxSubtotal: decPtr("100"), Price: d("3.00"), PricePerKg: d("1.50")},
+		{Method: model.ShippingStandard, MinSubtotal: d("100"), Price: d("0")},
+		{Method: model.ShippingExpress, MaxWeightGrams: intPtr(5000), Price: d("12.00")},
+		{Method: model.ShippingPickup, Price: d("0")},
+	}
+
+	tests := []struct {
+		name     string
+		shipment Shipment
+		want     map[string]string
+	}{
+		{
+			name:     "light parcel",
+			shipment: Shipment{WeightGrams: 500, Subtotal: d("20")},
+			want:     map[string]string{model.ShippingStandard: "4.90", model.ShippingExpress: "12.00", model.ShippingPickup: "0.00"},
+		},
+		{
+			name:     "heavy parcel pays per started kilogram and is too heavy for express",
+			shipment: Shipment{WeightGrams: 6200, Subtotal: d("20")},
+			want:     map[string]string{model.ShippingStandard: "13.50", model.ShippingPickup: "0.00"},
+		},
+		{
+			name:     "upper bounds are exclusive",
+			shipment: Shipment{WeightGrams: 2000, Subtotal: d("100")},
+			want:     map[string]string{model.ShippingStandard: "0.00", model.ShippingExpress: "12.00", model.ShippingPickup: "0.00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quotes := Rate(rates, tt.shipment)
+			got := make(map[string]string, len(quotes))
+			for _, q := range quotes {
+				assert.Equal(t, TableProviderName, q.Provider)
+				got[q.Method] = q.Cost.StringFixed(2)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	assert.Empty(t, Rate(nil, Shipment{WeightGrams: 100}))
+}
+
+func TestQuoter(t *testing.T) {
+	ctx := context.Background()
+	d := decimal.RequireFromString
+	table := &Fake{Quotes: []Quote{
+		{Method: model.ShippingPickup, Cost: d("0"), Provider: "table"},
+		{Method: model.ShippingStandard, Cost: d("5.00"), Provider: "table"},
+		{Method: model.ShippingExpress, Cost: d("12.00"), Provider: "table"},
+	}}
+	carrier := &Fake{Quotes: []Quote{
+		{Method: model.ShippingStandard, Cost: d("6.00"), Provider: "carrier"},
+		{Method: model.ShippingExpress, Cost: d("9.50"), Provider: "carrier"},
+	}}
+	shipment := Shipment{Country: "DE", WeightGrams: 800, Subtotal: d("30")}
+
+	quotes, err := NewQuoter(table, carrier).Quote(ctx, shipment)
+	require.NoError(t, err)
+	require.Len(t, quotes, 3)
+	assert.Equal(t, []string{"table", "carrier", "table"}, []string{quotes[0].Provider, quotes[1].Provider, quotes[2].Provider})
+	assert.Equal(t, model.ShippingStandard, quotes[0].Method)
+	assert.Equal(t, model.ShippingPickup, quotes[2].Method)
+	assert.Equal(t, []Shipment{shipment}, carrier.Shipments)
+
+	// A carrier that is down is skipped.
+	down := &Fake{Err: errors.New("timeout")}
+	quotes, err = NewQuoter(table, down).Quote(ctx, shipment)
+	require.NoError(t, err)
+	assert.Len(t, quotes, 3)
+
+	// Only when every provider fails is the error returned.
+	_, err = NewQuoter(down).Quote(ctx, shipment)
+	assert.ErrorContains(t, err, "timeout")
+
+	free := FreeStandard(quotes)
+	standard, ok := Find(free, model.ShippingStandard)
+	require.True(t, ok)
+	assert.True(t, standard.Cost.IsZero())
+	express, _ := Find(free, model.ShippingExpress)
+	assert.Equal(t, "12", express.Cost.String())
+	assert.Equal(t, "5", quotes[0].Cost.String(), "the input is left alone")
+}
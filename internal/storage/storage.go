@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Storage keeps uploaded binary objects (product images, thumbnails) and
+// knows the public URL each object is served from.
+type Storage interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStorage(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewLocalStorage(dir, "http://localhost:8080/media/")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, s.Put(ctx, "products/a/b.png", "image/png", []byte("png")))
+	data, err := os.ReadFile(filepath.Join(dir, "products", "a", "b.png"))
+	require.NoError(t, err)
+	assert.Equal(t, "png", string(data))
+	assert.Equal(t, "http://localhost:8080/media/products/a/b.png", s.URL("products/a/b.png"))
+
+	require.NoError(t, s.Delete(ctx, "products/a/b.png"))
+	assert.ErrorIs(t, s.Delete(ctx, "products/a/b.png"), ErrNotFound)
+	assert.Error(t, s.Put(ctx, "../escape", "text/plain", nil))
+}
+
+// fakeS3 is a minimal MinIO-style stand-in that stores objects in memory and
+// checks that requests carry a SigV4 authorization for the expected key.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=access/") ||
+		!strings.Contains(auth, "SignedHeaders=host;x-amz-content-sha256;x-amz-date") {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		http.Error(w, "XAmzContentSHA256Mismatch", http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[r.URL.Path] = body
+		f.types[r.URL.Path] = r.Header.Get("Content-Type")
+	case http.MethodDelete:
+		if _, ok := f.objects[r.URL.Path]; !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3Storage(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}, types: map[string]string{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	s := NewS3Storage(S3Config{
+		Endpoint: srv.URL, Region: "us-east-1", Bucket: "media",
+		AccessKey: "access", SecretKey: "secret",
+	}, srv.Client())
+	ctx := context.Background()
+
+	require.NoError(t, s.Put(ctx, "products/x/img 1.jpg", "image/jpeg", []byte("jpeg")))
+	assert.Equal(t, []byte("jpeg"), fake.objects["/media/products/x/img 1.jpg"])
+	assert.Equal(t, "image/jpeg", fake.types["/media/products/x/img 1.jpg"])
+	assert.Equal(t, srv.URL+"/media/products/x/img%201.jpg", s.URL("products/x/img 1.jpg"))
+
+	require.NoError(t, s.Delete(ctx, "products/x/img 1.jpg"))
+	assert.ErrorIs(t, s.Delete(ctx, "products/x/img 1.jpg"), ErrNotFound)
+}
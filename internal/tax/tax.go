@@ -0,0 +1,165 @@
+// Package tax works out the tax on an order's lines.
+package tax
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+// Address is where goods are taxed: the destination they ship to.
+type Address struct {
+	Country string
+	Region  string
+}
+
+// Line is one order line: its product's tax class and what the customer
+// pays for it after discounts.
+type Line struct {
+	Class  string
+	Amount decimal.Decimal
+}
+
+// LineTax is the tax on one line. Rate is a percentage.
+type LineTax struct {
+	Name   string
+	Rate   decimal.Decimal
+	Amount decimal.Decimal
+}
+
+// Result is the tax on every line, in the order the lines were given.
+// Inclusive means the tax is part of the line amounts rather than added on
+// top of them.
+type Result struct {
+	Lines     []LineTax
+	Total     decimal.Decimal
+	Inclusive bool
+}
+
+// Calculator taxes lines shipped to an address. The rate table is one;
+// an external tax service can be another.
+type Calculator interface {
+	Calculate(ctx context.Context, to Address, lines []Line) (Result, error)
+}
+
+// RateSource loads the rates for a country, for all its regions.
+type RateSource interface {
+	TaxRatesFor(ctx context.Context, country string) ([]model.TaxRate, error)
+}
+
+// Table is the Calculator backed by the shop's own rate table.
+type Table struct {
+	rates     RateSource
+	inclusive bool
+}
+
+// NewTable returns a rate-table calculator; pricesIncludeTax says whether
+// product prices already include tax.
+func NewTable(rates RateSource, pricesIncludeTax bool) *Table {
+	return &Table{rates: rates, inclusive: pricesIncludeTax}
+}
+
+func (t *Table) Calculate(ctx context.Context, to Address, lines []Line) (Result, error) {
+	rates, err := t.rates.TaxRatesFor(ctx, to.Country)
+	if err != nil {
+		return Result{}, fmt.Errorf("load tax rates: %w", err)
+	}
+	return Compute(rates, to, lines, t.inclusive), nil
+}
+
+var hundred = decimal.NewFromInt(100)
+
+// Compute taxes each line at its class's rate for the address: the region's
+// own rate if it has one, else the country-wide rate, else nothing. Tax is
+// rounded to cents per line.
+func Compute(rates []model.TaxRate, to Address, lines []Line, inclusive bool) Result {
+	res := Result{Lines: make([]LineTax, len(lines)), Inclusive: inclusive}
+	for i, l := range lines {
+		r, ok := find(rates, to, l.Class)
+		if !ok {
+			continue
+		}
+		var amount decimal.Decimal
+		if inclusive {
+			amount = l.Amount.Mul(r.Rate).Div(hundred.Add(r.Rate)).Round(2)
+		} else {
+			amount = l.Amount.Mul(r.Rate).Div(hundred).Round(2)
+		}
+		res.Lines[i] = LineTax{Name: r.Name, Rate: r.Rate, Amount: amount}
+		res.Total = res.Total.Add(amount)
+	}
+	return res
+}
+
+func find(rates []model.TaxRate, to Address, class string) (model.TaxRate, bool) {
+	var country *model.TaxRate
+	for i, r := range rates {
+		if !strings.EqualFold(r.Country, to.Country) || r.TaxClass != class {
+			continue
+		}
+		if r.Region == "" {
+			country = &rates[i]
+		} else if to.Region != "" && strings.EqualFold(r.Region, to.Region) {
+			return r, true
+		}
+	}
+	if country == nil {
+		return model.TaxRate{}, false
+	}
+	return *country, true
+}
+
+// SpreadDiscount shares discount out over amounts in proportion to them,
+// in cents, so that the shares add up to discount exactly. The last
+// non-zero amount takes the rounding remainder.
+func SpreadDiscount(amounts []decimal.Decimal, discount decimal.Decimal) []decimal.Decimal {
+	out := make([]decimal.Decimal, len(amounts))
+	var total decimal.Decimal
+	last := -1
+	for i, a := range amounts {
+		total = total.Add(a)
+		if a.IsPositive() {
+			last = i
+		}
+	}
+	if discount.IsZero() || last < 0 {
+		return out
+	}
+	left := discount
+	for i, a := range amounts {
+		if i == last {
+			out[i] = left
+			break
+		}
+		out[i] = discount.Mul(a).Div(total).Round(2)
+		left = left.Sub(out[i])
+	}
+	return out
+}
+
+// Breakdown totals line taxes by name and rate, in the order each first
+// appears. Untaxed lines are left out.
+func Breakdown(lines []LineTax) []LineTax {
+	var out []LineTax
+	for _, l := range lines {
+		if l.Amount.IsZero() && l.Rate.IsZero() {
+			continue
+		}
+		found := false
+		for i := range out {
+			if out[i].Name == l.Name && out[i].Rate.Equal(l.Rate) {
+				out[i].Amount = out[i].Amount.Add(l.Amount)
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, l)
+		}
+	}
+	return out
+}
@@ -0,0 +1,114 @@
+package tax
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+var d = decimal.RequireFromString
+
+var rates = []model.TaxRate{
+	{Country: "DE", TaxClass: "standard", Name: "MwSt", Rate: d("19")},
+	{Country: "DE", TaxClass: "reduced", Name: "MwSt ermäßigt", Rate: d("7")},
+	{Country: "US", TaxClass: "standard", Name: "State tax", Rate: d("4")},
+	{Country: "US", Region: "NY", TaxClass: "standard", Name: "NY sales tax", Rate: d("8.875")},
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name      string
+		to        Address
+		lines     []Line
+		inclusive bool
+		want      []string
+		wantTotal string
+	}{
+		{
+			name:      "exclusive prices, rate per class",
+			to:        Address{Country: "DE"},
+			lines:     []Line{{"standard", d("100")}, {"reduced", d("10")}},
+			want:      []string{"19.00", "0.70"},
+			wantTotal: "19.70",
+		},
+		{
+			name:      "inclusive prices take the tax out of the amount",
+			to:        Address{Country: "de"},
+			lines:     []Line{{"standard", d("119")}, {"reduced", d("10")}},
+			inclusive: true,
+			want:      []string{"19.00", "0.65"},
+			wantTotal: "19.65",
+		},
+		{
+			name:      "a region's own rate wins over the country's",
+			to:        Address{Country: "US", Region: "ny"},
+			lines:     []Line{{"standard", d("20")}},
+			want:      []string{"1.78"},
+			wantTotal: "1.78",
+		},
+		{
+			name:      "other regions get the country rate",
+			to:        Address{Country: "US", Region: "OR"},
+			lines:     []Line{{"standard", d("20")}},
+			want:      []string{"0.80"},
+			wantTotal: "0.80",
+		},
+		{
+			name:      "no rate for the class or country is untaxed",
+			to:        Address{Country: "FR"},
+			lines:     []Line{{"standard", d("20")}},
+			want:      []string{"0.00"},
+			wantTotal: "0.00",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Compute(rates, tt.to, tt.lines, tt.inclusive)
+			got := make([]string, len(res.Lines))
+			for i, l := range res.Lines {
+				got[i] = l.Amount.StringFixed(2)
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantTotal, res.Total.StringFixed(2))
+			assert.Equal(t, tt.inclusive, res.Inclusive)
+		})
+	}
+}
+
+type staticRates []model.TaxRate
+
+func (s staticRates) TaxRatesFor(_ context.Context, _ string) ([]model.TaxRate, error) { return s, nil }
+
+func TestTable(t *testing.T) {
+	res, err := NewTable(staticRates(rates), false).Calculate(context.Background(), Address{Country: "DE"}, []Line{{"standard", d("50")}})
+	require.NoError(t, err)
+	require.Len(t, res.Lines, 1)
+	assert.Equal(t, "MwSt", res.Lines[0].Name)
+	assert.Equal(t, "9.50", res.Total.StringFixed(2))
+}
+
+func TestSpreadDiscount(t *testing.T) {
+	shares := SpreadDiscount([]decimal.Decimal{d("10"), d("10"), d("10"), d("0")}, d("10"))
+	assert.Equal(t, []string{"3.33", "3.33", "3.34", "0.00"}, []string{
+		shares[0].StringFixed(2), shares[1].StringFixed(2), shares[2].StringFixed(2), shares[3].StringFixed(2),
+	})
+	assert.True(t, SpreadDiscount([]decimal.Decimal{d("5")}, decimal.Zero)[0].IsZero())
+}
+
+func TestBreakdown(t *testing.T) {
+	got := Breakdown([]LineTax{
+		{Name: "MwSt", Rate: d("19"), Amount: d("1.90")},
+		{},
+		{Name: "MwSt ermäßigt", Rate: d("7"), Amount: d("0.70")},
+		{Name: "MwSt", Rate: d("19"), Amount: d("3.80")},
+	})
+	require.Len(t, got, 2)
+	assert.Equal(t, "MwSt", got[0].Name)
+	assert.Equal(t, "5.70", got[0].Amount.StringFixed(2))
+	assert.Equal(t, "0.70", got[1].Amount.StringFixed(2))
+}
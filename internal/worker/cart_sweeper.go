@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// GuestCartSweeper deletes expired guest carts.
+type GuestCartSweeper interface {
+	SweepGuestCarts(ctx context.Context) (int, error)
+}
+
+// CartSweepWorker runs the guest cart sweep on a fixed interval.
+type CartSweepWorker struct {
+	sweeper  GuestCartSweeper
+	interval time.Duration
+	log      *slog.Logger
+	done     chan struct{}
+}
+
+func NewCartSweepWorker(sweeper GuestCartSweeper, interval time.Duration, log *slog.Logger) *CartSweepWorker {
+	return &CartSweepWorker{sweeper: sweeper, interval: interval, log: log, done: make(chan struct{})}
+}
+
+func (w *CartSweepWorker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n, err := w.sweeper.SweepGuestCarts(ctx)
+				if err != nil {
+					w.log.Error("sweep guest carts", "error", err)
+					continue
+				}
+				if n > 0 {
+					w.log.Info("expired guest carts deleted", "count", n)
+				}
+			case <-w.done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	w.log.Info("cart sweep worker started", "interval", w.interval)
+}
+
+func (w *CartSweepWorker) Stop() { close(w.done) }
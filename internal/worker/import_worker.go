@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+// ImportProcessor runs a queued product import job.
+type ImportProcessor interface {
+	Process(ctx context.Context, jobID uuid.UUID) error
+	Fail(ctx context.Context, jobID uuid.UUID, cause error) error
+}
+
+type ImportWorker struct {
+	ch        *amqp.Channel
+	processor ImportProcessor
+	log       *slog.Logger
+	done      chan struct{}
+}
+
+func NewImportWorker(ch *amqp.Channel, processor ImportProcessor, log *slog.Logger) *ImportWorker {
+	return &ImportWorker{ch: ch, processor: processor, log: log, done: make(chan struct{})}
+}
+
+func SetupImportQueue(ch *amqp.Channel) error {
+	if _, err := ch.QueueDeclare("product_imports", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare import queue: %w", err)
+	}
+	return nil
+}
+
+func (w *ImportWorker) Start(ctx context.Context) error {
+	msgs, err := w.ch.Consume("product_imports", "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("consume: %w", err)
+	}
+	go func() {
+		for {
+			select {
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				w.handle(ctx, msg)
+			case <-w.done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	w.log.Info("import worker started")
+	return nil
+}
+
+func (w *ImportWorker) Stop() { close(w.done) }
+
+func (w *ImportWorker) handle(ctx context.Context, msg amqp.Delivery) {
+	var m model.ImportMessage
+	if err := json.Unmarshal(msg.Body, &m); err != nil {
+		w.log.Error("unmarshal", "error", err)
+		_ = msg.Nack(false, false)
+		return
+	}
+
+	// Row errors are recorded on the job itself, and a job that cannot run to
+	// the end is marked failed. An error here means even that could not be
+	// recorded, or the worker is stopping, so the job is requeued to resume
+	// where it stopped; a job that fails again is marked failed and dropped
+	// rather than retried forever.
+	if err := w.processor.Process(ctx, m.JobID); err != nil {
+		w.log.Error("process import", "error", err, "job_id", m.JobID)
+		requeue := !msg.Redelivered || ctx.Err() != nil
+		if !requeue {
+			if err := w.processor.Fail(ctx, m.JobID, err); err != nil {
+				w.log.Error("fail import", "error", err, "job_id", m.JobID)
+			}
+		}
+		_ = msg.Nack(false, requeue)
+		return
+	}
+	_ = msg.Ack(false)
+	w.log.Info("import processed", "job_id", m.JobID)
+}
@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// PendingOrderPublisher queues the orders whose message never reached the
+// queue.
+type PendingOrderPublisher interface {
+	PublishPending(ctx context.Context) (int, error)
+}
+
+// OrderPublishWorker queues unqueued orders on a fixed interval, so an
+// order placed while RabbitMQ was unreachable is still processed.
+type OrderPublishWorker struct {
+	publisher PendingOrderPublisher
+	interval  time.Duration
+	log       *slog.Logger
+	done      chan struct{}
+}
+
+func NewOrderPublishWorker(publisher PendingOrderPublisher, interval time.Duration, log *slog.Logger) *OrderPublishWorker {
+	return &OrderPublishWorker{publisher: publisher, interval: interval, log: log, done: make(chan struct{})}
+}
+
+func (w *OrderPublishWorker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n, err := w.publisher.PublishPending(ctx)
+				if n > 0 {
+					w.log.Info("unqueued orders published", "count", n)
+				}
+				if err != nil {
+					w.log.Error("publish pending orders", "error", err)
+				}
+			case <-w.done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	w.log.Info("order publish worker started", "interval", w.interval)
+}
+
+func (w *OrderPublishWorker) Stop() { close(w.done) }
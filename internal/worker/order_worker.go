@@ -7,23 +7,33 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/redis/go-redis/v9"
 
+	"github.com/flicky/go-ecommerce-api/internal/cache"
 	"github.com/flicky/go-ecommerce-api/internal/model"
 	"github.com/flicky/go-ecommerce-api/internal/repository"
 )
 
+// StockObserver is told about the stock movements of processed orders so it
+// can raise stock alerts.
+type StockObserver interface {
+	Observe(ctx context.Context, movements []model.InventoryMovement) error
+}
+
 type OrderWorker struct {
 	ch        *amqp.Channel
 	orderRepo repository.OrderRepository
 	redis     *redis.Client
+	cache     *cache.Cache
+	alerts    StockObserver
 	log       *slog.Logger
 	done      chan struct{}
 }
 
-func NewOrderWorker(ch *amqp.Channel, orderRepo repository.OrderRepository, redis *redis.Client, log *slog.Logger) *OrderWorker {
-	return &OrderWorker{ch: ch, orderRepo: orderRepo, redis: redis, log: log, done: make(chan struct{})}
+func NewOrderWorker(ch *amqp.Channel, orderRepo repository.OrderRepository, redis *redis.Client, productCache *cache.Cache, alerts StockObserver, log *slog.Logger) *OrderWorker {
+	return &OrderWorker{ch: ch, orderRepo: orderRepo, redis: redis, cache: productCache, alerts: alerts, log: log, done: make(chan struct{})}
 }
 
 func SetupQueues(ch *amqp.Channel) error {
@@ -94,13 +104,24 @@ func (w *OrderWorker) handle(ctx context.Context, msg amqp.Delivery) {
 		return
 	}
 
-	if err := w.orderRepo.ProcessOrder(ctx, m.OrderID, order.Items); err != nil {
+	movements, err := w.orderRepo.ProcessOrder(ctx, m.OrderID)
+	if err != nil {
 		w.log.Error("process order", "error", err, "order_id", m.OrderID)
-		_ = w.orderRepo.UpdateStatus(ctx, m.OrderID, "failed")
+		_ = w.orderRepo.UpdateStatus(ctx, m.OrderID, model.OrderFailed)
 		_ = msg.Nack(false, false) // → DLQ
 		return
 	}
 
+	// Stock changed, so cached products and list pages are stale now.
+	productIDs := make([]uuid.UUID, len(order.Items))
+	for i, item := range order.Items {
+		productIDs[i] = item.ProductID
+	}
+	w.cache.InvalidateProducts(ctx, productIDs...)
+	if err := w.alerts.Observe(ctx, movements); err != nil {
+		w.log.Error("raise stock alerts", "error", err, "order_id", m.OrderID)
+	}
+
 	_ = w.redis.Set(ctx, key, "1", 24*time.Hour).Err()
 	_ = msg.Ack(false)
 	w.log.Info("order processed", "order_id", m.OrderID)
@@ -0,0 +1,89 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/flicky/go-ecommerce-api/internal/model"
+)
+
+// StockEventHandler turns a stock event into notifications.
+type StockEventHandler interface {
+	HandleStockEvent(ctx context.Context, e model.StockEvent) error
+}
+
+type StockAlertWorker struct {
+	ch      *amqp.Channel
+	handler StockEventHandler
+	log     *slog.Logger
+	done    chan struct{}
+}
+
+func NewStockAlertWorker(ch *amqp.Channel, handler StockEventHandler, log *slog.Logger) *StockAlertWorker {
+	return &StockAlertWorker{ch: ch, handler: handler, log: log, done: make(chan struct{})}
+}
+
+// SetupStockAlertQueue declares the product_events topic exchange and binds
+// the stock_alerts queue to the stock event types.
+func SetupStockAlertQueue(ch *amqp.Channel) error {
+	if err := ch.ExchangeDeclare("product_events", "topic", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare product events exchange: %w", err)
+	}
+	if _, err := ch.QueueDeclare("stock_alerts", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare stock alert queue: %w", err)
+	}
+	for _, key := range []string{model.EventLowStock, model.EventBackInStock} {
+		if err := ch.QueueBind("stock_alerts", key, "product_events", false, nil); err != nil {
+			return fmt.Errorf("bind stock alert queue: %w", err)
+		}
+	}
+	return nil
+}
+
+func (w *StockAlertWorker) Start(ctx context.Context) error {
+	msgs, err := w.ch.Consume("stock_alerts", "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("consume: %w", err)
+	}
+	go func() {
+		for {
+			select {
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				w.handle(ctx, msg)
+			case <-w.done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	w.log.Info("stock alert worker started")
+	return nil
+}
+
+func (w *StockAlertWorker) Stop() { close(w.done) }
+
+func (w *StockAlertWorker) handle(ctx context.Context, msg amqp.Delivery) {
+	var e model.StockEvent
+	if err := json.Unmarshal(msg.Body, &e); err != nil {
+		w.log.Error("unmarshal", "error", err)
+		_ = msg.Nack(false, false)
+		return
+	}
+	// Subscribers are marked notified together with their notification, so
+	// a retried event does not notify anyone twice.
+	if err := w.handler.HandleStockEvent(ctx, e); err != nil {
+		w.log.Error("handle stock event", "error", err, "type", e.Type, "product_id", e.ProductID)
+		_ = msg.Nack(false, false)
+		return
+	}
+	_ = msg.Ack(false)
+	w.log.Info("stock event handled", "type", e.Type, "product_id", e.ProductID)
+}
@@ -0,0 +1,4 @@
+-- 002_keyset_pagination.down.sql
+
+DROP INDEX IF EXISTS idx_orders_user_id_created_at_id;
+DROP INDEX IF EXISTS idx_products_created_at_id;
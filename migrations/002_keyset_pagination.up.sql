@@ -0,0 +1,5 @@
+-- 002_keyset_pagination.up.sql
+
+-- Keyset pagination walks (created_at, id) in descending order.
+CREATE INDEX IF NOT EXISTS idx_products_created_at_id ON products (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_user_id_created_at_id ON orders (user_id, created_at DESC, id DESC);
@@ -0,0 +1,3 @@
+-- 003_product_media.down.sql
+
+DROP TABLE IF EXISTS product_media;
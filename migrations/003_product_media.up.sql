@@ -0,0 +1,21 @@
+-- 003_product_media.up.sql
+
+CREATE TABLE IF NOT EXISTS product_media (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id    UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    storage_key   VARCHAR(512) NOT NULL,
+    thumbnail_key VARCHAR(512) NOT NULL,
+    url           TEXT NOT NULL,
+    thumbnail_url TEXT NOT NULL,
+    content_type  VARCHAR(100) NOT NULL,
+    size_bytes    BIGINT NOT NULL CHECK (size_bytes > 0),
+    width         INT NOT NULL,
+    height        INT NOT NULL,
+    alt_text      VARCHAR(255) NOT NULL DEFAULT '',
+    position      INT NOT NULL DEFAULT 0,
+    is_primary    BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_product_media_product_id ON product_media (product_id, position);
+CREATE UNIQUE INDEX idx_product_media_primary ON product_media (product_id) WHERE is_primary;
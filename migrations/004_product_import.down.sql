@@ -0,0 +1,4 @@
+-- 004_product_import.down.sql
+
+DROP TABLE IF EXISTS import_jobs;
+ALTER TABLE products DROP COLUMN IF EXISTS sku;
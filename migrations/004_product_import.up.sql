@@ -0,0 +1,20 @@
+-- 004_product_import.up.sql
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS sku VARCHAR(64) UNIQUE;
+
+-- Import Jobs
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    format       VARCHAR(20) NOT NULL,
+    status       VARCHAR(20) NOT NULL DEFAULT 'pending',
+    payload      BYTEA,
+    total_rows   INT NOT NULL DEFAULT 0,
+    created_rows INT NOT NULL DEFAULT 0,
+    updated_rows INT NOT NULL DEFAULT 0,
+    failed_rows  INT NOT NULL DEFAULT 0,
+    errors       JSONB NOT NULL DEFAULT '[]',
+    created_by   UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at  TIMESTAMPTZ
+);
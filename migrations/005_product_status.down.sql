@@ -0,0 +1,5 @@
+-- 005_product_status.down.sql
+
+DROP INDEX IF EXISTS idx_products_status;
+DROP INDEX IF EXISTS idx_products_active_created_at_id;
+ALTER TABLE products DROP COLUMN IF EXISTS deleted_at, DROP COLUMN IF EXISTS status;
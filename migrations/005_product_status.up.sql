@@ -0,0 +1,11 @@
+-- 005_product_status.up.sql
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active'
+        CHECK (status IN ('draft', 'active', 'archived')),
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- Public listing only ever shows active products.
+CREATE INDEX IF NOT EXISTS idx_products_active_created_at_id
+    ON products (created_at DESC, id DESC) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS idx_products_status ON products (status);
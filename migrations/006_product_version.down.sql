@@ -0,0 +1,3 @@
+-- 006_product_version.down.sql
+
+ALTER TABLE products DROP COLUMN IF EXISTS version;
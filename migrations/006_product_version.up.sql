@@ -0,0 +1,5 @@
+-- 006_product_version.up.sql
+
+-- Bumped by every catalogue edit; stock changes leave it alone so order
+-- processing never conflicts with an admin editing the product.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
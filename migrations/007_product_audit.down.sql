@@ -0,0 +1,3 @@
+-- 007_product_audit.down.sql
+
+DROP TABLE IF EXISTS product_audit_log;
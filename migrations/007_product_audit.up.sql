@@ -0,0 +1,13 @@
+-- 007_product_audit.up.sql
+
+CREATE TABLE IF NOT EXISTS product_audit_log (
+    id         UUID PRIMARY KEY,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    actor_id   UUID REFERENCES users(id) ON DELETE SET NULL,
+    action     VARCHAR(20) NOT NULL,
+    changes    JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_audit_log_product_created_at_id
+    ON product_audit_log (product_id, created_at DESC, id DESC);
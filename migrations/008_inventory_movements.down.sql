@@ -0,0 +1,4 @@
+-- 008_inventory_movements.down.sql
+
+DROP TABLE IF EXISTS inventory_movements;
+DROP FUNCTION IF EXISTS inventory_movements_append_only();
@@ -0,0 +1,36 @@
+-- 008_inventory_movements.up.sql
+
+CREATE TABLE IF NOT EXISTS inventory_movements (
+    id          UUID PRIMARY KEY,
+    product_id  UUID NOT NULL REFERENCES products(id),
+    kind        VARCHAR(20) NOT NULL
+        CHECK (kind IN ('sale', 'return', 'restock', 'adjustment', 'correction')),
+    quantity    INT NOT NULL CHECK (quantity <> 0),
+    stock_after INT NOT NULL CHECK (stock_after >= 0),
+    reason      TEXT NOT NULL DEFAULT '',
+    actor_id    UUID REFERENCES users(id),
+    order_id    UUID REFERENCES orders(id),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_movements_product_created_at_id
+    ON inventory_movements (product_id, created_at DESC, id DESC);
+
+-- The ledger is append-only: mistakes are fixed with a correction movement.
+CREATE OR REPLACE FUNCTION inventory_movements_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'inventory_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_inventory_movements_append_only ON inventory_movements;
+CREATE TRIGGER trg_inventory_movements_append_only
+    BEFORE UPDATE OR DELETE ON inventory_movements
+    FOR EACH ROW EXECUTE FUNCTION inventory_movements_append_only();
+
+-- Opening balance, so stock that predates the ledger reconciles against it.
+INSERT INTO inventory_movements (id, product_id, kind, quantity, stock_after, reason)
+SELECT gen_random_uuid(), id, 'correction', stock, stock, 'opening balance'
+FROM products
+WHERE stock <> 0
+  AND NOT EXISTS (SELECT 1 FROM inventory_movements m WHERE m.product_id = products.id);
@@ -0,0 +1,9 @@
+-- 009_warehouses.down.sql
+
+ALTER TABLE inventory_movements DROP CONSTRAINT IF EXISTS inventory_movements_kind_check;
+ALTER TABLE inventory_movements ADD CONSTRAINT inventory_movements_kind_check
+    CHECK (kind IN ('sale', 'return', 'restock', 'adjustment', 'correction'));
+ALTER TABLE inventory_movements DROP COLUMN IF EXISTS warehouse_id;
+ALTER TABLE order_items DROP COLUMN IF EXISTS warehouse_id;
+DROP TABLE IF EXISTS warehouse_stock;
+DROP TABLE IF EXISTS warehouses;
@@ -0,0 +1,37 @@
+-- 009_warehouses.up.sql
+
+CREATE TABLE IF NOT EXISTS warehouses (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code       VARCHAR(32) NOT NULL UNIQUE,
+    name       VARCHAR(255) NOT NULL,
+    -- Lower ships first; the active warehouse with the lowest priority is
+    -- also where stock without an explicit warehouse is booked.
+    priority   INT NOT NULL DEFAULT 0,
+    active     BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- products.stock stays the total over all warehouses.
+CREATE TABLE IF NOT EXISTS warehouse_stock (
+    warehouse_id UUID NOT NULL REFERENCES warehouses(id),
+    product_id   UUID NOT NULL REFERENCES products(id),
+    quantity     INT NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (warehouse_id, product_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_warehouse_stock_product_id ON warehouse_stock (product_id);
+
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS warehouse_id UUID REFERENCES warehouses(id);
+ALTER TABLE inventory_movements ADD COLUMN IF NOT EXISTS warehouse_id UUID REFERENCES warehouses(id);
+
+ALTER TABLE inventory_movements DROP CONSTRAINT IF EXISTS inventory_movements_kind_check;
+ALTER TABLE inventory_movements ADD CONSTRAINT inventory_movements_kind_check
+    CHECK (kind IN ('sale', 'return', 'restock', 'adjustment', 'correction', 'transfer'));
+
+-- Stock that predates warehouses lives in the main one.
+INSERT INTO warehouses (code, name) VALUES ('MAIN', 'Main warehouse') ON CONFLICT (code) DO NOTHING;
+INSERT INTO warehouse_stock (warehouse_id, product_id, quantity)
+SELECT w.id, p.id, p.stock FROM products p, warehouses w
+WHERE w.code = 'MAIN' AND p.stock > 0
+ON CONFLICT DO NOTHING;
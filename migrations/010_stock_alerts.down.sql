@@ -0,0 +1,5 @@
+-- 010_stock_alerts.down.sql
+
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS stock_subscriptions;
+ALTER TABLE products DROP COLUMN IF EXISTS reorder_threshold;
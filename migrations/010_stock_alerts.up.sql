@@ -0,0 +1,29 @@
+-- 010_stock_alerts.up.sql
+
+-- NULL means no low-stock alerts for the product.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS reorder_threshold INT CHECK (reorder_threshold >= 0);
+
+CREATE TABLE IF NOT EXISTS stock_subscriptions (
+    id          UUID PRIMARY KEY,
+    product_id  UUID NOT NULL REFERENCES products(id),
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    notified_at TIMESTAMPTZ
+);
+
+-- One pending subscription per customer and product; notified ones are kept
+-- as history and a customer may subscribe again.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_subscriptions_pending
+    ON stock_subscriptions (product_id, user_id) WHERE notified_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id         UUID PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind       VARCHAR(32) NOT NULL,
+    product_id UUID REFERENCES products(id),
+    message    TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    read_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_created ON notifications (user_id, created_at DESC, id DESC);
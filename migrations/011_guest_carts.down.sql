@@ -0,0 +1,7 @@
+-- 011_guest_carts.down.sql
+
+DELETE FROM carts WHERE user_id IS NULL;
+DROP INDEX IF EXISTS idx_carts_guest_expires_at;
+ALTER TABLE carts DROP CONSTRAINT IF EXISTS carts_owner_check;
+ALTER TABLE carts DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE carts ALTER COLUMN user_id SET NOT NULL;
@@ -0,0 +1,9 @@
+-- 011_guest_carts.up.sql
+
+-- Guest carts have no user; they expire unless used, and each use pushes
+-- expires_at forward.
+ALTER TABLE carts ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE carts ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+ALTER TABLE carts ADD CONSTRAINT carts_owner_check CHECK (user_id IS NOT NULL OR expires_at IS NOT NULL);
+
+CREATE INDEX IF NOT EXISTS idx_carts_guest_expires_at ON carts (expires_at) WHERE user_id IS NULL;
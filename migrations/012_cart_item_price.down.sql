@@ -0,0 +1,3 @@
+-- 012_cart_item_price.down.sql
+
+ALTER TABLE cart_items DROP COLUMN IF EXISTS price_at_add;
@@ -0,0 +1,7 @@
+-- 012_cart_item_price.up.sql
+
+-- Unit price the customer saw when the item was last added, so the cart can
+-- warn about price changes since.
+ALTER TABLE cart_items ADD COLUMN IF NOT EXISTS price_at_add NUMERIC(12,2);
+UPDATE cart_items ci SET price_at_add = p.price FROM products p WHERE p.id = ci.product_id AND ci.price_at_add IS NULL;
+ALTER TABLE cart_items ALTER COLUMN price_at_add SET NOT NULL;
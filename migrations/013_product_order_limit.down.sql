@@ -0,0 +1,3 @@
+-- 013_product_order_limit.down.sql
+
+ALTER TABLE products DROP COLUMN IF EXISTS max_per_order;
@@ -0,0 +1,4 @@
+-- 013_product_order_limit.up.sql
+
+-- NULL means no per-order limit beyond available stock.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS max_per_order INT CHECK (max_per_order > 0);
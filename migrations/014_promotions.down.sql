@@ -0,0 +1,11 @@
+-- 014_promotions.down.sql
+
+ALTER TABLE orders DROP COLUMN IF EXISTS promotion_code;
+ALTER TABLE orders DROP COLUMN IF EXISTS promotion_id;
+ALTER TABLE orders DROP COLUMN IF EXISTS discount;
+ALTER TABLE orders DROP COLUMN IF EXISTS subtotal;
+ALTER TABLE carts DROP COLUMN IF EXISTS promotion_id;
+DROP TABLE IF EXISTS promotion_redemptions;
+DROP TABLE IF EXISTS promotions;
+DROP INDEX IF EXISTS idx_products_category;
+ALTER TABLE products DROP COLUMN IF EXISTS category;
@@ -0,0 +1,51 @@
+-- 014_promotions.up.sql
+
+-- Free-form category used to scope promotions.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS category VARCHAR(64);
+CREATE INDEX IF NOT EXISTS idx_products_category ON products (category);
+
+CREATE TABLE IF NOT EXISTS promotions (
+    id                    UUID PRIMARY KEY,
+    -- Stored upper-case; codes are matched case-insensitively.
+    code                  VARCHAR(64) NOT NULL UNIQUE,
+    description           TEXT NOT NULL DEFAULT '',
+    kind                  VARCHAR(20) NOT NULL
+        CHECK (kind IN ('percentage', 'fixed', 'free_shipping', 'buy_x_get_y')),
+    value                 NUMERIC(12,2) NOT NULL DEFAULT 0 CHECK (value >= 0),
+    buy_quantity          INT NOT NULL DEFAULT 0,
+    get_quantity          INT NOT NULL DEFAULT 0,
+    min_subtotal          NUMERIC(12,2) NOT NULL DEFAULT 0,
+    -- Empty scopes mean the whole cart.
+    product_ids           UUID[] NOT NULL DEFAULT '{}',
+    categories            TEXT[] NOT NULL DEFAULT '{}',
+    max_uses              INT CHECK (max_uses > 0),
+    max_uses_per_customer INT CHECK (max_uses_per_customer > 0),
+    starts_at             TIMESTAMPTZ,
+    ends_at               TIMESTAMPTZ,
+    active                BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at            TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (ends_at IS NULL OR starts_at IS NULL OR ends_at > starts_at)
+);
+
+-- One row per order placed with a promotion; usage limits count these,
+-- leaving out orders that failed.
+CREATE TABLE IF NOT EXISTS promotion_redemptions (
+    id           UUID PRIMARY KEY,
+    promotion_id UUID NOT NULL REFERENCES promotions(id),
+    order_id     UUID NOT NULL UNIQUE REFERENCES orders(id) ON DELETE CASCADE,
+    user_id      UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_promotion_redemptions_promotion_user
+    ON promotion_redemptions (promotion_id, user_id);
+
+ALTER TABLE carts ADD COLUMN IF NOT EXISTS promotion_id UUID REFERENCES promotions(id) ON DELETE SET NULL;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS subtotal NUMERIC(12,2);
+UPDATE orders SET subtotal = total_price WHERE subtotal IS NULL;
+ALTER TABLE orders ALTER COLUMN subtotal SET NOT NULL;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS discount NUMERIC(12,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS promotion_id UUID REFERENCES promotions(id);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS promotion_code VARCHAR(64);
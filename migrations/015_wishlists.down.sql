@@ -0,0 +1,5 @@
+-- 015_wishlists.down.sql
+
+DROP TABLE IF EXISTS wishlist_items;
+DROP TABLE IF EXISTS wishlists;
+ALTER TABLE cart_items DROP COLUMN IF EXISTS saved_for_later;
@@ -0,0 +1,24 @@
+-- 015_wishlists.up.sql
+
+-- Saved-for-later items stay in the cart but are left out of its totals and
+-- of orders.
+ALTER TABLE cart_items ADD COLUMN IF NOT EXISTS saved_for_later BOOLEAN NOT NULL DEFAULT false;
+
+-- share_token is set while the wishlist is shared by public link.
+CREATE TABLE IF NOT EXISTS wishlists (
+    id          UUID PRIMARY KEY,
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name        VARCHAR(100) NOT NULL,
+    share_token VARCHAR(64) UNIQUE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS wishlist_items (
+    id          UUID PRIMARY KEY,
+    wishlist_id UUID NOT NULL REFERENCES wishlists(id) ON DELETE CASCADE,
+    product_id  UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (wishlist_id, product_id)
+);
@@ -0,0 +1,5 @@
+-- 016_addresses.down.sql
+
+ALTER TABLE orders DROP COLUMN IF EXISTS billing_address;
+ALTER TABLE orders DROP COLUMN IF EXISTS shipping_address;
+DROP TABLE IF EXISTS addresses;
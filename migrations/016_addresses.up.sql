@@ -0,0 +1,30 @@
+-- 016_addresses.up.sql
+
+CREATE TABLE IF NOT EXISTS addresses (
+    id                  UUID PRIMARY KEY,
+    user_id             UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    label               VARCHAR(64) NOT NULL DEFAULT '',
+    full_name           VARCHAR(200) NOT NULL,
+    line1               VARCHAR(200) NOT NULL,
+    line2               VARCHAR(200) NOT NULL DEFAULT '',
+    city                VARCHAR(100) NOT NULL,
+    region              VARCHAR(100) NOT NULL DEFAULT '',
+    postal_code         VARCHAR(20) NOT NULL,
+    country             CHAR(2) NOT NULL,
+    phone               VARCHAR(32) NOT NULL DEFAULT '',
+    is_default_shipping BOOLEAN NOT NULL DEFAULT false,
+    is_default_billing  BOOLEAN NOT NULL DEFAULT false,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_addresses_user ON addresses (user_id, created_at);
+-- At most one default of each kind per user.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_addresses_default_shipping ON addresses (user_id) WHERE is_default_shipping;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_addresses_default_billing ON addresses (user_id) WHERE is_default_billing;
+
+-- Orders keep a copy of their addresses, so editing or deleting an address
+-- book entry does not change past orders. Orders placed before addresses
+-- existed have none.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_address JSONB;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS billing_address JSONB;
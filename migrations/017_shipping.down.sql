@@ -0,0 +1,7 @@
+-- 017_shipping.down.sql
+
+ALTER TABLE orders DROP COLUMN IF EXISTS shipping_cost;
+ALTER TABLE orders DROP COLUMN IF EXISTS shipping_method;
+DROP TABLE IF EXISTS shipping_rates;
+DROP TABLE IF EXISTS shipping_zones;
+ALTER TABLE products DROP COLUMN IF EXISTS weight_grams;
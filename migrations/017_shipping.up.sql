@@ -0,0 +1,38 @@
+-- 017_shipping.up.sql
+
+-- Shipping weight of one unit; 0 means the product is not weighed.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS weight_grams INT NOT NULL DEFAULT 0 CHECK (weight_grams >= 0);
+
+-- A zone groups the destination countries that share rates.
+CREATE TABLE IF NOT EXISTS shipping_zones (
+    id         UUID PRIMARY KEY,
+    name       VARCHAR(64) NOT NULL UNIQUE,
+    -- ISO 3166-1 alpha-2 codes; a country belongs to at most one zone.
+    countries  TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_shipping_zones_countries ON shipping_zones USING GIN (countries);
+
+-- A rate prices a method for shipments in its weight and value bands. The
+-- cost is price plus price_per_kg for every started kilogram. A rate
+-- without a zone applies to every destination.
+CREATE TABLE IF NOT EXISTS shipping_rates (
+    id               UUID PRIMARY KEY,
+    method           VARCHAR(20) NOT NULL CHECK (method IN ('standard', 'express', 'pickup')),
+    zone_id          UUID REFERENCES shipping_zones(id) ON DELETE CASCADE,
+    min_weight_grams INT NOT NULL DEFAULT 0 CHECK (min_weight_grams >= 0),
+    max_weight_grams INT,
+    min_subtotal     NUMERIC(12,2) NOT NULL DEFAULT 0,
+    max_subtotal     NUMERIC(12,2),
+    price            NUMERIC(12,2) NOT NULL CHECK (price >= 0),
+    price_per_kg     NUMERIC(12,2) NOT NULL DEFAULT 0 CHECK (price_per_kg >= 0),
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (max_weight_grams IS NULL OR max_weight_grams > min_weight_grams),
+    CHECK (max_subtotal IS NULL OR max_subtotal > min_subtotal)
+);
+
+CREATE INDEX IF NOT EXISTS idx_shipping_rates_zone ON shipping_rates (zone_id);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_method VARCHAR(20);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_cost NUMERIC(12,2) NOT NULL DEFAULT 0;
@@ -0,0 +1,9 @@
+-- 018_tax.down.sql
+
+ALTER TABLE orders DROP COLUMN IF EXISTS prices_include_tax;
+ALTER TABLE orders DROP COLUMN IF EXISTS tax;
+ALTER TABLE order_items DROP COLUMN IF EXISTS tax_amount;
+ALTER TABLE order_items DROP COLUMN IF EXISTS tax_rate;
+ALTER TABLE order_items DROP COLUMN IF EXISTS tax_name;
+DROP TABLE IF EXISTS tax_rates;
+ALTER TABLE products DROP COLUMN IF EXISTS tax_class;
@@ -0,0 +1,25 @@
+-- 018_tax.up.sql
+
+-- Products are taxed by class; most goods are 'standard'.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS tax_class VARCHAR(32) NOT NULL DEFAULT 'standard';
+
+-- Rates are percentages. A rate with an empty region covers the whole
+-- country; a region's own rate takes precedence over it.
+CREATE TABLE IF NOT EXISTS tax_rates (
+    id         UUID PRIMARY KEY,
+    country    CHAR(2) NOT NULL,
+    region     VARCHAR(100) NOT NULL DEFAULT '',
+    tax_class  VARCHAR(32) NOT NULL,
+    name       VARCHAR(64) NOT NULL,
+    rate       NUMERIC(6,3) NOT NULL CHECK (rate >= 0 AND rate < 100),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (country, region, tax_class)
+);
+
+-- Orders keep the tax worked out when they were placed, per line, and
+-- whether it was included in the prices or added on top.
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax_name VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax_rate NUMERIC(6,3) NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax_amount NUMERIC(12,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax NUMERIC(12,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS prices_include_tax BOOLEAN NOT NULL DEFAULT false;
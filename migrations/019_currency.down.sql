@@ -0,0 +1,26 @@
+-- 019_currency.down.sql
+
+ALTER TABLE orders DROP COLUMN IF EXISTS exchange_rate;
+ALTER TABLE orders DROP COLUMN IF EXISTS currency;
+DROP TABLE IF EXISTS product_prices;
+DROP TABLE IF EXISTS exchange_rates;
+
+ALTER TABLE order_items
+    ALTER COLUMN tax_amount TYPE NUMERIC(12,2),
+    ALTER COLUMN price TYPE NUMERIC(12,2);
+ALTER TABLE orders
+    ALTER COLUMN total_price TYPE NUMERIC(12,2),
+    ALTER COLUMN tax TYPE NUMERIC(12,2),
+    ALTER COLUMN shipping_cost TYPE NUMERIC(12,2),
+    ALTER COLUMN discount TYPE NUMERIC(12,2),
+    ALTER COLUMN subtotal TYPE NUMERIC(12,2);
+ALTER TABLE shipping_rates
+    ALTER COLUMN price_per_kg TYPE NUMERIC(12,2),
+    ALTER COLUMN price TYPE NUMERIC(12,2),
+    ALTER COLUMN max_subtotal TYPE NUMERIC(12,2),
+    ALTER COLUMN min_subtotal TYPE NUMERIC(12,2);
+ALTER TABLE promotions
+    ALTER COLUMN min_subtotal TYPE NUMERIC(12,2),
+    ALTER COLUMN value TYPE NUMERIC(12,2);
+ALTER TABLE cart_items ALTER COLUMN price_at_add TYPE NUMERIC(12,2);
+ALTER TABLE products ALTER COLUMN price TYPE NUMERIC(12,2);
@@ -0,0 +1,47 @@
+-- 019_currency.up.sql
+
+-- Amounts get four decimal places: currencies differ in their minor unit
+-- (JPY has none, KWD has three), and the application rounds each amount to
+-- its own currency.
+ALTER TABLE products ALTER COLUMN price TYPE NUMERIC(19,4);
+ALTER TABLE cart_items ALTER COLUMN price_at_add TYPE NUMERIC(19,4);
+ALTER TABLE promotions
+    ALTER COLUMN value TYPE NUMERIC(19,4),
+    ALTER COLUMN min_subtotal TYPE NUMERIC(19,4);
+ALTER TABLE shipping_rates
+    ALTER COLUMN min_subtotal TYPE NUMERIC(19,4),
+    ALTER COLUMN max_subtotal TYPE NUMERIC(19,4),
+    ALTER COLUMN price TYPE NUMERIC(19,4),
+    ALTER COLUMN price_per_kg TYPE NUMERIC(19,4);
+ALTER TABLE orders
+    ALTER COLUMN subtotal TYPE NUMERIC(19,4),
+    ALTER COLUMN discount TYPE NUMERIC(19,4),
+    ALTER COLUMN shipping_cost TYPE NUMERIC(19,4),
+    ALTER COLUMN tax TYPE NUMERIC(19,4),
+    ALTER COLUMN total_price TYPE NUMERIC(19,4);
+ALTER TABLE order_items
+    ALTER COLUMN price TYPE NUMERIC(19,4),
+    ALTER COLUMN tax_amount TYPE NUMERIC(19,4);
+
+-- A rate is how many units of the currency one unit of the base currency
+-- buys. The shop sells in the base currency and every currency with a rate.
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    currency   CHAR(3) PRIMARY KEY,
+    rate       NUMERIC(20,10) NOT NULL CHECK (rate > 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Price lists: prices set by hand in a currency, used instead of converting
+-- the product's base price.
+CREATE TABLE IF NOT EXISTS product_prices (
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    currency   CHAR(3) NOT NULL,
+    price      NUMERIC(19,4) NOT NULL CHECK (price >= 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (product_id, currency)
+);
+
+-- Orders keep the currency they were placed in and the rate at the time.
+-- Orders placed before this have no currency and are in the base currency.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS currency CHAR(3);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS exchange_rate NUMERIC(20,10) NOT NULL DEFAULT 1;
@@ -0,0 +1,7 @@
+-- 020_checkout.down.sql
+
+DROP TABLE IF EXISTS checkout_sessions;
+ALTER TABLE carts DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE orders DROP COLUMN IF EXISTS payment_intent;
+ALTER TABLE orders DROP COLUMN IF EXISTS stock_reserved;
+ALTER TABLE products DROP COLUMN IF EXISTS reserved;
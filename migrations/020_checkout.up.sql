@@ -0,0 +1,43 @@
+-- 020_checkout.up.sql
+
+-- Stock held by orders that have been placed but not yet processed. What a
+-- product has available to sell is stock - reserved.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS reserved INT NOT NULL DEFAULT 0 CHECK (reserved >= 0);
+-- Orders placed from now on reserve their stock; older pending ones did not.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS stock_reserved BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS payment_intent VARCHAR(255);
+
+-- A cart in an open checkout cannot change until the checkout ends or
+-- locked_until passes.
+ALTER TABLE carts ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+
+-- A checkout session keeps the cart's lines as they were when it started
+-- and the choices made since, with the totals they came to. An open session
+-- past expires_at has expired.
+CREATE TABLE IF NOT EXISTS checkout_sessions (
+    id                 UUID PRIMARY KEY,
+    user_id            UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    cart_id            UUID NOT NULL REFERENCES carts(id) ON DELETE CASCADE,
+    status             VARCHAR(16) NOT NULL DEFAULT 'open',
+    currency           CHAR(3) NOT NULL,
+    items              JSONB NOT NULL,
+    shipping_address   JSONB,
+    billing_address    JSONB,
+    shipping_method    VARCHAR(20),
+    promotion_id       UUID REFERENCES promotions(id) ON DELETE SET NULL,
+    promotion_code     VARCHAR(64),
+    payment_intent     VARCHAR(255),
+    subtotal           NUMERIC(19,4) NOT NULL DEFAULT 0,
+    discount           NUMERIC(19,4) NOT NULL DEFAULT 0,
+    shipping_cost      NUMERIC(19,4) NOT NULL DEFAULT 0,
+    tax                NUMERIC(19,4) NOT NULL DEFAULT 0,
+    prices_include_tax BOOLEAN NOT NULL DEFAULT false,
+    total              NUMERIC(19,4) NOT NULL DEFAULT 0,
+    order_id           UUID REFERENCES orders(id) ON DELETE SET NULL,
+    expires_at         TIMESTAMPTZ NOT NULL,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- At most one open session per cart.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_checkout_sessions_open_cart ON checkout_sessions (cart_id) WHERE status = 'open';
@@ -0,0 +1,4 @@
+-- 021_order_history.down.sql
+
+CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders (user_id);
+DROP INDEX IF EXISTS idx_orders_user_id_status_created_at_id;
@@ -0,0 +1,9 @@
+-- 021_order_history.up.sql
+
+-- Order history filtered by status walks (user_id, status, created_at, id);
+-- unfiltered and date-bounded history, in either order, uses
+-- idx_orders_user_id_created_at_id, which also makes the plain user_id
+-- index redundant.
+CREATE INDEX IF NOT EXISTS idx_orders_user_id_status_created_at_id
+    ON orders (user_id, status, created_at DESC, id DESC);
+DROP INDEX IF EXISTS idx_orders_user_id;
@@ -0,0 +1,9 @@
+-- 022_admin_orders.down.sql
+
+CREATE INDEX IF NOT EXISTS idx_orders_status ON orders (status);
+DROP INDEX IF EXISTS idx_orders_status_created_at_id;
+DROP INDEX IF EXISTS idx_orders_created_at_id;
+ALTER TABLE notifications DROP COLUMN IF EXISTS order_id;
+DROP TABLE IF EXISTS order_refunds;
+DROP TABLE IF EXISTS order_events;
+ALTER TABLE orders DROP COLUMN IF EXISTS refunded;
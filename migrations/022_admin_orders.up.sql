@@ -0,0 +1,43 @@
+-- 022_admin_orders.up.sql
+
+-- What has been refunded of the order's total so far, in its currency.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS refunded NUMERIC(19,4) NOT NULL DEFAULT 0 CHECK (refunded >= 0);
+
+-- What staff did to an order: status changes, refunds and resent
+-- confirmations, with the note they left.
+CREATE TABLE IF NOT EXISTS order_events (
+    id          UUID PRIMARY KEY,
+    order_id    UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    kind        VARCHAR(20) NOT NULL,
+    from_status VARCHAR(50),
+    to_status   VARCHAR(50),
+    note        TEXT NOT NULL DEFAULT '',
+    actor_id    UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_events_order_created ON order_events (order_id, created_at, id);
+
+-- Money given back on an order. Reference is the payment provider's ID for
+-- the refund, when staff have one.
+CREATE TABLE IF NOT EXISTS order_refunds (
+    id         UUID PRIMARY KEY,
+    order_id   UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    amount     NUMERIC(19,4) NOT NULL CHECK (amount > 0),
+    currency   CHAR(3) NOT NULL,
+    reference  VARCHAR(255),
+    note       TEXT NOT NULL DEFAULT '',
+    actor_id   UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_refunds_order_created ON order_refunds (order_id, created_at, id);
+
+-- Notifications about an order, such as a resent confirmation.
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS order_id UUID REFERENCES orders(id) ON DELETE CASCADE;
+
+-- The admin order list pages through every order, or the orders with a
+-- status, newest first.
+CREATE INDEX IF NOT EXISTS idx_orders_created_at_id ON orders (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_status_created_at_id ON orders (status, created_at DESC, id DESC);
+DROP INDEX IF EXISTS idx_orders_status;
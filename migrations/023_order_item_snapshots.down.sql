@@ -0,0 +1,13 @@
+-- 023_order_item_snapshots.down.sql
+
+-- Lines of deleted products cannot point at a product again.
+DELETE FROM order_items WHERE product_id IS NULL;
+ALTER TABLE order_items DROP CONSTRAINT IF EXISTS order_items_product_id_fkey;
+ALTER TABLE order_items ADD CONSTRAINT order_items_product_id_fkey
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE RESTRICT;
+ALTER TABLE order_items ALTER COLUMN product_id SET NOT NULL;
+ALTER TABLE order_items DROP COLUMN IF EXISTS tax_class;
+ALTER TABLE order_items DROP COLUMN IF EXISTS product_image_url;
+ALTER TABLE order_items DROP COLUMN IF EXISTS product_attributes;
+ALTER TABLE order_items DROP COLUMN IF EXISTS product_sku;
+ALTER TABLE order_items DROP COLUMN IF EXISTS product_name;
@@ -0,0 +1,27 @@
+-- 023_order_item_snapshots.up.sql
+
+-- Each order line keeps the product as it was when the order was placed,
+-- so renaming, archiving or deleting the product leaves the order intact.
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS product_name VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS product_sku VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS product_attributes JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS product_image_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax_class VARCHAR(32) NOT NULL DEFAULT '';
+
+-- Existing lines get the product as it is now, the closest there is.
+UPDATE order_items oi SET
+    product_name = p.name,
+    product_sku = COALESCE(p.sku, ''),
+    product_attributes = jsonb_strip_nulls(jsonb_build_object(
+        'category', NULLIF(p.category, ''), 'weight_grams', NULLIF(p.weight_grams, 0))),
+    product_image_url = COALESCE((SELECT m.url FROM product_media m WHERE m.product_id = p.id
+        ORDER BY m.is_primary DESC, m.position, m.created_at LIMIT 1), ''),
+    tax_class = p.tax_class
+FROM products p
+WHERE p.id = oi.product_id;
+
+-- A deleted product no longer blocks deletion; its lines keep the snapshot.
+ALTER TABLE order_items ALTER COLUMN product_id DROP NOT NULL;
+ALTER TABLE order_items DROP CONSTRAINT IF EXISTS order_items_product_id_fkey;
+ALTER TABLE order_items ADD CONSTRAINT order_items_product_id_fkey
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE SET NULL;
@@ -0,0 +1,4 @@
+-- 024_invoices.down.sql
+
+DROP TABLE IF EXISTS invoices;
+DROP TABLE IF EXISTS invoice_counters;
@@ -0,0 +1,47 @@
+-- 024_invoices.up.sql
+
+-- The last number given out of each kind of document. Numbers are taken in
+-- the transaction that issues the document, under this row's lock, so a
+-- rolled back issue gives its number back and the sequence has no gaps.
+CREATE TABLE IF NOT EXISTS invoice_counters (
+    kind        VARCHAR(20) PRIMARY KEY,
+    last_number BIGINT NOT NULL DEFAULT 0
+);
+
+INSERT INTO invoice_counters (kind) VALUES ('invoice'), ('credit_note') ON CONFLICT DO NOTHING;
+
+-- Invoices are issued when an order completes, credit notes when an
+-- invoiced order is refunded. Total is the order's total or the refund
+-- amount, in the order's currency; like the order's, a missing currency is
+-- the base currency. The rendered PDF is kept so a document reads the same
+-- every time it is downloaded, until staff regenerate it.
+CREATE TABLE IF NOT EXISTS invoices (
+    id          UUID PRIMARY KEY,
+    kind        VARCHAR(20) NOT NULL REFERENCES invoice_counters(kind),
+    number      BIGINT NOT NULL,
+    order_id    UUID NOT NULL REFERENCES orders(id) ON DELETE RESTRICT,
+    invoice_id  UUID REFERENCES invoices(id) ON DELETE RESTRICT,
+    refund_id   UUID UNIQUE REFERENCES order_refunds(id) ON DELETE RESTRICT,
+    currency    CHAR(3),
+    total       NUMERIC(19,4) NOT NULL,
+    pdf         BYTEA,
+    rendered_at TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (kind, number),
+    CHECK ((kind = 'credit_note') = (invoice_id IS NOT NULL AND refund_id IS NOT NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_order ON invoices (order_id) WHERE kind = 'invoice';
+CREATE INDEX IF NOT EXISTS idx_invoices_order_created ON invoices (order_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_invoices_created_at_id ON invoices (created_at DESC, id DESC);
+
+-- Orders completed before invoicing get their invoices now, numbered in
+-- the order they were placed.
+INSERT INTO invoices (id, kind, number, order_id, currency, total, created_at)
+SELECT gen_random_uuid(), 'invoice', ROW_NUMBER() OVER (ORDER BY o.created_at, o.id), o.id, o.currency, o.total_price, NOW()
+FROM orders o
+WHERE o.status IN ('completed', 'shipped', 'delivered')
+  AND NOT EXISTS (SELECT 1 FROM invoices i WHERE i.order_id = o.id AND i.kind = 'invoice');
+
+UPDATE invoice_counters SET last_number = (SELECT COALESCE(MAX(number), 0) FROM invoices WHERE kind = 'invoice')
+WHERE kind = 'invoice';
@@ -0,0 +1,4 @@
+-- 025_order_publishing.down.sql
+
+DROP INDEX IF EXISTS idx_orders_unpublished;
+ALTER TABLE orders DROP COLUMN IF EXISTS published_at;
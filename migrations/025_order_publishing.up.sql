@@ -0,0 +1,9 @@
+-- 025_order_publishing.up.sql
+
+-- When the order was queued for processing. Pending orders without it are
+-- the outbox: they were stored but their message never reached the queue,
+-- and the publisher sends them again.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS published_at TIMESTAMPTZ;
+UPDATE orders SET published_at = created_at WHERE published_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_orders_unpublished ON orders (created_at) WHERE published_at IS NULL;